@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"clipboard-server/config"
+	"crypto/subtle"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminTokenHeader carries the ADMIN_TOKEN on admin API requests
+const AdminTokenHeader = "X-Admin-Token"
+
+// AdminTokenMiddleware 校验管理接口的 ADMIN_TOKEN，未配置时管理接口被禁用
+func AdminTokenMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.GetConfig().AdminToken
+		if expected == "" {
+			c.JSON(403, gin.H{
+				"error":   "forbidden",
+				"message": "the admin API is disabled, set ADMIN_TOKEN to enable it",
+			})
+			c.Abort()
+			return
+		}
+
+		token := c.GetHeader(AdminTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+			c.JSON(401, gin.H{
+				"error":   "unauthorized",
+				"message": "invalid or missing admin token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
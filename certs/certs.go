@@ -0,0 +1,217 @@
+// Package certs serves HTTPS with certificates loaded from files.
+//
+// The server certificate and the CAs trusted for client certificates are
+// reloaded when their files change, so that renewed certificates are used
+// without a restart and without dropping open connections.
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Client authentication modes
+const (
+	ClientAuthNone     = "none"
+	ClientAuthOptional = "optional"
+	ClientAuthRequire  = "require"
+)
+
+// Store holds the server certificate and the client CAs
+type Store struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+}
+
+// Load reads the certificate and key, and the client CAs when clientCAFile is set
+func Load(certFile, keyFile, clientCAFile string) (*Store, error) {
+	s := &Store{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the files again. On error the current certificates are kept.
+func (s *Store) Reload() error {
+	modTimes, err := s.stat()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	if cert.Leaf == nil {
+		if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return fmt.Errorf("failed to parse TLS certificate: %v", err)
+		}
+	}
+
+	var clientCAs *x509.CertPool
+	if s.clientCAFile != "" {
+		data, err := os.ReadFile(s.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %v", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in client CA file %s", s.clientCAFile)
+		}
+	}
+
+	s.mu.Lock()
+	s.cert = &cert
+	s.clientCAs = clientCAs
+	s.modTimes = modTimes
+	s.mu.Unlock()
+	return nil
+}
+
+// Certificate returns the current server certificate
+func (s *Store) Certificate() *tls.Certificate {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.cert
+}
+
+// Options of the server TLS configuration
+type Options struct {
+	// ClientAuth is ClientAuthNone, ClientAuthOptional or ClientAuthRequire
+	ClientAuth string
+	MinVersion uint16
+	// HTTP2 offers h2 during the handshake
+	HTTP2 bool
+}
+
+// TLSConfig returns a server configuration using the current certificates
+func (s *Store) TLSConfig(opts Options) (*tls.Config, error) {
+	authType, err := ParseClientAuth(opts.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if authType != tls.NoClientCert && s.clientCAFile == "" {
+		return nil, fmt.Errorf("client certificate authentication requires a client CA file")
+	}
+
+	base := &tls.Config{
+		MinVersion: opts.MinVersion,
+		ClientAuth: authType,
+		NextProtos: []string{"http/1.1"},
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return s.Certificate(), nil
+		},
+	}
+	if opts.HTTP2 {
+		base.NextProtos = []string{"h2", "http/1.1"}
+	}
+	if authType == tls.NoClientCert {
+		return base, nil
+	}
+
+	// The client CAs are looked up per handshake so that reloads apply
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = s.clientCAs
+		return config, nil
+	}
+	return base, nil
+}
+
+// Watch reloads the certificates whenever one of the files changes, checking
+// every interval until ctx is done
+func (s *Store) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !s.changed() {
+			continue
+		}
+		if err := s.Reload(); err != nil {
+			slog.Error("重新加载 TLS 证书失败，继续使用当前证书", "error", err)
+			continue
+		}
+		slog.Info("已重新加载 TLS 证书", "subject", s.Certificate().Leaf.Subject.String(),
+			"not_after", s.Certificate().Leaf.NotAfter)
+	}
+}
+
+func (s *Store) changed() bool {
+	modTimes, err := s.stat()
+	if err != nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for file, modTime := range modTimes {
+		if !modTime.Equal(s.modTimes[file]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Store) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, file := range []string{s.certFile, s.keyFile, s.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[file] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+// ParseClientAuth maps a client authentication mode to its tls type
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case ClientAuthNone, "":
+		return tls.NoClientCert, nil
+	case ClientAuthOptional:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, fmt.Errorf("client auth must be none, optional or require")
+}
+
+// ParseVersion maps "1.2" or "1.3" to the tls version
+func ParseVersion(version string) (uint16, error) {
+	switch version {
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("TLS version must be 1.2 or 1.3")
+}
@@ -0,0 +1,218 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPKI a CA with a server certificate written to a temporary directory
+type testPKI struct {
+	ca                        *Authority
+	dir                       string
+	certFile, keyFile, caFile string
+}
+
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	ca, err := NewAuthority("test CA", time.Hour)
+	if err != nil {
+		t.Fatalf("生成 CA 失败: %v", err)
+	}
+	dir := t.TempDir()
+	p := &testPKI{
+		ca:       ca,
+		dir:      dir,
+		certFile: filepath.Join(dir, "server.pem"),
+		keyFile:  filepath.Join(dir, "server-key.pem"),
+		caFile:   filepath.Join(dir, "ca.pem"),
+	}
+	if err := WriteCertificate(p.caFile, ca.Cert); err != nil {
+		t.Fatal(err)
+	}
+	p.issueServer(t)
+	return p
+}
+
+func (p *testPKI) issueServer(t *testing.T) *x509.Certificate {
+	t.Helper()
+	cert, key, err := p.ca.Issue("server", []string{"localhost", "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatalf("签发服务器证书失败: %v", err)
+	}
+	if err := WriteCertificate(p.certFile, cert); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKey(p.keyFile, key); err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func (p *testPKI) clientCertificate(t *testing.T, ca *Authority) tls.Certificate {
+	t.Helper()
+	cert, key, err := ca.Issue("laptop", nil, time.Hour)
+	if err != nil {
+		t.Fatalf("签发客户端证书失败: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+}
+
+// serve starts an HTTPS server with the store and returns its address
+func serve(t *testing.T, store *Store, opts Options) string {
+	t.Helper()
+	config, err := store.TLSConfig(opts)
+	if err != nil {
+		t.Fatalf("创建 TLS 配置失败: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{
+		TLSConfig: config,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.Proto))
+		}),
+	}
+	go server.ServeTLS(listener, "", "")
+	t.Cleanup(func() { server.Close() })
+	return listener.Addr().String()
+}
+
+// client trusts the test CA and presents certs, a new connection per request
+func (p *testPKI) client(certs ...tls.Certificate) *http.Client {
+	roots := x509.NewCertPool()
+	roots.AddCert(p.ca.Cert)
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certs},
+		ForceAttemptHTTP2: true,
+		DisableKeepAlives: true,
+	}}
+}
+
+func TestServeHTTPS(t *testing.T) {
+	p := newTestPKI(t)
+	store, err := Load(p.certFile, p.keyFile, "")
+	if err != nil {
+		t.Fatalf("加载证书失败: %v", err)
+	}
+
+	addr := serve(t, store, Options{MinVersion: tls.VersionTLS12, HTTP2: true})
+	resp, err := p.client().Get("https://" + addr)
+	if err != nil {
+		t.Fatalf("HTTPS 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("应协商 HTTP/2，实际得到 %s", resp.Proto)
+	}
+
+	addr = serve(t, store, Options{MinVersion: tls.VersionTLS12})
+	resp, err = p.client().Get("https://" + addr)
+	if err != nil {
+		t.Fatalf("HTTPS 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 1 {
+		t.Errorf("关闭 HTTP/2 后应使用 HTTP/1.1，实际得到 %s", resp.Proto)
+	}
+}
+
+func TestReloadCertificate(t *testing.T) {
+	p := newTestPKI(t)
+	store, err := Load(p.certFile, p.keyFile, "")
+	if err != nil {
+		t.Fatalf("加载证书失败: %v", err)
+	}
+	addr := serve(t, store, Options{MinVersion: tls.VersionTLS12})
+
+	// Make sure the modification time differs on file systems with coarse timestamps
+	renewed := p.issueServer(t)
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(p.certFile, future, future)
+	if !store.changed() {
+		t.Fatal("应检测到证书文件变化")
+	}
+	if err := store.Reload(); err != nil {
+		t.Fatalf("重新加载证书失败: %v", err)
+	}
+
+	resp, err := p.client().Get("https://" + addr)
+	if err != nil {
+		t.Fatalf("HTTPS 请求失败: %v", err)
+	}
+	resp.Body.Close()
+	if got := resp.TLS.PeerCertificates[0].SerialNumber; got.Cmp(renewed.SerialNumber) != 0 {
+		t.Error("重新加载后应使用新证书")
+	}
+
+	// A broken certificate is rejected and the current one kept
+	os.WriteFile(p.certFile, []byte("not a certificate"), 0644)
+	if err := store.Reload(); err == nil {
+		t.Error("无效的证书应返回错误")
+	}
+	if store.Certificate().Leaf.SerialNumber.Cmp(renewed.SerialNumber) != 0 {
+		t.Error("重新加载失败时应保留当前证书")
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	p := newTestPKI(t)
+	store, err := Load(p.certFile, p.keyFile, p.caFile)
+	if err != nil {
+		t.Fatalf("加载证书失败: %v", err)
+	}
+	addr := serve(t, store, Options{ClientAuth: ClientAuthRequire, MinVersion: tls.VersionTLS12, HTTP2: true})
+
+	if _, err := p.client().Get("https://" + addr); err == nil {
+		t.Error("没有客户端证书时应拒绝连接")
+	}
+
+	other, err := NewAuthority("other CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.client(p.clientCertificate(t, other)).Get("https://" + addr); err == nil {
+		t.Error("其他 CA 签发的客户端证书应被拒绝")
+	}
+
+	resp, err := p.client(p.clientCertificate(t, p.ca)).Get("https://" + addr)
+	if err != nil {
+		t.Fatalf("有效的客户端证书应被接受: %v", err)
+	}
+	resp.Body.Close()
+	if resp.ProtoMajor != 2 {
+		t.Errorf("双向 TLS 下也应协商 HTTP/2，实际得到 %s", resp.Proto)
+	}
+
+	if _, err := store.TLSConfig(Options{ClientAuth: "sometimes"}); err == nil {
+		t.Error("无效的客户端认证模式应返回错误")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		port string
+		want string
+	}{
+		{"8443", "https://example.com:8443/api/v1/clipboard?limit=5"},
+		{"443", "https://example.com/api/v1/clipboard?limit=5"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "http://example.com:8080/api/v1/clipboard?limit=5", nil)
+		RedirectHandler(tt.port).ServeHTTP(w, r)
+
+		if w.Code != http.StatusPermanentRedirect || w.Header().Get("Location") != tt.want {
+			t.Errorf("端口 %s: 期望重定向到 %s，实际得到 %d %s", tt.port, tt.want, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
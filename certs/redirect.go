@@ -0,0 +1,23 @@
+package certs
+
+import (
+	"net"
+	"net/http"
+)
+
+// RedirectHandler redirects plain HTTP requests to the same URL over HTTPS
+// on httpsPort. Port 443 is left out of the redirect URL.
+func RedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(r.Host); err == nil {
+			host = h
+		}
+		if httpsPort != "" && httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,115 @@
+package classify
+
+import (
+	"clipboard-server/models"
+	"encoding/base64"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// sniffLength bytes examined by http.DetectContentType
+const sniffLength = 512
+
+// base64Encodings accepted for binary payloads
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding,
+}
+
+// decodeBase64 decodes the start of a base64 payload, enough to sniff its
+// type. Content that is not base64 is returned as is.
+func decodeBase64(content string) []byte {
+	prefix := strings.TrimSpace(content)
+	if len(prefix) > sniffLength*3 {
+		prefix = prefix[:sniffLength*3]
+	}
+	prefix = strings.NewReplacer("\n", "", "\r", "").Replace(prefix)
+	// A whole number of 4 character blocks decodes without the final padding
+	if len(prefix) > sniffLength*2 {
+		prefix = prefix[:sniffLength*2]
+	}
+
+	for _, encoding := range base64Encodings {
+		if data, err := encoding.DecodeString(prefix); err == nil && len(data) > 0 {
+			return data
+		}
+	}
+	return []byte(content)
+}
+
+// decodeDataURL decodes the start of an RFC 2397 data URL, returning the
+// payload and its declared media type
+func decodeDataURL(content string) ([]byte, string, bool) {
+	header, payload, found := strings.Cut(strings.TrimPrefix(content, "data:"), ",")
+	if !found || strings.ContainsFunc(header, unicode.IsSpace) {
+		return nil, "", false
+	}
+
+	declared := ""
+	if mediaType, _, err := mime.ParseMediaType(strings.TrimSuffix(header, ";base64")); err == nil {
+		declared = mediaType
+	}
+
+	if strings.HasSuffix(header, ";base64") {
+		return decodeBase64(payload), declared, true
+	}
+	if len(payload) > sniffLength*3 {
+		payload = payload[:sniffLength*3]
+	}
+	data, err := url.PathUnescape(payload)
+	if err != nil {
+		// The prefix may end within an escape
+		return []byte(payload), declared, true
+	}
+	return []byte(data), declared, true
+}
+
+// classifyBinary sniffs the MIME type of a binary payload. The declared type
+// of a data URL is used when sniffing finds nothing more specific.
+func classifyBinary(data []byte, declared string) Result {
+	if len(data) > sniffLength {
+		data = data[:sniffLength]
+	}
+	mimeType, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	if declared != "" && (mimeType == "application/octet-stream" || mimeType == "text/plain") {
+		mimeType = declared
+	}
+	return Result{Subtype: binarySubtype(mimeType), MimeType: mimeType}
+}
+
+// archiveTypes MIME types of compressed archives
+var archiveTypes = map[string]bool{
+	"application/zip":              true,
+	"application/x-gzip":           true,
+	"application/gzip":             true,
+	"application/x-rar-compressed": true,
+	"application/vnd.rar":          true,
+	"application/x-7z-compressed":  true,
+	"application/x-tar":            true,
+	"application/x-bzip2":          true,
+}
+
+// binarySubtype maps a MIME type to its subtype
+func binarySubtype(mimeType string) models.ClipboardSubtype {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return models.ClipboardSubtypeImage
+	case strings.HasPrefix(mimeType, "audio/"):
+		return models.ClipboardSubtypeAudio
+	case strings.HasPrefix(mimeType, "video/"):
+		return models.ClipboardSubtypeVideo
+	case mimeType == "application/pdf":
+		return models.ClipboardSubtypePDF
+	case archiveTypes[mimeType]:
+		return models.ClipboardSubtypeArchive
+	case mimeType == "application/json":
+		return models.ClipboardSubtypeJSON
+	case mimeType == "text/html" || mimeType == "text/xml" || mimeType == "application/xml":
+		return models.ClipboardSubtypeCode
+	case strings.HasPrefix(mimeType, "text/"):
+		return models.ClipboardSubtypePlain
+	}
+	return models.ClipboardSubtypeBinary
+}
@@ -0,0 +1,169 @@
+// Package classify detects the subtype and MIME type of clipboard content.
+// Clients mostly send everything as text, so the server looks at the content
+// itself to tell URLs, code, JSON, colors, email addresses and phone numbers
+// apart, and sniffs the MIME type of binary payloads.
+package classify
+
+import (
+	"clipboard-server/models"
+	"encoding/json"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Result the detected classification of a content
+type Result struct {
+	Subtype  models.ClipboardSubtype
+	MimeType string
+}
+
+// maxTextScan bytes of text content examined by the code heuristics
+const maxTextScan = 64 << 10
+
+var (
+	emailPattern = regexp.MustCompile(`^[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}$`)
+	phonePattern = regexp.MustCompile(`^(?:\+|00)?[0-9(][0-9 ().\-]*[0-9]$`)
+	datePattern  = regexp.MustCompile(`^[0-9]{1,4}[-./][0-9]{1,2}[-./][0-9]{1,4}$`)
+	hexColor     = regexp.MustCompile(`^#(?:[0-9A-Fa-f]{3,4}|[0-9A-Fa-f]{6}|[0-9A-Fa-f]{8})$`)
+	funcColor    = regexp.MustCompile(`^(?i)(?:rgba?|hsla?|hwb|lab|lch|oklab|oklch)\(\s*[0-9.%+\-]+(?:\s*[, /]\s*[0-9.%+\-]+){2,3}\s*\)$`)
+)
+
+// Classify returns the subtype and MIME type of content stored as type t.
+// Image and file content, and data URLs of any type, are decoded and sniffed.
+func Classify(t models.ClipboardType, content string) Result {
+	if strings.HasPrefix(content, "data:") {
+		if data, declared, ok := decodeDataURL(content); ok {
+			return classifyBinary(data, declared)
+		}
+	}
+	if t == models.ClipboardTypeImage || t == models.ClipboardTypeFile {
+		return classifyBinary(decodeBase64(content), "")
+	}
+	return classifyText(content)
+}
+
+// classifyText detects the subtype of text content. Values that fit on one
+// line are matched exactly, so a URL inside a sentence stays plain text.
+func classifyText(content string) Result {
+	text := strings.TrimSpace(content)
+	plain := Result{Subtype: models.ClipboardSubtypePlain, MimeType: "text/plain"}
+	if text == "" {
+		return plain
+	}
+
+	if !strings.ContainsAny(text, "\n\r") {
+		switch {
+		case isURL(text):
+			return Result{Subtype: models.ClipboardSubtypeURL, MimeType: "text/uri-list"}
+		case emailPattern.MatchString(strings.TrimPrefix(text, "mailto:")):
+			return Result{Subtype: models.ClipboardSubtypeEmail, MimeType: "text/plain"}
+		case hexColor.MatchString(text) || funcColor.MatchString(text):
+			return Result{Subtype: models.ClipboardSubtypeColor, MimeType: "text/plain"}
+		case isPhone(text):
+			return Result{Subtype: models.ClipboardSubtypePhone, MimeType: "text/plain"}
+		}
+	}
+
+	if (text[0] == '{' || text[0] == '[') && json.Valid([]byte(text)) {
+		return Result{Subtype: models.ClipboardSubtypeJSON, MimeType: "application/json"}
+	}
+	if isCode(text) {
+		return Result{Subtype: models.ClipboardSubtypeCode, MimeType: "text/plain"}
+	}
+	return plain
+}
+
+// isURL reports whether text is a single absolute web URL
+func isURL(text string) bool {
+	if strings.ContainsFunc(text, unicode.IsSpace) {
+		return false
+	}
+	u, err := url.Parse(text)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "ftp", "ftps", "ws", "wss":
+		return u.Host != ""
+	}
+	return false
+}
+
+// isPhone reports whether text looks like a phone number: 7 to 15 digits, with
+// an international prefix or separators so that plain numbers don't match.
+// Dates and IP addresses are written with the same characters.
+func isPhone(text string) bool {
+	if !phonePattern.MatchString(text) || datePattern.MatchString(text) || net.ParseIP(text) != nil {
+		return false
+	}
+	digits := 0
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 7 || digits > 15 {
+		return false
+	}
+	return text[0] == '+' || strings.HasPrefix(text, "00") || strings.ContainsAny(text, " ()-.")
+}
+
+// codeKeywords start lines of source code in common languages. They are
+// matched case-sensitively, as prose sentences start with a capital letter.
+var codeKeywords = []string{
+	"package ", "import ", "from ", "func ", "def ", "class ", "return ", "const ", "let ", "var ",
+	"function ", "public ", "private ", "protected ", "static ", "async ", "#include", "#define",
+	"if ", "if(", "for ", "for(", "while ", "else", "elif ", "switch ", "case ", "try:", "except ",
+	"fn ", "pub ", "impl ", "struct ", "enum ", "interface ", "type ", "using ", "namespace ",
+	"echo ", "export ", "sudo ", "@",
+	"SELECT ", "INSERT INTO ", "UPDATE ", "DELETE FROM ", "CREATE TABLE ", "WHERE ", "FROM ",
+}
+
+// isCode reports whether text looks like source code: a shebang, or at least
+// two lines of which half carry a sign of code
+func isCode(text string) bool {
+	if len(text) > maxTextScan {
+		text = text[:maxTextScan]
+	}
+	if strings.HasPrefix(text, "#!") {
+		return true
+	}
+
+	lines, signals := 0, 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines++
+		if isCodeLine(line) {
+			signals++
+		}
+	}
+	return lines > 1 && signals >= 2 && signals*2 >= lines
+}
+
+// isCodeLine reports whether a trimmed line carries a sign of source code
+func isCodeLine(line string) bool {
+	switch line[len(line)-1] {
+	case ';', '{', '}':
+		return true
+	}
+	for _, marker := range []string{"//", "/*", "<?", "</"} {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	if strings.Contains(line, ":=") || strings.Contains(line, "=>") || strings.Contains(line, "->") {
+		return true
+	}
+	for _, keyword := range codeKeywords {
+		if strings.HasPrefix(line, keyword) {
+			return true
+		}
+	}
+	return false
+}
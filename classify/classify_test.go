@@ -0,0 +1,83 @@
+package classify
+
+import (
+	"clipboard-server/models"
+	"encoding/base64"
+	"testing"
+)
+
+// pngHeader PNG 文件签名和 IHDR 块的开头
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00")
+
+func TestClassifyText(t *testing.T) {
+	tests := []struct {
+		content string
+		want    models.ClipboardSubtype
+	}{
+		{"hello world", models.ClipboardSubtypePlain},
+		{"", models.ClipboardSubtypePlain},
+		{"https://example.com/path?q=1", models.ClipboardSubtypeURL},
+		{"  http://localhost:8080  ", models.ClipboardSubtypeURL},
+		{"see https://example.com for details", models.ClipboardSubtypePlain},
+		{"alice@example.com", models.ClipboardSubtypeEmail},
+		{"mailto:bob@mail.example.org", models.ClipboardSubtypeEmail},
+		{"+1 (555) 123-4567", models.ClipboardSubtypePhone},
+		{"0049 30 1234567", models.ClipboardSubtypePhone},
+		{"12345678", models.ClipboardSubtypePlain},
+		{"2024-01-01", models.ClipboardSubtypePlain},
+		{"192.168.100.200", models.ClipboardSubtypePlain},
+		{"#ff8800", models.ClipboardSubtypeColor},
+		{"#fff", models.ClipboardSubtypeColor},
+		{"rgba(255, 128, 0, 0.5)", models.ClipboardSubtypeColor},
+		{"hsl(120 50% 50%)", models.ClipboardSubtypeColor},
+		{`{"name": "clipboard", "tags": [1, 2]}`, models.ClipboardSubtypeJSON},
+		{"[1, 2, 3]", models.ClipboardSubtypeJSON},
+		{"{not json}", models.ClipboardSubtypePlain},
+		{"func main() {\n\tfmt.Println(\"hi\")\n}", models.ClipboardSubtypeCode},
+		{"def add(a, b):\n    return a + b", models.ClipboardSubtypeCode},
+		{"SELECT id, name\nFROM users\nWHERE active = 1;", models.ClipboardSubtypeCode},
+		{"#!/bin/sh\nls", models.ClipboardSubtypeCode},
+		{"If you read this,\nfor example on a phone,\nit is prose.", models.ClipboardSubtypePlain},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(models.ClipboardTypeText, tt.content); got.Subtype != tt.want {
+			t.Errorf("%q: 期望子类型 %q，实际得到 %q", tt.content, tt.want, got.Subtype)
+		}
+	}
+
+	if got := Classify("", "https://example.com"); got.MimeType != "text/uri-list" {
+		t.Errorf("未指定类型时应按文本分类，实际得到 %+v", got)
+	}
+}
+
+func TestClassifyBinary(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString(append(pngHeader, make([]byte, 2000)...))
+
+	tests := []struct {
+		name         string
+		itemType     models.ClipboardType
+		content      string
+		wantSubtype  models.ClipboardSubtype
+		wantMimeType string
+	}{
+		{"base64 图片", models.ClipboardTypeImage, encoded, models.ClipboardSubtypeImage, "image/png"},
+		{"无填充 base64", models.ClipboardTypeFile, base64.RawURLEncoding.EncodeToString(pngHeader), models.ClipboardSubtypeImage, "image/png"},
+		{"PDF 文件", models.ClipboardTypeFile, base64.StdEncoding.EncodeToString([]byte("%PDF-1.7\n%...")), models.ClipboardSubtypePDF, "application/pdf"},
+		{"ZIP 文件", models.ClipboardTypeFile, base64.StdEncoding.EncodeToString([]byte("PK\x03\x04\x14\x00")), models.ClipboardSubtypeArchive, "application/zip"},
+		{"未知二进制", models.ClipboardTypeFile, base64.StdEncoding.EncodeToString([]byte{0x00, 0x01, 0x02, 0xfe}), models.ClipboardSubtypeBinary, "application/octet-stream"},
+		{"文本类型的 data URL", models.ClipboardTypeText, "data:image/png;base64," + encoded, models.ClipboardSubtypeImage, "image/png"},
+		{"声明类型的 data URL", models.ClipboardTypeText, "data:application/json,%7B%22a%22%3A1%7D", models.ClipboardSubtypeJSON, "application/json"},
+	}
+
+	for _, tt := range tests {
+		got := Classify(tt.itemType, tt.content)
+		if got.Subtype != tt.wantSubtype || got.MimeType != tt.wantMimeType {
+			t.Errorf("%s: 期望 %q %q，实际得到 %q %q", tt.name, tt.wantSubtype, tt.wantMimeType, got.Subtype, got.MimeType)
+		}
+	}
+
+	if got := Classify(models.ClipboardTypeText, "data: these are my notes, not a URL"); got.Subtype != models.ClipboardSubtypePlain {
+		t.Errorf("以 data: 开头的普通文本应为 plain，实际得到 %q", got.Subtype)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bufio"
+	"clipboard-server/config"
+	"clipboard-server/database"
+	"clipboard-server/encryption"
+	"clipboard-server/models"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// command is a single CLI subcommand
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// rootCommands lists the top level subcommands of clipboard-server
+var rootCommands = []command{
+	{"serve", "start the HTTP server (default)", runServe},
+	{"user", "manage user accounts", runUser},
+	{"db", "database maintenance", runDB},
+	{"config", "inspect the configuration", runConfig},
+	{"keys", "manage the encryption at rest master key", runKeys},
+	{"tls", "generate certificates for HTTPS and mutual TLS", runTLS},
+	{"import", "import clipboard history for a user", runImport},
+	{"webhook", "test webhooks with a local receiver", runWebhook},
+}
+
+// run dispatches the command line and returns the process exit code
+func run(args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+
+	if err := dispatch("clipboard-server", args, rootCommands); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// dispatch runs the subcommand named by args[0]
+func dispatch(prefix string, args []string, commands []command) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(prefix, commands)
+		if len(args) == 0 {
+			return fmt.Errorf("missing subcommand")
+		}
+		return flag.ErrHelp
+	}
+
+	for _, cmd := range commands {
+		if cmd.name == args[0] {
+			return cmd.run(args[1:])
+		}
+	}
+
+	printUsage(prefix, commands)
+	return fmt.Errorf("unknown subcommand %q", args[0])
+}
+
+func printUsage(prefix string, commands []command) {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [options]\n\nCommands:\n", prefix)
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-16s %s\n", cmd.name, cmd.summary)
+	}
+}
+
+// splitPositional allows a single positional argument to appear before the flags
+func splitPositional(fs *flag.FlagSet, args []string) (string, error) {
+	var positional string
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		positional = args[0]
+		args = args[1:]
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return "", err
+	}
+
+	if positional == "" {
+		positional = fs.Arg(0)
+	} else if fs.NArg() > 0 {
+		return "", fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return positional, nil
+}
+
+// openDatabase loads the configuration and opens the database
+func openDatabase() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := setupEncryption(cfg); err != nil {
+		return nil, err
+	}
+	if err := database.Initialize(); err != nil {
+		return nil, fmt.Errorf("database initialization failed: %v", err)
+	}
+	return cfg, nil
+}
+
+// connectDatabase opens the database without applying migrations
+func connectDatabase() (*config.Config, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := setupEncryption(cfg); err != nil {
+		return nil, err
+	}
+	if err := database.Connect(); err != nil {
+		return nil, fmt.Errorf("database connection failed: %v", err)
+	}
+	return cfg, nil
+}
+
+// setupEncryption enables encryption at rest when a master key is configured
+func setupEncryption(cfg *config.Config) (*encryption.ContentCipher, error) {
+	masterKey, err := encryption.LoadMasterKey(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encryption master key: %v", err)
+	}
+	if masterKey == nil {
+		models.SetContentCipher(nil)
+		return nil, nil
+	}
+
+	contentCipher := encryption.NewContentCipher(masterKey)
+	models.SetContentCipher(contentCipher)
+	return contentCipher, nil
+}
+
+// readPassword reads a password from stdin or an interactive prompt.
+// Passwords are never accepted as arguments so they don't end up in shell history.
+func readPassword(fromStdin bool) (string, error) {
+	if fromStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("failed to read password from stdin: %v", err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("stdin is not a terminal, use --password-stdin to pipe the password")
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+
+	fmt.Fprint(os.Stderr, "Confirm password: ")
+	confirm, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %v", err)
+	}
+
+	if string(password) != string(confirm) {
+		return "", fmt.Errorf("passwords do not match")
+	}
+	return string(password), nil
+}
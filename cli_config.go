@@ -0,0 +1,42 @@
+package main
+
+import (
+	"clipboard-server/config"
+	"clipboard-server/encryption"
+	"flag"
+	"fmt"
+)
+
+func runConfig(args []string) error {
+	return dispatch("clipboard-server config", args, []command{
+		{"check", "validate the configuration and print it", configCheck},
+	})
+}
+
+func configCheck(args []string) error {
+	fs := flag.NewFlagSet("config check", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	cfg.Print()
+
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("configuration is invalid: %v", err)
+	}
+
+	if _, err := encryption.LoadMasterKey(cfg); err != nil {
+		return fmt.Errorf("configuration is invalid: %v", err)
+	}
+
+	if cfg.JWTSecret == "clipboard-sync-secret-key-change-in-production" {
+		fmt.Println("Warning: JWT_SECRET is using the default value")
+	}
+
+	fmt.Println("Configuration OK")
+	return nil
+}
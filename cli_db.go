@@ -0,0 +1,318 @@
+package main
+
+import (
+	"clipboard-server/config"
+	"clipboard-server/database"
+	"clipboard-server/encryption"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func runDB(args []string) error {
+	return dispatch("clipboard-server db", args, []command{
+		{"status", "show applied and pending schema migrations", dbStatus},
+		{"migrate", "apply pending schema migrations", dbMigrate},
+		{"rollback", "roll back the latest schema migrations", dbRollback},
+		{"vacuum", "rebuild the database file to reclaim space", dbVacuum},
+		{"cleanup", "delete clipboard items older than --days", dbCleanup},
+		{"backup", "write a verified online backup of the database", dbBackup},
+		{"backups", "list the backups in BACKUP_DIR", dbBackups},
+		{"verify", "check a backup file with PRAGMA integrity_check", dbVerify},
+		{"restore", "replace the database with a backup while the server is stopped", dbRestore},
+		{"encrypt", "encrypt clipboard content stored before encryption at rest was enabled", dbEncrypt},
+	})
+}
+
+func dbMigrate(args []string) error {
+	fs := flag.NewFlagSet("db migrate", flag.ContinueOnError)
+	to := fs.Int("to", 0, "migrate up to this version (default latest)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := connectDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	applied, err := database.MigrateTo(database.GetDB(), *to)
+	for _, m := range applied {
+		fmt.Printf("Applied %04d_%s\n", m.Version, m.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("Database schema is up to date")
+	}
+	return nil
+}
+
+func dbRollback(args []string) error {
+	fs := flag.NewFlagSet("db rollback", flag.ContinueOnError)
+	steps := fs.Int("steps", 1, "number of migrations to roll back")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *steps <= 0 {
+		return fmt.Errorf("--steps must be greater than 0")
+	}
+
+	if _, err := connectDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	reverted, err := database.Rollback(database.GetDB(), *steps)
+	for _, m := range reverted {
+		fmt.Printf("Rolled back %04d_%s\n", m.Version, m.Name)
+	}
+	return err
+}
+
+func dbStatus(args []string) error {
+	fs := flag.NewFlagSet("db status", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := connectDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	states, err := database.MigrationStatus(database.GetDB())
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED")
+	for _, state := range states {
+		status, appliedAt := "pending", ""
+		if state.AppliedAt != nil {
+			status, appliedAt = "applied", state.AppliedAt.Format(time.RFC3339)
+		}
+		if state.Unknown {
+			status = "unknown"
+		}
+		fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", state.Version, state.Name, status, appliedAt)
+	}
+	return w.Flush()
+}
+
+func dbVacuum(args []string) error {
+	fs := flag.NewFlagSet("db vacuum", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	return database.Vacuum()
+}
+
+func dbCleanup(args []string) error {
+	fs := flag.NewFlagSet("db cleanup", flag.ContinueOnError)
+	days := fs.Int("days", 0, "delete items older than this many days (default CLEANUP_DAYS)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if *days == 0 {
+		*days = cfg.CleanupDays
+	}
+	return database.Cleanup(*days)
+}
+
+func dbBackup(args []string) error {
+	fs := flag.NewFlagSet("db backup", flag.ContinueOnError)
+	dir := fs.String("dir", "", "backup directory (default BACKUP_DIR)")
+	keep := fs.Int("keep", -1, "backups kept after rotation, 0 keeps all (default BACKUP_KEEP)")
+	compress := fs.Bool("compress", false, "gzip the backup (default BACKUP_COMPRESS)")
+	dest, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	// An explicit target file is written as-is, without rotation
+	if dest != "" {
+		if _, err := os.Stat(dest); err == nil {
+			return fmt.Errorf("backup target %s already exists", dest)
+		}
+		return database.Backup(dest)
+	}
+
+	opts := database.ConfiguredBackupOptions(cfg)
+	if *dir != "" {
+		opts.Dir = *dir
+	}
+	if *keep >= 0 {
+		opts.Keep = *keep
+	}
+	opts.Compress = opts.Compress || *compress
+
+	backup, err := database.CreateBackup(opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Database backed up to: %s (%d bytes, verified)\n", backup.Path, backup.Size)
+	return nil
+}
+
+func dbBackups(args []string) error {
+	fs := flag.NewFlagSet("db backups", flag.ContinueOnError)
+	dir := fs.String("dir", "", "backup directory (default BACKUP_DIR)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *dir == "" {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			return err
+		}
+		*dir = cfg.BackupDir
+	}
+	backups, err := database.ListBackups(*dir)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tCREATED\tSIZE")
+	for _, backup := range backups {
+		fmt.Fprintf(w, "%s\t%s\t%d\n", backup.Name, backup.CreatedAt.Local().Format(time.RFC3339), backup.Size)
+	}
+	return w.Flush()
+}
+
+func dbVerify(args []string) error {
+	fs := flag.NewFlagSet("db verify", flag.ContinueOnError)
+	file, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if file == "" {
+		return fmt.Errorf("usage: clipboard-server db verify <backup-file>")
+	}
+
+	if err := database.VerifyBackup(file); err != nil {
+		return err
+	}
+	fmt.Printf("%s: ok\n", file)
+	return nil
+}
+
+func dbRestore(args []string) error {
+	fs := flag.NewFlagSet("db restore", flag.ContinueOnError)
+	at := fs.String("at", "", "restore the newest backup in BACKUP_DIR taken at or before this RFC 3339 time")
+	force := fs.Bool("force", false, "restore even if the database seems to be in use")
+	file, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if (file == "") == (*at == "") {
+		return fmt.Errorf("usage: clipboard-server db restore <backup-file> | --at <time>")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if cfg.DBDriver != database.DriverSQLite {
+		return fmt.Errorf("restore is only supported for SQLite, use pg_restore or mysql for %s", cfg.DBDriver)
+	}
+
+	if *at != "" {
+		t, err := time.Parse(time.RFC3339, *at)
+		if err != nil {
+			return fmt.Errorf("--at must be an RFC 3339 time: %v", err)
+		}
+		backup, err := database.BackupAt(cfg.BackupDir, t)
+		if err != nil {
+			return err
+		}
+		file = backup.Path
+	}
+
+	dest := sqlitePath(cfg)
+	if database.DatabaseInUse(dest) && !*force {
+		return fmt.Errorf("%s seems to be in use, stop the server first (use --force if it was not shut down cleanly)", dest)
+	}
+
+	previous, err := database.RestoreBackup(file, dest)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %s from %s\n", dest, file)
+	if previous != "" {
+		fmt.Printf("The previous database was kept as %s\n", previous)
+	}
+	return nil
+}
+
+// sqlitePath returns the database file of the SQLite configuration
+func sqlitePath(cfg *config.Config) string {
+	path := cfg.DBDSN
+	if path == "" {
+		return cfg.DBPath
+	}
+	path = strings.TrimPrefix(path, "file:")
+	if i := strings.Index(path, "?"); i >= 0 {
+		path = path[:i]
+	}
+	return path
+}
+
+func dbEncrypt(args []string) error {
+	fs := flag.NewFlagSet("db encrypt", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	if !cfg.EncryptionEnabled() {
+		return fmt.Errorf("encryption at rest is not configured, set ENCRYPTION_MASTER_KEY or ENCRYPTION_KEY_FILE")
+	}
+
+	contentCipher, err := setupEncryption(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := database.Initialize(); err != nil {
+		return fmt.Errorf("database initialization failed: %v", err)
+	}
+	defer database.Close()
+
+	count, err := encryption.EncryptExisting(database.GetDB(), contentCipher)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Encrypted %d clipboard items and webhook deliveries\n", count)
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/handlers"
+	"clipboard-server/importer"
+	"clipboard-server/repository"
+	"clipboard-server/transfer"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// mappingFlag collects repeated --map field=source flags
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	pairs := make([]string, 0, len(m))
+	for field, source := range m {
+		pairs = append(pairs, field+"="+source)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (m mappingFlag) Set(value string) error {
+	field, source, ok := strings.Cut(value, "=")
+	if !ok || field == "" || source == "" {
+		return fmt.Errorf("expected <item field>=<source field>, got %q", value)
+	}
+	m[field] = source
+	return nil
+}
+
+func runImport(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	username := fs.String("user", "", "username to import the items for")
+	format := fs.String("format", "export", "input format: export or one of the registered importers")
+	delimiter := fs.String("delimiter", "", "CSV field delimiter (default \",\")")
+	mapping := mappingFlag{}
+	fs.Var(mapping, "map", "map an item field to a source field, e.g. --map content=text (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: clipboard-server import <file|-> --user <username> [--format <format>] [--map field=source]...")
+		fs.PrintDefaults()
+		fmt.Fprintln(os.Stderr, "\nFormats:")
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", "export", "NDJSON or tar.gz export of clipboard-server")
+		for _, imp := range importer.All() {
+			fmt.Fprintf(os.Stderr, "  %-8s %s\n", imp.Name(), imp.Description())
+		}
+	}
+
+	path, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if path == "" || *username == "" {
+		fs.Usage()
+		return fmt.Errorf("file and --user are required")
+	}
+
+	read := func(im *transfer.Importer, r io.Reader) (transfer.Progress, error) {
+		return im.Import(r)
+	}
+	if *format != "export" {
+		imp, ok := importer.Lookup(*format)
+		if !ok {
+			return fmt.Errorf("unknown format %q", *format)
+		}
+		opts := importer.Options{Mapping: mapping, Delimiter: *delimiter}
+		if err := opts.Validate(); err != nil {
+			return err
+		}
+		read = func(im *transfer.Importer, r io.Reader) (transfer.Progress, error) {
+			if err := imp.Read(r, opts, im); err != nil {
+				progress, _ := im.Finish()
+				return progress, err
+			}
+			return im.Finish()
+		}
+	}
+
+	input := io.Reader(os.Stdin)
+	if path != "-" {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	user, err := database.FindUser(*username)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDB()
+	im := &transfer.Importer{
+		Items:   repository.NewTransferRepository(db),
+		UserID:  user.ID,
+		Prepare: handlers.ImportPreparer(repository.NewClipboardRepository(db), user.ID),
+		Progress: func(progress transfer.Progress) {
+			fmt.Fprintf(os.Stderr, "\rProcessed %d records", progress.Processed)
+		},
+	}
+
+	progress, err := read(im, input)
+	fmt.Fprintln(os.Stderr)
+	for _, recordErr := range progress.Errors {
+		fmt.Fprintf(os.Stderr, "record %d: %s\n", recordErr.Record, recordErr.Error)
+	}
+	fmt.Printf("Imported %d, skipped %d, failed %d of %d records\n",
+		progress.Imported, progress.Skipped, progress.Failed, progress.Processed)
+	return err
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"clipboard-server/config"
+	"clipboard-server/database"
+	"clipboard-server/encryption"
+	"flag"
+	"fmt"
+)
+
+func runKeys(args []string) error {
+	return dispatch("clipboard-server keys", args, []command{
+		{"generate", "print a new random master key", keysGenerate},
+		{"rotate", "re-wrap all data keys under a new master key", keysRotate},
+	})
+}
+
+func keysGenerate(args []string) error {
+	fs := flag.NewFlagSet("keys generate", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := encryption.GenerateMasterKey()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(key)
+	return nil
+}
+
+func keysRotate(args []string) error {
+	fs := flag.NewFlagSet("keys rotate", flag.ContinueOnError)
+	newKeyFile := fs.String("new-key-file", "", "file containing the new master key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *newKeyFile == "" {
+		return fmt.Errorf("usage: clipboard-server keys rotate --new-key-file <path>")
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+	oldKey, err := encryption.LoadMasterKey(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to load current master key: %v", err)
+	}
+	if oldKey == nil {
+		return fmt.Errorf("encryption at rest is not configured, set ENCRYPTION_MASTER_KEY or ENCRYPTION_KEY_FILE")
+	}
+
+	newKey, err := encryption.ReadMasterKeyFile(*newKeyFile)
+	if err != nil {
+		return err
+	}
+
+	if _, err := setupEncryption(cfg); err != nil {
+		return err
+	}
+	if err := database.Initialize(); err != nil {
+		return fmt.Errorf("database initialization failed: %v", err)
+	}
+	defer database.Close()
+
+	count, err := encryption.RotateMasterKey(database.GetDB(), oldKey, newKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Re-wrapped %d data keys from master key %s to %s\n", count, oldKey.ID, newKey.ID)
+	fmt.Println("Update ENCRYPTION_MASTER_KEY or ENCRYPTION_KEY_FILE to the new key before restarting the server")
+	return nil
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"clipboard-server/certs"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+func runTLS(args []string) error {
+	return dispatch("clipboard-server tls", args, []command{
+		{"generate", "generate a local CA with server and client certificates", tlsGenerate},
+	})
+}
+
+// tlsGenerate writes a CA, a server certificate and client certificates for
+// development and testing. Production servers should use certificates from
+// a real CA.
+func tlsGenerate(args []string) error {
+	fs := flag.NewFlagSet("tls generate", flag.ContinueOnError)
+	dir := fs.String("dir", "ssl", "output directory")
+	hosts := fs.String("hosts", "localhost,127.0.0.1", "comma separated host names and IP addresses of the server")
+	clients := fs.String("clients", "", "comma separated names of client certificates to issue for mutual TLS")
+	days := fs.Int("days", 365, "validity in days")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *days <= 0 {
+		return fmt.Errorf("--days must be greater than 0")
+	}
+	validity := time.Duration(*days) * 24 * time.Hour
+
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", *dir, err)
+	}
+
+	ca, err := certs.NewAuthority("clipboard-sync-server CA", validity)
+	if err != nil {
+		return err
+	}
+	if err := certs.WriteCertificate(filepath.Join(*dir, "ca.pem"), ca.Cert); err != nil {
+		return err
+	}
+	if err := certs.WriteKey(filepath.Join(*dir, "ca-key.pem"), ca.Key); err != nil {
+		return err
+	}
+
+	serverHosts := splitList(*hosts)
+	if len(serverHosts) == 0 {
+		return fmt.Errorf("--hosts must name at least one host")
+	}
+	if err := issueCertificate(ca, *dir, "server", serverHosts, validity); err != nil {
+		return err
+	}
+
+	for _, name := range splitList(*clients) {
+		if err := issueCertificate(ca, *dir, "client-"+name, nil, validity); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("Certificates written to %s\n", *dir)
+	fmt.Printf("  TLS_CERT_FILE=%s\n", filepath.Join(*dir, "server.pem"))
+	fmt.Printf("  TLS_KEY_FILE=%s\n", filepath.Join(*dir, "server-key.pem"))
+	if *clients != "" {
+		fmt.Printf("  TLS_CLIENT_CA_FILE=%s\n", filepath.Join(*dir, "ca.pem"))
+	}
+	return nil
+}
+
+func issueCertificate(ca *certs.Authority, dir, name string, hosts []string, validity time.Duration) error {
+	cert, key, err := ca.Issue(name, hosts, validity)
+	if err != nil {
+		return err
+	}
+	if err := certs.WriteCertificate(filepath.Join(dir, name+".pem"), cert); err != nil {
+		return err
+	}
+	return certs.WriteKey(filepath.Join(dir, name+"-key.pem"), key)
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
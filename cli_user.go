@@ -0,0 +1,142 @@
+package main
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/utils"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+func runUser(args []string) error {
+	return dispatch("clipboard-server user", args, []command{
+		{"create", "create a user account", userCreate},
+		{"list", "list user accounts", userList},
+		{"disable", "disable a user account and revoke its token", userDisable},
+		{"enable", "re-enable a disabled user account", userEnable},
+		{"reset-password", "reset a user's password", userResetPassword},
+	})
+}
+
+func userCreate(args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "email address of the new user")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of prompting")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: clipboard-server user create <username> --email <email> [--password-stdin]")
+		fs.PrintDefaults()
+	}
+
+	username, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if username == "" || *email == "" {
+		fs.Usage()
+		return fmt.Errorf("username and --email are required")
+	}
+
+	password, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	user, err := database.CreateUser(username, *email, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("User %s created (id: %s)\n", user.Username, user.ID)
+	return nil
+}
+
+func userList(args []string) error {
+	fs := flag.NewFlagSet("user list", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	users, err := database.ListUsers()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSERNAME\tEMAIL\tACTIVE\tCREATED")
+	for _, user := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\n",
+			user.ID, user.Username, user.Email, user.IsActive, user.CreatedAt.Format(time.RFC3339))
+	}
+	return w.Flush()
+}
+
+func userDisable(args []string) error {
+	return setUserActive("disable", args, false)
+}
+
+func userEnable(args []string) error {
+	return setUserActive("enable", args, true)
+}
+
+func setUserActive(name string, args []string, active bool) error {
+	fs := flag.NewFlagSet("user "+name, flag.ContinueOnError)
+	username, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if username == "" {
+		return fmt.Errorf("usage: clipboard-server user %s <username>", name)
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	if err := database.SetUserActive(username, active); err != nil {
+		return err
+	}
+
+	fmt.Printf("User %s %sd\n", username, name)
+	return nil
+}
+
+func userResetPassword(args []string) error {
+	fs := flag.NewFlagSet("user reset-password", flag.ContinueOnError)
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin instead of prompting")
+
+	username, err := splitPositional(fs, args)
+	if err != nil {
+		return err
+	}
+	if username == "" {
+		return fmt.Errorf("usage: clipboard-server user reset-password <username> [--password-stdin]")
+	}
+
+	password, err := readPassword(*passwordStdin)
+	if err != nil {
+		return err
+	}
+	if err := utils.ValidatePassword(password); err != nil {
+		return err
+	}
+
+	if _, err := openDatabase(); err != nil {
+		return err
+	}
+	defer database.Close()
+
+	return database.ResetUserPasswordWithSalt(username, password)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"clipboard-server/webhooks"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+func runWebhook(args []string) error {
+	return dispatch("clipboard-server webhook", args, []command{
+		{"receive", "run a local endpoint printing the webhook deliveries it receives", webhookReceive},
+	})
+}
+
+// webhookReceive serves a webhooks.Receiver until interrupted, to test
+// webhooks without deploying a receiver
+func webhookReceive(args []string) error {
+	fs := flag.NewFlagSet("webhook receive", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:9000", "address to listen on")
+	secret := fs.String("secret", os.Getenv("WEBHOOK_SECRET"), "secret verifying the signatures (default $WEBHOOK_SECRET)")
+	status := fs.Int("status", http.StatusNoContent, "status answered to valid deliveries, an error status exercises retries")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *status < 100 || *status > 599 {
+		return fmt.Errorf("--status must be an HTTP status code")
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           &webhooks.Receiver{Secret: *secret, Status: *status, Out: os.Stdout},
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	fmt.Printf("Receiving webhooks on http://%s/\n", *addr)
+	if *secret == "" {
+		fmt.Println("Warning: no --secret given, signatures are not verified")
+	}
+	return server.ListenAndServe()
+}
@@ -5,124 +5,334 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config application configuration structure
 type Config struct {
+	// File is the YAML or TOML config file, empty when only the environment is
+	// used. It is checked for changes every WatchInterval, 0 reloads on SIGHUP only.
+	File          string
+	WatchInterval time.Duration
+	Environment   string
+
 	ServerHost string
 	ServerPort string
 
+	// Listen lists the listeners, tcp://host:port, unix:///path or systemd[:name],
+	// instead of ServerHost:ServerPort. Unix sockets are created with
+	// UnixSocketMode and UnixSocketGroup. When AdminListen is set the admin API
+	// is only served on those listeners.
+	Listen          []string
+	AdminListen     []string
+	UnixSocketMode  os.FileMode
+	UnixSocketGroup string
+
+	// HTTPS is served when TLSCertFile and TLSKeyFile are set. The files are
+	// reloaded when they change. TLSClientAuth none, optional or require
+	// verifies client certificates against TLSClientCAFile. Plain HTTP on
+	// TLSRedirectAddr is redirected to HTTPS.
+	TLSCertFile      string
+	TLSKeyFile       string
+	TLSClientCAFile  string
+	TLSClientAuth    string
+	TLSMinVersion    string
+	TLSHTTP2         bool
+	TLSRedirectAddr  string
+	TLSWatchInterval time.Duration
+
 	JWTSecret     string
 	JWTExpireHour int
 
-	DBPath  string
-	DBDebug bool
+	// DBDriver is sqlite, postgres or mysql. DBDSN is required for postgres
+	// and mysql, SQLite uses DBPath unless a DSN is set.
+	DBDriver string
+	DBDSN    string
+	DBPath   string
+	DBDebug  bool
+	// Apply pending schema migrations on startup
+	DBAutoMigrate bool
 
 	CORSAllowOrigins []string
 	CORSAllowMethods []string
 	CORSAllowHeaders []string
 
-	LogLevel string
-	LogFile  string
+	// LogLevel is debug, info, warn or error, LogFormat json or text.
+	// LogFile is rotated after LogMaxSize MB, keeping LogMaxBackups files
+	// for at most LogMaxAge days; empty logs to stdout only.
+	LogLevel      string
+	LogFormat     string
+	LogFile       string
+	LogMaxSize    int
+	LogMaxBackups int
+	LogMaxAge     int
+
+	// Log headers and bodies of a sample of HTTP requests, turned off after
+	// HTTPDebugDuration (0 keeps it on). Also adjustable through the admin API.
+	HTTPDebugLog        bool
+	HTTPDebugSampleRate float64
+	HTTPDebugDuration   time.Duration
 
 	MaxContentSize  int64
 	CleanupDays     int
 	EnableCleanup   bool
 	CleanupInterval string
 
+	// How often expired clipboard items are purged
+	ExpirySweepInterval time.Duration
+
 	RateLimitRPS   int
 	RateLimitBurst int
 
 	UploadMaxSize int64
 	UploadPath    string
+
+	// Encryption at rest, enabled when a master key or key file is set
+	EncryptionMasterKey string
+	EncryptionKeyFile   string
+
+	// Online SQLite backups, scheduled when BackupInterval is set
+	BackupDir      string
+	BackupInterval time.Duration
+	BackupKeep     int
+	BackupCompress bool
+
+	// Health probes: each check is bounded by HealthCheckTimeout, and readiness
+	// fails when less than HealthMinDiskFreeMB is free under UploadPath
+	HealthCheckTimeout  time.Duration
+	HealthMinDiskFreeMB int64
+
+	// Webhook deliveries are sent every WebhookPollInterval and as soon as
+	// events are queued. A failed attempt is retried after WebhookRetryBase,
+	// doubling each time, until WebhookMaxAttempts attempts were made.
+	// Private, loopback and link-local targets need WebhookAllowPrivateTargets.
+	WebhookTimeout             time.Duration
+	WebhookMaxAttempts         int
+	WebhookRetryBase           time.Duration
+	WebhookPollInterval        time.Duration
+	WebhookAllowPrivateTargets bool
+
+	// AdminToken protects the admin API, which is disabled while it is empty
+	AdminToken string
+
+	// Serve Prometheus metrics on /metrics
+	MetricsEnabled bool
+
+	// OpenTelemetry traces exported over OTLP/HTTP to TracingEndpoint (host:port)
+	TracingEnabled     bool
+	TracingEndpoint    string
+	TracingInsecure    bool
+	TracingSampleRatio float64
 }
 
-var AppConfig *Config
+// current is the configuration returned by GetConfig, replaced on reload
+var current atomic.Pointer[Config]
+
+// LoadConfig loads the configuration from the environment, .env and the
+// config file named by CONFIG_FILE. Environment variables take precedence
+// over the config file.
+func LoadConfig() (*Config, error) {
+	config, err := load()
+	if err != nil {
+		return nil, err
+	}
+	current.Store(config)
+	return config, nil
+}
 
-func LoadConfig() *Config {
+func load() (*Config, error) {
 	if err := godotenv.Load(); err != nil {
 		// .env file not existing is fine
 	}
 
-	config := &Config{
-		ServerHost: getEnv("SERVER_HOST", "localhost"),
-		ServerPort: getEnv("SERVER_PORT", "8080"),
-
-		JWTSecret:     getEnv("JWT_SECRET", "clipboard-sync-secret-key-change-in-production"),
-		JWTExpireHour: getEnvAsInt("JWT_EXPIRE_HOUR", 24*7),
-
-		DBPath:  getEnv("DB_PATH", "data/clipboard.db"),
-		DBDebug: getEnvAsBool("DB_DEBUG", false),
+	file := os.Getenv("CONFIG_FILE")
+	var values map[string]string
+	if file != "" {
+		var err error
+		if values, err = readFile(file); err != nil {
+			return nil, err
+		}
+	}
+	src := newSource(values)
 
-		CORSAllowOrigins: getEnvAsSlice("CORS_ALLOW_ORIGINS", []string{"*"}, ","),
-		CORSAllowMethods: getEnvAsSlice("CORS_ALLOW_METHODS", []string{
+	config := &Config{
+		File:          file,
+		WatchInterval: src.getEnvAsDuration("CONFIG_WATCH_INTERVAL", 5*time.Second),
+		Environment:   src.getEnv("GO_ENV", "development"),
+
+		ServerHost: src.getEnv("SERVER_HOST", "localhost"),
+		ServerPort: src.getEnv("SERVER_PORT", "8080"),
+
+		Listen:          src.getEnvAsSlice("LISTEN", nil, ","),
+		AdminListen:     src.getEnvAsSlice("ADMIN_LISTEN", nil, ","),
+		UnixSocketMode:  src.getEnvAsFileMode("UNIX_SOCKET_MODE", 0660),
+		UnixSocketGroup: src.getEnv("UNIX_SOCKET_GROUP", ""),
+
+		TLSCertFile:      src.getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:       src.getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:  src.getEnv("TLS_CLIENT_CA_FILE", ""),
+		TLSClientAuth:    strings.ToLower(src.getEnv("TLS_CLIENT_AUTH", "none")),
+		TLSMinVersion:    src.getEnv("TLS_MIN_VERSION", "1.2"),
+		TLSHTTP2:         src.getEnvAsBool("TLS_HTTP2", true),
+		TLSRedirectAddr:  src.getEnv("TLS_REDIRECT_ADDR", ""),
+		TLSWatchInterval: src.getEnvAsDuration("TLS_WATCH_INTERVAL", time.Minute),
+
+		JWTSecret:     src.getEnv("JWT_SECRET", "clipboard-sync-secret-key-change-in-production"),
+		JWTExpireHour: src.getEnvAsInt("JWT_EXPIRE_HOUR", 24*7),
+
+		DBDriver: strings.ToLower(src.getEnv("DB_DRIVER", "sqlite")),
+		DBDSN:    src.getEnv("DB_DSN", ""),
+		DBPath:   src.getEnv("DB_PATH", "data/clipboard.db"),
+		DBDebug:  src.getEnvAsBool("DB_DEBUG", false),
+
+		DBAutoMigrate: src.getEnvAsBool("DB_AUTO_MIGRATE", true),
+
+		CORSAllowOrigins: src.getEnvAsSlice("CORS_ALLOW_ORIGINS", []string{"*"}, ","),
+		CORSAllowMethods: src.getEnvAsSlice("CORS_ALLOW_METHODS", []string{
 			"GET", "POST", "PUT", "DELETE", "OPTIONS",
 		}, ","),
-		CORSAllowHeaders: getEnvAsSlice("CORS_ALLOW_HEADERS", []string{
+		CORSAllowHeaders: src.getEnvAsSlice("CORS_ALLOW_HEADERS", []string{
 			"Origin", "Content-Type", "Accept", "Authorization", "Cache-Control",
 		}, ","),
 
-		LogLevel: getEnv("LOG_LEVEL", "info"),
-		LogFile:  getEnv("LOG_FILE", "logs/app.log"),
+		LogLevel:      strings.ToLower(src.getEnv("LOG_LEVEL", "info")),
+		LogFormat:     strings.ToLower(src.getEnv("LOG_FORMAT", "json")),
+		LogFile:       src.getEnv("LOG_FILE", "logs/app.log"),
+		LogMaxSize:    src.getEnvAsInt("LOG_MAX_SIZE", 100),
+		LogMaxBackups: src.getEnvAsInt("LOG_MAX_BACKUPS", 5),
+		LogMaxAge:     src.getEnvAsInt("LOG_MAX_AGE", 30),
+
+		HTTPDebugLog:        src.getEnvAsBool("HTTP_DEBUG_LOG", false),
+		HTTPDebugSampleRate: src.getEnvAsFloat("HTTP_DEBUG_SAMPLE_RATE", 1.0),
+		HTTPDebugDuration:   src.getEnvAsDuration("HTTP_DEBUG_DURATION", 15*time.Minute),
+
+		MaxContentSize:  src.getEnvAsInt64("MAX_CONTENT_SIZE", 1024*1024),
+		CleanupDays:     src.getEnvAsInt("CLEANUP_DAYS", 30),
+		EnableCleanup:   src.getEnvAsBool("ENABLE_CLEANUP", true),
+		CleanupInterval: src.getEnv("CLEANUP_INTERVAL", "0 2 * * *"),
+
+		ExpirySweepInterval: src.getEnvAsDuration("EXPIRY_SWEEP_INTERVAL", time.Minute),
+
+		RateLimitRPS:   src.getEnvAsInt("RATE_LIMIT_RPS", 100),
+		RateLimitBurst: src.getEnvAsInt("RATE_LIMIT_BURST", 200),
+
+		UploadMaxSize: src.getEnvAsInt64("UPLOAD_MAX_SIZE", 10*1024*1024),
+		UploadPath:    src.getEnv("UPLOAD_PATH", "data/uploads"),
 
-		MaxContentSize:  getEnvAsInt64("MAX_CONTENT_SIZE", 1024*1024),
-		CleanupDays:     getEnvAsInt("CLEANUP_DAYS", 30),
-		EnableCleanup:   getEnvAsBool("ENABLE_CLEANUP", true),
-		CleanupInterval: getEnv("CLEANUP_INTERVAL", "0 2 * * *"),
+		EncryptionMasterKey: src.getEnv("ENCRYPTION_MASTER_KEY", ""),
+		EncryptionKeyFile:   src.getEnv("ENCRYPTION_KEY_FILE", ""),
 
-		RateLimitRPS:   getEnvAsInt("RATE_LIMIT_RPS", 100),
-		RateLimitBurst: getEnvAsInt("RATE_LIMIT_BURST", 200),
+		HealthCheckTimeout:  src.getEnvAsDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+		HealthMinDiskFreeMB: src.getEnvAsInt64("HEALTH_MIN_DISK_FREE_MB", 100),
 
-		UploadMaxSize: getEnvAsInt64("UPLOAD_MAX_SIZE", 10*1024*1024),
-		UploadPath:    getEnv("UPLOAD_PATH", "data/uploads"),
+		BackupDir:      src.getEnv("BACKUP_DIR", "data/backups"),
+		BackupInterval: src.getEnvAsDuration("BACKUP_INTERVAL", 0),
+		BackupKeep:     src.getEnvAsInt("BACKUP_KEEP", 7),
+		BackupCompress: src.getEnvAsBool("BACKUP_COMPRESS", false),
+
+		WebhookTimeout:             src.getEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+		WebhookMaxAttempts:         src.getEnvAsInt("WEBHOOK_MAX_ATTEMPTS", 8),
+		WebhookRetryBase:           src.getEnvAsDuration("WEBHOOK_RETRY_BASE", 30*time.Second),
+		WebhookPollInterval:        src.getEnvAsDuration("WEBHOOK_POLL_INTERVAL", 10*time.Second),
+		WebhookAllowPrivateTargets: src.getEnvAsBool("WEBHOOK_ALLOW_PRIVATE_TARGETS", false),
+
+		AdminToken: src.getEnv("ADMIN_TOKEN", ""),
+
+		MetricsEnabled: src.getEnvAsBool("METRICS_ENABLED", true),
+
+		TracingEnabled:     src.getEnvAsBool("TRACING_ENABLED", false),
+		TracingEndpoint:    src.getEnv("TRACING_ENDPOINT", "localhost:4318"),
+		TracingInsecure:    src.getEnvAsBool("TRACING_INSECURE", true),
+		TracingSampleRatio: src.getEnvAsFloat("TRACING_SAMPLE_RATIO", 1.0),
 	}
 
-	AppConfig = config
-	return config
+	if unknown := src.unknownKeys(); len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown settings in config file %s: %s", file, strings.Join(unknown, ", "))
+	}
+	if len(src.malformed) > 0 {
+		return nil, fmt.Errorf("invalid values for %s", strings.Join(src.malformed, ", "))
+	}
+	return config, nil
 }
 
+// GetConfig returns the current configuration, loading it on first use
 func GetConfig() *Config {
-	if AppConfig == nil {
-		return LoadConfig()
+	if config := current.Load(); config != nil {
+		return config
+	}
+	config, err := LoadConfig()
+	if err != nil {
+		panic(err)
 	}
-	return AppConfig
+	return config
 }
 
-func getEnv(key, defaultValue string) string {
-	if value, exists := os.LookupEnv(key); exists {
+func (s *source) getEnv(key, defaultValue string) string {
+	if value, exists := s.lookup(key); exists {
 		return value
 	}
 	return defaultValue
 }
 
-func getEnvAsInt(name string, defaultVal int) int {
-	valueStr := getEnv(name, "")
+func (s *source) getEnvAsInt(name string, defaultVal int) int {
+	valueStr := s.getEnv(name, "")
 	if value, err := strconv.Atoi(valueStr); err == nil {
 		return value
 	}
+	s.reject(name, valueStr)
 	return defaultVal
 }
 
-func getEnvAsInt64(name string, defaultVal int64) int64 {
-	valueStr := getEnv(name, "")
+func (s *source) getEnvAsInt64(name string, defaultVal int64) int64 {
+	valueStr := s.getEnv(name, "")
 	if value, err := strconv.ParseInt(valueStr, 10, 64); err == nil {
 		return value
 	}
+	s.reject(name, valueStr)
 	return defaultVal
 }
 
-func getEnvAsBool(name string, defaultVal bool) bool {
-	valueStr := getEnv(name, "")
+func (s *source) getEnvAsBool(name string, defaultVal bool) bool {
+	valueStr := s.getEnv(name, "")
 	if value, err := strconv.ParseBool(valueStr); err == nil {
 		return value
 	}
+	s.reject(name, valueStr)
+	return defaultVal
+}
+
+func (s *source) getEnvAsFloat(name string, defaultVal float64) float64 {
+	valueStr := s.getEnv(name, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	s.reject(name, valueStr)
 	return defaultVal
 }
 
-func getEnvAsSlice(name string, defaultVal []string, sep string) []string {
-	valueStr := getEnv(name, "")
+func (s *source) getEnvAsDuration(name string, defaultVal time.Duration) time.Duration {
+	valueStr := s.getEnv(name, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	s.reject(name, valueStr)
+	return defaultVal
+}
+
+func (s *source) getEnvAsFileMode(name string, defaultVal os.FileMode) os.FileMode {
+	valueStr := s.getEnv(name, "")
+	if value, err := strconv.ParseUint(valueStr, 8, 32); err == nil && value <= 0777 {
+		return os.FileMode(value)
+	}
+	s.reject(name, valueStr)
+	return defaultVal
+}
+
+func (s *source) getEnvAsSlice(name string, defaultVal []string, sep string) []string {
+	valueStr := s.getEnv(name, "")
 	if valueStr == "" {
 		return defaultVal
 	}
@@ -142,24 +352,100 @@ func getEnvAsSlice(name string, defaultVal []string, sep string) []string {
 }
 
 func (c *Config) IsDevelopment() bool {
-	env := getEnv("GO_ENV", "development")
-	return env == "development" || env == "dev"
+	return c.Environment == "development" || c.Environment == "dev"
 }
 
 func (c *Config) IsProduction() bool {
-	env := getEnv("GO_ENV", "development")
-	return env == "production" || env == "prod"
+	return c.Environment == "production" || c.Environment == "prod"
+}
+
+// TLSEnabled reports whether the server serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// EncryptionEnabled reports whether clipboard content is encrypted at rest
+func (c *Config) EncryptionEnabled() bool {
+	return c.EncryptionMasterKey != "" || c.EncryptionKeyFile != ""
 }
 
 func (c *Config) GetAddress() string {
 	return c.ServerHost + ":" + c.ServerPort
 }
 
+// Listeners returns the listener addresses, LISTEN or else the server address
+func (c *Config) Listeners() []string {
+	if len(c.Listen) > 0 {
+		return c.Listen
+	}
+	return []string{c.GetAddress()}
+}
+
 func (c *Config) Validate() error {
 	if c.JWTSecret == "clipboard-sync-secret-key-change-in-production" && c.IsProduction() {
 		return fmt.Errorf("JWT_SECRET must be changed in production")
 	}
 
+	if c.WatchInterval < 0 {
+		return fmt.Errorf("CONFIG_WATCH_INTERVAL must not be negative")
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	switch c.TLSClientAuth {
+	case "none":
+	case "optional", "require":
+		if c.TLSClientCAFile == "" {
+			return fmt.Errorf("TLS_CLIENT_CA_FILE is required for TLS_CLIENT_AUTH=%s", c.TLSClientAuth)
+		}
+		if !c.TLSEnabled() {
+			return fmt.Errorf("TLS_CLIENT_AUTH requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+	default:
+		return fmt.Errorf("TLS_CLIENT_AUTH must be none, optional or require")
+	}
+	if c.TLSMinVersion != "1.2" && c.TLSMinVersion != "1.3" {
+		return fmt.Errorf("TLS_MIN_VERSION must be 1.2 or 1.3")
+	}
+	if c.TLSRedirectAddr != "" && !c.TLSEnabled() {
+		return fmt.Errorf("TLS_REDIRECT_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if c.TLSWatchInterval < 0 {
+		return fmt.Errorf("TLS_WATCH_INTERVAL must not be negative")
+	}
+
+	switch c.DBDriver {
+	case "sqlite":
+	case "postgres", "mysql":
+		if c.DBDSN == "" {
+			return fmt.Errorf("DB_DSN is required for DB_DRIVER=%s", c.DBDriver)
+		}
+	default:
+		return fmt.Errorf("DB_DRIVER must be sqlite, postgres or mysql")
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("LOG_LEVEL must be debug, info, warn or error")
+	}
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		return fmt.Errorf("LOG_FORMAT must be json or text")
+	}
+	if c.LogMaxSize <= 0 {
+		return fmt.Errorf("LOG_MAX_SIZE must be greater than 0")
+	}
+	if c.LogMaxBackups < 0 || c.LogMaxAge < 0 {
+		return fmt.Errorf("LOG_MAX_BACKUPS and LOG_MAX_AGE must not be negative")
+	}
+	if c.HTTPDebugSampleRate < 0 || c.HTTPDebugSampleRate > 1 {
+		return fmt.Errorf("HTTP_DEBUG_SAMPLE_RATE must be between 0 and 1")
+	}
+	if c.HTTPDebugDuration < 0 {
+		return fmt.Errorf("HTTP_DEBUG_DURATION must not be negative")
+	}
+
 	if c.MaxContentSize <= 0 {
 		return fmt.Errorf("MAX_CONTENT_SIZE must be greater than 0")
 	}
@@ -168,16 +454,87 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("CLEANUP_DAYS must be greater than 0")
 	}
 
+	if c.ExpirySweepInterval <= 0 {
+		return fmt.Errorf("EXPIRY_SWEEP_INTERVAL must be greater than 0")
+	}
+
+	if c.EncryptionMasterKey != "" && c.EncryptionKeyFile != "" {
+		return fmt.Errorf("only one of ENCRYPTION_MASTER_KEY and ENCRYPTION_KEY_FILE may be set")
+	}
+
+	if c.BackupInterval < 0 {
+		return fmt.Errorf("BACKUP_INTERVAL must not be negative")
+	}
+	if c.BackupInterval > 0 && c.DBDriver != "sqlite" {
+		return fmt.Errorf("BACKUP_INTERVAL is only supported with DB_DRIVER=sqlite")
+	}
+	if c.BackupKeep < 0 {
+		return fmt.Errorf("BACKUP_KEEP must not be negative")
+	}
+
+	if c.HealthCheckTimeout <= 0 {
+		return fmt.Errorf("HEALTH_CHECK_TIMEOUT must be greater than 0")
+	}
+	if c.HealthMinDiskFreeMB < 0 {
+		return fmt.Errorf("HEALTH_MIN_DISK_FREE_MB must not be negative")
+	}
+
+	if c.WebhookTimeout <= 0 || c.WebhookRetryBase <= 0 || c.WebhookPollInterval <= 0 {
+		return fmt.Errorf("WEBHOOK_TIMEOUT, WEBHOOK_RETRY_BASE and WEBHOOK_POLL_INTERVAL must be greater than 0")
+	}
+	if c.WebhookMaxAttempts <= 0 {
+		return fmt.Errorf("WEBHOOK_MAX_ATTEMPTS must be greater than 0")
+	}
+
+	if c.TracingEnabled && c.TracingEndpoint == "" {
+		return fmt.Errorf("TRACING_ENDPOINT is required when tracing is enabled")
+	}
+	if c.TracingSampleRatio < 0 || c.TracingSampleRatio > 1 {
+		return fmt.Errorf("TRACING_SAMPLE_RATIO must be between 0 and 1")
+	}
+
 	return nil
 }
 
 func (c *Config) Print() {
 	fmt.Println("Clipboard Sync Server Configuration:")
-	fmt.Println("  Server:", c.GetAddress())
-	fmt.Println("  Environment:", getEnv("GO_ENV", "development"))
-	fmt.Println("  Database Path:", c.DBPath)
+	fmt.Println("  Server:", strings.Join(c.Listeners(), ", "))
+	if len(c.AdminListen) > 0 {
+		fmt.Println("  Admin Listeners:", strings.Join(c.AdminListen, ", "))
+	}
+	if c.TLSEnabled() {
+		fmt.Printf("  TLS: %s (min %s, client auth %s, HTTP/2 %t)\n", c.TLSCertFile, c.TLSMinVersion, c.TLSClientAuth, c.TLSHTTP2)
+		if c.TLSRedirectAddr != "" {
+			fmt.Println("  HTTP Redirect:", c.TLSRedirectAddr)
+		}
+	}
+	fmt.Println("  Environment:", c.Environment)
+	if c.File != "" {
+		fmt.Println("  Config File:", c.File)
+	}
+	fmt.Println("  Database Driver:", c.DBDriver)
+	if c.DBDriver == "sqlite" && c.DBDSN == "" {
+		fmt.Println("  Database Path:", c.DBPath)
+	}
 	fmt.Println("  Log Level:", c.LogLevel)
+	if c.LogFile != "" {
+		fmt.Printf("  Log File: %s (rotated at %d MB)\n", c.LogFile, c.LogMaxSize)
+	}
+	if c.HTTPDebugLog {
+		fmt.Printf("  HTTP Debug Log: %g of requests for %s\n", c.HTTPDebugSampleRate, c.HTTPDebugDuration)
+	}
 	fmt.Printf("  Max Content Size: %d bytes\n", c.MaxContentSize)
 	fmt.Println("  Cleanup Days:", c.CleanupDays)
+	fmt.Println("  Expiry Sweep Interval:", c.ExpirySweepInterval)
+	fmt.Println("  Encryption At Rest:", c.EncryptionEnabled())
+	if c.BackupInterval > 0 {
+		fmt.Printf("  Backups: every %s to %s, keep %d\n", c.BackupInterval, c.BackupDir, c.BackupKeep)
+	}
+	fmt.Printf("  Webhooks: %d attempts, retried after %s\n", c.WebhookMaxAttempts, c.WebhookRetryBase)
+	fmt.Println("  Admin API:", c.AdminToken != "")
+	fmt.Println("  Metrics:", c.MetricsEnabled)
+	if c.TracingEnabled {
+		fmt.Printf("  Tracing: OTLP to %s, sample ratio %g\n", c.TracingEndpoint, c.TracingSampleRatio)
+	}
 	fmt.Printf("  Rate Limit: %d RPS, %d Burst\n", c.RateLimitRPS, c.RateLimitBurst)
 }
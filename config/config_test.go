@@ -0,0 +1,133 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("写入配置文件失败: %v", err)
+	}
+	t.Setenv("CONFIG_FILE", path)
+	return path
+}
+
+func TestLoadYAMLFile(t *testing.T) {
+	writeConfigFile(t, "config.yaml", `
+server:
+  port: 9090
+rate_limit:
+  rps: 5
+  burst: 10
+cors_allow_origins:
+  - https://a.example
+  - https://b.example
+max_content_size: 2048
+expiry_sweep_interval: 30s
+`)
+	t.Setenv("RATE_LIMIT_BURST", "20")
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+
+	if cfg.ServerPort != "9090" || cfg.RateLimitRPS != 5 || cfg.MaxContentSize != 2048 {
+		t.Errorf("应使用配置文件中的值，实际得到 %s %d %d", cfg.ServerPort, cfg.RateLimitRPS, cfg.MaxContentSize)
+	}
+	if cfg.RateLimitBurst != 20 {
+		t.Errorf("环境变量应覆盖配置文件，实际得到 %d", cfg.RateLimitBurst)
+	}
+	if !reflect.DeepEqual(cfg.CORSAllowOrigins, []string{"https://a.example", "https://b.example"}) {
+		t.Errorf("列表应解析为多个值，实际得到 %v", cfg.CORSAllowOrigins)
+	}
+	if cfg.ExpirySweepInterval != 30*time.Second {
+		t.Errorf("时长应被解析，实际得到 %s", cfg.ExpirySweepInterval)
+	}
+	if cfg.JWTExpireHour != 24*7 {
+		t.Errorf("未设置的值应使用默认值，实际得到 %d", cfg.JWTExpireHour)
+	}
+}
+
+func TestLoadTOMLFile(t *testing.T) {
+	writeConfigFile(t, "config.toml", `
+log_level = "debug"
+tracing_sample_ratio = 0.25
+
+[backup]
+keep = 3
+`)
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+	if cfg.LogLevel != "debug" || cfg.TracingSampleRatio != 0.25 || cfg.BackupKeep != 3 {
+		t.Errorf("应使用 TOML 配置文件中的值，实际得到 %s %g %d", cfg.LogLevel, cfg.TracingSampleRatio, cfg.BackupKeep)
+	}
+}
+
+func TestLoadRejectsBadFiles(t *testing.T) {
+	writeConfigFile(t, "config.yaml", "rate_limt_rps: 5\n")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("未知的配置项应返回错误")
+	}
+
+	writeConfigFile(t, "config.yaml", "rate_limit_rps: 5x\n")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("无法解析的值应返回错误，而不是使用默认值")
+	}
+
+	writeConfigFile(t, "config.json", "{}")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("不支持的文件格式应返回错误")
+	}
+
+	writeConfigFile(t, "config.yaml", "server: [")
+	if _, err := LoadConfig(); err == nil {
+		t.Error("无法解析的文件应返回错误")
+	}
+}
+
+func TestReload(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "rate_limit_rps: 5\nserver_port: 9090\n")
+	if _, err := LoadConfig(); err != nil {
+		t.Fatalf("加载配置失败: %v", err)
+	}
+
+	var notified *Config
+	OnReload(func(cfg *Config) { notified = cfg })
+
+	if err := os.WriteFile(path, []byte("rate_limit_rps: 50\nserver_port: 9091\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	ignored, err := Reload()
+	if err != nil {
+		t.Fatalf("重新加载失败: %v", err)
+	}
+
+	cfg := GetConfig()
+	if cfg.RateLimitRPS != 50 || notified != cfg {
+		t.Errorf("可重新加载的配置应生效并通知监听者，实际得到 %d", cfg.RateLimitRPS)
+	}
+	if cfg.ServerPort != "9090" || !reflect.DeepEqual(ignored, []string{"ServerPort"}) {
+		t.Errorf("需要重启的配置应保持不变，实际得到 %s %v", cfg.ServerPort, ignored)
+	}
+
+	// An invalid file is rejected and the current configuration kept
+	if err := os.WriteFile(path, []byte("rate_limit_rps: 1\ncleanup_days: 0\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Reload(); err == nil {
+		t.Error("无效的配置不应被应用")
+	}
+	if GetConfig() != cfg {
+		t.Error("重新加载失败时应保留当前配置")
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up in the environment, then in the config file
+type source struct {
+	file      map[string]string
+	used      map[string]bool
+	malformed []string
+}
+
+func newSource(file map[string]string) *source {
+	return &source{file: file, used: make(map[string]bool)}
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	s.used[key] = true
+	if value, exists := os.LookupEnv(key); exists {
+		return value, true
+	}
+	value, exists := s.file[key]
+	return value, exists
+}
+
+// reject records a set value which couldn't be parsed. Empty values are
+// treated as unset.
+func (s *source) reject(key, value string) {
+	if value != "" {
+		s.malformed = append(s.malformed, key)
+	}
+}
+
+// unknownKeys returns the keys of the config file which aren't settings
+func (s *source) unknownKeys() []string {
+	var unknown []string
+	for key := range s.file {
+		if !s.used[key] {
+			unknown = append(unknown, strings.ToLower(key))
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// readFile reads a YAML or TOML config file, chosen by extension, into values
+// keyed by environment variable name. Nested tables are joined with
+// underscores, so both `rate_limit_rps: 100` and `rate_limit: {rps: 100}`
+// set RATE_LIMIT_RPS. Lists are joined with commas.
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %v", err)
+	}
+
+	var doc map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	case ".toml":
+		err = toml.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flatten("", doc, values); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %v", path, err)
+	}
+	return values, nil
+}
+
+func flatten(prefix string, value interface{}, values map[string]string) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			name := strings.ToUpper(key)
+			if prefix != "" {
+				name = prefix + "_" + name
+			}
+			if err := flatten(name, field, values); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := scalar(prefix, item)
+			if err != nil {
+				return err
+			}
+			items = append(items, s)
+		}
+		values[prefix] = strings.Join(items, ",")
+		return nil
+	default:
+		s, err := scalar(prefix, v)
+		if err != nil {
+			return err
+		}
+		values[prefix] = s
+		return nil
+	}
+}
+
+func scalar(key string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf("unsupported value for %s", strings.ToLower(key))
+	}
+}
@@ -0,0 +1,128 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// reloadable settings applied by Reload; the others need a restart
+var reloadable = map[string]bool{
+	"CORSAllowOrigins": true,
+	"CORSAllowMethods": true,
+	"CORSAllowHeaders": true,
+	"LogLevel":         true,
+	"JWTExpireHour":    true,
+	"MaxContentSize":   true,
+	"UploadMaxSize":    true,
+	"CleanupDays":      true,
+	"RateLimitRPS":     true,
+	"RateLimitBurst":   true,
+	"AdminToken":       true,
+
+	"WebhookTimeout":     true,
+	"WebhookMaxAttempts": true,
+	"WebhookRetryBase":   true,
+}
+
+var (
+	reloadMu  sync.Mutex
+	listeners []func(*Config)
+)
+
+// OnReload registers fn to be called with the new configuration after each
+// reload, for components which copy settings when they are created
+func OnReload(fn func(*Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+// Reload loads the configuration again and, when it is valid, replaces the
+// current one. Changed settings that need a restart keep their current
+// values and are returned.
+func Reload() ([]string, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	next, err := load()
+	if err != nil {
+		return nil, err
+	}
+	if err := next.Validate(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %v", err)
+	}
+
+	config, ignored := GetConfig().merge(next)
+	current.Store(config)
+	for _, fn := range listeners {
+		fn(config)
+	}
+
+	if len(ignored) > 0 {
+		slog.Warn("部分配置需要重启才能生效", "settings", ignored)
+	}
+	slog.Info("配置已重新加载", "file", config.File)
+	return ignored, nil
+}
+
+// merge returns c with the reloadable settings of next, and the names of the
+// other settings which differ
+func (c *Config) merge(next *Config) (*Config, []string) {
+	merged := *c
+	var ignored []string
+
+	to := reflect.ValueOf(&merged).Elem()
+	from := reflect.ValueOf(next).Elem()
+	for i := 0; i < to.NumField(); i++ {
+		name := to.Type().Field(i).Name
+		if reflect.DeepEqual(to.Field(i).Interface(), from.Field(i).Interface()) {
+			continue
+		}
+		if reloadable[name] {
+			to.Field(i).Set(from.Field(i))
+		} else {
+			ignored = append(ignored, name)
+		}
+	}
+	return &merged, ignored
+}
+
+// Watch reloads the configuration whenever the modification time or size of
+// the config file changes, checking every interval until ctx is done
+func Watch(ctx context.Context, interval time.Duration) {
+	path := GetConfig().File
+	if path == "" || interval <= 0 {
+		return
+	}
+
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			slog.Warn("无法读取配置文件", "file", path, "error", err)
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+
+		if _, err := Reload(); err != nil {
+			slog.Error("重新加载配置失败，继续使用当前配置", "file", path, "error", err)
+		}
+	}
+}
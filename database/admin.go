@@ -0,0 +1,90 @@
+package database
+
+import (
+	"clipboard-server/models"
+	"clipboard-server/utils"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// CreateUser 创建一个新用户（供管理命令使用）
+func CreateUser(username, email, password string) (*models.User, error) {
+	if err := utils.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+	if !utils.ValidateEmail(email) {
+		return nil, fmt.Errorf("invalid email format")
+	}
+	if err := utils.ValidatePassword(password); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	DB.Model(&models.User{}).Where("username = ? OR email = ?", username, email).Count(&count)
+	if count > 0 {
+		return nil, fmt.Errorf("username or email already exists")
+	}
+
+	salt, err := utils.GenerateSalt()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %v", err)
+	}
+
+	hashedPassword, err := utils.HashPasswordWithSalt(password, salt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %v", err)
+	}
+
+	user := models.User{
+		Username: username,
+		Email:    email,
+		Password: hashedPassword,
+		Salt:     salt,
+		IsActive: true,
+	}
+	if err := DB.Create(&user).Error; err != nil {
+		return nil, fmt.Errorf("failed to create user: %v", err)
+	}
+
+	return &user, nil
+}
+
+// ListUsers 列出所有用户，按创建时间排序
+func ListUsers() ([]models.User, error) {
+	var users []models.User
+	if err := DB.Order("created_at ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("failed to list users: %v", err)
+	}
+	return users, nil
+}
+
+// FindUser 按用户名查找用户
+func FindUser(username string) (*models.User, error) {
+	var user models.User
+	if err := DB.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("user not found: %s", username)
+		}
+		return nil, fmt.Errorf("failed to find user: %v", err)
+	}
+	return &user, nil
+}
+
+// SetUserActive 启用或禁用用户，禁用时同时清除其令牌
+func SetUserActive(username string, active bool) error {
+	updates := map[string]interface{}{"is_active": active}
+	if !active {
+		updates["token"] = ""
+	}
+
+	result := DB.Model(&models.User{}).Where("username = ?", username).Updates(updates)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update user: %v", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("user not found: %s", username)
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package database
+
+import (
+	"clipboard-server/config"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	backupPrefix     = "backup-"
+	backupTimeFormat = "20060102-150405.000"
+	backupExt        = ".db"
+	compressedExt    = ".db.gz"
+)
+
+// backupMu serializes scheduled and manually triggered backups
+var backupMu sync.Mutex
+
+// BackupOptions where backups are written and how many are kept
+type BackupOptions struct {
+	Dir      string
+	Keep     int  // backups kept after rotation, 0 keeps all
+	Compress bool // gzip the backup file
+}
+
+// ConfiguredBackupOptions returns the backup options of the configuration
+func ConfiguredBackupOptions(cfg *config.Config) BackupOptions {
+	return BackupOptions{
+		Dir:      cfg.BackupDir,
+		Keep:     cfg.BackupKeep,
+		Compress: cfg.BackupCompress,
+	}
+}
+
+// BackupInfo a backup file in the backup directory
+type BackupInfo struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"-"`
+	Size       int64     `json:"size"`
+	Compressed bool      `json:"compressed"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Backup 使用 VACUUM INTO 将数据库在线备份到指定文件并校验（仅 SQLite）
+func Backup(dest string) error {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if err := vacuumInto(dest); err != nil {
+		return err
+	}
+	if err := VerifyBackup(dest); err != nil {
+		os.Remove(dest)
+		return err
+	}
+
+	fmt.Printf("Database backed up to: %s\n", dest)
+	return nil
+}
+
+// CreateBackup writes a verified online backup to the backup directory and
+// removes the oldest backups beyond opts.Keep
+func CreateBackup(opts BackupOptions) (BackupInfo, error) {
+	backupMu.Lock()
+	defer backupMu.Unlock()
+
+	if err := os.MkdirAll(opts.Dir, 0755); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	now := time.Now()
+	name := backupPrefix + now.UTC().Format(backupTimeFormat)
+	path := filepath.Join(opts.Dir, name+backupExt)
+
+	// The backup is written under a temporary name so that an interrupted
+	// backup is never mistaken for a complete one
+	tmp := path + ".tmp"
+	if err := vacuumInto(tmp); err != nil {
+		return BackupInfo{}, err
+	}
+	defer os.Remove(tmp)
+
+	if err := VerifyBackup(tmp); err != nil {
+		return BackupInfo{}, err
+	}
+
+	if opts.Compress {
+		path = filepath.Join(opts.Dir, name+compressedExt)
+		if err := compressFile(tmp, path); err != nil {
+			return BackupInfo{}, fmt.Errorf("failed to compress backup: %v", err)
+		}
+	} else if err := os.Rename(tmp, path); err != nil {
+		return BackupInfo{}, fmt.Errorf("failed to write backup: %v", err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	info := BackupInfo{
+		Name:       filepath.Base(path),
+		Path:       path,
+		Size:       stat.Size(),
+		Compressed: opts.Compress,
+		CreatedAt:  now,
+	}
+
+	if _, err := RotateBackups(opts.Dir, opts.Keep); err != nil {
+		return info, err
+	}
+	return info, nil
+}
+
+// ListBackups returns the backups in dir, newest first
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []BackupInfo
+	for _, entry := range entries {
+		info, ok := parseBackupName(entry.Name())
+		if !ok || entry.IsDir() {
+			continue
+		}
+		stat, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		info.Path = filepath.Join(dir, entry.Name())
+		info.Size = stat.Size()
+		backups = append(backups, info)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// RotateBackups removes the oldest backups in dir so that keep remain
+func RotateBackups(dir string, keep int) ([]BackupInfo, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil || len(backups) <= keep {
+		return nil, err
+	}
+
+	removed := backups[keep:]
+	for _, backup := range removed {
+		if err := os.Remove(backup.Path); err != nil {
+			return nil, fmt.Errorf("failed to remove old backup: %v", err)
+		}
+	}
+	return removed, nil
+}
+
+// BackupAt returns the newest backup in dir taken at or before t
+func BackupAt(dir string, t time.Time) (BackupInfo, error) {
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	for _, backup := range backups {
+		if !backup.CreatedAt.After(t) {
+			return backup, nil
+		}
+	}
+	return BackupInfo{}, fmt.Errorf("no backup taken at or before %s in %s", t.Format(time.RFC3339), dir)
+}
+
+// VerifyBackup checks a backup file, compressed or not, with PRAGMA integrity_check
+func VerifyBackup(path string) error {
+	if strings.HasSuffix(path, ".gz") {
+		tmp, err := decompressToTemp(path)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		path = tmp
+	}
+
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return fmt.Errorf("failed to open backup: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	defer sqlDB.Close()
+
+	var results []string
+	if err := db.Raw("PRAGMA integrity_check").Scan(&results).Error; err != nil {
+		return fmt.Errorf("failed to check backup integrity: %v", err)
+	}
+	if len(results) != 1 || results[0] != "ok" {
+		return fmt.Errorf("backup integrity check failed: %s", strings.Join(results, "; "))
+	}
+	return nil
+}
+
+// RestoreBackup replaces the SQLite database at dest with a verified backup.
+// The current database is kept next to it as <dest>.pre-restore-<time>.
+// The server must not be running while the database is restored.
+func RestoreBackup(src, dest string) (string, error) {
+	if err := VerifyBackup(src); err != nil {
+		return "", err
+	}
+
+	tmp := dest + ".restore.tmp"
+	var err error
+	if strings.HasSuffix(src, ".gz") {
+		err = decompressFile(src, tmp)
+	} else {
+		err = copyFile(src, tmp)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to copy backup: %v", err)
+	}
+
+	var previous string
+	if _, err := os.Stat(dest); err == nil {
+		previous = fmt.Sprintf("%s.pre-restore-%s", dest, time.Now().UTC().Format(backupTimeFormat))
+		if err := os.Rename(dest, previous); err != nil {
+			os.Remove(tmp)
+			return "", fmt.Errorf("failed to keep the current database: %v", err)
+		}
+	}
+
+	// WAL files belong to the replaced database and must not be applied to the
+	// backup. The WAL moves with the kept copy, it may hold committed changes.
+	if previous != "" {
+		if err := os.Rename(dest+"-wal", previous+"-wal"); err != nil && !os.IsNotExist(err) {
+			return previous, err
+		}
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dest + suffix); err != nil && !os.IsNotExist(err) {
+			return previous, err
+		}
+	}
+
+	if err := os.Rename(tmp, dest); err != nil {
+		return previous, fmt.Errorf("failed to restore database: %v", err)
+	}
+	return previous, nil
+}
+
+// DatabaseInUse reports whether the SQLite database at path seems to be open,
+// judging by the shared memory file SQLite keeps while connections are open in WAL mode
+func DatabaseInUse(path string) bool {
+	_, err := os.Stat(path + "-shm")
+	return err == nil
+}
+
+// vacuumInto writes a consistent copy of the live database to dest
+func vacuumInto(dest string) error {
+	if Driver() != DriverSQLite {
+		return fmt.Errorf("online backup is only supported for SQLite, use pg_dump or mysqldump for %s", Driver())
+	}
+	if err := DB.Exec("VACUUM INTO ?", dest).Error; err != nil {
+		return fmt.Errorf("failed to backup database: %v", err)
+	}
+	return nil
+}
+
+// parseBackupName recognizes the file names written by CreateBackup
+func parseBackupName(name string) (BackupInfo, bool) {
+	info := BackupInfo{Name: name}
+
+	stamp := strings.TrimPrefix(name, backupPrefix)
+	switch {
+	case stamp == name:
+		return info, false
+	case strings.HasSuffix(stamp, compressedExt):
+		stamp = strings.TrimSuffix(stamp, compressedExt)
+		info.Compressed = true
+	case strings.HasSuffix(stamp, backupExt):
+		stamp = strings.TrimSuffix(stamp, backupExt)
+	default:
+		return info, false
+	}
+
+	createdAt, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return info, false
+	}
+	info.CreatedAt = createdAt
+	return info, true
+}
+
+func compressFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if closeErr := gz.Close(); err == nil {
+		err = closeErr
+	}
+	if syncErr := out.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dest)
+	}
+	return err
+}
+
+func decompressFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	gz, err := gzip.NewReader(in)
+	if err != nil {
+		return fmt.Errorf("invalid gzip file: %v", err)
+	}
+	defer gz.Close()
+
+	return writeFile(dest, gz)
+}
+
+func decompressToTemp(src string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(src), ".verify-*.db")
+	if err != nil {
+		return "", err
+	}
+	tmp.Close()
+
+	if err := decompressFile(src, tmp.Name()); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return tmp.Name(), nil
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	return writeFile(dest, in)
+}
+
+// writeFile writes r to dest and syncs it to disk
+func writeFile(dest string, r io.Reader) error {
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, r)
+	if syncErr := out.Sync(); err == nil {
+		err = syncErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
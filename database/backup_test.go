@@ -0,0 +1,84 @@
+package database_test
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/database/dbtest"
+	"clipboard-server/models"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestBackupRotateAndRestore(t *testing.T) {
+	database.DB = dbtest.Open(t)
+	if database.Driver() != database.DriverSQLite {
+		t.Skip("在线备份仅支持 SQLite")
+	}
+	database.DB.Create(&models.ClipboardItem{UserID: "u", Content: "backed up"})
+
+	dir := t.TempDir()
+	opts := database.BackupOptions{Dir: dir, Keep: 2}
+	var created []database.BackupInfo
+	for i := 0; i < 3; i++ {
+		opts.Compress = i == 1
+		backup, err := database.CreateBackup(opts)
+		if err != nil {
+			t.Fatalf("备份失败: %v", err)
+		}
+		created = append(created, backup)
+		time.Sleep(2 * time.Millisecond)
+	}
+
+	backups, _ := database.ListBackups(dir)
+	if len(backups) != 2 || backups[0].Name != created[2].Name || !backups[1].Compressed {
+		t.Fatalf("轮换后应保留最新的 2 个备份，实际得到 %+v", backups)
+	}
+	if _, err := os.Stat(created[0].Path); !os.IsNotExist(err) {
+		t.Error("最旧的备份应被删除")
+	}
+
+	if backup, err := database.BackupAt(dir, created[1].CreatedAt); err != nil || backup.Name != created[1].Name {
+		t.Errorf("应选择指定时间之前最新的备份，实际得到 %q, 错误: %v", backup.Name, err)
+	}
+	if _, err := database.BackupAt(dir, created[0].CreatedAt); err == nil {
+		t.Error("没有更早的备份时应返回错误")
+	}
+
+	// 从压缩备份恢复，原数据库保留为 pre-restore 副本
+	dest := filepath.Join(t.TempDir(), "clipboard.db")
+	os.WriteFile(dest, []byte("current"), 0644)
+	previous, err := database.RestoreBackup(backups[1].Path, dest)
+	if err != nil || previous == "" {
+		t.Fatalf("恢复失败: %v", err)
+	}
+	if data, _ := os.ReadFile(previous); string(data) != "current" {
+		t.Errorf("原数据库应保留在 %s", previous)
+	}
+
+	restored, err := gorm.Open(sqlite.Open(dest), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("打开恢复的数据库失败: %v", err)
+	}
+	sqlDB, _ := restored.DB()
+	defer sqlDB.Close()
+	var item models.ClipboardItem
+	if err := restored.First(&item).Error; err != nil || item.Content != "backed up" {
+		t.Errorf("恢复的数据库应包含备份的项目，实际得到 %q, 错误: %v", item.Content, err)
+	}
+}
+
+func TestVerifyBackupRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup-corrupt.db")
+	os.WriteFile(path, []byte("not a database"), 0644)
+
+	if err := database.VerifyBackup(path); err == nil {
+		t.Error("损坏的备份应校验失败")
+	}
+	if _, err := database.RestoreBackup(path, filepath.Join(t.TempDir(), "clipboard.db")); err == nil {
+		t.Error("不应从损坏的备份恢复")
+	}
+}
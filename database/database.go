@@ -1,72 +1,113 @@
 package database
 
 import (
+	"context"
 	"fmt"
-	"os"
-	"path/filepath"
+	"log/slog"
+	"strings"
+	"time"
 
+	"clipboard-server/config"
 	"clipboard-server/models"
+	"clipboard-server/tracing"
 
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 )
 
 var DB *gorm.DB
 
+// Initialize connects to the database and applies pending migrations,
+// unless DB_AUTO_MIGRATE is disabled
 func Initialize() error {
-	var err error
+	if err := Connect(); err != nil {
+		return err
+	}
 
-	dbPath := os.Getenv("DB_PATH")
-	if dbPath == "" {
-		dbPath = "data/clipboard.db"
+	if !config.GetConfig().DBAutoMigrate {
+		if pending, err := PendingMigrations(DB); err != nil {
+			return err
+		} else if pending > 0 {
+			slog.Warn("有待执行的迁移，请运行 db migrate", "pending", pending)
+		}
+		return nil
 	}
 
-	// 从数据库路径中提取目录路径并创建目录
-	dbDir := filepath.Dir(dbPath)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return fmt.Errorf("failed to create database directory %s: %v", dbDir, err)
+	if err := Migrate(DB); err != nil {
+		return fmt.Errorf("failed to migrate database: %v", err)
+	}
+
+	fmt.Printf("Database initialized successfully (%s)\n", Driver())
+	return nil
+}
+
+// Connect opens the configured database without migrating it
+func Connect() error {
+	cfg := config.GetConfig()
+
+	dsn := cfg.DBDSN
+	if cfg.DBDriver == DriverSQLite && dsn == "" {
+		dsn = cfg.DBPath
 	}
 
-	fmt.Printf("Created/verified database directory: %s\n", dbDir)
+	dialector, err := Dialector(cfg.DBDriver, dsn)
+	if err != nil {
+		return err
+	}
 
 	logLevel := logger.Silent
-	if os.Getenv("DB_DEBUG") == "true" {
+	if cfg.DBDebug {
 		logLevel = logger.Info
 	}
 
-	DB, err = gorm.Open(sqlite.Open(dbPath), &gorm.Config{
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logLevel),
 	})
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %v", err)
 	}
 
-	DB.Exec("PRAGMA journal_mode = WAL;")
-	DB.Exec("PRAGMA synchronous = NORMAL;")
-	DB.Exec("PRAGMA cache_size = 1000;")
-	DB.Exec("PRAGMA foreign_keys = ON;")
-	DB.Exec("PRAGMA temp_store = memory;")
-
-	if err := autoMigrate(); err != nil {
-		return fmt.Errorf("failed to migrate database: %v", err)
+	if cfg.TracingEnabled {
+		if err := DB.Use(tracing.GormPlugin()); err != nil {
+			return fmt.Errorf("failed to enable query tracing: %v", err)
+		}
 	}
 
-	fmt.Printf("Database initialized successfully at: %s\n", dbPath)
+	if Driver() == DriverSQLite {
+		DB.Exec("PRAGMA journal_mode = WAL;")
+		DB.Exec("PRAGMA synchronous = NORMAL;")
+		DB.Exec("PRAGMA cache_size = 1000;")
+		DB.Exec("PRAGMA foreign_keys = ON;")
+		DB.Exec("PRAGMA temp_store = memory;")
+	}
 	return nil
 }
 
-func autoMigrate() error {
-	return DB.AutoMigrate(
-		&models.User{},
-		&models.ClipboardItem{},
-	)
-}
-
 func GetDB() *gorm.DB {
 	return DB
 }
 
+// NotExpired scope excludes clipboard items whose expiry time has passed
+func NotExpired(db *gorm.DB) *gorm.DB {
+	return db.Where("expires_at IS NULL OR expires_at > ?", time.Now())
+}
+
+// Personal scope selects the records of a user's own clipboard, excluding the
+// items they posted to channels. It applies to clipboard items, deletion
+// records and sync conflicts.
+func Personal(userID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ? AND channel_id = ?", userID, "")
+	}
+}
+
+// InChannel scope selects the records of a channel clipboard, posted by any member
+func InChannel(channelID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("channel_id = ?", channelID)
+	}
+}
+
 func Close() error {
 	if DB != nil {
 		sqlDB, err := DB.DB()
@@ -78,7 +119,8 @@ func Close() error {
 	return nil
 }
 
-func HealthCheck() error {
+// HealthCheck pings the database
+func HealthCheck(ctx context.Context) error {
 	if DB == nil {
 		return fmt.Errorf("database connection is nil")
 	}
@@ -88,7 +130,7 @@ func HealthCheck() error {
 		return fmt.Errorf("failed to get underlying sql.DB: %v", err)
 	}
 
-	if err := sqlDB.Ping(); err != nil {
+	if err := sqlDB.PingContext(ctx); err != nil {
 		return fmt.Errorf("failed to ping database: %v", err)
 	}
 
@@ -126,44 +168,47 @@ func GetStats() map[string]interface{} {
 	return stats
 }
 
-func CreateIndexes() error {
-	indexes := []string{
-		"CREATE INDEX IF NOT EXISTS idx_clipboard_items_user_timestamp ON clipboard_items(user_id, timestamp DESC);",
-		"CREATE INDEX IF NOT EXISTS idx_clipboard_items_type ON clipboard_items(type);",
-		"CREATE INDEX IF NOT EXISTS idx_clipboard_items_content ON clipboard_items(content);",
-		"CREATE INDEX IF NOT EXISTS idx_users_username ON users(username);",
-		"CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);",
-		"CREATE INDEX IF NOT EXISTS idx_users_active ON users(is_active);",
-	}
-
-	for _, index := range indexes {
-		if err := DB.Exec(index).Error; err != nil {
-			return fmt.Errorf("failed to create index: %v", err)
-		}
-	}
-
-	fmt.Println("Database indexes created successfully")
-	return nil
-}
-
 func Cleanup(daysOld int) error {
 	if daysOld <= 0 {
 		return fmt.Errorf("daysOld must be greater than 0")
 	}
 
-	result := DB.Where("created_at < datetime('now', '-' || ? || ' days')",
-		daysOld).Delete(&models.ClipboardItem{})
+	cutoff := time.Now().AddDate(0, 0, -daysOld)
+	result := DB.Where("created_at < ?", cutoff).Delete(&models.ClipboardItem{})
 
 	if result.Error != nil {
 		return fmt.Errorf("failed to cleanup old clipboard items: %v", result.Error)
 	}
 
 	fmt.Printf("Cleaned up %d old clipboard items\n", result.RowsAffected)
+
+	result = DB.Where("created_at < ?", cutoff).Delete(&models.SyncConflict{})
+
+	if result.Error != nil {
+		return fmt.Errorf("failed to cleanup old sync conflicts: %v", result.Error)
+	}
 	return nil
 }
 
+// Vacuum reclaims unused space with the maintenance command of the driver
 func Vacuum() error {
-	if err := DB.Exec("VACUUM").Error; err != nil {
+	var err error
+	switch Driver() {
+	case DriverPostgres:
+		err = DB.Exec("VACUUM ANALYZE").Error
+	case DriverMySQL:
+		var tables []string
+		if tables, err = DB.Migrator().GetTables(); err == nil && len(tables) > 0 {
+			quoted := make([]string, len(tables))
+			for i, table := range tables {
+				quoted[i] = DB.Statement.Quote(table)
+			}
+			err = DB.Exec("OPTIMIZE TABLE " + strings.Join(quoted, ", ")).Error
+		}
+	default:
+		err = DB.Exec("VACUUM").Error
+	}
+	if err != nil {
 		return fmt.Errorf("failed to vacuum database: %v", err)
 	}
 
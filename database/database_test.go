@@ -0,0 +1,248 @@
+package database_test
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/database/dbtest"
+	"clipboard-server/models"
+	"errors"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func TestMigrations(t *testing.T) {
+	db := dbtest.Open(t)
+
+	// dbtest 已应用全部迁移，重复执行不应有变化
+	if applied, err := database.MigrateTo(db, 0); err != nil || len(applied) != 0 {
+		t.Fatalf("期望没有待执行的迁移，实际应用 %d 个, 错误: %v", len(applied), err)
+	}
+	if !db.Migrator().HasIndex("clipboard_items", "idx_clipboard_items_user_timestamp") {
+		t.Error("缺少 idx_clipboard_items_user_timestamp 索引")
+	}
+
+	reverted, err := database.Rollback(db, 6)
+	if err != nil || len(reverted) != 6 || reverted[0].Version != 9 || reverted[5].Version != 4 {
+		t.Fatalf("期望回滚迁移 9 到 4，实际得到 %+v, 错误: %v", reverted, err)
+	}
+	if db.Migrator().HasColumn("clipboard_items", "encrypted_at_rest") || db.Migrator().HasColumn("webhook_deliveries", "encrypted_at_rest") {
+		t.Error("回滚后 encrypted_at_rest 列应被删除")
+	}
+	if db.Migrator().HasColumn("clipboard_items", "content_size") {
+		t.Error("回滚后 content_size 列应被删除")
+	}
+	if db.Migrator().HasColumn("clipboard_items", "subtype") || db.Migrator().HasColumn("clipboard_items", "mime_type") {
+		t.Error("回滚后 subtype 和 mime_type 列应被删除")
+	}
+	if db.Migrator().HasTable("webhooks") || db.Migrator().HasTable("webhook_deliveries") {
+		t.Error("回滚后 webhook 表应被删除")
+	}
+	if db.Migrator().HasTable("share_links") {
+		t.Error("回滚后分享链接表应被删除")
+	}
+	if db.Migrator().HasTable("channels") || db.Migrator().HasColumn("clipboard_items", "channel_id") {
+		t.Error("回滚后频道表和 channel_id 列应被删除")
+	}
+
+	reverted, err = database.Rollback(db, 2)
+	if err != nil || len(reverted) != 2 || reverted[0].Version != 3 || reverted[1].Version != 2 {
+		t.Fatalf("期望按倒序回滚迁移 3 和 2，实际得到 %+v, 错误: %v", reverted, err)
+	}
+	if db.Migrator().HasIndex("clipboard_items", "idx_clipboard_items_user_timestamp") {
+		t.Error("回滚后索引应被删除")
+	}
+	if pending, _ := database.PendingMigrations(db); pending != 8 {
+		t.Errorf("期望 8 个待执行的迁移，实际得到 %d", pending)
+	}
+
+	if applied, err := database.MigrateTo(db, 2); err != nil || len(applied) != 1 {
+		t.Fatalf("期望只应用迁移 2，实际应用 %d 个, 错误: %v", len(applied), err)
+	}
+
+	// 初始迁移不可回滚，之前的迁移仍会被回滚
+	reverted, err = database.Rollback(db, 10)
+	if !errors.Is(err, database.ErrIrreversible) || len(reverted) != 1 {
+		t.Errorf("期望回滚 1 个迁移后因初始迁移不可回滚而失败，实际得到 %d 个, 错误: %v", len(reverted), err)
+	}
+
+	var locks int64
+	db.Table("schema_migrations_lock").Count(&locks)
+	if locks != 0 {
+		t.Errorf("迁移结束后应释放锁，实际有 %d 个锁", locks)
+	}
+}
+
+// 迁移使用固定的表结构快照，模型新增字段时必须添加对应的迁移
+func TestMigrationsMatchModels(t *testing.T) {
+	db := dbtest.Open(t)
+
+	for _, model := range []interface{}{
+		&models.User{}, &models.ClipboardItem{}, &models.DeviceKey{}, &models.UserDataKey{},
+		&models.SensitivePolicy{}, &models.DeletedItem{}, &models.SyncConflict{}, &models.IdempotencyRecord{},
+		&models.Channel{}, &models.ChannelMember{}, &models.ChannelInvitation{},
+		&models.ShareLink{}, &models.Webhook{}, &models.WebhookDelivery{},
+	} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatalf("解析模型失败: %v", err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !db.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("迁移后 %s 表缺少 %s 列", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+}
+
+func TestBackfillContentHash(t *testing.T) {
+	db := dbtest.Open(t)
+
+	item := models.ClipboardItem{UserID: "u", Content: "hello"}
+	db.Create(&item)
+	expected := item.ContentHash
+
+	db.Model(&item).UpdateColumn("content_hash", "")
+	if _, err := database.Rollback(db, 7); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	var hash string
+	db.Model(&models.ClipboardItem{}).Where("id = ?", item.ID).Pluck("content_hash", &hash)
+	if expected == "" || hash != expected {
+		t.Errorf("期望回填哈希 %q，实际得到 %q", expected, hash)
+	}
+}
+
+func TestBackfillSubtypes(t *testing.T) {
+	db := dbtest.Open(t)
+
+	item := models.ClipboardItem{UserID: "u", Content: "https://example.com/page", Type: models.ClipboardTypeText}
+	encrypted := models.ClipboardItem{UserID: "u", Content: "Y2lwaGVy", Encrypted: true, KeyID: "k1"}
+	db.Create(&item)
+	db.Create(&encrypted)
+
+	if _, err := database.Rollback(db, 3); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	var stored models.ClipboardItem
+	db.First(&stored, "id = ?", item.ID)
+	if stored.Subtype != models.ClipboardSubtypeURL || stored.MimeType != "text/uri-list" {
+		t.Errorf("期望回填子类型 url，实际得到 %q %q", stored.Subtype, stored.MimeType)
+	}
+	var storedEncrypted models.ClipboardItem
+	db.First(&storedEncrypted, "id = ?", encrypted.ID)
+	if storedEncrypted.ID == "" || storedEncrypted.Subtype != "" {
+		t.Errorf("端到端加密项目不应被分类，实际得到 %q", storedEncrypted.Subtype)
+	}
+}
+
+func TestBackfillContentSize(t *testing.T) {
+	db := dbtest.Open(t)
+
+	item := models.ClipboardItem{UserID: "u", Content: "你好"}
+	db.Create(&item)
+	if item.ContentSize != 6 {
+		t.Errorf("保存时应记录内容字节数 6，实际得到 %d", item.ContentSize)
+	}
+
+	if _, err := database.Rollback(db, 2); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	var size int64
+	db.Model(&models.ClipboardItem{}).Where("id = ?", item.ID).Pluck("content_size", &size)
+	if size != 6 {
+		t.Errorf("期望回填内容大小 6，实际得到 %d", size)
+	}
+}
+
+func TestBackfillEncryptedAtRest(t *testing.T) {
+	db := dbtest.Open(t)
+
+	// 迁移前由加密写入的值只能按前缀识别
+	item := models.ClipboardItem{UserID: "u", Content: "plain"}
+	e2ee := models.ClipboardItem{UserID: "u", Content: "Y2lwaGVy", Encrypted: true, KeyID: "k1"}
+	db.Create(&item)
+	db.Create(&e2ee)
+	db.Model(&item).UpdateColumn("content", "enc:v1:c2VhbGVk")
+	db.Model(&e2ee).UpdateColumn("content", "enc:v1:Y2lwaGVy")
+
+	if _, err := database.Rollback(db, 1); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	var flags []bool
+	db.Model(&models.ClipboardItem{}).Where("id IN ?", []string{item.ID, e2ee.ID}).
+		Order("encrypted").Pluck("encrypted_at_rest", &flags)
+	if len(flags) != 2 || !flags[0] || flags[1] {
+		t.Errorf("期望只标记静态加密的项目，实际得到 %v", flags)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	database.DB = dbtest.Open(t)
+
+	old := models.ClipboardItem{UserID: "u", Content: "old"}
+	database.DB.Create(&old)
+	database.DB.Model(&old).UpdateColumn("created_at", time.Now().AddDate(0, 0, -10))
+	database.DB.Create(&models.ClipboardItem{UserID: "u", Content: "new"})
+
+	if err := database.Cleanup(5); err != nil {
+		t.Fatalf("清理失败: %v", err)
+	}
+
+	var contents []string
+	database.DB.Model(&models.ClipboardItem{}).Pluck("content", &contents)
+	if len(contents) != 1 || contents[0] != "new" {
+		t.Errorf("期望只保留新项目，实际得到 %v", contents)
+	}
+}
+
+func TestPurgeShareLinks(t *testing.T) {
+	database.DB = dbtest.Open(t)
+
+	item := models.ClipboardItem{UserID: "u", Content: "shared"}
+	database.DB.Create(&item)
+	past, future := time.Now().Add(-time.Minute), time.Now().Add(time.Hour)
+	database.DB.Create(&models.ShareLink{ItemID: item.ID, UserID: "u", ExpiresAt: &past})
+	kept := models.ShareLink{ItemID: item.ID, UserID: "u", ExpiresAt: &future}
+	database.DB.Create(&kept)
+	database.DB.Create(&models.ShareLink{ItemID: "deleted-item", UserID: "u"})
+
+	if purged, err := database.PurgeShareLinks(time.Now()); err != nil || purged != 2 {
+		t.Fatalf("期望清除过期和项目已删除的 2 个链接，实际清除 %d 个, 错误: %v", purged, err)
+	}
+
+	var ids []string
+	database.DB.Model(&models.ShareLink{}).Pluck("id", &ids)
+	if len(ids) != 1 || ids[0] != kept.ID {
+		t.Errorf("期望只保留未过期的链接，实际得到 %v", ids)
+	}
+}
+
+func TestDateExpr(t *testing.T) {
+	db := dbtest.Open(t)
+
+	timestamp := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+	db.Create(&models.ClipboardItem{UserID: "u", Content: "x", Timestamp: timestamp})
+
+	var date string
+	db.Model(&models.ClipboardItem{}).Select(database.DateExpr(db, "timestamp")).Scan(&date)
+	if date != "2024-03-05" {
+		t.Errorf("期望日期 2024-03-05，实际得到 %q", date)
+	}
+}
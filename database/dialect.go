@@ -0,0 +1,116 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// Supported DB_DRIVER values
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+	DriverMySQL    = "mysql"
+)
+
+// Drivers lists the supported database drivers
+var Drivers = []string{DriverSQLite, DriverPostgres, DriverMySQL}
+
+// Dialector returns the GORM dialector for a driver. For SQLite the DSN is
+// the database file path, its directory is created if needed.
+func Dialector(driver, dsn string) (gorm.Dialector, error) {
+	switch driver {
+	case DriverSQLite, "":
+		if dir := filepath.Dir(dsn); dsn != ":memory:" && !strings.HasPrefix(dsn, "file:") {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				return nil, fmt.Errorf("failed to create database directory %s: %v", dir, err)
+			}
+		}
+		return sqlite.Open(dsn), nil
+
+	case DriverPostgres:
+		return postgres.Open(dsn), nil
+
+	case DriverMySQL:
+		// Timestamps are scanned into time.Time and stored in UTC
+		mysqlCfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mysql DSN: %v", err)
+		}
+		mysqlCfg.ParseTime = true
+		return mysql.Open(mysqlCfg.FormatDSN()), nil
+
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q, expected one of %s", driver, strings.Join(Drivers, ", "))
+	}
+}
+
+// Driver returns the driver of the open database
+func Driver() string {
+	return DB.Dialector.Name()
+}
+
+// DateExpr returns a SQL expression formatting a time column as YYYY-MM-DD
+func DateExpr(db *gorm.DB, column string) string {
+	quoted := db.Statement.Quote(column)
+
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		return fmt.Sprintf("TO_CHAR(%s, 'YYYY-MM-DD')", quoted)
+	case DriverMySQL:
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m-%%d')", quoted)
+	default:
+		return fmt.Sprintf("DATE(%s)", quoted)
+	}
+}
+
+// index a secondary index created after the schema migration
+type index struct {
+	name    string
+	table   string
+	columns []string
+	// drivers limits the index to some drivers, all drivers when empty
+	drivers []string
+}
+
+// secondaryIndexes indexes not declared on the models
+var secondaryIndexes = []index{
+	{name: "idx_clipboard_items_user_timestamp", table: "clipboard_items", columns: []string{"user_id", "timestamp DESC"}},
+	{name: "idx_clipboard_items_type", table: "clipboard_items", columns: []string{"type"}},
+	// Content may exceed the key size limits of Postgres and MySQL, where
+	// lookups use content_hash instead
+	{name: "idx_clipboard_items_content", table: "clipboard_items", columns: []string{"content"}, drivers: []string{DriverSQLite}},
+	{name: "idx_users_username", table: "users", columns: []string{"username"}},
+	{name: "idx_users_email", table: "users", columns: []string{"email"}},
+	{name: "idx_users_active", table: "users", columns: []string{"is_active"}},
+}
+
+func (idx index) supports(driver string) bool {
+	if len(idx.drivers) == 0 {
+		return true
+	}
+	for _, d := range idx.drivers {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
+
+// createSQL builds the CREATE INDEX statement with identifiers quoted for the dialect
+func (idx index) createSQL(db *gorm.DB) string {
+	columns := make([]string, len(idx.columns))
+	for i, column := range idx.columns {
+		name, order, _ := strings.Cut(column, " ")
+		columns[i] = strings.TrimSpace(db.Statement.Quote(name) + " " + order)
+	}
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)",
+		db.Statement.Quote(idx.name), db.Statement.Quote(idx.table), strings.Join(columns, ", "))
+}
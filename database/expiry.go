@@ -0,0 +1,101 @@
+package database
+
+import (
+	"clipboard-server/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize expired items deleted per transaction
+const purgeBatchSize = 500
+
+// DeleteItems deletes clipboard items and records them as deleted so that
+// clients can remove their local copies
+func DeleteItems(tx *gorm.DB, items []models.ClipboardItem, reason string) ([]models.DeletedItem, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	ids := make([]string, len(items))
+	deleted := make([]models.DeletedItem, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+		deleted[i] = models.DeletedItem{
+			ItemID:    item.ID,
+			UserID:    item.UserID,
+			ChannelID: item.ChannelID,
+			ClientID:  item.ClientID,
+			Reason:    reason,
+			DeletedAt: now,
+		}
+	}
+
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ?", ids).Delete(&models.ClipboardItem{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&deleted).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// PurgeExpired deletes clipboard items whose expiry time is before now
+func PurgeExpired(now time.Time) ([]models.DeletedItem, error) {
+	var purged []models.DeletedItem
+	for {
+		var items []models.ClipboardItem
+		if err := DB.Select("id", "user_id", "channel_id", "client_id").
+			Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+			Limit(purgeBatchSize).
+			Find(&items).Error; err != nil {
+			return purged, err
+		}
+
+		deleted, err := DeleteItems(DB, items, models.DeletionReasonExpired)
+		if err != nil {
+			return purged, err
+		}
+		purged = append(purged, deleted...)
+
+		if len(items) < purgeBatchSize {
+			return purged, nil
+		}
+	}
+}
+
+// PurgeDeletions removes deletion records older than before
+func PurgeDeletions(before time.Time) (int64, error) {
+	result := DB.Where("deleted_at < ?", before).Delete(&models.DeletedItem{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeShareLinks removes expired share links and the links of items that no
+// longer exist
+func PurgeShareLinks(now time.Time) (int64, error) {
+	result := DB.Where("(expires_at IS NOT NULL AND expires_at <= ?) OR item_id NOT IN (?)",
+		now, DB.Model(&models.ClipboardItem{}).Select("id")).
+		Delete(&models.ShareLink{})
+	return result.RowsAffected, result.Error
+}
+
+// PurgeWebhookDeliveries removes finished webhook deliveries created before before
+func PurgeWebhookDeliveries(before time.Time) (int64, error) {
+	result := DB.Where("status <> ? AND created_at < ?", models.WebhookDeliveryPending, before).
+		Delete(&models.WebhookDelivery{})
+	return result.RowsAffected, result.Error
+}
+
+// DeletedSince returns the items selected by scope deleted after since, oldest first
+func DeletedSince(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, since time.Time, limit int) ([]models.DeletedItem, error) {
+	var deleted []models.DeletedItem
+	err := tx.Scopes(scope).Where("deleted_at > ?", since).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&deleted).Error
+	return deleted, err
+}
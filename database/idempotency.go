@@ -0,0 +1,93 @@
+package database
+
+import (
+	"clipboard-server/models"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrIdempotencyKeyReused the key was already used for a different request
+	ErrIdempotencyKeyReused = errors.New("idempotency key was used for a different request")
+	// ErrIdempotencyInProgress a request with the same key is still being processed
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is in progress")
+)
+
+// ReserveIdempotencyKey claims an idempotency key for a request. It returns the
+// stored record when the same request already completed, or nil when the caller
+// now owns the key and must complete or release it.
+func ReserveIdempotencyKey(tx *gorm.DB, userID, key, requestHash string) (*models.IdempotencyRecord, error) {
+	record := models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+	}
+
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var existing models.IdempotencyRecord
+	if err := tx.Where("user_id = ? AND idempotency_key = ?", userID, key).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing.RequestHash != requestHash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if existing.StatusCode == 0 {
+		return nil, ErrIdempotencyInProgress
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey stores the outcome of the request owning the key
+func CompleteIdempotencyKey(tx *gorm.DB, record *models.IdempotencyRecord) error {
+	return tx.Model(&models.IdempotencyRecord{}).
+		Where("user_id = ? AND idempotency_key = ?", record.UserID, record.Key).
+		Select("status_code", "mode", "item_ids", "failed", "total").
+		Updates(record).Error
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so it can be retried
+func ReleaseIdempotencyKey(tx *gorm.DB, userID, key string) error {
+	return tx.Where("user_id = ? AND idempotency_key = ?", userID, key).
+		Delete(&models.IdempotencyRecord{}).Error
+}
+
+// PurgeIdempotencyKeys removes idempotency records created before the given time
+func PurgeIdempotencyKeys(before time.Time) (int64, error) {
+	result := DB.Where("created_at < ?", before).Delete(&models.IdempotencyRecord{})
+	return result.RowsAffected, result.Error
+}
+
+// ItemsByID loads the items selected by scope in the order of ids, skipping deleted ones
+func ItemsByID(tx *gorm.DB, scope func(*gorm.DB) *gorm.DB, ids []string) ([]models.ClipboardItem, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var items []models.ClipboardItem
+	if err := tx.Scopes(scope).Where("id IN ?", ids).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]models.ClipboardItem, len(items))
+	for _, item := range items {
+		byID[item.ID] = item
+	}
+
+	ordered := make([]models.ClipboardItem, 0, len(items))
+	for _, id := range ids {
+		if item, ok := byID[id]; ok {
+			ordered = append(ordered, item)
+		}
+	}
+	return ordered, nil
+}
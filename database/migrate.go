@@ -0,0 +1,314 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration a numbered schema or data migration. Each migration runs in its
+// own transaction together with the update of schema_migrations; MySQL
+// commits DDL statements implicitly, so a failed migration there may need
+// manual cleanup.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	// Down reverts Up, nil when the migration cannot be rolled back
+	Down func(tx *gorm.DB) error
+}
+
+// SchemaMigration a migration applied to the database
+type SchemaMigration struct {
+	Version   int       `gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"size:100;not null"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// TableName specifies table name for SchemaMigration
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// MigrationState the state of a known or applied migration
+type MigrationState struct {
+	Version   int
+	Name      string
+	AppliedAt *time.Time
+	// Unknown is set for applied migrations this binary doesn't know,
+	// written by a newer release
+	Unknown bool
+}
+
+const (
+	// migrationLockName advisory lock held while migrating (MySQL)
+	migrationLockName = "clipboard_server_schema_migrations"
+	// migrationLockID advisory lock key held while migrating (Postgres)
+	migrationLockID = 7384512096
+	// migrationLockTimeout how long to wait for another instance to finish migrating
+	migrationLockTimeout = 5 * time.Minute
+	// migrationLockStale age after which a SQLite lock row is considered abandoned
+	migrationLockStale = 10 * time.Minute
+)
+
+// ErrIrreversible returned when rolling back a migration without Down
+var ErrIrreversible = errors.New("migration cannot be rolled back")
+
+// Migrate applies all pending migrations
+func Migrate(db *gorm.DB) error {
+	_, err := MigrateTo(db, 0)
+	return err
+}
+
+// MigrateTo applies pending migrations up to and including version, or all
+// pending migrations when version is 0. It returns the applied migrations.
+func MigrateTo(db *gorm.DB, version int) ([]Migration, error) {
+	var applied []Migration
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		done, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		if latest := latestVersion(); len(done) > 0 && maxVersion(done) > latest {
+			slog.Warn("数据库版本高于当前程序支持的版本", "version", maxVersion(done), "latest", latest)
+		}
+
+		for _, m := range migrations {
+			if version > 0 && m.Version > version {
+				break
+			}
+			if _, ok := done[m.Version]; ok {
+				continue
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Up(tx); err != nil {
+					return err
+				}
+				return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+			})
+			if err != nil {
+				return fmt.Errorf("migration %d %s failed: %v", m.Version, m.Name, err)
+			}
+			slog.Info("已应用迁移", "migration", fmt.Sprintf("%04d_%s", m.Version, m.Name))
+			applied = append(applied, m)
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Rollback reverts the latest steps applied migrations and returns them
+func Rollback(db *gorm.DB, steps int) ([]Migration, error) {
+	var reverted []Migration
+	err := withMigrationLock(db, func(db *gorm.DB) error {
+		done, err := appliedVersions(db)
+		if err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(done))
+		for v := range done {
+			versions = append(versions, v)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+		if steps < len(versions) {
+			versions = versions[:steps]
+		}
+
+		for _, v := range versions {
+			m, ok := findMigration(v)
+			if !ok {
+				return fmt.Errorf("migration %d was applied by a newer release and is unknown to this binary", v)
+			}
+			if m.Down == nil {
+				return fmt.Errorf("migration %d %s: %w", m.Version, m.Name, ErrIrreversible)
+			}
+
+			err := db.Transaction(func(tx *gorm.DB) error {
+				if err := m.Down(tx); err != nil {
+					return err
+				}
+				return tx.Delete(&SchemaMigration{}, m.Version).Error
+			})
+			if err != nil {
+				return fmt.Errorf("rollback of migration %d %s failed: %v", m.Version, m.Name, err)
+			}
+			slog.Info("已回滚迁移", "migration", fmt.Sprintf("%04d_%s", m.Version, m.Name))
+			reverted = append(reverted, m)
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// MigrationStatus lists known and applied migrations ordered by version
+func MigrationStatus(db *gorm.DB) ([]MigrationState, error) {
+	done, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]MigrationState, 0, len(migrations))
+	for _, m := range migrations {
+		state := MigrationState{Version: m.Version, Name: m.Name}
+		if applied, ok := done[m.Version]; ok {
+			state.AppliedAt = &applied.AppliedAt
+			delete(done, m.Version)
+		}
+		states = append(states, state)
+	}
+	for _, applied := range done {
+		appliedAt := applied.AppliedAt
+		states = append(states, MigrationState{Version: applied.Version, Name: applied.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+
+	sort.Slice(states, func(i, j int) bool { return states[i].Version < states[j].Version })
+	return states, nil
+}
+
+// PendingMigrations counts the known migrations not applied yet
+func PendingMigrations(db *gorm.DB) (int, error) {
+	states, err := MigrationStatus(db)
+	if err != nil {
+		return 0, err
+	}
+
+	pending := 0
+	for _, state := range states {
+		if state.AppliedAt == nil {
+			pending++
+		}
+	}
+	return pending, nil
+}
+
+// appliedVersions returns the applied migrations by version
+func appliedVersions(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+
+	done := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+func latestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+func maxVersion(done map[int]SchemaMigration) int {
+	max := 0
+	for v := range done {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+func findMigration(version int) (Migration, bool) {
+	for _, m := range migrations {
+		if m.Version == version {
+			return m, true
+		}
+	}
+	return Migration{}, false
+}
+
+// schemaMigrationLock lock row used on SQLite, which has no advisory locks
+type schemaMigrationLock struct {
+	ID       int       `gorm:"primaryKey;autoIncrement:false"`
+	Owner    string    `gorm:"size:100"`
+	LockedAt time.Time `gorm:"not null"`
+}
+
+// TableName specifies table name for schemaMigrationLock
+func (schemaMigrationLock) TableName() string {
+	return "schema_migrations_lock"
+}
+
+// withMigrationLock runs fn while holding a lock that keeps concurrently
+// starting instances from migrating at the same time. Postgres and MySQL use
+// session advisory locks, released when the connection closes; fn runs on
+// that connection.
+func withMigrationLock(db *gorm.DB, fn func(db *gorm.DB) error) error {
+	switch db.Dialector.Name() {
+	case DriverPostgres:
+		return db.Connection(func(conn *gorm.DB) error {
+			if err := conn.Exec("SELECT pg_advisory_lock(?)", migrationLockID).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %v", err)
+			}
+			defer conn.Exec("SELECT pg_advisory_unlock(?)", migrationLockID)
+			return fn(conn)
+		})
+
+	case DriverMySQL:
+		return db.Connection(func(conn *gorm.DB) error {
+			var acquired int
+			if err := conn.Raw("SELECT GET_LOCK(?, ?)", migrationLockName, int(migrationLockTimeout.Seconds())).Scan(&acquired).Error; err != nil {
+				return fmt.Errorf("failed to acquire migration lock: %v", err)
+			}
+			if acquired != 1 {
+				return fmt.Errorf("timed out waiting for the migration lock")
+			}
+			defer conn.Exec("SELECT RELEASE_LOCK(?)", migrationLockName)
+			return fn(conn)
+		})
+
+	default:
+		release, err := lockTable(db)
+		if err != nil {
+			return err
+		}
+		defer release()
+		return fn(db)
+	}
+}
+
+// lockTable takes the lock row of schema_migrations_lock, taking over locks
+// abandoned by crashed instances
+func lockTable(db *gorm.DB) (func(), error) {
+	if err := db.AutoMigrate(&schemaMigrationLock{}); err != nil {
+		return nil, fmt.Errorf("failed to create migration lock table: %v", err)
+	}
+
+	hostname, _ := os.Hostname()
+	owner := fmt.Sprintf("%s:%d:%d", hostname, os.Getpid(), time.Now().UnixNano())
+	deadline := time.Now().Add(migrationLockTimeout)
+
+	for {
+		lock := schemaMigrationLock{ID: 1, Owner: owner, LockedAt: time.Now()}
+		result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&lock)
+		if result.Error != nil {
+			return nil, fmt.Errorf("failed to acquire migration lock: %v", result.Error)
+		}
+		if result.RowsAffected == 1 {
+			return func() {
+				db.Where("id = ? AND owner = ?", 1, owner).Delete(&schemaMigrationLock{})
+			}, nil
+		}
+
+		db.Where("id = ? AND locked_at < ?", 1, time.Now().Add(-migrationLockStale)).Delete(&schemaMigrationLock{})
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for the migration lock")
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+}
@@ -0,0 +1,290 @@
+package database
+
+import (
+	"clipboard-server/classify"
+	"clipboard-server/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// migrations in version order. Released migrations must never be edited or
+// renumbered, schema changes are added as a new migration at the end.
+var migrations = []Migration{
+	{
+		// Baseline matching the schema AutoMigrate created in earlier
+		// releases, so existing databases are adopted without changes
+		Version: 1,
+		Name:    "initial_schema",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(
+				&userV1{},
+				&clipboardItemV1{},
+				&deviceKeyV1{},
+				&userDataKeyV1{},
+				&sensitivePolicyV1{},
+				&deletedItemV1{},
+				&syncConflictV1{},
+				&idempotencyRecordV1{},
+			)
+		},
+	},
+	{
+		Version: 2,
+		Name:    "secondary_indexes",
+		Up: func(tx *gorm.DB) error {
+			driver := tx.Dialector.Name()
+			for _, idx := range secondaryIndexes {
+				if !idx.supports(driver) || tx.Migrator().HasIndex(idx.table, idx.name) {
+					continue
+				}
+				if err := tx.Exec(idx.createSQL(tx)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, idx := range secondaryIndexes {
+				if tx.Migrator().HasIndex(idx.table, idx.name) {
+					if err := tx.Migrator().DropIndex(idx.table, idx.name); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Items stored before content hashes were introduced have none and
+		// are not found by deduplication
+		Version: 3,
+		Name:    "backfill_content_hash",
+		Up:      backfillContentHash,
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	},
+	{
+		Version: 4,
+		Name:    "channels",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&channelV4{}, &channelMemberV4{}, &channelInvitationV4{}); err != nil {
+				return err
+			}
+			for _, model := range channelScopedTables {
+				if err := tx.Migrator().AddColumn(model, "ChannelID"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().CreateIndex(model, "ChannelID"); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, model := range channelScopedTables {
+				if tx.Migrator().HasIndex(model, "ChannelID") {
+					if err := tx.Migrator().DropIndex(model, "ChannelID"); err != nil {
+						return err
+					}
+				}
+				if err := tx.Migrator().DropColumn(model, "ChannelID"); err != nil {
+					return err
+				}
+			}
+			return tx.Migrator().DropTable(&channelInvitationV4{}, &channelMemberV4{}, &channelV4{})
+		},
+	},
+	{
+		Version: 5,
+		Name:    "share_links",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&shareLinkV5{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&shareLinkV5{})
+		},
+	},
+	{
+		Version: 6,
+		Name:    "webhooks",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&webhookV6{}, &webhookDeliveryV6{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&webhookDeliveryV6{}, &webhookV6{})
+		},
+	},
+	{
+		// Items stored before classification are classified from their content
+		Version: 7,
+		Name:    "content_subtypes",
+		Up: func(tx *gorm.DB) error {
+			for _, field := range []string{"Subtype", "MimeType"} {
+				if err := tx.Migrator().AddColumn(&clipboardItemV7{}, field); err != nil {
+					return err
+				}
+			}
+			if err := tx.Migrator().CreateIndex(&clipboardItemV7{}, "Subtype"); err != nil {
+				return err
+			}
+			return backfillSubtypes(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			if tx.Migrator().HasIndex(&clipboardItemV7{}, "Subtype") {
+				if err := tx.Migrator().DropIndex(&clipboardItemV7{}, "Subtype"); err != nil {
+					return err
+				}
+			}
+			for _, field := range []string{"MimeType", "Subtype"} {
+				if err := tx.Migrator().DropColumn(&clipboardItemV7{}, field); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		// Statistics sum the plaintext size, the stored content is ciphertext
+		// when encryption at rest is enabled
+		Version: 8,
+		Name:    "content_size",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&clipboardItemV8{}, "ContentSize"); err != nil {
+				return err
+			}
+			return backfillContentSize(tx)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&clipboardItemV8{}, "ContentSize")
+		},
+	},
+	{
+		// Whether a value is encrypted at rest was told from its prefix, which
+		// plaintext content can have too. Values written by the cipher before
+		// this migration are marked from the prefix one last time.
+		Version: 9,
+		Name:    "encrypted_at_rest",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&clipboardItemV9{}, "EncryptedAtRest"); err != nil {
+				return err
+			}
+			if err := tx.Migrator().AddColumn(&webhookDeliveryV9{}, "EncryptedAtRest"); err != nil {
+				return err
+			}
+			if err := tx.Table("clipboard_items").
+				Where("encrypted = ? AND content LIKE ?", false, encryptedAtRestPrefix+"%").
+				UpdateColumn("encrypted_at_rest", true).Error; err != nil {
+				return err
+			}
+			return tx.Table("webhook_deliveries").
+				Where("payload LIKE ?", encryptedAtRestPrefix+"%").
+				UpdateColumn("encrypted_at_rest", true).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := tx.Migrator().DropColumn(&webhookDeliveryV9{}, "EncryptedAtRest"); err != nil {
+				return err
+			}
+			return tx.Migrator().DropColumn(&clipboardItemV9{}, "EncryptedAtRest")
+		},
+	},
+}
+
+// encryptedAtRestPrefix started every value encrypted at rest before migration 9
+const encryptedAtRestPrefix = "enc:v1:"
+
+// channelScopedTables records that belong either to a user or to a channel
+var channelScopedTables = []interface{}{
+	&clipboardItemChannelV4{},
+	&deletedItemChannelV4{},
+	&syncConflictChannelV4{},
+}
+
+// backfillContentHash computes the missing content hashes. Content is read
+// through the model hooks, so it is hashed as plaintext when encryption at
+// rest is enabled.
+func backfillContentHash(tx *gorm.DB) error {
+	decrypt := legacyDecryption(tx)
+	var items []models.ClipboardItem
+	return tx.Where("content_hash = ? OR content_hash IS NULL", "").
+		FindInBatches(&items, 500, func(batch *gorm.DB, _ int) error {
+			for _, item := range items {
+				if err := decrypt(&item); err != nil {
+					return err
+				}
+				hash, err := models.HashContent(tx, item.UserID, item.Content, item.Encrypted)
+				if err != nil {
+					return err
+				}
+				if err := tx.Session(&gorm.Session{NewDB: true}).Model(&models.ClipboardItem{}).
+					Where("id = ?", item.ID).UpdateColumn("content_hash", hash).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// backfillSubtypes classifies the plaintext items without a subtype. Content
+// is read through the model hooks, so it is classified as plaintext when
+// encryption at rest is enabled.
+func backfillSubtypes(tx *gorm.DB) error {
+	decrypt := legacyDecryption(tx)
+	var items []models.ClipboardItem
+	return tx.Where("subtype = ? AND encrypted = ?", "", false).
+		FindInBatches(&items, 500, func(batch *gorm.DB, _ int) error {
+			for _, item := range items {
+				if err := decrypt(&item); err != nil {
+					return err
+				}
+				result := classify.Classify(item.Type, item.Content)
+				if err := tx.Session(&gorm.Session{NewDB: true}).Model(&models.ClipboardItem{}).
+					Where("id = ?", item.ID).UpdateColumns(map[string]interface{}{
+					"subtype":   result.Subtype,
+					"mime_type": result.MimeType,
+				}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// backfillContentSize measures the items stored without a content size.
+// Content is read through the model hooks, so the plaintext is measured when
+// encryption at rest is enabled.
+func backfillContentSize(tx *gorm.DB) error {
+	decrypt := legacyDecryption(tx)
+	var items []models.ClipboardItem
+	return tx.Where("content_size = ? AND content <> ?", 0, "").
+		FindInBatches(&items, 500, func(batch *gorm.DB, _ int) error {
+			for _, item := range items {
+				if err := decrypt(&item); err != nil {
+					return err
+				}
+				if err := tx.Session(&gorm.Session{NewDB: true}).Model(&models.ClipboardItem{}).
+					Where("id = ?", item.ID).UpdateColumn("content_size", len(item.Content)).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+}
+
+// legacyDecryption returns a function decrypting the items loaded by a
+// migration running before migration 9 added the encrypted_at_rest column,
+// when content encrypted at rest was told from its prefix. Once the column
+// exists the model hooks decrypt the items and the function does nothing.
+func legacyDecryption(tx *gorm.DB) func(item *models.ClipboardItem) error {
+	if tx.Migrator().HasColumn(&clipboardItemV9{}, "EncryptedAtRest") {
+		return func(*models.ClipboardItem) error { return nil }
+	}
+	return func(item *models.ClipboardItem) error {
+		if item.Encrypted || !strings.HasPrefix(item.Content, encryptedAtRestPrefix) {
+			return nil
+		}
+		item.EncryptedAtRest = true
+		return item.AfterFind(tx)
+	}
+}
@@ -0,0 +1,249 @@
+package database
+
+import "time"
+
+// Schema snapshots of the tables and columns created by the migrations. Each
+// describes the schema as its migration left it and must never change with
+// the models; a schema change is a new migration with its own snapshot.
+
+// Version 1, the baseline adopted from the AutoMigrate releases
+
+type userV1 struct {
+	ID           string `gorm:"primaryKey"`
+	Username     string `gorm:"uniqueIndex;size:100"`
+	Email        string `gorm:"uniqueIndex;size:255"`
+	Password     string `gorm:"size:255"`
+	Salt         string `gorm:"size:32"`
+	Token        string `gorm:"size:500"`
+	IsActive     bool   `gorm:"default:true"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	E2EERequired bool `gorm:"column:e2ee_required;default:false"`
+
+	ClipboardItems []clipboardItemV1 `gorm:"foreignKey:UserID"`
+}
+
+type clipboardItemV1 struct {
+	ID            string `gorm:"primaryKey"`
+	UserID        string `gorm:"index"`
+	ClientID      string `gorm:"index"`
+	Content       string
+	ContentHash   string     `gorm:"size:64;index"`
+	Type          string     `gorm:"type:varchar(20);default:'text'"`
+	Timestamp     time.Time  `gorm:"index"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime:nano"`
+	UpdatedAt     time.Time  `gorm:"autoUpdateTime:nano"`
+	Version       int64      `gorm:"not null;default:1"`
+	Encrypted     bool       `gorm:"default:false;index"`
+	KeyID         string     `gorm:"size:100;index"`
+	Nonce         string     `gorm:"size:255"`
+	Algorithm     string     `gorm:"size:50"`
+	Sensitive     bool       `gorm:"default:false;index"`
+	SensitiveTags string     `gorm:"size:255"`
+	ExpiresAt     *time.Time `gorm:"index"`
+}
+
+type deviceKeyV1 struct {
+	ID         string `gorm:"primaryKey"`
+	UserID     string `gorm:"index"`
+	DeviceID   string `gorm:"size:100;index"`
+	KeyID      string `gorm:"size:100;index"`
+	WrappedKey string `gorm:"type:text"`
+	Algorithm  string `gorm:"size:50"`
+	Status     string `gorm:"size:20;default:'active'"`
+	RetiredAt  *time.Time
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+type userDataKeyV1 struct {
+	UserID      string `gorm:"primaryKey"`
+	WrappedKey  string `gorm:"type:text"`
+	MasterKeyID string `gorm:"size:32;index"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type sensitivePolicyV1 struct {
+	UserID        string            `gorm:"primaryKey"`
+	DefaultAction string            `gorm:"size:20;default:'tag'"`
+	Actions       map[string]string `gorm:"serializer:json"`
+	ExpireMinutes int               `gorm:"default:10"`
+	UpdatedAt     time.Time
+}
+
+type deletedItemV1 struct {
+	ID        uint   `gorm:"primaryKey"`
+	ItemID    string `gorm:"index"`
+	UserID    string `gorm:"index"`
+	ClientID  string
+	Reason    string    `gorm:"size:20"`
+	DeletedAt time.Time `gorm:"index"`
+}
+
+type syncConflictV1 struct {
+	ID              string `gorm:"primaryKey"`
+	UserID          string `gorm:"index"`
+	ItemID          string `gorm:"index"`
+	ClientID        string
+	Strategy        string `gorm:"size:20"`
+	Resolution      string `gorm:"size:20"`
+	BaseVersion     *int64
+	ServerVersion   int64
+	ServerTimestamp time.Time
+	ClientTimestamp time.Time
+	CreatedAt       time.Time `gorm:"index"`
+}
+
+type idempotencyRecordV1 struct {
+	UserID      string `gorm:"primaryKey;size:36"`
+	Key         string `gorm:"column:idempotency_key;primaryKey;size:255"`
+	RequestHash string `gorm:"size:64"`
+	StatusCode  int
+	Mode        string                   `gorm:"size:20"`
+	ItemIDs     []string                 `gorm:"serializer:json"`
+	Failed      []map[string]interface{} `gorm:"serializer:json"`
+	Total       int
+	CreatedAt   time.Time `gorm:"index"`
+}
+
+func (userV1) TableName() string              { return "users" }
+func (clipboardItemV1) TableName() string     { return "clipboard_items" }
+func (deviceKeyV1) TableName() string         { return "device_keys" }
+func (userDataKeyV1) TableName() string       { return "user_data_keys" }
+func (sensitivePolicyV1) TableName() string   { return "sensitive_policies" }
+func (deletedItemV1) TableName() string       { return "deleted_items" }
+func (syncConflictV1) TableName() string      { return "sync_conflicts" }
+func (idempotencyRecordV1) TableName() string { return "idempotency_records" }
+
+// Version 4, channels
+
+type channelV4 struct {
+	ID          string `gorm:"primaryKey"`
+	Name        string `gorm:"size:100"`
+	Description string `gorm:"size:500"`
+	CreatedBy   string `gorm:"size:36"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+type channelMemberV4 struct {
+	ChannelID string `gorm:"primaryKey;size:36"`
+	UserID    string `gorm:"primaryKey;size:36;index"`
+	Role      string `gorm:"size:20"`
+	CreatedAt time.Time
+}
+
+type channelInvitationV4 struct {
+	ID        string    `gorm:"primaryKey"`
+	ChannelID string    `gorm:"size:36;index"`
+	InviteeID string    `gorm:"size:36;index"`
+	InviterID string    `gorm:"size:36"`
+	Role      string    `gorm:"size:20"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// The channel_id column added to the channel scoped tables
+
+type clipboardItemChannelV4 struct {
+	ChannelID string `gorm:"size:36;not null;default:'';index"`
+}
+
+type deletedItemChannelV4 struct {
+	ChannelID string `gorm:"size:36;not null;default:'';index"`
+}
+
+type syncConflictChannelV4 struct {
+	ChannelID string `gorm:"size:36;not null;default:'';index"`
+}
+
+func (channelV4) TableName() string              { return "channels" }
+func (channelMemberV4) TableName() string        { return "channel_members" }
+func (channelInvitationV4) TableName() string    { return "channel_invitations" }
+func (clipboardItemChannelV4) TableName() string { return "clipboard_items" }
+func (deletedItemChannelV4) TableName() string   { return "deleted_items" }
+func (syncConflictChannelV4) TableName() string  { return "sync_conflicts" }
+
+// Version 5, share links
+
+type shareLinkV5 struct {
+	ID           string     `gorm:"primaryKey"`
+	Token        string     `gorm:"size:64;uniqueIndex"`
+	ItemID       string     `gorm:"size:36;index"`
+	UserID       string     `gorm:"index"`
+	ExpiresAt    *time.Time `gorm:"index"`
+	MaxViews     int        `gorm:"not null;default:0"`
+	Views        int        `gorm:"not null;default:0"`
+	PasswordHash string     `gorm:"size:255"`
+	PasswordSalt string     `gorm:"size:64"`
+	CreatedAt    time.Time
+}
+
+func (shareLinkV5) TableName() string { return "share_links" }
+
+// Version 6, webhooks
+
+type webhookV6 struct {
+	ID           string `gorm:"primaryKey"`
+	UserID       string `gorm:"index"`
+	URL          string `gorm:"size:2048"`
+	Description  string `gorm:"size:255"`
+	Secret       string `gorm:"size:128"`
+	Active       bool   `gorm:"not null"`
+	EventTypes   string `gorm:"size:255"`
+	ContentTypes string `gorm:"size:255"`
+	Tags         string `gorm:"size:255"`
+	Devices      string `gorm:"size:1024"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+type webhookDeliveryV6 struct {
+	ID             string     `gorm:"primaryKey"`
+	WebhookID      string     `gorm:"size:36;index"`
+	UserID         string     `gorm:"index"`
+	EventType      string     `gorm:"size:50"`
+	Payload        string     `gorm:"type:text"`
+	Status         string     `gorm:"size:20;index"`
+	Attempts       int        `gorm:"not null;default:0"`
+	NextAttemptAt  *time.Time `gorm:"index"`
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	Error          string `gorm:"size:1024"`
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+func (webhookV6) TableName() string         { return "webhooks" }
+func (webhookDeliveryV6) TableName() string { return "webhook_deliveries" }
+
+// Version 7, content subtypes
+
+type clipboardItemV7 struct {
+	Subtype  string `gorm:"size:20;not null;default:'';index"`
+	MimeType string `gorm:"size:100;not null;default:''"`
+}
+
+func (clipboardItemV7) TableName() string { return "clipboard_items" }
+
+// Version 8, content size
+
+type clipboardItemV8 struct {
+	ContentSize int64 `gorm:"not null;default:0"`
+}
+
+func (clipboardItemV8) TableName() string { return "clipboard_items" }
+
+// Version 9, encryption at rest state
+
+type clipboardItemV9 struct {
+	EncryptedAtRest bool `gorm:"not null;default:false"`
+}
+
+type webhookDeliveryV9 struct {
+	EncryptedAtRest bool `gorm:"not null;default:false"`
+}
+
+func (clipboardItemV9) TableName() string   { return "clipboard_items" }
+func (webhookDeliveryV9) TableName() string { return "webhook_deliveries" }
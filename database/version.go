@@ -0,0 +1,28 @@
+package database
+
+import (
+	"clipboard-server/models"
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// ErrVersionConflict the item was modified after it was read
+var ErrVersionConflict = errors.New("clipboard item was modified concurrently")
+
+// SaveItem writes an existing item only if it is still at the version it was
+// read at, and increments its version
+func SaveItem(tx *gorm.DB, item *models.ClipboardItem) error {
+	expected := item.Version
+	item.Version = expected + 1
+
+	result := tx.Model(item).Where("version = ?", expected).Select("*").Updates(item)
+	if result.Error == nil && result.RowsAffected == 0 {
+		result.Error = ErrVersionConflict
+	}
+	if result.Error != nil {
+		item.Version = expected
+		return result.Error
+	}
+	return nil
+}
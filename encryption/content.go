@@ -0,0 +1,155 @@
+package encryption
+
+import (
+	"clipboard-server/models"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// contentPrefix marks content encrypted at rest, version 1 is AES-256-GCM
+const contentPrefix = "enc:v1:"
+
+// IsEncrypted reports whether a stored value has the format of a value
+// encrypted at rest. Plaintext may have it too, whether a row is encrypted is
+// recorded by its encrypted_at_rest column.
+func IsEncrypted(stored string) bool {
+	return strings.HasPrefix(stored, contentPrefix)
+}
+
+// ContentCipher encrypts clipboard content at rest under per-user data keys.
+// It implements models.ContentCipher and models.ContentHasher.
+type ContentCipher struct {
+	master *MasterKey
+
+	mu   sync.Mutex
+	keys map[string][]byte // unwrapped data keys by user ID
+}
+
+// NewContentCipher creates a content cipher using the given master key
+func NewContentCipher(master *MasterKey) *ContentCipher {
+	return &ContentCipher{
+		master: master,
+		keys:   make(map[string][]byte),
+	}
+}
+
+// Encrypt encrypts plaintext for the given user. It is never skipped, even
+// for plaintext that looks like an encrypted value.
+func (c *ContentCipher) Encrypt(tx *gorm.DB, userID, plaintext string) (string, error) {
+	key, err := c.dataKey(tx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(key, []byte(plaintext), []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	return contentPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt decrypts a value stored by Encrypt
+func (c *ContentCipher) Decrypt(tx *gorm.DB, userID, stored string) (string, error) {
+	if !IsEncrypted(stored) {
+		return "", fmt.Errorf("invalid encrypted content format")
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(stored[len(contentPrefix):])
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted content encoding: %v", err)
+	}
+
+	key, err := c.dataKey(tx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(key, sealed, []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Hash returns an HMAC-SHA256 of the plaintext keyed by a subkey of the user's
+// data key, so equal content can be found without storing a plain hash
+func (c *ContentCipher) Hash(tx *gorm.DB, userID, plaintext string) (string, error) {
+	key, err := c.dataKey(tx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	subkey := hmac.New(sha256.New, key)
+	subkey.Write([]byte("content-hash"))
+
+	mac := hmac.New(sha256.New, subkey.Sum(nil))
+	mac.Write([]byte(plaintext))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// dataKey returns the unwrapped data key of a user, creating it on first use
+func (c *ContentCipher) dataKey(tx *gorm.DB, userID string) ([]byte, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("user ID is required for content encryption")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key, ok := c.keys[userID]; ok {
+		return key, nil
+	}
+
+	// Run on a fresh statement that shares the caller's connection or transaction
+	db := tx.Session(&gorm.Session{NewDB: true})
+
+	var record models.UserDataKey
+	err := db.Where("user_id = ?", userID).First(&record).Error
+	if err == gorm.ErrRecordNotFound {
+		key, err := randomKey()
+		if err != nil {
+			return nil, err
+		}
+		wrapped, err := c.master.Wrap(userID, key)
+		if err != nil {
+			return nil, err
+		}
+
+		record = models.UserDataKey{
+			UserID:      userID,
+			WrappedKey:  wrapped,
+			MasterKeyID: c.master.ID,
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to store data key: %v", err)
+		}
+
+		// Another server instance may have won the race, always use the stored key
+		if err := db.Where("user_id = ?", userID).First(&record).Error; err != nil {
+			return nil, fmt.Errorf("failed to load data key: %v", err)
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to load data key: %v", err)
+	}
+
+	if record.MasterKeyID != c.master.ID {
+		return nil, fmt.Errorf("data key of user %s is wrapped by master key %s, configured key is %s",
+			userID, record.MasterKeyID, c.master.ID)
+	}
+
+	key, err := c.master.Unwrap(userID, record.WrappedKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %v", err)
+	}
+
+	c.keys[userID] = key
+	return key, nil
+}
@@ -0,0 +1,242 @@
+package encryption
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/database/dbtest"
+	"clipboard-server/models"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	db := dbtest.Open(t)
+	t.Cleanup(func() { models.SetContentCipher(nil) })
+	return db
+}
+
+func newMasterKey(t *testing.T) *MasterKey {
+	encoded, err := GenerateMasterKey()
+	if err != nil {
+		t.Fatalf("生成主密钥失败: %v", err)
+	}
+	key, err := ParseMasterKey(encoded)
+	if err != nil {
+		t.Fatalf("解析主密钥失败: %v", err)
+	}
+	return key
+}
+
+func rawContent(db *gorm.DB, id string) string {
+	var content string
+	db.Raw("SELECT content FROM clipboard_items WHERE id = ?", id).Scan(&content)
+	return content
+}
+
+func TestContentEncryptedAtRest(t *testing.T) {
+	db := setupTestDB(t)
+	models.SetContentCipher(NewContentCipher(newMasterKey(t)))
+
+	item := models.ClipboardItem{UserID: "user-1", Content: "my secret password"}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("创建项目失败: %v", err)
+	}
+
+	// The in-memory item keeps its plaintext after saving
+	if item.Content != "my secret password" {
+		t.Errorf("保存后内容应为明文，实际得到 %q", item.Content)
+	}
+
+	stored := rawContent(db, item.ID)
+	if !IsEncrypted(stored) || strings.Contains(stored, "secret") {
+		t.Errorf("数据库中的内容应被加密，实际得到 %q", stored)
+	}
+	var size int64
+	db.Raw("SELECT content_size FROM clipboard_items WHERE id = ?", item.ID).Scan(&size)
+	if size != int64(len("my secret password")) {
+		t.Errorf("内容大小应按明文计算，实际得到 %d", size)
+	}
+
+	var loaded models.ClipboardItem
+	db.First(&loaded, "id = ?", item.ID)
+	if loaded.Content != "my secret password" {
+		t.Errorf("读取后内容应被解密，实际得到 %q", loaded.Content)
+	}
+
+	// End-to-end encrypted items are stored verbatim
+	e2ee := models.ClipboardItem{UserID: "user-1", Content: "Y2lwaGVy", Encrypted: true}
+	db.Create(&e2ee)
+	if stored := rawContent(db, e2ee.ID); stored != "Y2lwaGVy" {
+		t.Errorf("端到端加密内容应原样存储，实际得到 %q", stored)
+	}
+}
+
+func TestDataKeysAreBoundToUser(t *testing.T) {
+	db := setupTestDB(t)
+	master := newMasterKey(t)
+
+	var record models.UserDataKey
+	contentCipher := NewContentCipher(master)
+	if _, err := contentCipher.Encrypt(db, "user-1", "hello"); err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	db.First(&record, "user_id = ?", "user-1")
+
+	if _, err := master.Unwrap("user-2", record.WrappedKey); err == nil {
+		t.Error("数据密钥不应能以其他用户身份解包")
+	}
+}
+
+func TestEncryptExistingAndRotate(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Rows written before encryption was enabled
+	for _, content := range []string{"one", "two", "three"} {
+		db.Create(&models.ClipboardItem{UserID: "user-1", Content: content})
+	}
+
+	oldKey := newMasterKey(t)
+	contentCipher := NewContentCipher(oldKey)
+	models.SetContentCipher(contentCipher)
+
+	count, err := EncryptExisting(db, contentCipher)
+	if err != nil || count != 3 {
+		t.Fatalf("期望加密 3 条记录，实际得到 %d, 错误: %v", count, err)
+	}
+
+	count, _ = EncryptExisting(db, contentCipher)
+	if count != 0 {
+		t.Errorf("重复执行不应再加密任何记录，实际得到 %d", count)
+	}
+
+	newKey := newMasterKey(t)
+	rotated, err := RotateMasterKey(db, oldKey, newKey)
+	if err != nil || rotated != 1 {
+		t.Fatalf("期望轮换 1 个数据密钥，实际得到 %d, 错误: %v", rotated, err)
+	}
+
+	// A fresh cipher with the new master key reads the existing content
+	models.SetContentCipher(NewContentCipher(newKey))
+	var items []models.ClipboardItem
+	if err := db.Order("content").Find(&items).Error; err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if len(items) != 3 {
+		t.Fatalf("期望 3 条记录，实际得到 %d", len(items))
+	}
+	for _, item := range items {
+		if IsEncrypted(item.Content) {
+			t.Errorf("轮换后内容应可解密，实际得到 %q", item.Content)
+		}
+	}
+
+	// The old master key no longer matches the stored data keys
+	models.SetContentCipher(NewContentCipher(oldKey))
+	if err := db.Find(&items).Error; err == nil {
+		t.Error("旧主密钥不应再能解密内容")
+	}
+}
+
+func TestWebhookPayloadEncryptedAtRest(t *testing.T) {
+	db := setupTestDB(t)
+	rawPayload := func(id string) string {
+		var payload string
+		db.Raw("SELECT payload FROM webhook_deliveries WHERE id = ?", id).Scan(&payload)
+		return payload
+	}
+
+	// A delivery queued before encryption was enabled
+	legacy := models.WebhookDelivery{UserID: "user-1", Payload: `{"content":"old secret"}`}
+	if err := db.Create(&legacy).Error; err != nil {
+		t.Fatalf("创建投递失败: %v", err)
+	}
+
+	contentCipher := NewContentCipher(newMasterKey(t))
+	models.SetContentCipher(contentCipher)
+
+	delivery := models.WebhookDelivery{UserID: "user-1", Payload: `{"content":"new secret"}`}
+	if err := db.Create(&delivery).Error; err != nil {
+		t.Fatalf("创建投递失败: %v", err)
+	}
+	if delivery.Payload != `{"content":"new secret"}` {
+		t.Errorf("保存后负载应为明文，实际得到 %q", delivery.Payload)
+	}
+	if stored := rawPayload(delivery.ID); !IsEncrypted(stored) || strings.Contains(stored, "secret") {
+		t.Errorf("数据库中的负载应被加密，实际得到 %q", stored)
+	}
+
+	var loaded models.WebhookDelivery
+	db.First(&loaded, "id = ?", delivery.ID)
+	if loaded.Payload != `{"content":"new secret"}` {
+		t.Errorf("读取后负载应被解密，实际得到 %q", loaded.Payload)
+	}
+
+	count, err := EncryptExisting(db, contentCipher)
+	if err != nil || count != 1 {
+		t.Fatalf("期望加密 1 条记录，实际得到 %d, 错误: %v", count, err)
+	}
+	if stored := rawPayload(legacy.ID); !IsEncrypted(stored) {
+		t.Errorf("已有的负载应被加密，实际得到 %q", stored)
+	}
+	var migrated models.WebhookDelivery
+	db.First(&migrated, "id = ?", legacy.ID)
+	if migrated.Payload != `{"content":"old secret"}` {
+		t.Errorf("读取后负载应被解密，实际得到 %q", migrated.Payload)
+	}
+}
+
+func TestContentWithEncryptedPrefix(t *testing.T) {
+	db := setupTestDB(t)
+
+	// Written before encryption was enabled, the prefix doesn't make it ciphertext
+	legacy := models.ClipboardItem{UserID: "user-1", Content: "enc:v1:legacy note"}
+	db.Create(&legacy)
+
+	contentCipher := NewContentCipher(newMasterKey(t))
+	models.SetContentCipher(contentCipher)
+
+	item := models.ClipboardItem{UserID: "user-1", Content: "enc:v1:my password is hunter2"}
+	if err := db.Create(&item).Error; err != nil {
+		t.Fatalf("创建项目失败: %v", err)
+	}
+	if stored := rawContent(db, item.ID); strings.Contains(stored, "hunter2") {
+		t.Errorf("以加密前缀开头的内容也应被加密，实际得到 %q", stored)
+	}
+
+	if count, err := EncryptExisting(db, contentCipher); err != nil || count != 1 {
+		t.Fatalf("期望加密 1 条记录，实际得到 %d, 错误: %v", count, err)
+	}
+	if stored := rawContent(db, legacy.ID); strings.Contains(stored, "legacy note") {
+		t.Errorf("已有的明文内容应被加密，实际得到 %q", stored)
+	}
+
+	for id, want := range map[string]string{legacy.ID: "enc:v1:legacy note", item.ID: "enc:v1:my password is hunter2"} {
+		var loaded models.ClipboardItem
+		if err := db.First(&loaded, "id = ?", id).Error; err != nil || loaded.Content != want {
+			t.Errorf("读取后内容应被解密为 %q，实际得到 %q, 错误: %v", want, loaded.Content, err)
+		}
+	}
+}
+
+func TestBackfillDecryptsLegacyContent(t *testing.T) {
+	db := setupTestDB(t)
+	models.SetContentCipher(NewContentCipher(newMasterKey(t)))
+
+	item := models.ClipboardItem{UserID: "user-1", Content: "https://example.com"}
+	db.Create(&item)
+
+	// Before migration 9 encrypted content was only told from its prefix
+	if _, err := database.Rollback(db, 2); err != nil {
+		t.Fatalf("回滚失败: %v", err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatalf("迁移失败: %v", err)
+	}
+
+	var size int64
+	db.Raw("SELECT content_size FROM clipboard_items WHERE id = ?", item.ID).Scan(&size)
+	if size != int64(len("https://example.com")) {
+		t.Errorf("回填的内容大小应按明文计算，实际得到 %d", size)
+	}
+}
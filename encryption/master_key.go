@@ -0,0 +1,91 @@
+package encryption
+
+import (
+	"clipboard-server/config"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// MasterKey wraps the per-user data keys. It is never stored in the database.
+type MasterKey struct {
+	ID  string
+	key []byte
+}
+
+// ParseMasterKey parses a base64 or hex encoded 32 byte key
+func ParseMasterKey(encoded string) (*MasterKey, error) {
+	encoded = strings.TrimSpace(encoded)
+
+	var key []byte
+	if decoded, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(decoded) == KeySize {
+		key = decoded
+	} else if decoded, err := hex.DecodeString(encoded); err == nil && len(decoded) == KeySize {
+		key = decoded
+	} else {
+		return nil, fmt.Errorf("master key must be %d bytes encoded as base64 or hex", KeySize)
+	}
+
+	// The ID lets data keys record which master key wrapped them
+	sum := sha256.Sum256(key)
+	return &MasterKey{
+		ID:  hex.EncodeToString(sum[:])[:16],
+		key: key,
+	}, nil
+}
+
+// ReadMasterKeyFile reads a master key from a file
+func ReadMasterKeyFile(path string) (*MasterKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file %s: %v", path, err)
+	}
+	return ParseMasterKey(string(data))
+}
+
+// LoadMasterKey loads the master key from the configuration.
+// It returns nil when encryption at rest is not configured.
+func LoadMasterKey(cfg *config.Config) (*MasterKey, error) {
+	if cfg.EncryptionMasterKey != "" {
+		return ParseMasterKey(cfg.EncryptionMasterKey)
+	}
+	if cfg.EncryptionKeyFile != "" {
+		return ReadMasterKeyFile(cfg.EncryptionKeyFile)
+	}
+	return nil, nil
+}
+
+// GenerateMasterKey returns a new random master key encoded as base64
+func GenerateMasterKey() (string, error) {
+	key, err := randomKey()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Wrap encrypts a data key for the given user
+func (m *MasterKey) Wrap(userID string, dataKey []byte) (string, error) {
+	sealed, err := seal(m.key, dataKey, wrapAAD(userID))
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unwrap decrypts a data key wrapped for the given user
+func (m *MasterKey) Unwrap(userID, wrapped string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key encoding: %v", err)
+	}
+	return open(m.key, sealed, wrapAAD(userID))
+}
+
+// wrapAAD binds a wrapped data key to its owner so keys cannot be swapped between users
+func wrapAAD(userID string) []byte {
+	return []byte("data-key:" + userID)
+}
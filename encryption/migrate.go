@@ -0,0 +1,117 @@
+package encryption
+
+import (
+	"clipboard-server/models"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+const encryptBatchSize = 500
+
+// EncryptExisting encrypts clipboard content and webhook delivery payloads
+// stored before encryption at rest was enabled. It is safe to run repeatedly
+// and returns the number of rows encrypted.
+func EncryptExisting(db *gorm.DB, c *ContentCipher) (int64, error) {
+	// End-to-end encrypted items are already ciphertext
+	items, err := encryptColumn(db, c, &models.ClipboardItem{}, "content", func(q *gorm.DB) *gorm.DB {
+		return q.Where("encrypted = ?", false)
+	})
+	if err != nil {
+		return items, err
+	}
+	deliveries, err := encryptColumn(db, c, &models.WebhookDelivery{}, "payload", nil)
+	return items + deliveries, err
+}
+
+// encryptColumn encrypts the plaintext values of column in the rows of model
+// narrowed by the optional scope, in batches
+func encryptColumn(db *gorm.DB, c *ContentCipher, model interface{}, column string, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	type row struct {
+		ID     string
+		UserID string
+		Value  string
+	}
+
+	var total int64
+	for {
+		query := db.Model(model)
+		if scope != nil {
+			query = query.Scopes(scope)
+		}
+
+		var rows []row
+		if err := query.
+			Select("id", "user_id", column+" AS value").
+			Where(column+" <> ? AND encrypted_at_rest = ?", "", false).
+			Limit(encryptBatchSize).
+			Find(&rows).Error; err != nil {
+			return total, fmt.Errorf("failed to query plaintext %s: %v", column, err)
+		}
+
+		if len(rows) == 0 {
+			return total, nil
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, r := range rows {
+				encrypted, err := c.Encrypt(tx, r.UserID, r.Value)
+				if err != nil {
+					return fmt.Errorf("failed to encrypt %s of %s: %v", column, r.ID, err)
+				}
+				// UpdateColumns skips the model hooks, the value is already encrypted
+				if err := tx.Model(model).Where("id = ?", r.ID).UpdateColumns(map[string]interface{}{
+					column:              encrypted,
+					"encrypted_at_rest": true,
+				}).Error; err != nil {
+					return fmt.Errorf("failed to update %s: %v", r.ID, err)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += int64(len(rows))
+	}
+}
+
+// RotateMasterKey re-wraps every data key from oldKey to newKey.
+// Clipboard content does not need to be re-encrypted.
+func RotateMasterKey(db *gorm.DB, oldKey, newKey *MasterKey) (int64, error) {
+	if oldKey.ID == newKey.ID {
+		return 0, fmt.Errorf("the new master key is the same as the current one")
+	}
+
+	var rotated int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var records []models.UserDataKey
+		if err := tx.Where("master_key_id = ?", oldKey.ID).Find(&records).Error; err != nil {
+			return fmt.Errorf("failed to load data keys: %v", err)
+		}
+
+		for _, record := range records {
+			key, err := oldKey.Unwrap(record.UserID, record.WrappedKey)
+			if err != nil {
+				return fmt.Errorf("failed to unwrap data key of user %s: %v", record.UserID, err)
+			}
+
+			wrapped, err := newKey.Wrap(record.UserID, key)
+			if err != nil {
+				return err
+			}
+
+			if err := tx.Model(&models.UserDataKey{}).Where("user_id = ?", record.UserID).
+				Updates(map[string]interface{}{
+					"wrapped_key":   wrapped,
+					"master_key_id": newKey.ID,
+				}).Error; err != nil {
+				return fmt.Errorf("failed to update data key of user %s: %v", record.UserID, err)
+			}
+			rotated++
+		}
+		return nil
+	})
+	return rotated, err
+}
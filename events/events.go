@@ -0,0 +1,204 @@
+package events
+
+import (
+	"clipboard-server/models"
+	"sync"
+	"time"
+)
+
+// Type kind of clipboard event
+type Type string
+
+const (
+	ItemCreated Type = "item.created"
+	ItemUpdated Type = "item.updated"
+	ItemDeleted Type = "item.deleted"
+
+	// MemberRemoved a member left or was removed from a channel
+	MemberRemoved Type = "member.removed"
+)
+
+// Event a change to a user's clipboard, or to a channel clipboard when ChannelID is set
+type Event struct {
+	Type      Type                          `json:"type"`
+	UserID    string                        `json:"-"`
+	ChannelID string                        `json:"channel_id,omitempty"`
+	MemberID  string                        `json:"member_id,omitempty"`
+	ItemID    string                        `json:"item_id,omitempty"`
+	ClientID  string                        `json:"client_id,omitempty"`
+	DeviceID  string                        `json:"device_id,omitempty"` // device the change was made on, if known
+	Reason    string                        `json:"reason,omitempty"`
+	Item      *models.ClipboardItemResponse `json:"item,omitempty"`
+	Timestamp time.Time                     `json:"timestamp"`
+}
+
+// subscriberBuffer events buffered per subscriber before new events are dropped
+const subscriberBuffer = 64
+
+// ChannelTopic the topic to subscribe to for the events of a channel
+func ChannelTopic(channelID string) string {
+	return "channel:" + channelID
+}
+
+// topic the user or channel whose subscribers receive the event
+func (e Event) topic() string {
+	if e.ChannelID != "" {
+		return ChannelTopic(e.ChannelID)
+	}
+	return e.UserID
+}
+
+// Hub fans out events to the subscribers of each user or channel
+type Hub struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[chan Event]struct{}
+	hooks       []func(Event)
+	closed      bool
+}
+
+// NewHub creates an event hub
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: make(map[string]map[chan Event]struct{}),
+	}
+}
+
+// Default is the hub used by the HTTP handlers
+var Default = NewHub()
+
+// Publish sends an event to the default hub
+func Publish(e Event) {
+	Default.Publish(e)
+}
+
+// Publish sends an event to every subscriber of its user or channel, then
+// passes it to the hooks. Slow subscribers miss events instead of blocking
+// the publisher.
+func (h *Hub) Publish(e Event) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for ch := range h.subscribers[e.topic()] {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+	for _, hook := range h.hooks {
+		hook(e)
+	}
+}
+
+// OnPublish registers fn to be called with every published event, of all
+// users and channels. It runs in the publisher's goroutine and must not block.
+func (h *Hub) OnPublish(fn func(Event)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+// Subscribe registers a subscriber for the events of a user ID, or of a channel
+// with ChannelTopic. The returned function unsubscribes; the channel is closed
+// when the hub is closed.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	if h.subscribers[topic] == nil {
+		h.subscribers[topic] = make(map[chan Event]struct{})
+	}
+	h.subscribers[topic][ch] = struct{}{}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			if _, ok := h.subscribers[topic][ch]; ok {
+				delete(h.subscribers[topic], ch)
+				if len(h.subscribers[topic]) == 0 {
+					delete(h.subscribers, topic)
+				}
+				close(ch)
+			}
+		})
+	}
+}
+
+// Connections returns the number of active subscribers
+func (h *Hub) Connections() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	count := 0
+	for _, subs := range h.subscribers {
+		count += len(subs)
+	}
+	return count
+}
+
+// Close disconnects all subscribers, used on server shutdown
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for userID, subs := range h.subscribers {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(h.subscribers, userID)
+	}
+}
+
+// PublishItem announces an item created or updated on a device, which may be empty
+func PublishItem(t Type, item models.ClipboardItem, deviceID string) {
+	response := item.ToResponse()
+	Publish(Event{
+		Type:      t,
+		UserID:    item.UserID,
+		ChannelID: item.ChannelID,
+		ItemID:    item.ID,
+		ClientID:  item.ClientID,
+		DeviceID:  deviceID,
+		Item:      &response,
+	})
+}
+
+// PublishDeletions announces items deleted on a device, or expired ones with
+// an empty deviceID
+func PublishDeletions(deleted []models.DeletedItem, deviceID string) {
+	for _, d := range deleted {
+		Publish(Event{
+			Type:      ItemDeleted,
+			UserID:    d.UserID,
+			ChannelID: d.ChannelID,
+			ItemID:    d.ItemID,
+			ClientID:  d.ClientID,
+			DeviceID:  deviceID,
+			Reason:    d.Reason,
+			Timestamp: d.DeletedAt,
+		})
+	}
+}
+
+// PublishMemberRemoved announces that a user no longer has access to a channel,
+// which also ends the event streams the user has open on the channel
+func PublishMemberRemoved(channelID, userID string) {
+	Publish(Event{
+		Type:      MemberRemoved,
+		ChannelID: channelID,
+		MemberID:  userID,
+	})
+}
@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"clipboard-server/config"
+	"clipboard-server/database"
+	"clipboard-server/logging"
+	"clipboard-server/models"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminHandler for server administration handlers, protected by the admin token
+type AdminHandler struct{}
+
+// NewAdminHandler creates admin handler instance
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{}
+}
+
+// CreateBackup writes a verified online backup of the database to the backup directory
+func (h *AdminHandler) CreateBackup(c *gin.Context) {
+	if database.Driver() != database.DriverSQLite {
+		c.JSON(http.StatusNotImplemented, models.ErrorResponse{
+			Error:   "not supported",
+			Message: "online backup is only supported for SQLite",
+		})
+		return
+	}
+
+	backup, err := database.CreateBackup(database.ConfiguredBackupOptions(config.GetConfig()))
+	if err != nil {
+		logging.For(c).Error("备份失败", "handler", "CreateBackup", "error", err)
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "backup failed",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	logging.For(c).Info("已创建备份", "handler", "CreateBackup", "backup", backup.Name, "size", backup.Size)
+	c.JSON(http.StatusCreated, backup)
+}
+
+// ListBackups lists the backups in the backup directory, newest first
+func (h *AdminHandler) ListBackups(c *gin.Context) {
+	backups, err := database.ListBackups(config.GetConfig().BackupDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "list failed",
+			Message: "failed to list backups",
+		})
+		return
+	}
+	if backups == nil {
+		backups = []database.BackupInfo{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"items": backups,
+		"total": len(backups),
+	})
+}
+
+// debugLoggingRequest body of UpdateDebugLogging. Duration, such as "15m",
+// sets the expiry relative to now and takes precedence over expires_at.
+type debugLoggingRequest struct {
+	logging.HTTPDebugSettings
+	Duration string `json:"duration,omitempty"`
+}
+
+// GetDebugLogging returns the HTTP debug logging settings
+func (h *AdminHandler) GetDebugLogging(c *gin.Context) {
+	c.JSON(http.StatusOK, logging.HTTPDebug.Settings())
+}
+
+// UpdateDebugLogging replaces the HTTP debug logging settings. Logging is
+// always turned off after at most logging.MaxHTTPDebugDuration.
+func (h *AdminHandler) UpdateDebugLogging(c *gin.Context) {
+	req := debugLoggingRequest{
+		HTTPDebugSettings: logging.HTTPDebugSettings{Enabled: true, SampleRate: 1},
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	now := time.Now()
+	settings := req.HTTPDebugSettings
+	if req.Duration != "" {
+		duration, err := time.ParseDuration(req.Duration)
+		if err != nil || duration <= 0 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid duration",
+				Message: "duration must be a positive duration such as 15m",
+			})
+			return
+		}
+		expiresAt := now.Add(duration)
+		settings.ExpiresAt = &expiresAt
+	}
+	if settings.Enabled {
+		if limit := now.Add(logging.MaxHTTPDebugDuration); settings.ExpiresAt == nil || settings.ExpiresAt.After(limit) {
+			settings.ExpiresAt = &limit
+		}
+	} else {
+		settings.ExpiresAt = nil
+	}
+
+	if err := logging.HTTPDebug.Configure(settings); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid settings",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	logging.For(c).Info("已更新 HTTP 调试日志设置", "handler", "UpdateDebugLogging", "enabled", settings.Enabled)
+	c.JSON(http.StatusOK, logging.HTTPDebug.Settings())
+}
+
+// DisableDebugLogging turns HTTP debug logging off
+func (h *AdminHandler) DisableDebugLogging(c *gin.Context) {
+	settings := logging.HTTPDebug.Settings()
+	settings.Enabled = false
+	settings.ExpiresAt = nil
+	if err := logging.HTTPDebug.Configure(settings); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, logging.HTTPDebug.Settings())
+}
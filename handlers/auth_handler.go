@@ -2,23 +2,29 @@ package handlers
 
 import (
 	"clipboard-server/auth"
-	"clipboard-server/database"
 	"clipboard-server/models"
+	"clipboard-server/repository"
 	"clipboard-server/utils"
 	"fmt"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 )
 
 // AuthHandler for authentication related handlers
-type AuthHandler struct{}
+type AuthHandler struct {
+	users repository.UserRepository
+}
 
 // NewAuthHandler creates auth handler instance
-func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+func NewAuthHandler(users repository.UserRepository) *AuthHandler {
+	return &AuthHandler{users: users}
+}
+
+// repo returns the repository bound to the context of the request
+func (h *AuthHandler) repo(c *gin.Context) repository.UserRepository {
+	return h.users.WithContext(c.Request.Context())
 }
 
 // Register user registration
@@ -59,11 +65,8 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
 	// Check if username exists
-	var existingUser models.User
-	if err := db.Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
+	if _, err := h.repo(c).FindByUsername(req.Username); err == nil {
 		c.JSON(http.StatusConflict, models.ErrorResponse{
 			Error:   "username already exists",
 			Message: "the username is already taken",
@@ -72,7 +75,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	// Check if email exists
-	if err := db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
+	if _, err := h.repo(c).FindByEmail(req.Email); err == nil {
 		c.JSON(http.StatusConflict, models.ErrorResponse{
 			Error:   "email already exists",
 			Message: "the email is already registered",
@@ -109,7 +112,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		IsActive: true,
 	}
 
-	if err := db.Create(&user).Error; err != nil {
+	if err := h.repo(c).CreateUser(&user); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "user creation failed",
 			Message: "failed to create user",
@@ -129,7 +132,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Save token to user record
 	user.Token = token
-	db.Save(&user)
+	h.repo(c).SaveUser(&user)
 
 	// Return login info (without password)
 	user.Password = ""
@@ -150,13 +153,10 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-	var user models.User
-
 	// Find user (support username or email login)
-	query := db.Where("username = ? OR email = ?", req.Username, req.Username)
-	if err := query.First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	user, err := h.repo(c).FindByLogin(req.Username)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 				Error:   "invalid credentials",
 				Message: "username or password is incorrect",
@@ -196,7 +196,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 				if err == nil {
 					user.Salt = salt
 					user.Password = hashedPassword
-					database.GetDB().Save(&user) // 保存升级后的密码
+					h.repo(c).SaveUser(&user) // 保存升级后的密码
 					fmt.Printf("用户 %s 的密码存储方式已升级\n", user.Username)
 				}
 			}
@@ -223,7 +223,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Update user token
 	user.Token = token
-	db.Save(&user)
+	h.repo(c).SaveUser(&user)
 
 	// Return login info (without password)
 	user.Password = ""
@@ -270,8 +270,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 	}
 
 	// Update token in database
-	db := database.GetDB()
-	db.Model(&models.User{}).Where("id = ?", claims.UserID).Update("token", newToken)
+	h.repo(c).UpdateToken(claims.UserID, newToken)
 
 	c.JSON(http.StatusOK, gin.H{
 		"token":      newToken,
@@ -291,8 +290,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 	}
 
 	// Clear token in database
-	db := database.GetDB()
-	db.Model(&models.User{}).Where("id = ?", userID).Update("token", "")
+	h.repo(c).UpdateToken(userID, "")
 
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "logout successful",
@@ -310,11 +308,9 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-	var user models.User
-
-	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	user, err := h.repo(c).GetUser(userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "user not found",
 				Message: "user profile not found",
@@ -364,12 +360,10 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-	var user models.User
-
 	// Get current user
-	if err := db.Where("id = ?", userID).First(&user).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	user, err := h.repo(c).GetUser(userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "user not found",
 				Message: "user profile not found",
@@ -413,10 +407,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	// Update user password and salt
-	if err := db.Model(&user).Updates(models.User{
-		Password: hashedNewPassword,
-		Salt:     newSalt,
-	}).Error; err != nil {
+	if err := h.repo(c).UpdatePassword(user.ID, hashedNewPassword, newSalt); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "update failed",
 			Message: "failed to update password",
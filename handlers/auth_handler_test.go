@@ -3,37 +3,33 @@ package handlers
 import (
 	"bytes"
 	"clipboard-server/auth"
-	"clipboard-server/database"
+	"clipboard-server/database/dbtest"
 	"clipboard-server/models"
+	"clipboard-server/repository"
 	"clipboard-server/utils"
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"testing"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func setupTestDB() *gorm.DB {
-	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
-	if err != nil {
-		panic("failed to connect to test database")
-	}
+func TestMain(m *testing.M) {
+	// 在并行测试开始前设置，避免并发修改 gin 的全局模式
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
 
-	// 自动迁移
-	db.AutoMigrate(&models.User{}, &models.ClipboardItem{})
-	return db
+// setupTestDB 打开已迁移的测试数据库，默认使用内存 SQLite（见 dbtest）
+func setupTestDB(t *testing.T) *gorm.DB {
+	return dbtest.Open(t)
 }
 
 func TestChangePassword(t *testing.T) {
-	// 设置测试数据库
-	database.DB = setupTestDB()
-	defer func() {
-		sqlDB, _ := database.DB.DB()
-		sqlDB.Close()
-	}()
+	t.Parallel()
 
 	// 创建测试用户
 	salt, _ := utils.GenerateSalt()
@@ -46,15 +42,14 @@ func TestChangePassword(t *testing.T) {
 		Salt:     salt,
 		IsActive: true,
 	}
-	database.DB.Create(&user)
+	users := repository.NewMemoryUserRepository(user)
 
 	// 生成JWT token
 	token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
 
 	// 设置Gin
-	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	authHandler := NewAuthHandler()
+	authHandler := NewAuthHandler(users)
 
 	// 设置路由
 	authenticated := router.Group("/")
@@ -131,8 +126,7 @@ func TestChangePassword(t *testing.T) {
 				}
 
 				// 验证密码确实被更改了
-				var updatedUser models.User
-				database.DB.Where("id = ?", user.ID).First(&updatedUser)
+				updatedUser, _ := users.GetUser(user.ID)
 
 				// 旧密码不应该再有效
 				if utils.CheckPasswordWithSalt("oldpassword123", updatedUser.Salt, updatedUser.Password) {
@@ -158,10 +152,11 @@ func TestChangePassword(t *testing.T) {
 }
 
 func TestChangePasswordWithoutAuth(t *testing.T) {
+	t.Parallel()
+
 	// 设置Gin
-	gin.SetMode(gin.TestMode)
 	router := gin.New()
-	authHandler := NewAuthHandler()
+	authHandler := NewAuthHandler(repository.NewMemoryUserRepository())
 
 	// 设置路由（需要认证）
 	authenticated := router.Group("/")
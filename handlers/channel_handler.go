@@ -0,0 +1,554 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/events"
+	"clipboard-server/logging"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Context keys set by ChannelHandler.RequireRole for the handlers of channel routes
+const (
+	channelIDKey   = "channel_id"
+	channelRoleKey = "channel_role"
+)
+
+// channelInvitationTTL how long an invitation can be accepted
+const channelInvitationTTL = 7 * 24 * time.Hour
+
+// ChannelHandler manages channels, clipboards shared between their members
+type ChannelHandler struct {
+	channels repository.ChannelRepository
+	users    repository.UserRepository
+}
+
+// NewChannelHandler creates channel handler instance
+func NewChannelHandler(channels repository.ChannelRepository, users repository.UserRepository) *ChannelHandler {
+	return &ChannelHandler{channels: channels, users: users}
+}
+
+// repo returns the repository bound to the context of the request
+func (h *ChannelHandler) repo(c *gin.Context) repository.ChannelRepository {
+	return h.channels.WithContext(c.Request.Context())
+}
+
+// RequireRole only lets members of the :channel_id channel with at least role
+// through, and scopes the clipboard handlers to the channel. Channels the user
+// is not a member of are reported as not found.
+func (h *ChannelHandler) RequireRole(role models.ChannelRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, exists := auth.GetCurrentUserID(c)
+		if !exists {
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "unauthorized",
+				Message: "user not authenticated",
+			})
+			c.Abort()
+			return
+		}
+
+		channelID := c.Param("channel_id")
+		member, err := h.repo(c).Member(channelID, userID)
+		if err == repository.ErrNotFound {
+			c.JSON(http.StatusNotFound, models.ErrorResponse{
+				Error:   "channel not found",
+				Message: "channel not found or not a member",
+			})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "database error",
+				Message: "failed to check channel membership",
+			})
+			c.Abort()
+			return
+		}
+
+		if !member.Role.Allows(role) {
+			c.JSON(http.StatusForbidden, models.ErrorResponse{
+				Error:   "forbidden",
+				Message: "this action requires the " + string(role) + " role in the channel",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(channelIDKey, channelID)
+		c.Set(channelRoleKey, member.Role)
+		c.Next()
+	}
+}
+
+// channelRole returns the role of the current user in the channel of the route
+func channelRole(c *gin.Context) models.ChannelRole {
+	role, _ := c.Get(channelRoleKey)
+	r, _ := role.(models.ChannelRole)
+	return r
+}
+
+// CreateChannel creates a channel owned by the current user
+func (h *ChannelHandler) CreateChannel(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.ChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	channel := models.Channel{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedBy:   userID,
+	}
+	if err := h.repo(c).CreateChannel(&channel); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to create channel",
+		})
+		return
+	}
+
+	logging.For(c).Info("已创建频道", "handler", "CreateChannel", "channel_id", channel.ID)
+	c.JSON(http.StatusCreated, models.ChannelMembership{Channel: channel, Role: models.ChannelRoleOwner})
+}
+
+// ListChannels lists the channels of the current user with the user's role
+func (h *ChannelHandler) ListChannels(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	channels, err := h.repo(c).ListChannels(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch channels",
+		})
+		return
+	}
+	if channels == nil {
+		channels = []models.ChannelMembership{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels": channels,
+		"total":    len(channels),
+	})
+}
+
+// GetChannel returns a channel with the role of the current user
+func (h *ChannelHandler) GetChannel(c *gin.Context) {
+	channel, err := h.repo(c).GetChannel(c.GetString(channelIDKey))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "channel not found",
+			Message: "channel not found or not a member",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ChannelMembership{Channel: channel, Role: channelRole(c)})
+}
+
+// UpdateChannel renames a channel or changes its description
+func (h *ChannelHandler) UpdateChannel(c *gin.Context) {
+	var req models.ChannelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	channel, err := h.repo(c).GetChannel(c.GetString(channelIDKey))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "channel not found",
+			Message: "channel not found or not a member",
+		})
+		return
+	}
+
+	channel.Name = req.Name
+	channel.Description = req.Description
+	if err := h.repo(c).UpdateChannel(&channel); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to update channel",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ChannelMembership{Channel: channel, Role: models.ChannelRoleOwner})
+}
+
+// DeleteChannel deletes a channel with all of its items
+func (h *ChannelHandler) DeleteChannel(c *gin.Context) {
+	channelID := c.GetString(channelIDKey)
+	members, err := h.repo(c).Members(channelID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch channel members",
+		})
+		return
+	}
+
+	if err := h.repo(c).DeleteChannel(channelID); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to delete channel",
+		})
+		return
+	}
+
+	for _, member := range members {
+		events.PublishMemberRemoved(channelID, member.UserID)
+	}
+	logging.For(c).Info("已删除频道", "handler", "DeleteChannel", "channel_id", channelID)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "channel deleted successfully",
+	})
+}
+
+// ListMembers lists the members of a channel with their usernames
+func (h *ChannelHandler) ListMembers(c *gin.Context) {
+	members, err := h.repo(c).Members(c.GetString(channelIDKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch channel members",
+		})
+		return
+	}
+
+	users := h.users.WithContext(c.Request.Context())
+	for i := range members {
+		if user, err := users.GetUser(members[i].UserID); err == nil {
+			members[i].Username = user.Username
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"total":   len(members),
+	})
+}
+
+// UpdateMember changes the role of a member
+func (h *ChannelHandler) UpdateMember(c *gin.Context) {
+	var req models.ChannelMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	channelID := c.GetString(channelIDKey)
+	memberID := c.Param("user_id")
+	if !h.changeMember(c, h.repo(c).SetRole(channelID, memberID, req.Role)) {
+		return
+	}
+
+	member, err := h.repo(c).Member(channelID, memberID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch channel member",
+		})
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+// RemoveMember removes a member from a channel. Owners remove any member,
+// other members can only leave the channel themselves.
+func (h *ChannelHandler) RemoveMember(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	channelID := c.GetString(channelIDKey)
+	memberID := c.Param("user_id")
+	if memberID != userID && channelRole(c) != models.ChannelRoleOwner {
+		c.JSON(http.StatusForbidden, models.ErrorResponse{
+			Error:   "forbidden",
+			Message: "only owners can remove other members",
+		})
+		return
+	}
+
+	if !h.changeMember(c, h.repo(c).RemoveMember(channelID, memberID)) {
+		return
+	}
+
+	events.PublishMemberRemoved(channelID, memberID)
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "member removed successfully",
+	})
+}
+
+// changeMember writes the response of a failed member change and reports
+// whether the change succeeded
+func (h *ChannelHandler) changeMember(c *gin.Context, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case repository.ErrNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "member not found",
+			Message: "the user is not a member of the channel",
+		})
+	case repository.ErrLastOwner:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "last owner",
+			Message: "a channel must keep at least one owner, delete the channel instead",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to update channel member",
+		})
+	}
+	return false
+}
+
+// CreateInvitation invites a user to the channel by username. Inviting a user
+// again replaces the pending invitation.
+func (h *ChannelHandler) CreateInvitation(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.ChannelInvitationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	invitee, err := h.users.WithContext(c.Request.Context()).FindByUsername(req.Username)
+	if err != nil || !invitee.IsActive {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "user not found",
+			Message: "no active user with this username",
+		})
+		return
+	}
+
+	invitation := models.ChannelInvitation{
+		ChannelID: c.GetString(channelIDKey),
+		InviteeID: invitee.ID,
+		InviterID: userID,
+		Role:      req.Role,
+		ExpiresAt: time.Now().Add(channelInvitationTTL),
+	}
+	err = h.repo(c).CreateInvitation(&invitation)
+	if err == repository.ErrAlreadyMember {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already member",
+			Message: "the user is already a member of the channel",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to create invitation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// ListChannelInvitations lists the pending invitations to a channel
+func (h *ChannelHandler) ListChannelInvitations(c *gin.Context) {
+	invitations, err := h.repo(c).ChannelInvitations(c.GetString(channelIDKey))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch invitations",
+		})
+		return
+	}
+	h.invitationList(c, invitations)
+}
+
+// RevokeInvitation deletes a pending invitation to the channel
+func (h *ChannelHandler) RevokeInvitation(c *gin.Context) {
+	invitation, err := h.repo(c).GetInvitation(c.Param("invitation_id"))
+	if err != nil || invitation.ChannelID != c.GetString(channelIDKey) {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "invitation not found",
+			Message: "invitation not found or expired",
+		})
+		return
+	}
+
+	if err := h.repo(c).DeleteInvitation(invitation.ID); err != nil && err != repository.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to revoke invitation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "invitation revoked successfully",
+	})
+}
+
+// ListInvitations lists the pending invitations of the current user
+func (h *ChannelHandler) ListInvitations(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	invitations, err := h.repo(c).UserInvitations(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch invitations",
+		})
+		return
+	}
+	h.invitationList(c, invitations)
+}
+
+// invitationList responds with invitations and the names of their channels
+func (h *ChannelHandler) invitationList(c *gin.Context, invitations []models.ChannelInvitation) {
+	if invitations == nil {
+		invitations = []models.ChannelInvitation{}
+	}
+	for i := range invitations {
+		if channel, err := h.repo(c).GetChannel(invitations[i].ChannelID); err == nil {
+			invitations[i].ChannelName = channel.Name
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invitations": invitations,
+		"total":       len(invitations),
+	})
+}
+
+// userInvitation returns the pending invitation of the current user named by
+// the :id parameter, or writes the error response
+func (h *ChannelHandler) userInvitation(c *gin.Context) (models.ChannelInvitation, bool) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return models.ChannelInvitation{}, false
+	}
+
+	invitation, err := h.repo(c).GetInvitation(c.Param("id"))
+	if err != nil || invitation.InviteeID != userID {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "invitation not found",
+			Message: "invitation not found or expired",
+		})
+		return models.ChannelInvitation{}, false
+	}
+	return invitation, true
+}
+
+// AcceptInvitation makes the current user a member of the invited channel
+func (h *ChannelHandler) AcceptInvitation(c *gin.Context) {
+	invitation, ok := h.userInvitation(c)
+	if !ok {
+		return
+	}
+
+	member, err := h.repo(c).AcceptInvitation(invitation)
+	switch err {
+	case nil:
+	case repository.ErrNotFound:
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "invitation not found",
+			Message: "invitation not found or expired",
+		})
+		return
+	case repository.ErrAlreadyMember:
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "already member",
+			Message: "you are already a member of the channel",
+		})
+		return
+	default:
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "accept failed",
+			Message: "failed to accept invitation",
+		})
+		return
+	}
+
+	logging.For(c).Info("已加入频道", "handler", "AcceptInvitation", "channel_id", member.ChannelID, "role", member.Role)
+	c.JSON(http.StatusOK, member)
+}
+
+// DeclineInvitation deletes an invitation of the current user
+func (h *ChannelHandler) DeclineInvitation(c *gin.Context) {
+	invitation, ok := h.userInvitation(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo(c).DeleteInvitation(invitation.ID); err != nil && err != repository.ErrNotFound {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "decline failed",
+			Message: "failed to decline invitation",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "invitation declined",
+	})
+}
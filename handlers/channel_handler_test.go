@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/events"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupChannelRouter 使用内存存储创建频道路由，返回每个用户的 token
+func setupChannelRouter(t *testing.T) (*gin.Engine, map[string]string) {
+	t.Parallel()
+
+	tokens := make(map[string]string)
+	var users []models.User
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := models.User{ID: name + "-id", Username: name, Email: name + "@example.com", IsActive: true}
+		users = append(users, user)
+		tokens[name], _ = auth.GenerateToken(user.ID, user.Username, user.Email)
+	}
+
+	channelHandler := NewChannelHandler(repository.NewMemoryChannelRepository(), repository.NewMemoryUserRepository(users...))
+	clipboardHandler := NewClipboardHandler(repository.NewMemoryClipboardRepository())
+
+	router := gin.New()
+	authenticated := router.Group("/")
+	authenticated.Use(auth.JWTAuthMiddleware())
+	authenticated.GET("/items", clipboardHandler.GetItems)
+	authenticated.POST("/channels", channelHandler.CreateChannel)
+	authenticated.GET("/channels", channelHandler.ListChannels)
+	authenticated.GET("/invitations", channelHandler.ListInvitations)
+	authenticated.POST("/invitations/:id/accept", channelHandler.AcceptInvitation)
+
+	reader := authenticated.Group("/channels/:channel_id", channelHandler.RequireRole(models.ChannelRoleReader))
+	writer := authenticated.Group("/channels/:channel_id", channelHandler.RequireRole(models.ChannelRoleWriter))
+	owner := authenticated.Group("/channels/:channel_id", channelHandler.RequireRole(models.ChannelRoleOwner))
+	reader.GET("", channelHandler.GetChannel)
+	owner.POST("/invitations", channelHandler.CreateInvitation)
+	owner.PUT("/members/:user_id", channelHandler.UpdateMember)
+	reader.DELETE("/members/:user_id", channelHandler.RemoveMember)
+	reader.GET("/clipboard/items", clipboardHandler.GetItems)
+	writer.POST("/clipboard/items", clipboardHandler.CreateItem)
+	writer.POST("/clipboard/sync", clipboardHandler.BatchSync)
+
+	return router, tokens
+}
+
+// joinChannel 邀请用户并以该用户身份接受邀请
+func joinChannel(t *testing.T, router *gin.Engine, tokens map[string]string, channelID, username string, role models.ChannelRole) {
+	t.Helper()
+	w := doJSON(router, "POST", "/channels/"+channelID+"/invitations", tokens["alice"], gin.H{"username": username, "role": role})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("邀请 %s 失败: %d %s", username, w.Code, w.Body.String())
+	}
+	var invitation models.ChannelInvitation
+	json.Unmarshal(w.Body.Bytes(), &invitation)
+
+	if w := doJSON(router, "POST", "/invitations/"+invitation.ID+"/accept", tokens[username], nil); w.Code != http.StatusOK {
+		t.Fatalf("%s 接受邀请失败: %d %s", username, w.Code, w.Body.String())
+	}
+}
+
+func TestChannelSharing(t *testing.T) {
+	router, tokens := setupChannelRouter(t)
+
+	w := doJSON(router, "POST", "/channels", tokens["alice"], gin.H{"name": "team"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建频道失败: %d %s", w.Code, w.Body.String())
+	}
+	var channel models.ChannelMembership
+	json.Unmarshal(w.Body.Bytes(), &channel)
+	if channel.Role != models.ChannelRoleOwner {
+		t.Errorf("创建者应为所有者，实际得到 %q", channel.Role)
+	}
+	base := "/channels/" + channel.ID
+
+	if w := doJSON(router, "GET", base, tokens["bob"], nil); w.Code != http.StatusNotFound {
+		t.Errorf("非成员访问频道应返回 404，实际得到 %d", w.Code)
+	}
+	if w := doJSON(router, "POST", base+"/invitations", tokens["alice"], gin.H{"username": "nobody", "role": "reader"}); w.Code != http.StatusNotFound {
+		t.Errorf("邀请不存在的用户应返回 404，实际得到 %d", w.Code)
+	}
+
+	// 被邀请者能看到邀请及频道名称
+	doJSON(router, "POST", base+"/invitations", tokens["alice"], gin.H{"username": "bob", "role": "reader"})
+	w = doJSON(router, "GET", "/invitations", tokens["bob"], nil)
+	var invitations struct {
+		Invitations []models.ChannelInvitation `json:"invitations"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &invitations)
+	if len(invitations.Invitations) != 1 || invitations.Invitations[0].ChannelName != "team" {
+		t.Fatalf("期望 1 个 team 频道的邀请，实际得到 %s", w.Body.String())
+	}
+	if w := doJSON(router, "POST", "/invitations/"+invitations.Invitations[0].ID+"/accept", tokens["carol"], nil); w.Code != http.StatusNotFound {
+		t.Errorf("不应接受其他用户的邀请，实际得到 %d", w.Code)
+	}
+	joinChannel(t, router, tokens, channel.ID, "bob", models.ChannelRoleReader)
+	joinChannel(t, router, tokens, channel.ID, "carol", models.ChannelRoleWriter)
+
+	stream, unsubscribe := events.Default.Subscribe(events.ChannelTopic(channel.ID))
+	defer unsubscribe()
+
+	if w := doJSON(router, "POST", base+"/clipboard/items", tokens["bob"], gin.H{"content": "from bob"}); w.Code != http.StatusForbidden {
+		t.Errorf("只读成员不应推送项目，实际得到 %d", w.Code)
+	}
+	w = doJSON(router, "POST", base+"/clipboard/items", tokens["carol"], gin.H{"content": "from carol"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("写入成员推送项目失败: %d %s", w.Code, w.Body.String())
+	}
+	if event := <-stream; event.Type != events.ItemCreated || event.Item.AuthorID != "carol-id" {
+		t.Errorf("频道订阅者应收到创建事件，实际得到 %+v", event)
+	}
+
+	w = doJSON(router, "GET", base+"/clipboard/items", tokens["bob"], nil)
+	var page models.PaginationResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if page.Total != 1 || page.Items[0].Content != "from carol" || page.Items[0].ChannelID != channel.ID {
+		t.Errorf("成员应看到频道项目，实际得到 %s", w.Body.String())
+	}
+	w = doJSON(router, "GET", "/items", tokens["carol"], nil)
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if page.Total != 0 {
+		t.Errorf("频道项目不应出现在个人剪贴板，实际得到 %d 条", page.Total)
+	}
+
+	if w := doJSON(router, "DELETE", base+"/members/carol-id", tokens["bob"], nil); w.Code != http.StatusForbidden {
+		t.Errorf("非所有者不应移除其他成员，实际得到 %d", w.Code)
+	}
+	if w := doJSON(router, "DELETE", base+"/members/bob-id", tokens["bob"], nil); w.Code != http.StatusOK {
+		t.Errorf("成员应能退出频道，实际得到 %d", w.Code)
+	}
+	if event := <-stream; event.Type != events.MemberRemoved || event.MemberID != "bob-id" {
+		t.Errorf("应收到成员移除事件，实际得到 %+v", event)
+	}
+	if w := doJSON(router, "GET", base+"/clipboard/items", tokens["bob"], nil); w.Code != http.StatusNotFound {
+		t.Errorf("退出后不应读取频道项目，实际得到 %d", w.Code)
+	}
+
+	if w := doJSON(router, "DELETE", base+"/members/alice-id", tokens["alice"], nil); w.Code != http.StatusConflict {
+		t.Errorf("最后一个所有者不应退出频道，实际得到 %d", w.Code)
+	}
+	if w := doJSON(router, "PUT", base+"/members/carol-id", tokens["alice"], gin.H{"role": "admin"}); w.Code != http.StatusBadRequest {
+		t.Errorf("无效的角色应被拒绝，实际得到 %d", w.Code)
+	}
+	if w := doJSON(router, "PUT", base+"/members/carol-id", tokens["alice"], gin.H{"role": "owner"}); w.Code != http.StatusOK {
+		t.Fatalf("修改角色失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(router, "DELETE", base+"/members/alice-id", tokens["alice"], nil); w.Code != http.StatusOK {
+		t.Errorf("有其他所有者时应能退出频道，实际得到 %d", w.Code)
+	}
+}
+
+func TestChannelBatchSync(t *testing.T) {
+	router, tokens := setupChannelRouter(t)
+
+	w := doJSON(router, "POST", "/channels", tokens["alice"], gin.H{"name": "sync"})
+	var channel models.ChannelMembership
+	json.Unmarshal(w.Body.Bytes(), &channel)
+	base := "/channels/" + channel.ID
+
+	stream, unsubscribe := events.Default.Subscribe(events.ChannelTopic(channel.ID))
+	defer unsubscribe()
+
+	w = doJSON(router, "POST", base+"/clipboard/sync", tokens["alice"], gin.H{
+		"device_id": "laptop",
+		"items":     []gin.H{{"client_id": "c1", "content": "batch item"}},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("频道批量同步失败: %d %s", w.Code, w.Body.String())
+	}
+	select {
+	case event := <-stream:
+		if event.Type != events.ItemCreated || event.Item.ChannelID != channel.ID {
+			t.Errorf("频道订阅者应收到创建事件，实际得到 %+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("频道订阅者未收到批量同步的创建事件")
+	}
+
+	var page models.PaginationResponse
+	json.Unmarshal(doJSON(router, "GET", base+"/clipboard/items", tokens["alice"], nil).Body.Bytes(), &page)
+	if page.Total != 1 || page.Items[0].ChannelID != channel.ID {
+		t.Errorf("批量同步的项目应写入频道，实际得到 %d 条", page.Total)
+	}
+	json.Unmarshal(doJSON(router, "GET", "/items", tokens["alice"], nil).Body.Bytes(), &page)
+	if page.Total != 0 {
+		t.Errorf("批量同步的项目不应出现在个人剪贴板，实际得到 %d 条", page.Total)
+	}
+}
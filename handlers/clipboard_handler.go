@@ -2,25 +2,48 @@ package handlers
 
 import (
 	"clipboard-server/auth"
+	"clipboard-server/classify"
 	"clipboard-server/config"
-	"clipboard-server/database"
+	"clipboard-server/events"
+	"clipboard-server/logging"
+	"clipboard-server/metrics"
 	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/sensitive"
+	"clipboard-server/tracing"
 	"clipboard-server/utils"
-	"log"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // ClipboardHandler for clipboard related handlers
-type ClipboardHandler struct{}
+type ClipboardHandler struct {
+	items repository.ClipboardRepository
+}
 
 // NewClipboardHandler creates clipboard handler instance
-func NewClipboardHandler() *ClipboardHandler {
-	return &ClipboardHandler{}
+func NewClipboardHandler(items repository.ClipboardRepository) *ClipboardHandler {
+	return &ClipboardHandler{items: items}
+}
+
+// repo returns the repository bound to the context of the request, scoped to
+// the channel of channel routes
+func (h *ClipboardHandler) repo(c *gin.Context) repository.ClipboardRepository {
+	repo := h.items.WithContext(c.Request.Context())
+	if channelID := c.GetString(channelIDKey); channelID != "" {
+		repo = repo.InChannel(channelID)
+	}
+	return repo
 }
 
 // CreateItem creates clipboard item
@@ -67,14 +90,44 @@ func (h *ClipboardHandler) CreateItem(c *gin.Context) {
 		req.Type = models.ClipboardTypeText
 	}
 
-	// Sanitize sensitive content
-	sanitizedContent := utils.SanitizeContent(req.Content)
+	expiresAt, err := requestedExpiry(req.ExpiresAt, req.TTL, req.ClearExpiry, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid expiry",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Encryption == nil && h.repo(c).RequiresEncryption(userID) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "encryption required",
+			Message: "end-to-end encryption is required for this account",
+		})
+		return
+	}
+
+	if req.ClientID != "" {
+		if _, err := h.repo(c).FindByClientID(userID, req.ClientID); err == nil {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "duplicate client id",
+				Message: "an item with this client ID already exists",
+			})
+			return
+		}
+	}
 
 	// Create clipboard item
 	item := models.ClipboardItem{
-		UserID:  userID,
-		Content: sanitizedContent,
-		Type:    req.Type,
+		UserID:    userID,
+		ClientID:  req.ClientID,
+		Type:      req.Type,
+		ExpiresAt: expiresAt,
+	}
+	report := applyContent(&item, req.Content, req.Encryption, h.repo(c).SensitivePolicy(userID))
+	if report != nil && report.Action == models.SensitiveActionReject {
+		rejectSensitive(c, report)
+		return
 	}
 
 	// Use provided timestamp or current time
@@ -84,8 +137,7 @@ func (h *ClipboardHandler) CreateItem(c *gin.Context) {
 		item.Timestamp = time.Now()
 	}
 
-	db := database.GetDB()
-	if err := db.Create(&item).Error; err != nil {
+	if err := h.repo(c).CreateItem(&item); err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "creation failed",
 			Message: "failed to create clipboard item",
@@ -93,7 +145,9 @@ func (h *ClipboardHandler) CreateItem(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, item.ToResponse())
+	events.PublishItem(events.ItemCreated, item, sourceDevice(c))
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusCreated, withSensitivity(item, report))
 }
 
 // GetItems gets clipboard items list
@@ -124,40 +178,30 @@ func (h *ClipboardHandler) GetItems(c *gin.Context) {
 		query.PageSize = 20
 	}
 
-	db := database.GetDB()
-
-	// Build query
-	dbQuery := db.Model(&models.ClipboardItem{}).Where("user_id = ?", userID)
+	filter := repository.ItemFilter{
+		Encrypted: query.Encrypted,
+		Search:    query.Search,
+		Offset:    (query.Page - 1) * query.PageSize,
+		Limit:     query.PageSize,
+	}
 
 	// Time filter
 	if query.Since != "" {
 		if sinceTime, err := time.Parse(time.RFC3339, query.Since); err == nil {
-			dbQuery = dbQuery.Where("timestamp >= ?", sinceTime)
+			filter.Since = &sinceTime
 		}
 	}
 
 	// Type filter
 	if query.Type != "" && utils.IsValidContentType(query.Type) {
-		dbQuery = dbQuery.Where("type = ?", query.Type)
+		filter.Type = models.ClipboardType(query.Type)
 	}
-
-	// Content search
-	if query.Search != "" {
-		dbQuery = dbQuery.Where("content LIKE ?", "%"+query.Search+"%")
+	if query.Subtype != "" && utils.IsValidContentSubtype(query.Subtype) {
+		filter.Subtype = models.ClipboardSubtype(query.Subtype)
 	}
 
-	// Get total count
-	var total int64
-	dbQuery.Count(&total)
-
-	// Paginated query
-	var items []models.ClipboardItem
-	offset := (query.Page - 1) * query.PageSize
-
-	if err := dbQuery.Order("timestamp DESC").
-		Offset(offset).
-		Limit(query.PageSize).
-		Find(&items).Error; err != nil {
+	items, total, err := h.repo(c).ListItems(userID, filter)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "query failed",
 			Message: "failed to query clipboard items",
@@ -210,11 +254,9 @@ func (h *ClipboardHandler) GetItem(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-	var item models.ClipboardItem
-
-	if err := db.Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	item, err := h.repo(c).GetItem(userID, itemID)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "item not found",
 				Message: "clipboard item not found",
@@ -228,6 +270,7 @@ func (h *ClipboardHandler) GetItem(c *gin.Context) {
 		return
 	}
 
+	c.Header("ETag", itemETag(item))
 	c.JSON(http.StatusOK, item.ToResponse())
 }
 
@@ -270,12 +313,27 @@ func (h *ClipboardHandler) UpdateItem(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-	var item models.ClipboardItem
+	expiresAt, err := requestedExpiry(req.ExpiresAt, req.TTL, req.ClearExpiry, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid expiry",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Encryption == nil && h.repo(c).RequiresEncryption(userID) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "encryption required",
+			Message: "end-to-end encryption is required for this account",
+		})
+		return
+	}
 
 	// Find item
-	if err := db.Where("id = ? AND user_id = ?", itemID, userID).First(&item).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
+	item, err := h.repo(c).GetItem(userID, itemID)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "item not found",
 				Message: "clipboard item not found",
@@ -289,17 +347,34 @@ func (h *ClipboardHandler) UpdateItem(c *gin.Context) {
 		return
 	}
 
+	// Reject the update if the client edited an older version
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" && !matchesETag(ifMatch, itemETag(item)) {
+		preconditionFailed(c, item)
+		return
+	}
+
 	// Update fields
-	item.Content = utils.SanitizeContent(req.Content)
 	if req.Type != "" && utils.IsValidContentType(string(req.Type)) {
 		item.Type = req.Type
 	}
+	setExpiry(&item, expiresAt, req.ClearExpiry)
+	report := applyContent(&item, req.Content, req.Encryption, h.repo(c).SensitivePolicy(userID))
+	if report != nil && report.Action == models.SensitiveActionReject {
+		rejectSensitive(c, report)
+		return
+	}
 	if req.Timestamp != nil {
 		item.Timestamp = req.Timestamp.Time
 	}
 
 	// Save update
-	if err := db.Save(&item).Error; err != nil {
+	if err := h.repo(c).SaveItem(&item); err != nil {
+		if err == repository.ErrVersionConflict {
+			if current, err := h.repo(c).GetItem(userID, item.ID); err == nil {
+				preconditionFailed(c, current)
+				return
+			}
+		}
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "update failed",
 			Message: "failed to update clipboard item",
@@ -307,7 +382,9 @@ func (h *ClipboardHandler) UpdateItem(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, item.ToResponse())
+	events.PublishItem(events.ItemUpdated, item, sourceDevice(c))
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusOK, withSensitivity(item, report))
 }
 
 // DeleteItem deletes clipboard item
@@ -330,53 +407,53 @@ func (h *ClipboardHandler) DeleteItem(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
-	// Delete item (ensure only own items can be deleted)
-	result := db.Where("id = ? AND user_id = ?", itemID, userID).Delete(&models.ClipboardItem{})
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "deletion failed",
-			Message: "failed to delete clipboard item",
-		})
-		return
-	}
-
-	if result.RowsAffected == 0 {
+	// Only own items can be deleted
+	deleted, err := h.repo(c).DeleteItem(userID, itemID)
+	if err == repository.ErrNotFound {
 		c.JSON(http.StatusNotFound, models.ErrorResponse{
 			Error:   "item not found",
 			Message: "clipboard item not found",
 		})
 		return
 	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to delete clipboard item",
+		})
+		return
+	}
 
+	events.PublishDeletions(deleted, sourceDevice(c))
 	c.JSON(http.StatusOK, models.SuccessResponse{
 		Message: "clipboard item deleted successfully",
 	})
 }
 
+// errBatchRejected aborts an atomic batch in which an item failed
+var errBatchRejected = errors.New("batch rejected")
+
 // BatchSync batch sync clipboard items
 func (h *ClipboardHandler) BatchSync(c *gin.Context) {
-	log.Printf("[BatchSync] 开始处理批量同步请求")
+	logger := logging.For(c).With("handler", "BatchSync")
+	logger.Debug("开始处理批量同步请求")
 
 	userID, exists := auth.GetCurrentUserID(c)
 	if !exists {
-		log.Printf("[BatchSync] 用户未认证")
+		logger.Warn("用户未认证")
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "unauthorized",
 			Message: "user not authenticated",
 		})
 		return
 	}
-	log.Printf("[BatchSync] 用户ID: %s", userID)
 
 	var req models.BatchSyncRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("[BatchSync] JSON解析失败: %v", err)
-		log.Printf("[BatchSync] 原始请求体长度: %d", c.Request.ContentLength)
-		if body, readErr := c.GetRawData(); readErr == nil {
-			log.Printf("[BatchSync] 原始请求体前500字符: %s", utils.TruncateString(string(body), 500))
-		}
+	_, bindSpan := tracing.Start(c.Request.Context(), "BatchSync.bind")
+	err := c.ShouldBindJSON(&req)
+	bindSpan.End()
+	if err != nil {
+		logger.Warn("JSON解析失败", "error", err, "content_length", c.Request.ContentLength)
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid request",
 			Message: err.Error(),
@@ -384,10 +461,15 @@ func (h *ClipboardHandler) BatchSync(c *gin.Context) {
 		return
 	}
 
-	log.Printf("[BatchSync] 成功解析请求，设备ID: %s, 项目数量: %d", req.DeviceID, len(req.Items))
+	logger.Debug("成功解析请求", "device_id", req.DeviceID, "items", len(req.Items))
+
+	deviceID := req.DeviceID
+	if deviceID == "" {
+		deviceID = sourceDevice(c)
+	}
 
 	if len(req.Items) == 0 {
-		log.Printf("[BatchSync] 请求中没有项目")
+		logger.Warn("请求中没有项目")
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "empty request",
 			Message: "no items to sync",
@@ -395,86 +477,304 @@ func (h *ClipboardHandler) BatchSync(c *gin.Context) {
 		return
 	}
 
-	cfg := config.GetConfig()
-	db := database.GetDB()
-	log.Printf("[BatchSync] 配置最大内容大小: %d 字节", cfg.MaxContentSize)
-
-	var synced []models.ClipboardItemResponse
-	var failed []models.FailedItem
+	if req.Mode == "" {
+		req.Mode = models.BatchModeBestEffort
+	}
 
-	// Process each item in batch
-	for i, itemReq := range req.Items {
-		log.Printf("[BatchSync] 处理第 %d 个项目，内容长度: %d, 类型: %s",
-			i+1, len(itemReq.Content), itemReq.Type)
-		log.Printf("[BatchSync] 项目内容前50字符: %s",
-			utils.TruncateString(itemReq.Content, 50))
-
-		// Validate content size
-		contentSize := utils.GetContentSize(itemReq.Content)
-		if contentSize > cfg.MaxContentSize {
-			log.Printf("[BatchSync] 项目 %d 内容过大: %d > %d", i+1, contentSize, cfg.MaxContentSize)
-			failed = append(failed, models.FailedItem{
-				Content: utils.TruncateString(itemReq.Content, 50),
-				Error:   "content too large",
+	cfg := config.GetConfig()
+	logger.Debug("开始同步", "max_content_size", cfg.MaxContentSize, "mode", req.Mode)
+
+	// A retried batch with the same Idempotency-Key returns the original outcome
+	idempotencyKey := c.GetHeader("Idempotency-Key")
+	if idempotencyKey != "" {
+		if len(idempotencyKey) > 255 {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid request",
+				Message: "Idempotency-Key must be at most 255 characters",
 			})
-			continue
+			return
 		}
 
-		// Validate content type
-		if itemReq.Type != "" && !utils.IsValidContentType(string(itemReq.Type)) {
-			log.Printf("[BatchSync] 项目 %d 类型无效: %s", i+1, itemReq.Type)
-			failed = append(failed, models.FailedItem{
-				Content: utils.TruncateString(itemReq.Content, 50),
-				Error:   "invalid content type",
+		record, err := h.repo(c).ReserveIdempotencyKey(userID, idempotencyKey, batchHash(req))
+		switch {
+		case err == repository.ErrIdempotencyKeyReused:
+			c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+				Error:   "idempotency key reused",
+				Message: err.Error(),
+			})
+			return
+		case err == repository.ErrIdempotencyInProgress:
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "request in progress",
+				Message: err.Error(),
+			})
+			return
+		case err != nil:
+			logger.Error("幂等键处理失败", "error", err)
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "database error",
+				Message: "failed to check idempotency key",
 			})
-			continue
+			return
+		case record != nil:
+			logger.Info("重放幂等键的结果", "idempotency_key", idempotencyKey)
+			h.replayBatch(c, record)
+			return
 		}
+	}
 
-		// Set default type
-		if itemReq.Type == "" {
-			itemReq.Type = models.ClipboardTypeText
-			log.Printf("[BatchSync] 项目 %d 设置默认类型: text", i+1)
-		}
+	var synced []models.ClipboardItemResponse
+	var failed []models.FailedItem
+	var changes []batchChange
+	deduplicated := 0
+
+	encryptionRequired := h.repo(c).RequiresEncryption(userID)
+	policy := h.repo(c).SensitivePolicy(userID)
+
+	// Failed items are identified by index and client ID, their content is
+	// not echoed back
+	fail := func(i int, itemReq models.ClipboardItemRequest, reason string) {
+		failed = append(failed, models.FailedItem{
+			Index:    i,
+			ClientID: itemReq.ClientID,
+			Error:    reason,
+		})
+	}
 
-		// Create item
-		item := models.ClipboardItem{
-			UserID:  userID,
-			Content: utils.SanitizeContent(itemReq.Content),
-			Type:    itemReq.Type,
-		}
+	ctx, itemsSpan := tracing.Start(c.Request.Context(), "BatchSync.items",
+		attribute.Int("batch.size", len(req.Items)),
+		attribute.String("batch.mode", string(req.Mode)),
+	)
+	err = h.repo(c).WithContext(ctx).Transaction(func(tx repository.ClipboardRepository) error {
+		// Process each item in batch
+		for i, itemReq := range req.Items {
+			logger.Debug("处理项目", "index", i+1, "content_length", len(itemReq.Content), "type", itemReq.Type,
+				"content", logging.Content(itemReq.Content))
+
+			// Validate content size
+			contentSize := utils.GetContentSize(itemReq.Content)
+			if contentSize > cfg.MaxContentSize {
+				logger.Warn("项目内容过大", "index", i+1, "size", contentSize, "max_content_size", cfg.MaxContentSize)
+				fail(i, itemReq, "content too large")
+				continue
+			}
+
+			if len(itemReq.ClientID) > 100 {
+				logger.Warn("项目客户端ID过长", "index", i+1)
+				fail(i, itemReq, "invalid client id")
+				continue
+			}
+
+			// Validate content type
+			if itemReq.Type != "" && !utils.IsValidContentType(string(itemReq.Type)) {
+				logger.Warn("项目类型无效", "index", i+1, "type", itemReq.Type)
+				fail(i, itemReq, "invalid content type")
+				continue
+			}
+
+			expiresAt, err := requestedExpiry(itemReq.ExpiresAt, itemReq.TTL, itemReq.ClearExpiry, time.Now())
+			if err != nil {
+				logger.Warn("项目过期时间无效", "index", i+1, "error", err)
+				fail(i, itemReq, "invalid expiry")
+				continue
+			}
+
+			if itemReq.Encryption == nil && encryptionRequired {
+				logger.Warn("项目未加密，但用户要求端到端加密", "index", i+1)
+				fail(i, itemReq, "encryption required")
+				continue
+			}
+
+			// Set default type
+			if itemReq.Type == "" {
+				itemReq.Type = models.ClipboardTypeText
+			}
+
+			timestamp := time.Now()
+			if itemReq.Timestamp != nil {
+				timestamp = itemReq.Timestamp.Time
+			}
 
-		if itemReq.Timestamp != nil {
-			item.Timestamp = itemReq.Timestamp.Time
-			log.Printf("[BatchSync] 项目 %d 使用提供的时间戳: %v", i+1, itemReq.Timestamp.Time)
-		} else {
-			item.Timestamp = time.Now()
-			log.Printf("[BatchSync] 项目 %d 使用当前时间戳", i+1)
+			// Items already stored under their client ID are not duplicated
+			var item models.ClipboardItem
+			exists := false
+			if itemReq.ClientID != "" {
+				item, err = tx.FindByClientID(userID, itemReq.ClientID)
+				if err != nil && err != repository.ErrNotFound {
+					return err
+				}
+				exists = err == nil
+			}
+
+			if exists && ((item.Content == itemReq.Content && item.Type == itemReq.Type) || item.Timestamp.After(timestamp)) {
+				logger.Debug("项目已存在，跳过", "index", i+1, "client_id", itemReq.ClientID)
+				synced = append(synced, item.ToResponse())
+				deduplicated++
+				continue
+			}
+
+			if !exists {
+				item = models.ClipboardItem{
+					UserID:   userID,
+					ClientID: itemReq.ClientID,
+				}
+			}
+			item.Type = itemReq.Type
+			item.Timestamp = timestamp
+			setExpiry(&item, expiresAt, itemReq.ClearExpiry)
+
+			report := applyContent(&item, itemReq.Content, itemReq.Encryption, policy)
+			if report != nil && report.Action == models.SensitiveActionReject {
+				logger.Warn("项目包含敏感内容，按策略拒绝", "index", i+1)
+				failed = append(failed, models.FailedItem{
+					Index:       i,
+					ClientID:    itemReq.ClientID,
+					Error:       "sensitive content rejected",
+					Sensitivity: report,
+				})
+				continue
+			}
+
+			// In best effort mode a failed write only rolls back its own item
+			savepoint := fmt.Sprintf("batch_item_%d", i)
+			if req.Mode == models.BatchModeBestEffort {
+				if err := tx.SavePoint(savepoint); err != nil {
+					return err
+				}
+			}
+
+			event := events.ItemCreated
+			if exists {
+				event = events.ItemUpdated
+				err = tx.SaveItem(&item)
+			} else {
+				err = tx.CreateItem(&item)
+			}
+			if err != nil {
+				logger.Error("项目数据库保存失败", "index", i+1, "error", err)
+				if req.Mode == models.BatchModeAtomic {
+					return err
+				}
+				if err := tx.RollbackTo(savepoint); err != nil {
+					return err
+				}
+				fail(i, itemReq, "database error")
+				continue
+			}
+
+			logger.Debug("项目成功保存", "index", i+1, "item_id", item.ID)
+			synced = append(synced, withSensitivity(item, report))
+			changes = append(changes, batchChange{event, item})
 		}
 
-		log.Printf("[BatchSync] 尝试保存项目 %d 到数据库", i+1)
-		if err := db.Create(&item).Error; err != nil {
-			log.Printf("[BatchSync] 项目 %d 数据库保存失败: %v", i+1, err)
-			failed = append(failed, models.FailedItem{
-				Content: utils.TruncateString(itemReq.Content, 50),
-				Error:   "database error",
-			})
-			continue
+		if req.Mode == models.BatchModeAtomic && len(failed) > 0 {
+			return errBatchRejected
 		}
+		return nil
+	})
+	if err != nil && err != errBatchRejected {
+		itemsSpan.RecordError(err)
+	}
+	itemsSpan.End()
+
+	status := http.StatusOK
+	switch {
+	case err == errBatchRejected:
+		logger.Warn("原子批次中有项目失败，全部回滚", "failed", len(failed))
+		status = http.StatusUnprocessableEntity
+		synced = nil
+	case err != nil:
+		logger.Error("批量同步失败", "error", err)
+		if idempotencyKey != "" {
+			h.repo(c).ReleaseIdempotencyKey(userID, idempotencyKey)
+		}
+		metrics.BatchSyncItems(metrics.BatchItemFailed, len(req.Items))
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "sync failed",
+			Message: "failed to sync clipboard items",
+		})
+		return
+	}
 
-		log.Printf("[BatchSync] 项目 %d 成功保存，ID: %s", i+1, item.ID)
-		synced = append(synced, item.ToResponse())
+	if status == http.StatusOK {
+		for _, change := range changes {
+			events.PublishItem(change.event, change.item, deviceID)
+			if change.event == events.ItemCreated {
+				metrics.BatchSyncItems(metrics.BatchItemCreated, 1)
+			} else {
+				metrics.BatchSyncItems(metrics.BatchItemUpdated, 1)
+			}
+		}
+		metrics.BatchSyncItems(metrics.BatchItemDeduplicated, deduplicated)
 	}
+	metrics.BatchSyncItems(metrics.BatchItemFailed, len(failed))
 
-	log.Printf("[BatchSync] 批量同步完成，成功: %d, 失败: %d, 总计: %d",
-		len(synced), len(failed), len(req.Items))
+	logger.Info("批量同步完成", "synced", len(synced), "failed", len(failed), "total", len(req.Items), "mode", req.Mode)
 
 	response := models.BatchSyncResponse{
+		Mode:   req.Mode,
 		Synced: synced,
 		Failed: failed,
 		Total:  len(req.Items),
 	}
 
-	c.JSON(http.StatusOK, response)
+	if idempotencyKey != "" {
+		record := models.IdempotencyRecord{
+			UserID:     userID,
+			Key:        idempotencyKey,
+			StatusCode: status,
+			Mode:       req.Mode,
+			Total:      len(req.Items),
+		}
+		for _, item := range synced {
+			record.ItemIDs = append(record.ItemIDs, item.ID)
+		}
+		record.Failed = failed
+		if err := h.repo(c).CompleteIdempotencyKey(&record); err != nil {
+			logger.Error("保存幂等键结果失败", "error", err)
+		}
+	}
+
+	c.JSON(status, response)
+}
+
+// batchChange an item written by a batch sync, announced once the batch is committed
+type batchChange struct {
+	event events.Type
+	item  models.ClipboardItem
+}
+
+// batchHash fingerprints a batch request to detect reuse of an idempotency key
+func batchHash(req models.BatchSyncRequest) string {
+	data, _ := json.Marshal(req)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayBatch responds with the stored outcome of a batch sync.
+// Synced items are returned in their current state.
+func (h *ClipboardHandler) replayBatch(c *gin.Context, record *models.IdempotencyRecord) {
+	items, err := h.repo(c).ItemsByID(record.UserID, record.ItemIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to load synced items",
+		})
+		return
+	}
+
+	synced := make([]models.ClipboardItemResponse, len(items))
+	for i, item := range items {
+		synced[i] = item.ToResponse()
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(record.StatusCode, models.BatchSyncResponse{
+		Mode:   record.Mode,
+		Synced: synced,
+		Failed: record.Failed,
+		Total:  record.Total,
+	})
 }
 
 // GetStatistics gets clipboard statistics
@@ -488,70 +788,14 @@ func (h *ClipboardHandler) GetStatistics(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
-	// Total items
-	var totalItems int64
-	db.Model(&models.ClipboardItem{}).Where("user_id = ?", userID).Count(&totalItems)
-
-	// All items stored on server are considered synced
-	syncedItems := totalItems
-
-	// Unsynced items (always 0 for server items)
-	unsyncedItems := int64(0)
-
-	// Total content size
-	var totalContentSize int64
-	db.Model(&models.ClipboardItem{}).
-		Where("user_id = ?", userID).
-		Select("SUM(LENGTH(content))").
-		Scan(&totalContentSize)
-
-	// Type distribution
-	typeDistribution := make(map[string]int64)
-	rows, err := db.Model(&models.ClipboardItem{}).
-		Select("type, COUNT(*) as count").
-		Where("user_id = ?", userID).
-		Group("type").Rows()
-
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var contentType string
-			var count int64
-			if err := rows.Scan(&contentType, &count); err == nil {
-				typeDistribution[contentType] = count
-			}
-		}
-	}
-
-	// Recent 7 days activity
-	var recentActivity []models.DailyActivity
-	sevenDaysAgo := time.Now().AddDate(0, 0, -7)
-
-	activityRows, err := db.Model(&models.ClipboardItem{}).
-		Select("DATE(timestamp) as date, COUNT(*) as count").
-		Where("user_id = ? AND timestamp >= ?", userID, sevenDaysAgo).
-		Group("DATE(timestamp)").
-		Order("date DESC").Rows()
-
-	if err == nil {
-		defer activityRows.Close()
-		for activityRows.Next() {
-			var activity models.DailyActivity
-			if err := activityRows.Scan(&activity.Date, &activity.Count); err == nil {
-				recentActivity = append(recentActivity, activity)
-			}
-		}
-	}
-
-	stats := models.StatisticsResponse{
-		TotalItems:       totalItems,
-		SyncedItems:      syncedItems,
-		UnsyncedItems:    unsyncedItems,
-		TotalContentSize: totalContentSize,
-		TypeDistribution: typeDistribution,
-		RecentActivity:   recentActivity,
+	// Statistics with the activity of the recent 7 days
+	stats, err := h.repo(c).Statistics(userID, time.Now().AddDate(0, 0, -7))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to compute statistics",
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, stats)
@@ -568,8 +812,6 @@ func (h *ClipboardHandler) GetRecentSyncItems(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
 	// Get limit parameter (default 10, max 50)
 	limit := 10
 	if limitParam := c.Query("limit"); limitParam != "" {
@@ -582,14 +824,9 @@ func (h *ClipboardHandler) GetRecentSyncItems(c *gin.Context) {
 		}
 	}
 
-	// Get recent items ordered by created_at desc
-	var items []models.ClipboardItem
-	result := db.Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Limit(limit).
-		Find(&items)
-
-	if result.Error != nil {
+	// Get recent items ordered by created_at desc, and the total count
+	items, totalCount, err := h.repo(c).RecentItems(userID, limit)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "database error",
 			Message: "failed to fetch recent items",
@@ -597,10 +834,6 @@ func (h *ClipboardHandler) GetRecentSyncItems(c *gin.Context) {
 		return
 	}
 
-	// Get total count
-	var totalCount int64
-	db.Model(&models.ClipboardItem{}).Where("user_id = ?", userID).Count(&totalCount)
-
 	// Convert to response format
 	responseItems := make([]models.ClipboardItemResponse, len(items))
 	for i, item := range items {
@@ -626,16 +859,10 @@ func (h *ClipboardHandler) GetLatestSyncItem(c *gin.Context) {
 		return
 	}
 
-	db := database.GetDB()
-
 	// Get the latest item ordered by updated_at desc
-	var item models.ClipboardItem
-	result := db.Where("user_id = ?", userID).
-		Order("updated_at DESC").
-		First(&item)
-
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
+	item, err := h.repo(c).LatestItem(userID)
+	if err != nil {
+		if err == repository.ErrNotFound {
 			c.JSON(http.StatusNotFound, models.ErrorResponse{
 				Error:   "no data found",
 				Message: "no clipboard items found",
@@ -655,9 +882,11 @@ func (h *ClipboardHandler) GetLatestSyncItem(c *gin.Context) {
 
 // SyncSingleItem syncs a single clipboard item by client ID
 func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
+	logger := logging.For(c).With("handler", "SyncSingleItem")
+
 	userID, exists := auth.GetCurrentUserID(c)
 	if !exists {
-		log.Printf("[SyncSingleItem] 用户未认证")
+		logger.Warn("用户未认证")
 		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
 			Error:   "unauthorized",
 			Message: "user not authenticated",
@@ -666,15 +895,23 @@ func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
 	}
 
 	type SyncSingleItemRequest struct {
-		ClientID  string               `json:"client_id" binding:"required"`
-		Content   string               `json:"content" binding:"required"`
-		Type      models.ClipboardType `json:"type"`
-		Timestamp *models.CustomTime   `json:"timestamp"`
+		ClientID    string                     `json:"client_id" binding:"required"`
+		Content     string                     `json:"content" binding:"required"`
+		Type        models.ClipboardType       `json:"type"`
+		Timestamp   *models.CustomTime         `json:"timestamp"`
+		Encryption  *models.EncryptionEnvelope `json:"encryption,omitempty"`
+		ExpiresAt   *models.CustomTime         `json:"expires_at,omitempty"`
+		TTL         *int                       `json:"ttl,omitempty" binding:"omitempty,min=1"`
+		ClearExpiry bool                       `json:"clear_expiry,omitempty"`
+
+		// Conflict handling when the item was changed by another device
+		BaseVersion *int64                  `json:"base_version,omitempty"`
+		Strategy    models.ConflictStrategy `json:"strategy" binding:"omitempty,oneof=lww server_wins conflict"`
 	}
 
 	var req SyncSingleItemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		log.Printf("[SyncSingleItem] JSON解析失败: %v", err)
+		logger.Warn("JSON解析失败", "error", err)
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid request",
 			Message: err.Error(),
@@ -685,7 +922,7 @@ func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
 	// Validate content size
 	cfg := config.GetConfig()
 	if utils.GetContentSize(req.Content) > cfg.MaxContentSize {
-		log.Printf("[SyncSingleItem] 内容过大: %d > %d, client_id=%s", utils.GetContentSize(req.Content), cfg.MaxContentSize, req.ClientID)
+		logger.Warn("内容过大", "size", utils.GetContentSize(req.Content), "max_content_size", cfg.MaxContentSize, "client_id", req.ClientID)
 		c.JSON(http.StatusRequestEntityTooLarge, models.ErrorResponse{
 			Error:   "content too large",
 			Message: "content size exceeds limit",
@@ -700,7 +937,7 @@ func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
 
 	// Validate content type
 	if !utils.IsValidContentType(string(req.Type)) {
-		log.Printf("[SyncSingleItem] 内容类型无效: %s, client_id=%s", req.Type, req.ClientID)
+		logger.Warn("内容类型无效", "type", req.Type, "client_id", req.ClientID)
 		c.JSON(http.StatusBadRequest, models.ErrorResponse{
 			Error:   "invalid content type",
 			Message: "unsupported content type",
@@ -708,32 +945,52 @@ func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
 		return
 	}
 
-	// Sanitize sensitive content
-	sanitizedContent := utils.SanitizeContent(req.Content)
+	expiresAt, err := requestedExpiry(req.ExpiresAt, req.TTL, req.ClearExpiry, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid expiry",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Encryption == nil && h.repo(c).RequiresEncryption(userID) {
+		logger.Warn("未加密内容被拒绝", "client_id", req.ClientID)
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "encryption required",
+			Message: "end-to-end encryption is required for this account",
+		})
+		return
+	}
 
-	db := database.GetDB()
+	policy := h.repo(c).SensitivePolicy(userID)
 
-	// Check if item already exists with this client_id for this user
-	var existingItem models.ClipboardItem
-	err := db.Where("user_id = ? AND client_id = ?", userID, req.ClientID).First(&existingItem).Error
+	// Check if item already exists with this client_id for this user.
+	// An expired item not purged yet is not found, a new item replaces it.
+	existingItem, err := h.repo(c).FindByClientID(userID, req.ClientID)
 
 	timestamp := time.Now()
 	if req.Timestamp != nil {
 		timestamp = req.Timestamp.Time
 	}
 
-	if err == gorm.ErrRecordNotFound {
+	if err == repository.ErrNotFound {
 		// Create new item
 		item := models.ClipboardItem{
 			UserID:    userID,
 			ClientID:  req.ClientID,
-			Content:   sanitizedContent,
 			Type:      req.Type,
 			Timestamp: timestamp,
+			ExpiresAt: expiresAt,
+		}
+		report := applyContent(&item, req.Content, req.Encryption, policy)
+		if report != nil && report.Action == models.SensitiveActionReject {
+			rejectSensitive(c, report)
+			return
 		}
 
-		if err := db.Create(&item).Error; err != nil {
-			log.Printf("[SyncSingleItem] 创建失败: %v", err)
+		if err := h.repo(c).CreateItem(&item); err != nil {
+			logger.Error("创建失败", "error", err)
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 				Error:   "creation failed",
 				Message: "failed to create clipboard item",
@@ -741,32 +998,406 @@ func (h *ClipboardHandler) SyncSingleItem(c *gin.Context) {
 			return
 		}
 
-		log.Printf("[SyncSingleItem] 创建新记录: client_id=%s, user_id=%s", req.ClientID, userID)
-		c.JSON(http.StatusCreated, item.ToResponse())
+		logger.Info("创建新记录", "client_id", req.ClientID, "item_id", item.ID)
+		events.PublishItem(events.ItemCreated, item, sourceDevice(c))
+		c.JSON(http.StatusCreated, withSensitivity(item, report))
 	} else if err != nil {
 		// Database error
-		log.Printf("[SyncSingleItem] 数据库错误: %v", err)
+		logger.Error("数据库错误", "error", err)
 		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 			Error:   "database error",
 			Message: "failed to query clipboard item",
 		})
 		return
 	} else {
+		strategy := req.Strategy
+		if strategy == "" {
+			strategy = models.ConflictStrategyLastWriterWins
+		}
+
+		// Resolve edits of a server copy that changed since the client last saw it
+		var conflict *models.SyncConflict
+		if hasSyncConflict(existingItem, req.Content, req.Type, req.BaseVersion, timestamp) {
+			conflict = &models.SyncConflict{
+				UserID:          userID,
+				ItemID:          existingItem.ID,
+				ClientID:        req.ClientID,
+				Strategy:        strategy,
+				BaseVersion:     req.BaseVersion,
+				ServerVersion:   existingItem.Version,
+				ServerTimestamp: existingItem.Timestamp,
+				ClientTimestamp: timestamp,
+			}
+
+			switch {
+			case strategy == models.ConflictStrategyConflict:
+				conflict.Resolution = models.ConflictResolutionRejected
+				h.recordConflict(c, conflict)
+
+				client := models.ClipboardItemResponse{
+					ID:         existingItem.ID,
+					Content:    req.Content,
+					Type:       req.Type,
+					Timestamp:  timestamp,
+					Encryption: req.Encryption,
+				}
+				if req.BaseVersion != nil {
+					client.Version = *req.BaseVersion
+				}
+
+				logger.Info("检测到冲突，返回双方版本", "client_id", req.ClientID)
+				c.Header("ETag", itemETag(existingItem))
+				c.JSON(http.StatusConflict, models.SyncConflictResponse{
+					Error:    "conflict",
+					Message:  "the item was modified on another device",
+					Server:   existingItem.ToResponse(),
+					Client:   client,
+					Conflict: conflict,
+				})
+				return
+			case strategy == models.ConflictStrategyServerWins || timestamp.Before(existingItem.Timestamp):
+				conflict.Resolution = models.ConflictResolutionServerWon
+				h.recordConflict(c, conflict)
+
+				logger.Info("检测到冲突，保留服务器版本", "client_id", req.ClientID)
+				response := existingItem.ToResponse()
+				response.Conflict = conflict
+				c.Header("ETag", itemETag(existingItem))
+				c.JSON(http.StatusOK, response)
+				return
+			default:
+				conflict.Resolution = models.ConflictResolutionClientWon
+			}
+		}
+
 		// Update existing item (only update timestamp and content)
-		existingItem.Timestamp = timestamp
-		existingItem.Content = sanitizedContent
 		existingItem.Type = req.Type
+		setExpiry(&existingItem, expiresAt, req.ClearExpiry)
+		report := applyContent(&existingItem, req.Content, req.Encryption, policy)
+		if report != nil && report.Action == models.SensitiveActionReject {
+			rejectSensitive(c, report)
+			return
+		}
+		existingItem.Timestamp = timestamp
 
-		if err := db.Save(&existingItem).Error; err != nil {
-			log.Printf("[SyncSingleItem] 更新失败: %v", err)
+		if err := h.repo(c).SaveItem(&existingItem); err != nil {
+			logger.Warn("更新失败", "error", err)
+			if err == repository.ErrVersionConflict {
+				c.JSON(http.StatusConflict, models.ErrorResponse{
+					Error:   "conflict",
+					Message: "the item was modified concurrently, retry the sync",
+				})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
 				Error:   "update failed",
 				Message: "failed to update clipboard item",
 			})
 			return
 		}
+		if conflict != nil {
+			h.recordConflict(c, conflict)
+		}
+
+		logger.Info("更新现有记录", "client_id", req.ClientID, "item_id", existingItem.ID)
+		events.PublishItem(events.ItemUpdated, existingItem, sourceDevice(c))
+
+		response := withSensitivity(existingItem, report)
+		response.Conflict = conflict
+		c.Header("ETag", itemETag(existingItem))
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetConflicts lists the conflicts detected while syncing the user's items
+func (h *ClipboardHandler) GetConflicts(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	// Get limit parameter (default 50, max 200)
+	limit := 50
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			if parsedLimit > 200 {
+				limit = 200
+			} else {
+				limit = parsedLimit
+			}
+		}
+	}
+
+	conflicts, total, err := h.repo(c).ListConflicts(userID, c.Query("item_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch sync conflicts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.ConflictListResponse{
+		Items: conflicts,
+		Total: total,
+	})
+}
+
+// eventHeartbeatInterval keeps idle event streams open through proxies
+const eventHeartbeatInterval = 30 * time.Second
+
+// Events streams changes to the user's clipboard, or to the channel clipboard,
+// as server-sent events
+func (h *ClipboardHandler) Events(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
 
-		log.Printf("[SyncSingleItem] 更新现有记录: client_id=%s, user_id=%s", req.ClientID, userID)
-		c.JSON(http.StatusOK, existingItem.ToResponse())
+	topic := userID
+	if channelID := c.GetString(channelIDKey); channelID != "" {
+		topic = events.ChannelTopic(channelID)
 	}
+	stream, unsubscribe := events.Default.Subscribe(topic)
+	defer unsubscribe()
+
+	// The stream stays open longer than the server write timeout
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		logging.For(c).Warn("无法取消写超时", "handler", "Events", "error", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-stream:
+			if !ok {
+				return
+			}
+			c.SSEvent(string(event.Type), event)
+			c.Writer.Flush()
+			// A removed member must not receive further changes of the channel
+			if event.Type == events.MemberRemoved && event.MemberID == userID {
+				return
+			}
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": heartbeat\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// GetDeletions lists items deleted or expired since the given time, so that
+// clients which missed the events can remove their local copies
+func (h *ClipboardHandler) GetDeletions(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var since time.Time
+	if sinceParam := c.Query("since"); sinceParam != "" {
+		parsed, err := time.Parse(time.RFC3339, sinceParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.ErrorResponse{
+				Error:   "invalid query parameters",
+				Message: "since must be an RFC 3339 time",
+			})
+			return
+		}
+		since = parsed
+	}
+
+	deleted, err := h.repo(c).DeletedSince(userID, since, 500)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch deleted items",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.DeletionsResponse{
+		Items: deleted,
+		Total: len(deleted),
+	})
+}
+
+// applyContent sets the content of item, classifies it as item.Type and applies the
+// user's sensitive content policy. End-to-end encrypted content is opaque to the
+// server and stored verbatim. An existing expiry is kept, the policy can only
+// make it earlier.
+func applyContent(item *models.ClipboardItem, content string, env *models.EncryptionEnvelope, policy models.SensitivePolicy) *models.SensitivityReport {
+	item.SetEnvelope(env)
+	if env != nil {
+		item.Content = content
+		item.Subtype = ""
+		item.MimeType = ""
+		item.Sensitive = false
+		item.SensitiveTags = ""
+		return nil
+	}
+
+	// Classified before masking, which may hide what the content is
+	class := classify.Classify(item.Type, content)
+	item.Subtype = class.Subtype
+	item.MimeType = class.MimeType
+
+	result := sensitive.Result{Content: content}
+	if isTextContent(item.Type, class) {
+		result = sensitive.Evaluate(content, policy, time.Now())
+	}
+	item.Content = result.Content
+	item.Sensitive = result.Sensitive
+	item.SensitiveTags = strings.Join(result.Tags, ",")
+	applyExpiry(item, result.ExpiresAt)
+	return result.Report
+}
+
+// isTextContent reports whether content is text the sensitive content detectors
+// apply to. Binary payloads are base64, which the high entropy detector matches
+// and masking would corrupt.
+func isTextContent(t models.ClipboardType, class classify.Result) bool {
+	if t == models.ClipboardTypeImage || t == models.ClipboardTypeFile {
+		return false
+	}
+	return strings.HasPrefix(class.MimeType, "text/") || class.MimeType == "application/json"
+}
+
+// itemETag returns the entity tag of the current version of an item
+func itemETag(item models.ClipboardItem) string {
+	return fmt.Sprintf(`"%d"`, item.Version)
+}
+
+// matchesETag reports whether an If-Match header matches the entity tag
+func matchesETag(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed responds to an update based on an outdated version of the item
+func preconditionFailed(c *gin.Context, current models.ClipboardItem) {
+	c.Header("ETag", itemETag(current))
+	c.JSON(http.StatusPreconditionFailed, gin.H{
+		"error":   "precondition failed",
+		"message": "the item was modified since it was read",
+		"current": current.ToResponse(),
+	})
+}
+
+// hasSyncConflict reports whether the server copy changed since the client's copy
+// was based on it. Without a base version, a server copy newer than the client
+// timestamp is a conflict. Re-sending identical content never conflicts.
+func hasSyncConflict(server models.ClipboardItem, content string, itemType models.ClipboardType, baseVersion *int64, clientTimestamp time.Time) bool {
+	if server.Content == content && server.Type == itemType {
+		return false
+	}
+	if baseVersion != nil {
+		return *baseVersion != server.Version
+	}
+	return server.Timestamp.After(clientTimestamp)
+}
+
+// sourceDevice returns the device named by the X-Device-ID header, which events
+// report as the device a change was made on
+func sourceDevice(c *gin.Context) string {
+	return c.GetHeader("X-Device-ID")
+}
+
+// recordConflict adds a conflict to the user's conflict log
+func (h *ClipboardHandler) recordConflict(c *gin.Context, conflict *models.SyncConflict) {
+	if err := h.repo(c).CreateConflict(conflict); err != nil {
+		logging.For(c).Error("记录冲突失败", "handler", "SyncSingleItem", "error", err)
+	}
+}
+
+// requestedExpiry resolves the expiry asked for by the client, given either
+// as an absolute expires_at or as a TTL in seconds
+func requestedExpiry(expiresAt *models.CustomTime, ttl *int, clear bool, now time.Time) (*time.Time, error) {
+	if expiresAt != nil && expiresAt.IsZero() {
+		expiresAt = nil
+	}
+
+	switch {
+	case clear && (expiresAt != nil || ttl != nil):
+		return nil, fmt.Errorf("clear_expiry cannot be combined with expires_at or ttl")
+	case expiresAt != nil && ttl != nil:
+		return nil, fmt.Errorf("only one of expires_at and ttl may be set")
+	case ttl != nil:
+		if *ttl <= 0 {
+			return nil, fmt.Errorf("ttl must be greater than 0")
+		}
+		t := now.Add(time.Duration(*ttl) * time.Second)
+		return &t, nil
+	case expiresAt != nil:
+		if !expiresAt.After(now) {
+			return nil, fmt.Errorf("expires_at must be in the future")
+		}
+		t := expiresAt.Time
+		return &t, nil
+	}
+	return nil, nil
+}
+
+// setExpiry replaces the expiry of an item with the one requested by the
+// client, or removes it when clear is set. Without either the current expiry
+// is kept. It runs before applyContent, whose sensitive content policy can
+// still make the expiry earlier.
+func setExpiry(item *models.ClipboardItem, expiresAt *time.Time, clear bool) {
+	switch {
+	case clear:
+		item.ExpiresAt = nil
+	case expiresAt != nil:
+		item.ExpiresAt = expiresAt
+	}
+}
+
+// applyExpiry sets the given expiry unless the item already expires earlier
+func applyExpiry(item *models.ClipboardItem, expiresAt *time.Time) {
+	if expiresAt != nil && (item.ExpiresAt == nil || expiresAt.Before(*item.ExpiresAt)) {
+		item.ExpiresAt = expiresAt
+	}
+}
+
+// withSensitivity builds the item response including the sensitive content decision
+func withSensitivity(item models.ClipboardItem, report *models.SensitivityReport) models.ClipboardItemResponse {
+	response := item.ToResponse()
+	response.Sensitivity = report
+	return response
+}
+
+// rejectSensitive responds to content rejected by the sensitive content policy
+func rejectSensitive(c *gin.Context, report *models.SensitivityReport) {
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":       "sensitive content rejected",
+		"message":     "content matches a detector whose policy action is reject",
+		"sensitivity": report,
+	})
 }
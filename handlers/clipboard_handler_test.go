@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"bytes"
+	"clipboard-server/auth"
+	"clipboard-server/database"
+	"clipboard-server/events"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var clipboardUser = models.User{
+	ID:       "clipboard-user-id",
+	Username: "clipboarduser",
+	Email:    "clipboard@example.com",
+	IsActive: true,
+}
+
+// setupClipboardRouter 使用测试数据库创建剪贴板路由
+func setupClipboardRouter(t *testing.T) (*gin.Engine, string, *gorm.DB) {
+	db := setupTestDB(t)
+	user := clipboardUser
+	db.Create(&user)
+
+	router, token := newClipboardRouter(repository.NewClipboardRepository(db))
+	return router, token, db
+}
+
+// setupMemoryClipboardRouter 使用内存存储创建剪贴板路由，测试可以并行运行
+func setupMemoryClipboardRouter(t *testing.T) (*gin.Engine, string, *repository.MemoryClipboardRepository) {
+	t.Parallel()
+
+	items := repository.NewMemoryClipboardRepository()
+	router, token := newClipboardRouter(items)
+	return router, token, items
+}
+
+func newClipboardRouter(items repository.ClipboardRepository) (*gin.Engine, string) {
+	token, _ := auth.GenerateToken(clipboardUser.ID, clipboardUser.Username, clipboardUser.Email)
+
+	router := gin.New()
+	clipboardHandler := NewClipboardHandler(items)
+
+	authenticated := router.Group("/")
+	authenticated.Use(auth.JWTAuthMiddleware())
+	authenticated.POST("/items", clipboardHandler.CreateItem)
+	authenticated.GET("/items", clipboardHandler.GetItems)
+	authenticated.GET("/items/:id", clipboardHandler.GetItem)
+	authenticated.PUT("/items/:id", clipboardHandler.UpdateItem)
+	authenticated.DELETE("/items/:id", clipboardHandler.DeleteItem)
+	authenticated.POST("/sync", clipboardHandler.BatchSync)
+	authenticated.POST("/sync-single", clipboardHandler.SyncSingleItem)
+	authenticated.GET("/deletions", clipboardHandler.GetDeletions)
+	authenticated.GET("/conflicts", clipboardHandler.GetConflicts)
+	authenticated.GET("/statistics", clipboardHandler.GetStatistics)
+
+	return router, token
+}
+
+func doJSONWithHeaders(router *gin.Engine, method, path, token string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(body)
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestItemExpiry(t *testing.T) {
+	router, token, db := setupClipboardRouter(t)
+	database.DB = db
+
+	stream, unsubscribe := events.Default.Subscribe("clipboard-user-id")
+	defer unsubscribe()
+
+	t.Run("无效的过期时间", func(t *testing.T) {
+		past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+		w := doJSON(router, "POST", "/items", token, gin.H{"content": "otp 123456", "expires_at": past})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("过去的过期时间应被拒绝，实际状态码 %d", w.Code)
+		}
+
+		w = doJSON(router, "POST", "/items", token, gin.H{"content": "otp 123456", "ttl": 60, "expires_at": past})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("同时指定 ttl 和 expires_at 应被拒绝，实际状态码 %d", w.Code)
+		}
+	})
+
+	w := doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-1", "content": "otp 123456", "ttl": 60})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建项目失败: %d %s", w.Code, w.Body.String())
+	}
+	var created models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ExpiresAt == nil || time.Until(*created.ExpiresAt) > time.Minute {
+		t.Fatalf("期望约 60 秒后过期，实际得到 %v", created.ExpiresAt)
+	}
+
+	if event := <-stream; event.Type != events.ItemCreated || event.ItemID != created.ID {
+		t.Errorf("期望收到创建事件，实际得到 %+v", event)
+	}
+
+	// Let the item expire without waiting for the sweeper
+	db.Model(&models.ClipboardItem{}).Where("id = ?", created.ID).
+		Update("expires_at", time.Now().Add(-time.Second))
+
+	if w := doJSON(router, "GET", "/items/"+created.ID, token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("过期项目不应可读，实际状态码 %d", w.Code)
+	}
+
+	var list models.PaginationResponse
+	json.Unmarshal(doJSON(router, "GET", "/items", token, nil).Body.Bytes(), &list)
+	if list.Total != 0 {
+		t.Errorf("过期项目不应出现在列表中，实际得到 %d 条", list.Total)
+	}
+
+	purged, err := database.PurgeExpired(time.Now())
+	if err != nil || len(purged) != 1 {
+		t.Fatalf("期望清除 1 个过期项目，实际得到 %d, 错误: %v", len(purged), err)
+	}
+	events.PublishDeletions(purged, "")
+
+	if event := <-stream; event.Type != events.ItemDeleted || event.Reason != models.DeletionReasonExpired || event.ClientID != "otp-1" {
+		t.Errorf("期望收到过期删除事件，实际得到 %+v", event)
+	}
+
+	var deletions models.DeletionsResponse
+	json.Unmarshal(doJSON(router, "GET", "/deletions", token, nil).Body.Bytes(), &deletions)
+	if deletions.Total != 1 || deletions.Items[0].ItemID != created.ID {
+		t.Errorf("期望删除记录包含过期项目，实际得到 %+v", deletions)
+	}
+}
+
+func TestUpdateKeepsExpiry(t *testing.T) {
+	router, token, _ := setupMemoryClipboardRouter(t)
+
+	w := doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-2", "content": "otp 123456", "ttl": 300})
+	var created models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.ExpiresAt == nil {
+		t.Fatalf("创建的项目应有过期时间: %s", w.Body.String())
+	}
+
+	updates := []struct {
+		name string
+		path string
+		body gin.H
+	}{
+		{"更新项目", "/items/" + created.ID, gin.H{"content": "otp 654321"}},
+		{"同步更新", "/sync-single", gin.H{"client_id": "otp-2", "content": "otp 111111", "timestamp": time.Now().Add(time.Minute).Format(time.RFC3339)}},
+		{"加密内容", "/items/" + created.ID, gin.H{"content": "Y2lwaGVy", "encryption": gin.H{"key_id": "k1", "nonce": "bm9uY2U=", "algorithm": "xchacha20poly1305"}}},
+	}
+	for _, u := range updates {
+		method := "PUT"
+		if u.path == "/sync-single" {
+			method = "POST"
+		}
+		w := doJSON(router, method, u.path, token, u.body)
+		var item models.ClipboardItemResponse
+		json.Unmarshal(w.Body.Bytes(), &item)
+		if w.Code != http.StatusOK || item.ExpiresAt == nil || !item.ExpiresAt.Equal(*created.ExpiresAt) {
+			t.Errorf("%s: 未指定 ttl 时应保留过期时间，实际得到 %d %s", u.name, w.Code, w.Body.String())
+		}
+	}
+
+	// 更早的 ttl 仍会缩短过期时间
+	w = doJSON(router, "PUT", "/items/"+created.ID, token, gin.H{"content": "otp 222222", "ttl": 60})
+	var shortened models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &shortened)
+	if shortened.ExpiresAt == nil || !shortened.ExpiresAt.Before(*created.ExpiresAt) {
+		t.Errorf("更早的 ttl 应缩短过期时间，实际得到 %v", shortened.ExpiresAt)
+	}
+
+	// 显式指定的 ttl 替换原过期时间，可以延长
+	w = doJSON(router, "PUT", "/items/"+created.ID, token, gin.H{"content": "otp 333333", "ttl": 3600})
+	var extended models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &extended)
+	if extended.ExpiresAt == nil || !extended.ExpiresAt.After(*created.ExpiresAt) {
+		t.Errorf("更晚的 ttl 应延长过期时间，实际得到 %v", extended.ExpiresAt)
+	}
+
+	if w := doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-2", "content": "otp 444444", "ttl": 60, "clear_expiry": true}); w.Code != http.StatusBadRequest {
+		t.Errorf("clear_expiry 与 ttl 同时指定应被拒绝，实际状态码 %d", w.Code)
+	}
+	w = doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-2", "content": "otp 444444", "clear_expiry": true, "timestamp": time.Now().Add(2 * time.Minute).Format(time.RFC3339)})
+	var cleared models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &cleared)
+	if w.Code != http.StatusOK || cleared.ExpiresAt != nil {
+		t.Errorf("clear_expiry 应清除过期时间，实际得到 %d %s", w.Code, w.Body.String())
+	}
+}
+
+func TestExpiredClientIDReplaced(t *testing.T) {
+	router, token, items := setupMemoryClipboardRouter(t)
+
+	past := time.Now().Add(-time.Minute)
+	for _, clientID := range []string{"otp-3", "otp-4", "otp-5"} {
+		items.CreateItem(&models.ClipboardItem{UserID: clipboardUser.ID, ClientID: clientID, Content: "expired", ExpiresAt: &past})
+	}
+
+	// 同步未清理的过期项目时创建新项目，而不是复用过期项目及其过期时间
+	w := doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-3", "content": "fresh"})
+	var synced models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &synced)
+	if w.Code != http.StatusCreated || synced.Version != 1 || synced.ExpiresAt != nil {
+		t.Errorf("应创建不过期的新项目，实际得到 %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(router, "GET", "/items/"+synced.ID, token, nil); w.Code != http.StatusOK {
+		t.Errorf("同步后的项目应可读取，实际状态码 %d", w.Code)
+	}
+
+	w = doJSON(router, "POST", "/sync-single", token, gin.H{"client_id": "otp-4", "content": "fresh", "ttl": 300})
+	json.Unmarshal(w.Body.Bytes(), &synced)
+	if w.Code != http.StatusCreated || synced.ExpiresAt == nil || !synced.ExpiresAt.After(time.Now()) {
+		t.Errorf("新项目应使用请求的 ttl，实际得到 %d %s", w.Code, w.Body.String())
+	}
+
+	// 过期项目不再占用客户端 ID
+	if w := doJSON(router, "POST", "/items", token, gin.H{"client_id": "otp-5", "content": "fresh"}); w.Code != http.StatusCreated {
+		t.Errorf("过期项目的客户端 ID 应可重新使用，实际状态码 %d", w.Code)
+	}
+
+	var page models.PaginationResponse
+	json.Unmarshal(doJSON(router, "GET", "/items", token, nil).Body.Bytes(), &page)
+	if page.Total != 3 {
+		t.Errorf("期望 3 个可见项目，实际得到 %d", page.Total)
+	}
+}
+
+func TestDeleteItemRecordsDeletion(t *testing.T) {
+	router, token, _ := setupMemoryClipboardRouter(t)
+
+	w := doJSON(router, "POST", "/items", token, gin.H{"content": "hello"})
+	var created models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+
+	if w := doJSON(router, "DELETE", "/items/"+created.ID, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("删除失败，状态码 %d", w.Code)
+	}
+	if w := doJSON(router, "DELETE", "/items/"+created.ID, token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("重复删除应返回 404，实际状态码 %d", w.Code)
+	}
+
+	since := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	var deletions models.DeletionsResponse
+	json.Unmarshal(doJSON(router, "GET", "/deletions?since="+since, token, nil).Body.Bytes(), &deletions)
+	if deletions.Total != 1 || deletions.Items[0].Reason != models.DeletionReasonDeleted {
+		t.Errorf("期望 1 条删除记录，实际得到 %+v", deletions)
+	}
+}
+
+func TestUpdateItemIfMatch(t *testing.T) {
+	router, token, _ := setupMemoryClipboardRouter(t)
+
+	w := doJSON(router, "POST", "/items", token, gin.H{"content": "v1"})
+	var created models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	etag := w.Header().Get("ETag")
+	if created.Version != 1 || etag != `"1"` {
+		t.Fatalf("新项目应为版本 1，实际得到 %d / %s", created.Version, etag)
+	}
+
+	update := func(content, ifMatch string) *httptest.ResponseRecorder {
+		headers := map[string]string{}
+		if ifMatch != "" {
+			headers["If-Match"] = ifMatch
+		}
+		return doJSONWithHeaders(router, "PUT", "/items/"+created.ID, token, gin.H{"content": content}, headers)
+	}
+
+	w = update("v2", etag)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"2"` {
+		t.Fatalf("匹配的 If-Match 应更新成功，实际状态码 %d, ETag %s", w.Code, w.Header().Get("ETag"))
+	}
+
+	// A second device still holding version 1
+	w = update("stale", etag)
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("过期的 If-Match 应返回 412，实际状态码 %d", w.Code)
+	}
+
+	var item models.ClipboardItemResponse
+	json.Unmarshal(doJSON(router, "GET", "/items/"+created.ID, token, nil).Body.Bytes(), &item)
+	if item.Content != "v2" || item.Version != 2 {
+		t.Errorf("期望内容 v2 版本 2，实际得到 %q 版本 %d", item.Content, item.Version)
+	}
+
+	if w := update("v3", ""); w.Code != http.StatusOK {
+		t.Errorf("未提供 If-Match 时应直接更新，实际状态码 %d", w.Code)
+	}
+}
+
+func TestContentSubtypes(t *testing.T) {
+	router, token, _ := setupClipboardRouter(t)
+
+	for _, content := range []string{"https://example.com", "https://example.org/docs", "#336699", "just a note"} {
+		doJSON(router, "POST", "/items", token, gin.H{"content": content, "type": "text"})
+	}
+	w := doJSON(router, "POST", "/items", token, gin.H{"content": "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJ", "type": "image"})
+	var image models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &image)
+	if image.Subtype != models.ClipboardSubtypeImage || image.MimeType != "image/png" {
+		t.Errorf("期望识别为 PNG 图片，实际得到 %q %q", image.Subtype, image.MimeType)
+	}
+
+	// 更新内容时重新分类
+	w = doJSON(router, "PUT", "/items/"+image.ID, token, gin.H{"content": `{"a": 1}`, "type": "text"})
+	json.Unmarshal(w.Body.Bytes(), &image)
+	if image.Subtype != models.ClipboardSubtypeJSON || image.MimeType != "application/json" {
+		t.Errorf("更新后期望子类型 json，实际得到 %q %q", image.Subtype, image.MimeType)
+	}
+
+	var page models.PaginationResponse
+	json.Unmarshal(doJSON(router, "GET", "/items?subtype=url", token, nil).Body.Bytes(), &page)
+	if page.Total != 2 || len(page.Items) != 2 || page.Items[0].Subtype != models.ClipboardSubtypeURL {
+		t.Errorf("期望 2 个 url 项目，实际得到 %d", page.Total)
+	}
+
+	var stats models.StatisticsResponse
+	json.Unmarshal(doJSON(router, "GET", "/statistics", token, nil).Body.Bytes(), &stats)
+	if stats.TypeDistribution["text"] != 5 || len(stats.TypeDistribution) != 1 {
+		t.Errorf("类型分布应只按类型计数，实际得到 %v", stats.TypeDistribution)
+	}
+	want := map[string]int64{"text.url": 2, "text.color": 1, "text.plain": 1, "text.json": 1}
+	for key, count := range want {
+		if stats.SubtypeDistribution[key] != count {
+			t.Errorf("子类型分布 %s 期望 %d，实际得到 %v", key, count, stats.SubtypeDistribution)
+		}
+	}
+}
+
+func TestSensitiveBinaryContent(t *testing.T) {
+	router, token, items := setupMemoryClipboardRouter(t)
+	items.SaveSensitivePolicy(&models.SensitivePolicy{UserID: clipboardUser.ID, DefaultAction: models.SensitiveActionMask})
+
+	payload := "iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg=="
+	for _, body := range []gin.H{
+		{"content": payload, "type": "image"},
+		{"content": "data:image/png;base64," + payload, "type": "text"},
+	} {
+		w := doJSON(router, "POST", "/items", token, body)
+		var item models.ClipboardItemResponse
+		json.Unmarshal(w.Body.Bytes(), &item)
+		if w.Code != http.StatusCreated || item.Content != body["content"] || item.Sensitive {
+			t.Errorf("二进制内容不应被检测或遮盖: %d %s", w.Code, w.Body.String())
+		}
+	}
+
+	// 文本内容仍按策略遮盖
+	w := doJSON(router, "POST", "/items", token, gin.H{"content": "token " + payload, "type": "text"})
+	var text models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &text)
+	if !text.Sensitive || text.Content == "token "+payload {
+		t.Errorf("文本中的高熵字符串应被遮盖，实际得到 %s", w.Body.String())
+	}
+}
+
+func TestSyncSingleConflictStrategies(t *testing.T) {
+	router, token, _ := setupMemoryClipboardRouter(t)
+
+	base := time.Now().Add(-time.Hour)
+	sync := func(body gin.H) *httptest.ResponseRecorder {
+		body["client_id"] = "shared-item"
+		return doJSON(router, "POST", "/sync-single", token, body)
+	}
+
+	// Device A creates version 1, then updates it to version 2
+	sync(gin.H{"content": "from A", "timestamp": base.Format(time.RFC3339)})
+	sync(gin.H{"content": "from A again", "base_version": 1, "timestamp": base.Add(2 * time.Minute).Format(time.RFC3339)})
+
+	t.Run("冲突时返回双方版本", func(t *testing.T) {
+		w := sync(gin.H{"content": "from B", "base_version": 1, "strategy": "conflict",
+			"timestamp": base.Add(time.Minute).Format(time.RFC3339)})
+		if w.Code != http.StatusConflict {
+			t.Fatalf("期望 409，实际状态码 %d", w.Code)
+		}
+		var resp models.SyncConflictResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Server.Content != "from A again" || resp.Client.Content != "from B" {
+			t.Errorf("期望返回双方版本，实际得到 %+v", resp)
+		}
+	})
+
+	t.Run("服务器优先", func(t *testing.T) {
+		w := sync(gin.H{"content": "from B", "base_version": 1, "strategy": "server_wins",
+			"timestamp": base.Add(time.Hour).Format(time.RFC3339)})
+		var resp models.ClipboardItemResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Content != "from A again" || resp.Conflict == nil || resp.Conflict.Resolution != models.ConflictResolutionServerWon {
+			t.Errorf("期望保留服务器版本，实际得到 %+v", resp)
+		}
+	})
+
+	t.Run("最后写入者优先", func(t *testing.T) {
+		// Older client timestamp loses
+		w := sync(gin.H{"content": "old from B", "base_version": 1,
+			"timestamp": base.Add(time.Minute).Format(time.RFC3339)})
+		var resp models.ClipboardItemResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Content != "from A again" {
+			t.Errorf("较旧的客户端版本不应覆盖服务器版本，实际得到 %q", resp.Content)
+		}
+
+		// Newer client timestamp wins
+		w = sync(gin.H{"content": "new from B", "base_version": 1,
+			"timestamp": base.Add(3 * time.Minute).Format(time.RFC3339)})
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Content != "new from B" || resp.Version != 3 || resp.Conflict.Resolution != models.ConflictResolutionClientWon {
+			t.Errorf("较新的客户端版本应覆盖服务器版本，实际得到 %+v", resp)
+		}
+	})
+
+	var conflicts models.ConflictListResponse
+	json.Unmarshal(doJSON(router, "GET", "/conflicts", token, nil).Body.Bytes(), &conflicts)
+	if conflicts.Total != 4 {
+		t.Errorf("期望冲突日志包含 4 条记录，实际得到 %d", conflicts.Total)
+	}
+}
+
+func TestBatchSyncIdempotency(t *testing.T) {
+	router, token, items := setupMemoryClipboardRouter(t)
+
+	batch := gin.H{"items": []gin.H{
+		{"client_id": "a", "content": "first"},
+		{"client_id": "b", "content": "second"},
+		{"client_id": "c", "content": "third", "type": "unknown"},
+	}}
+	headers := map[string]string{"Idempotency-Key": "batch-1"}
+
+	w := doJSONWithHeaders(router, "POST", "/sync", token, batch, headers)
+	var first models.BatchSyncResponse
+	json.Unmarshal(w.Body.Bytes(), &first)
+	if w.Code != http.StatusOK || len(first.Synced) != 2 || len(first.Failed) != 1 {
+		t.Fatalf("期望 2 个成功 1 个失败，实际得到 %d: %+v", w.Code, first)
+	}
+	if failed := first.Failed[0]; failed.Index != 2 || failed.ClientID != "c" {
+		t.Errorf("失败项目应按索引和客户端ID标识，实际得到 %+v", failed)
+	}
+	if strings.Contains(w.Body.String(), "third") {
+		t.Errorf("失败项目不应返回内容: %s", w.Body.String())
+	}
+
+	// Retrying with the same key replays the result
+	w = doJSONWithHeaders(router, "POST", "/sync", token, batch, headers)
+	var replayed models.BatchSyncResponse
+	json.Unmarshal(w.Body.Bytes(), &replayed)
+	if w.Header().Get("Idempotent-Replayed") != "true" || len(replayed.Synced) != 2 || replayed.Synced[0].ID != first.Synced[0].ID {
+		t.Errorf("重试应重放原结果，实际得到 %+v", replayed)
+	}
+
+	// The same key with a different body is rejected
+	w = doJSONWithHeaders(router, "POST", "/sync", token, gin.H{"items": []gin.H{{"content": "other"}}}, headers)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("不同请求复用幂等键应返回 422，实际状态码 %d", w.Code)
+	}
+
+	// Without a key, client IDs still prevent duplicates
+	doJSON(router, "POST", "/sync", token, batch)
+	_, count, _ := items.ListItems(clipboardUser.ID, repository.ItemFilter{})
+	if count != 2 {
+		t.Errorf("重试不应产生重复项目，期望 2 条，实际得到 %d", count)
+	}
+}
+
+func TestBatchSyncAtomic(t *testing.T) {
+	router, token, db := setupClipboardRouter(t)
+
+	w := doJSON(router, "POST", "/sync", token, gin.H{"mode": "atomic", "items": []gin.H{
+		{"client_id": "a", "content": "first"},
+		{"client_id": "b", "content": "second", "type": "unknown"},
+		{"client_id": "c", "content": "third", "ttl": -1},
+	}})
+	var resp models.BatchSyncResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusUnprocessableEntity || len(resp.Synced) != 0 || len(resp.Failed) != 2 {
+		t.Errorf("原子模式中任一失败应全部回滚，实际得到 %d: %+v", w.Code, resp)
+	}
+
+	var count int64
+	db.Model(&models.ClipboardItem{}).Count(&count)
+	if count != 0 {
+		t.Errorf("原子批次失败后不应保存任何项目，实际得到 %d", count)
+	}
+}
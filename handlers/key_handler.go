@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// KeyHandler manages wrapped key material for end-to-end encryption.
+// The server only ever stores keys wrapped by the clients.
+type KeyHandler struct {
+	keys  repository.KeyRepository
+	users repository.UserRepository
+	items repository.ClipboardRepository
+}
+
+// NewKeyHandler creates key handler instance
+func NewKeyHandler(keys repository.KeyRepository, users repository.UserRepository, items repository.ClipboardRepository) *KeyHandler {
+	return &KeyHandler{keys: keys, users: users, items: items}
+}
+
+// repo returns the key repository bound to the context of the request
+func (h *KeyHandler) repo(c *gin.Context) repository.KeyRepository {
+	return h.keys.WithContext(c.Request.Context())
+}
+
+// ListKeys lists the wrapped keys of the current user, optionally for one device
+func (h *KeyHandler) ListKeys(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	keys, err := h.repo(c).ListKeys(userID, c.Query("device_id"), models.KeyStatus(c.Query("status")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "query failed",
+			Message: "failed to query keys",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"keys":  keys,
+		"total": len(keys),
+	})
+}
+
+// AddKey stores a content key wrapped for a device, e.g. when enrolling a new device
+func (h *KeyHandler) AddKey(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.WrappedKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	// A retired key cannot be handed out to new devices
+	retired, err := h.repo(c).CountKeys(userID, req.KeyID, models.KeyStatusRetired)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "query failed",
+			Message: "failed to query keys",
+		})
+		return
+	}
+	if retired > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "key retired",
+			Message: "the key has been rotated, wrap the active key instead",
+		})
+		return
+	}
+
+	key := models.DeviceKey{
+		UserID:     userID,
+		DeviceID:   req.DeviceID,
+		KeyID:      req.KeyID,
+		WrappedKey: req.WrappedKey,
+		Algorithm:  req.Algorithm,
+		Status:     models.KeyStatusActive,
+	}
+
+	// Replace any previous copy of the same key for this device
+	if err := h.repo(c).ReplaceKey(&key); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to store key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, key)
+}
+
+// RotateKey retires all active keys and installs a new key wrapped for each device.
+// Retired keys are kept so items encrypted under them can still be decrypted and re-encrypted.
+func (h *KeyHandler) RotateKey(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.KeyRotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	existing, err := h.repo(c).CountKeys(userID, req.KeyID, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "query failed",
+			Message: "failed to query keys",
+		})
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "key exists",
+			Message: "a key with this ID already exists",
+		})
+		return
+	}
+
+	keys := make([]models.DeviceKey, 0, len(req.WrappedKeys))
+	for _, wrapped := range req.WrappedKeys {
+		keys = append(keys, models.DeviceKey{
+			UserID:     userID,
+			DeviceID:   wrapped.DeviceID,
+			KeyID:      req.KeyID,
+			WrappedKey: wrapped.WrappedKey,
+			Algorithm:  req.Algorithm,
+			Status:     models.KeyStatusActive,
+		})
+	}
+
+	retiredKeyIDs, err := h.repo(c).RotateKey(userID, keys)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "rotation failed",
+			Message: "failed to rotate key",
+		})
+		return
+	}
+
+	// Items still encrypted under a retired key, to be re-encrypted by the clients
+	pendingItems, _ := h.items.WithContext(c.Request.Context()).CountEncryptedItems(userID, retiredKeyIDs)
+
+	c.JSON(http.StatusOK, models.KeyRotationResponse{
+		KeyID:        req.KeyID,
+		Keys:         keys,
+		RetiredKeys:  retiredKeyIDs,
+		PendingItems: pendingItems,
+	})
+}
+
+// DeleteKey removes one wrapped key, e.g. when a device is lost
+func (h *KeyHandler) DeleteKey(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	keyID := c.Param("id")
+	if keyID == "" {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: "key ID is required",
+		})
+		return
+	}
+
+	err := h.repo(c).DeleteKey(userID, keyID)
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "key not found",
+			Message: "key not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to delete key",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "key deleted successfully",
+	})
+}
+
+// SetE2EEMode turns the end-to-end encryption requirement on or off.
+// Enabling it requires at least one active key so devices can keep syncing.
+func (h *KeyHandler) SetE2EEMode(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.E2EEModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if *req.Required {
+		activeKeys, err := h.repo(c).CountKeys(userID, "", models.KeyStatusActive)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "query failed",
+				Message: "failed to query keys",
+			})
+			return
+		}
+		if activeKeys == 0 {
+			c.JSON(http.StatusConflict, models.ErrorResponse{
+				Error:   "no active key",
+				Message: "upload a wrapped key before enabling end-to-end encryption",
+			})
+			return
+		}
+	}
+
+	if err := h.users.WithContext(c.Request.Context()).SetE2EERequired(userID, *req.Required); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to update encryption mode",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "encryption mode updated",
+		Data:    gin.H{"e2ee_required": *req.Required},
+	})
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"clipboard-server/auth"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func setupE2EERouter(t *testing.T) (*gin.Engine, string) {
+	db := setupTestDB(t)
+
+	user := models.User{
+		ID:       "e2ee-user-id",
+		Username: "e2eeuser",
+		Email:    "e2ee@example.com",
+		IsActive: true,
+	}
+	db.Create(&user)
+	token, _ := auth.GenerateToken(user.ID, user.Username, user.Email)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	items := repository.NewClipboardRepository(db)
+	keyHandler := NewKeyHandler(repository.NewKeyRepository(db), repository.NewUserRepository(db), items)
+	clipboardHandler := NewClipboardHandler(items)
+
+	authenticated := router.Group("/")
+	authenticated.Use(auth.JWTAuthMiddleware())
+	authenticated.PUT("/user/e2ee", keyHandler.SetE2EEMode)
+	authenticated.GET("/keys", keyHandler.ListKeys)
+	authenticated.POST("/keys", keyHandler.AddKey)
+	authenticated.POST("/keys/rotate", keyHandler.RotateKey)
+	authenticated.POST("/items", clipboardHandler.CreateItem)
+	authenticated.GET("/items", clipboardHandler.GetItems)
+
+	return router, token
+}
+
+func doJSON(router *gin.Engine, method, path, token string, body interface{}) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req, _ := http.NewRequest(method, path, &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestE2EERequiresActiveKey(t *testing.T) {
+	router, token := setupE2EERouter(t)
+	required := true
+
+	w := doJSON(router, "PUT", "/user/e2ee", token, models.E2EEModeRequest{Required: &required})
+	if w.Code != http.StatusConflict {
+		t.Fatalf("期望状态码 %d，实际得到 %d", http.StatusConflict, w.Code)
+	}
+
+	w = doJSON(router, "POST", "/keys", token, models.WrappedKeyRequest{
+		DeviceID:   "laptop",
+		KeyID:      "k1",
+		WrappedKey: "d3JhcHBlZA==",
+		Algorithm:  "x25519-aes256gcm",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("上传密钥失败，状态码 %d", w.Code)
+	}
+
+	w = doJSON(router, "PUT", "/user/e2ee", token, models.E2EEModeRequest{Required: &required})
+	if w.Code != http.StatusOK {
+		t.Fatalf("期望状态码 %d，实际得到 %d", http.StatusOK, w.Code)
+	}
+
+	// Plaintext uploads are rejected once E2EE is required
+	w = doJSON(router, "POST", "/items", token, models.ClipboardItemRequest{Content: "plaintext"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("明文内容应被拒绝，实际状态码 %d", w.Code)
+	}
+
+	envelope := &models.EncryptionEnvelope{KeyID: "k1", Nonce: "bm9uY2U=", Algorithm: "aes-256-gcm"}
+	w = doJSON(router, "POST", "/items", token, models.ClipboardItemRequest{
+		Content:    "Y2lwaGVydGV4dA==",
+		Encryption: envelope,
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("加密内容创建失败，状态码 %d: %s", w.Code, w.Body.String())
+	}
+
+	var created models.ClipboardItemResponse
+	json.Unmarshal(w.Body.Bytes(), &created)
+	if created.Content != "Y2lwaGVydGV4dA==" || created.Encryption == nil || *created.Encryption != *envelope {
+		t.Errorf("加密内容应原样返回，实际得到 %+v", created)
+	}
+
+	// Search never matches ciphertext
+	w = doJSON(router, "GET", "/items?search=Y2lw", token, nil)
+	var page models.PaginationResponse
+	json.Unmarshal(w.Body.Bytes(), &page)
+	if page.Total != 0 {
+		t.Errorf("搜索不应匹配加密内容，实际得到 %d 条", page.Total)
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	router, token := setupE2EERouter(t)
+
+	for _, device := range []string{"laptop", "phone"} {
+		doJSON(router, "POST", "/keys", token, models.WrappedKeyRequest{
+			DeviceID: device, KeyID: "k1", WrappedKey: "old-" + device, Algorithm: "x25519-aes256gcm",
+		})
+	}
+	doJSON(router, "POST", "/items", token, models.ClipboardItemRequest{
+		Content:    "Y2lwaGVydGV4dA==",
+		Encryption: &models.EncryptionEnvelope{KeyID: "k1", Nonce: "bm9uY2U=", Algorithm: "aes-256-gcm"},
+	})
+
+	w := doJSON(router, "POST", "/keys/rotate", token, models.KeyRotationRequest{
+		KeyID:     "k2",
+		Algorithm: "x25519-aes256gcm",
+		WrappedKeys: []models.DeviceWrappedKey{
+			{DeviceID: "laptop", WrappedKey: "new-laptop"},
+			{DeviceID: "phone", WrappedKey: "new-phone"},
+		},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("密钥轮换失败，状态码 %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp models.KeyRotationResponse
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if len(resp.Keys) != 2 || len(resp.RetiredKeys) != 1 || resp.RetiredKeys[0] != "k1" {
+		t.Errorf("轮换结果不正确: %+v", resp)
+	}
+	if resp.PendingItems != 1 {
+		t.Errorf("期望 1 个待重新加密的项目，实际得到 %d", resp.PendingItems)
+	}
+
+	var active struct {
+		Total int `json:"total"`
+	}
+	json.Unmarshal(doJSON(router, "GET", "/keys?status=active", token, nil).Body.Bytes(), &active)
+	if active.Total != 2 {
+		t.Errorf("期望 2 个活动密钥，实际得到 %d", active.Total)
+	}
+
+	// Retired keys cannot be enrolled on new devices
+	w = doJSON(router, "POST", "/keys", token, models.WrappedKeyRequest{
+		DeviceID: "tablet", KeyID: "k1", WrappedKey: "old-tablet", Algorithm: "x25519-aes256gcm",
+	})
+	if w.Code != http.StatusConflict {
+		t.Errorf("已退役密钥不应被添加，实际状态码 %d", w.Code)
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/sensitive"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PolicyHandler manages per-user sensitive content policies
+type PolicyHandler struct {
+	items repository.ClipboardRepository
+}
+
+// NewPolicyHandler creates policy handler instance
+func NewPolicyHandler(items repository.ClipboardRepository) *PolicyHandler {
+	return &PolicyHandler{items: items}
+}
+
+// repo returns the repository bound to the context of the request
+func (h *PolicyHandler) repo(c *gin.Context) repository.ClipboardRepository {
+	return h.items.WithContext(c.Request.Context())
+}
+
+// GetSensitivePolicy returns the sensitive content policy of the current user
+func (h *PolicyHandler) GetSensitivePolicy(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	policy := h.repo(c).SensitivePolicy(userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":    policy,
+		"detectors": sensitive.DetectorNames(),
+	})
+}
+
+// UpdateSensitivePolicy replaces the sensitive content policy of the current user
+func (h *PolicyHandler) UpdateSensitivePolicy(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	var req models.SensitivePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if err := validatePolicyRequest(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid policy",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	policy := models.SensitivePolicy{
+		UserID:        userID,
+		DefaultAction: req.DefaultAction,
+		Actions:       req.Actions,
+		ExpireMinutes: req.ExpireMinutes,
+	}
+	if policy.ExpireMinutes == 0 {
+		policy.ExpireMinutes = sensitive.DefaultPolicy().ExpireMinutes
+	}
+
+	if err := h.repo(c).SaveSensitivePolicy(&policy); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to update sensitive content policy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policy":    policy,
+		"detectors": sensitive.DetectorNames(),
+	})
+}
+
+func validatePolicyRequest(req models.SensitivePolicyRequest) error {
+	if !sensitive.ValidAction(req.DefaultAction) {
+		return fmt.Errorf("unknown action %q", req.DefaultAction)
+	}
+
+	for detector, action := range req.Actions {
+		if _, ok := sensitive.Lookup(detector); !ok {
+			return fmt.Errorf("unknown detector %q", detector)
+		}
+		if !sensitive.ValidAction(action) {
+			return fmt.Errorf("unknown action %q for detector %q", action, detector)
+		}
+	}
+	return nil
+}
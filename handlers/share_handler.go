@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/logging"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sharePasswordHeader carries the password of a protected share link. Browsers
+// can send it as the password of HTTP basic authentication instead.
+const sharePasswordHeader = "X-Share-Password"
+
+// ShareHandler manages public share links of clipboard items and serves
+// them to visitors without an account
+type ShareHandler struct {
+	items  repository.ClipboardRepository
+	shares repository.ShareRepository
+}
+
+// NewShareHandler creates share handler instance
+func NewShareHandler(items repository.ClipboardRepository, shares repository.ShareRepository) *ShareHandler {
+	return &ShareHandler{items: items, shares: shares}
+}
+
+// repo returns the repository bound to the context of the request
+func (h *ShareHandler) repo(c *gin.Context) repository.ShareRepository {
+	return h.shares.WithContext(c.Request.Context())
+}
+
+// CreateShare creates a share link for one item of the current user, with
+// an optional expiry, view limit and password
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	// All settings are optional, so the body may be empty
+	var req models.ShareLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	expiresAt, err := requestedExpiry(req.ExpiresAt, req.TTL, false, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid expiry",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	item, err := h.items.WithContext(c.Request.Context()).GetItem(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "item not found",
+			Message: "clipboard item not found",
+		})
+		return
+	}
+
+	// Visitors have no key to decrypt end-to-end encrypted content
+	if item.Encrypted {
+		c.JSON(http.StatusUnprocessableEntity, models.ErrorResponse{
+			Error:   "encrypted item",
+			Message: "end-to-end encrypted items cannot be shared",
+		})
+		return
+	}
+
+	share := models.ShareLink{
+		ItemID:    item.ID,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		MaxViews:  req.MaxViews,
+	}
+	if req.Password != "" {
+		if share.PasswordSalt, err = utils.GenerateSalt(); err == nil {
+			share.PasswordHash, err = utils.HashPasswordWithSalt(req.Password, share.PasswordSalt)
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "creation failed",
+				Message: "failed to hash password",
+			})
+			return
+		}
+	}
+
+	if err := h.repo(c).CreateShare(&share); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to create share link",
+		})
+		return
+	}
+
+	logging.For(c).Info("已创建分享链接", "handler", "CreateShare", "item_id", item.ID, "share_id", share.ID)
+	c.JSON(http.StatusCreated, share.ToResponse(shareBaseURL(c)))
+}
+
+// ListShares lists the share links of the current user, only those of the
+// :id item on item routes
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	shares, err := h.repo(c).ListShares(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch share links",
+		})
+		return
+	}
+
+	baseURL := shareBaseURL(c)
+	responses := make([]models.ShareLinkResponse, len(shares))
+	for i := range shares {
+		responses[i] = shares[i].ToResponse(baseURL)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"shares": responses,
+		"total":  len(responses),
+	})
+}
+
+// RevokeShare deletes a share link of the current user, so it can no longer be opened
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	err := h.repo(c).DeleteShare(userID, c.Param("id"))
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "share not found",
+			Message: "share link not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to revoke share link",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "share link revoked successfully",
+	})
+}
+
+// ViewShare renders the content of a shared item as plain text
+func (h *ShareHandler) ViewShare(c *gin.Context) {
+	item, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(item.Content))
+}
+
+// DownloadShare serves the content of a shared item as a file download
+func (h *ShareHandler) DownloadShare(c *gin.Context) {
+	item, ok := h.openShare(c)
+	if !ok {
+		return
+	}
+
+	filename := "clipboard-" + item.ID
+	if item.Type == models.ClipboardTypeText {
+		filename += ".txt"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/octet-stream", []byte(item.Content))
+}
+
+// openShare checks the :token share link and its password, counts the view
+// and returns the shared item, or writes the error response. A wrong password
+// does not use up a view.
+func (h *ShareHandler) openShare(c *gin.Context) (models.ClipboardItem, bool) {
+	// Shared content must not be cached, indexed or leak the link to other sites
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	share, err := h.repo(c).FindByToken(c.Param("token"))
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "share not found",
+			Message: "share link not found or revoked",
+		})
+		return models.ClipboardItem{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch share link",
+		})
+		return models.ClipboardItem{}, false
+	}
+
+	if share.Expired(time.Now()) || share.Exhausted() {
+		shareGone(c)
+		return models.ClipboardItem{}, false
+	}
+
+	if share.Protected() {
+		password := c.GetHeader(sharePasswordHeader)
+		if password == "" {
+			_, password, _ = c.Request.BasicAuth()
+		}
+		if password == "" || !utils.CheckPasswordWithSalt(password, share.PasswordSalt, share.PasswordHash) {
+			c.Header("WWW-Authenticate", `Basic realm="shared clipboard item", charset="UTF-8"`)
+			c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+				Error:   "password required",
+				Message: "this share link requires a valid password",
+			})
+			return models.ClipboardItem{}, false
+		}
+	}
+
+	// Deleted and expired items are gone for the link as well
+	item, err := h.items.WithContext(c.Request.Context()).GetItem(share.UserID, share.ItemID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "share not found",
+			Message: "the shared item no longer exists",
+		})
+		return models.ClipboardItem{}, false
+	}
+
+	err = h.repo(c).RecordView(share.ID)
+	if err == repository.ErrViewLimitReached {
+		shareGone(c)
+		return models.ClipboardItem{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to record share view",
+		})
+		return models.ClipboardItem{}, false
+	}
+
+	return item, true
+}
+
+// shareGone responds to a share link that expired or has no views left
+func shareGone(c *gin.Context) {
+	c.JSON(http.StatusGone, models.ErrorResponse{
+		Error:   "share expired",
+		Message: "share link has expired or reached its view limit",
+	})
+}
+
+// shareBaseURL the scheme and host the request reached the server at, which
+// share link URLs are built from
+func shareBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
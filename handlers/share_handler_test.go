@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupShareRouter 使用内存存储创建分享链接路由
+func setupShareRouter(t *testing.T) (*gin.Engine, string, *repository.MemoryClipboardRepository) {
+	t.Parallel()
+
+	items := repository.NewMemoryClipboardRepository()
+	shareHandler := NewShareHandler(items, repository.NewMemoryShareRepository())
+	token, _ := auth.GenerateToken(clipboardUser.ID, clipboardUser.Username, clipboardUser.Email)
+
+	router := gin.New()
+	authenticated := router.Group("/")
+	authenticated.Use(auth.JWTAuthMiddleware())
+	authenticated.POST("/items/:id/shares", shareHandler.CreateShare)
+	authenticated.GET("/shares", shareHandler.ListShares)
+	authenticated.DELETE("/shares/:id", shareHandler.RevokeShare)
+	router.GET("/s/:token", shareHandler.ViewShare)
+	router.GET("/s/:token/raw", shareHandler.DownloadShare)
+
+	return router, token, items
+}
+
+// openShareLink 以未登录访客身份打开分享链接
+func openShareLink(router *gin.Engine, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", path, nil)
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestShareLink(t *testing.T) {
+	router, token, items := setupShareRouter(t)
+
+	item := models.ClipboardItem{UserID: clipboardUser.ID, Content: "shared snippet", Type: models.ClipboardTypeText}
+	items.CreateItem(&item)
+
+	if w := doJSON(router, "POST", "/items/missing/shares", token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的项目应返回 404，实际得到 %d", w.Code)
+	}
+
+	w := doJSON(router, "POST", "/items/"+item.ID+"/shares", token, gin.H{"max_views": 2, "password": "secret", "ttl": 3600})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建分享链接失败: %d %s", w.Code, w.Body.String())
+	}
+	var share models.ShareLinkResponse
+	json.Unmarshal(w.Body.Bytes(), &share)
+	if !share.PasswordProtected || share.ExpiresAt == nil || share.Path != "/s/"+share.Token {
+		t.Fatalf("分享链接设置不正确: %s", w.Body.String())
+	}
+	if strings.Contains(w.Body.String(), "secret") {
+		t.Error("响应不应包含密码")
+	}
+
+	// 密码错误不消耗访问次数
+	if w := openShareLink(router, share.Path, nil); w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("缺少密码应返回 401 并要求认证，实际得到 %d", w.Code)
+	}
+	if w := openShareLink(router, share.Path, map[string]string{sharePasswordHeader: "wrong"}); w.Code != http.StatusUnauthorized {
+		t.Errorf("密码错误应返回 401，实际得到 %d", w.Code)
+	}
+
+	w = openShareLink(router, share.Path, map[string]string{sharePasswordHeader: "secret"})
+	if w.Code != http.StatusOK || w.Body.String() != "shared snippet" || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("期望以纯文本返回内容，实际得到 %d %q", w.Code, w.Body.String())
+	}
+
+	req, _ := http.NewRequest("GET", share.Path+"/raw", nil)
+	req.SetBasicAuth("", "secret")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("期望以附件下载内容，实际得到 %d %v", w.Code, w.Header())
+	}
+
+	if w := openShareLink(router, share.Path, map[string]string{sharePasswordHeader: "secret"}); w.Code != http.StatusGone {
+		t.Errorf("超过访问次数应返回 410，实际得到 %d", w.Code)
+	}
+
+	w = doJSON(router, "GET", "/shares", token, nil)
+	var list struct {
+		Shares []models.ShareLinkResponse `json:"shares"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Shares) != 1 || list.Shares[0].Views != 2 {
+		t.Errorf("期望 1 个访问 2 次的链接，实际得到 %s", w.Body.String())
+	}
+
+	if w := doJSON(router, "DELETE", "/shares/"+share.ID, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("撤销分享链接失败: %d", w.Code)
+	}
+	if w := openShareLink(router, share.Path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("撤销后应返回 404，实际得到 %d", w.Code)
+	}
+}
+
+func TestShareLinkItemGone(t *testing.T) {
+	router, token, items := setupShareRouter(t)
+
+	encrypted := models.ClipboardItem{UserID: clipboardUser.ID, Content: "Y2lwaGVy", Encrypted: true, KeyID: "k1"}
+	items.CreateItem(&encrypted)
+	if w := doJSON(router, "POST", "/items/"+encrypted.ID+"/shares", token, nil); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("端到端加密项目不应被分享，实际得到 %d", w.Code)
+	}
+
+	item := models.ClipboardItem{UserID: clipboardUser.ID, Content: "short lived"}
+	items.CreateItem(&item)
+	w := doJSON(router, "POST", "/items/"+item.ID+"/shares", token, nil)
+	var share models.ShareLinkResponse
+	json.Unmarshal(w.Body.Bytes(), &share)
+
+	items.DeleteItem(clipboardUser.ID, item.ID)
+	if w := openShareLink(router, share.Path, nil); w.Code != http.StatusNotFound {
+		t.Errorf("项目删除后链接应返回 404，实际得到 %d", w.Code)
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/config"
+	"clipboard-server/importer"
+	"clipboard-server/logging"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/transfer"
+	"clipboard-server/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// transferWriteTimeout write deadline for each batch of a streamed export
+const transferWriteTimeout = 30 * time.Second
+
+// TransferHandler for bulk export and import of clipboard history
+type TransferHandler struct {
+	transfers repository.TransferRepository
+	items     repository.ClipboardRepository
+}
+
+// NewTransferHandler creates transfer handler instance
+func NewTransferHandler(transfers repository.TransferRepository, items repository.ClipboardRepository) *TransferHandler {
+	return &TransferHandler{transfers: transfers, items: items}
+}
+
+// Export streams all of the user's clipboard items as NDJSON or a tar.gz archive
+func (h *TransferHandler) Export(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	format := c.DefaultQuery("format", "ndjson")
+	contentType := "application/x-ndjson"
+	switch format {
+	case "ndjson":
+	case "tar.gz":
+		contentType = "application/gzip"
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid format",
+			Message: "format must be ndjson or tar.gz",
+		})
+		return
+	}
+
+	// Every batch gets its own write deadline, so large exports are not cut off
+	// by the server write timeout
+	rc := http.NewResponseController(c.Writer)
+	flush := func() {
+		rc.SetWriteDeadline(time.Now().Add(transferWriteTimeout))
+		c.Writer.Flush()
+	}
+
+	filename := fmt.Sprintf("clipboard-export-%s.%s", time.Now().Format("20060102-150405"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+	flush()
+
+	transfers := h.transfers.WithContext(c.Request.Context())
+
+	var count int
+	var err error
+	if format == "tar.gz" {
+		count, err = transfer.WriteArchive(c.Writer, transfers, userID, flush)
+	} else {
+		count, err = transfer.WriteNDJSON(c.Writer, transfers, userID, flush)
+	}
+
+	// The status is already sent, a failed export ends with a truncated stream
+	if err != nil {
+		logging.For(c).Error("导出失败", "handler", "Export", "exported", count, "error", err)
+		return
+	}
+	logging.For(c).Info("导出完成", "handler", "Export", "exported", count)
+}
+
+// Import streams an NDJSON or tar.gz export into the user's clipboard history.
+// Progress is reported as NDJSON lines while the upload is read, the last line
+// has done set.
+func (h *TransferHandler) Import(c *gin.Context) {
+	h.runImport(c, func(im *transfer.Importer, body io.Reader) (transfer.Progress, error) {
+		return im.Import(body)
+	})
+}
+
+// ImportFormat imports clipboard history exported by another clipboard manager
+// through one of the registered importers. Source fields are mapped with
+// map[<item field>]=<source field> query parameters.
+func (h *TransferHandler) ImportFormat(c *gin.Context) {
+	imp, ok := importer.Lookup(c.Param("format"))
+	if !ok {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "unknown format",
+			Message: "no importer registered for format " + c.Param("format"),
+		})
+		return
+	}
+
+	opts := importer.Options{
+		Mapping:   c.QueryMap("map"),
+		Delimiter: c.Query("delimiter"),
+	}
+	if err := opts.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid mapping",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	h.runImport(c, func(im *transfer.Importer, body io.Reader) (transfer.Progress, error) {
+		if err := imp.Read(body, opts, im); err != nil {
+			progress, _ := im.Finish()
+			return progress, err
+		}
+		return im.Finish()
+	})
+}
+
+// ListImporters lists the formats accepted by ImportFormat
+func (h *TransferHandler) ListImporters(c *gin.Context) {
+	formats := make([]gin.H, 0)
+	for _, imp := range importer.All() {
+		formats = append(formats, gin.H{
+			"name":        imp.Name(),
+			"description": imp.Description(),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"importers": formats,
+		"fields":    importer.Fields,
+	})
+}
+
+// runImport reads the request body with read and streams the import progress
+func (h *TransferHandler) runImport(c *gin.Context, read func(*transfer.Importer, io.Reader) (transfer.Progress, error)) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	im := &transfer.Importer{
+		Items:   h.transfers.WithContext(c.Request.Context()),
+		UserID:  userID,
+		Prepare: ImportPreparer(h.items.WithContext(c.Request.Context()), userID),
+	}
+
+	// Progress is written while the request body is still being read
+	rc := http.NewResponseController(c.Writer)
+	if err := rc.EnableFullDuplex(); err != nil {
+		logging.For(c).Warn("无法启用全双工", "handler", "Import", "error", err)
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	im.Progress = func(progress transfer.Progress) {
+		rc.SetWriteDeadline(time.Now().Add(transferWriteTimeout))
+		encoder.Encode(progress)
+		c.Writer.Flush()
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, config.GetConfig().UploadMaxSize)
+	progress, err := read(im, body)
+	if err != nil {
+		logging.For(c).Error("导入中止", "handler", "Import", "error", err)
+		progress.Error = err.Error()
+	}
+	progress.Done = true
+	encoder.Encode(progress)
+
+	logging.For(c).Info("导入完成", "handler", "Import",
+		"imported", progress.Imported, "skipped", progress.Skipped, "failed", progress.Failed)
+}
+
+// ImportPreparer validates imported records the same way as items created
+// through the API: size and type limits, E2EE requirement, sensitive content
+// policy and expiry. Records that already expired are skipped.
+func ImportPreparer(items repository.ClipboardRepository, userID string) func(*models.ClipboardItem, transfer.Record) error {
+	cfg := config.GetConfig()
+	encryptionRequired := items.RequiresEncryption(userID)
+	policy := items.SensitivePolicy(userID)
+	now := time.Now()
+
+	return func(item *models.ClipboardItem, record transfer.Record) error {
+		if utils.GetContentSize(record.Content) > cfg.MaxContentSize {
+			return errors.New("content too large")
+		}
+		if item.Type == "" {
+			item.Type = models.ClipboardTypeText
+		}
+		if !utils.IsValidContentType(string(item.Type)) {
+			return errors.New("invalid content type")
+		}
+		if record.Encryption == nil && encryptionRequired {
+			return errors.New("encryption required")
+		}
+		if record.ExpiresAt != nil && !record.ExpiresAt.After(now) {
+			return transfer.ErrSkip
+		}
+
+		report := applyContent(item, record.Content, record.Encryption, policy)
+		if report != nil && report.Action == models.SensitiveActionReject {
+			return errors.New("sensitive content rejected")
+		}
+		if record.ExpiresAt != nil {
+			expiresAt := record.ExpiresAt.Time
+			applyExpiry(item, &expiresAt)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"clipboard-server/auth"
+	"clipboard-server/events"
+	"clipboard-server/logging"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/webhooks"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler manages the webhook subscriptions of users and their delivery log
+type WebhookHandler struct {
+	webhooks   repository.WebhookRepository
+	dispatcher *webhooks.Dispatcher
+}
+
+// NewWebhookHandler creates webhook handler instance
+func NewWebhookHandler(repo repository.WebhookRepository, dispatcher *webhooks.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{webhooks: repo, dispatcher: dispatcher}
+}
+
+// repo returns the repository bound to the context of the request
+func (h *WebhookHandler) repo(c *gin.Context) repository.WebhookRepository {
+	return h.webhooks.WithContext(c.Request.Context())
+}
+
+// ListWebhooks lists the webhooks of the current user
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	list, err := h.repo(c).ListWebhooks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch webhooks",
+		})
+		return
+	}
+
+	responses := make([]models.WebhookResponse, len(list))
+	for i := range list {
+		responses[i] = list[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": responses,
+		"total":    len(responses),
+	})
+}
+
+// CreateWebhook subscribes a URL to the events of the current user. The
+// secret signing the payloads is only returned in this response.
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	req, ok := h.bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.GenerateSecret(); err != nil {
+			c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+				Error:   "creation failed",
+				Message: "failed to generate webhook secret",
+			})
+			return
+		}
+	}
+
+	webhook := models.Webhook{
+		UserID:      userID,
+		URL:         req.URL,
+		Description: req.Description,
+		Secret:      secret,
+		Active:      req.Active == nil || *req.Active,
+	}
+	webhook.SetFilters(req.Filters)
+
+	if err := h.repo(c).CreateWebhook(&webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to create webhook",
+		})
+		return
+	}
+
+	logging.For(c).Info("已创建 webhook", "handler", "CreateWebhook", "webhook_id", webhook.ID)
+	response := webhook.ToResponse()
+	response.Secret = secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetWebhook returns a webhook of the current user
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, webhook.ToResponse())
+}
+
+// UpdateWebhook replaces the URL, description, filters and state of a
+// webhook. The secret is kept unless a new one is given.
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	req, ok := h.bindWebhookRequest(c)
+	if !ok {
+		return
+	}
+
+	webhook.URL = req.URL
+	webhook.Description = req.Description
+	webhook.SetFilters(req.Filters)
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	if req.Secret != "" {
+		webhook.Secret = req.Secret
+	}
+
+	if err := h.repo(c).SaveWebhook(&webhook); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to update webhook",
+		})
+		return
+	}
+
+	logging.For(c).Info("已更新 webhook", "handler", "UpdateWebhook", "webhook_id", webhook.ID)
+	response := webhook.ToResponse()
+	response.Secret = req.Secret
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteWebhook deletes a webhook of the current user and its delivery log
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return
+	}
+
+	err := h.repo(c).DeleteWebhook(userID, c.Param("id"))
+	if err == repository.ErrNotFound {
+		webhookNotFound(c)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "deletion failed",
+			Message: "failed to delete webhook",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.SuccessResponse{
+		Message: "webhook deleted successfully",
+	})
+}
+
+// TestWebhook queues a ping delivery to a webhook, regardless of its filters
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	if !webhook.Active {
+		webhookInactive(c)
+		return
+	}
+
+	delivery, err := webhooks.NewDelivery(webhook, events.Event{Type: webhooks.Ping}, time.Now())
+	if err == nil {
+		err = h.repo(c).CreateDeliveries([]models.WebhookDelivery{delivery})
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "creation failed",
+			Message: "failed to queue test delivery",
+		})
+		return
+	}
+
+	h.dispatcher.Notify()
+	c.JSON(http.StatusAccepted, delivery.ToResponse())
+}
+
+// ListDeliveries returns the delivery log of a webhook, newest first,
+// optionally filtered by ?status=
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+
+	status := models.WebhookDeliveryStatus(c.Query("status"))
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: "status must be pending, succeeded or failed",
+		})
+		return
+	}
+
+	// Get limit parameter (default 50, max 200)
+	limit := 50
+	if limitParam := c.Query("limit"); limitParam != "" {
+		if parsedLimit, err := strconv.Atoi(limitParam); err == nil && parsedLimit > 0 {
+			limit = min(parsedLimit, 200)
+		}
+	}
+
+	deliveries, err := h.repo(c).ListDeliveries(webhook.ID, status, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch webhook deliveries",
+		})
+		return
+	}
+
+	responses := make([]models.WebhookDeliveryResponse, len(deliveries))
+	for i := range deliveries {
+		responses[i] = deliveries[i].ToResponse()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": responses,
+		"total":      len(responses),
+	})
+}
+
+// RetryDelivery queues a finished delivery again with a fresh set of attempts
+func (h *WebhookHandler) RetryDelivery(c *gin.Context) {
+	webhook, ok := h.loadWebhook(c)
+	if !ok {
+		return
+	}
+	if !webhook.Active {
+		webhookInactive(c)
+		return
+	}
+
+	delivery, err := h.repo(c).GetDelivery(webhook.ID, c.Param("delivery_id"))
+	if err == repository.ErrNotFound {
+		c.JSON(http.StatusNotFound, models.ErrorResponse{
+			Error:   "delivery not found",
+			Message: "webhook delivery not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch webhook delivery",
+		})
+		return
+	}
+
+	// A pending delivery may be in flight, it is retried on its own
+	if delivery.Status == models.WebhookDeliveryPending {
+		c.JSON(http.StatusConflict, models.ErrorResponse{
+			Error:   "delivery pending",
+			Message: "the delivery is still queued",
+		})
+		return
+	}
+
+	now := time.Now()
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	delivery.Error = ""
+	if err := h.repo(c).SaveDelivery(&delivery); err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "update failed",
+			Message: "failed to queue webhook delivery",
+		})
+		return
+	}
+
+	logging.For(c).Info("已重新投递 webhook", "handler", "RetryDelivery", "webhook_id", webhook.ID, "delivery_id", delivery.ID)
+	h.dispatcher.Notify()
+	c.JSON(http.StatusAccepted, delivery.ToResponse())
+}
+
+// loadWebhook returns the :id webhook of the current user, or writes the error response
+func (h *WebhookHandler) loadWebhook(c *gin.Context) (models.Webhook, bool) {
+	userID, exists := auth.GetCurrentUserID(c)
+	if !exists {
+		c.JSON(http.StatusUnauthorized, models.ErrorResponse{
+			Error:   "unauthorized",
+			Message: "user not authenticated",
+		})
+		return models.Webhook{}, false
+	}
+
+	webhook, err := h.repo(c).GetWebhook(userID, c.Param("id"))
+	if err == repository.ErrNotFound {
+		webhookNotFound(c)
+		return models.Webhook{}, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
+			Error:   "database error",
+			Message: "failed to fetch webhook",
+		})
+		return models.Webhook{}, false
+	}
+	return webhook, true
+}
+
+// bindWebhookRequest binds a webhook request, whose URL must pass the
+// dispatcher's target check
+func (h *WebhookHandler) bindWebhookRequest(c *gin.Context) (models.WebhookRequest, bool) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return req, false
+	}
+
+	if err := h.dispatcher.CheckTarget(c.Request.Context(), req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, models.ErrorResponse{
+			Error:   "invalid request",
+			Message: err.Error(),
+		})
+		return req, false
+	}
+	return req, true
+}
+
+func webhookNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.ErrorResponse{
+		Error:   "webhook not found",
+		Message: "webhook not found",
+	})
+}
+
+func webhookInactive(c *gin.Context) {
+	c.JSON(http.StatusConflict, models.ErrorResponse{
+		Error:   "webhook inactive",
+		Message: "activate the webhook before sending deliveries",
+	})
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"bytes"
+	"clipboard-server/auth"
+	"clipboard-server/events"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/webhooks"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// setupWebhookRouter 使用内存存储创建 webhook 路由，允许本地接收端作为投递目标
+func setupWebhookRouter(t *testing.T) (*gin.Engine, string, *webhooks.Dispatcher, *repository.MemoryWebhookRepository) {
+	t.Parallel()
+
+	repo := repository.NewMemoryWebhookRepository()
+	dispatcher := webhooks.NewDispatcher(repo, true)
+	webhookHandler := NewWebhookHandler(repo, dispatcher)
+	token, _ := auth.GenerateToken(clipboardUser.ID, clipboardUser.Username, clipboardUser.Email)
+
+	router := gin.New()
+	group := router.Group("/webhooks")
+	group.Use(auth.JWTAuthMiddleware())
+	group.GET("", webhookHandler.ListWebhooks)
+	group.POST("", webhookHandler.CreateWebhook)
+	group.GET("/:id", webhookHandler.GetWebhook)
+	group.PUT("/:id", webhookHandler.UpdateWebhook)
+	group.DELETE("/:id", webhookHandler.DeleteWebhook)
+	group.POST("/:id/test", webhookHandler.TestWebhook)
+	group.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+	group.POST("/:id/deliveries/:delivery_id/retry", webhookHandler.RetryDelivery)
+
+	return router, token, dispatcher, repo
+}
+
+func TestWebhookLifecycle(t *testing.T) {
+	router, token, dispatcher, _ := setupWebhookRouter(t)
+
+	var out bytes.Buffer
+	receiver := &webhooks.Receiver{Status: http.StatusInternalServerError, Out: &out}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	invalid := []gin.H{
+		{"url": "ftp://example.com/hook"},
+		{"url": server.URL, "filters": gin.H{"event_types": []string{"item.moved"}}},
+		{"url": server.URL, "filters": gin.H{"tags": []string{"a,b"}}},
+	}
+	for _, body := range invalid {
+		if w := doJSON(router, "POST", "/webhooks", token, body); w.Code != http.StatusBadRequest {
+			t.Errorf("无效的 webhook %v 应返回 400，实际得到 %d", body, w.Code)
+		}
+	}
+
+	w := doJSON(router, "POST", "/webhooks", token, gin.H{"url": server.URL, "filters": gin.H{"event_types": []string{"item.created"}}})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("创建 webhook 失败: %d %s", w.Code, w.Body.String())
+	}
+	var webhook models.WebhookResponse
+	json.Unmarshal(w.Body.Bytes(), &webhook)
+	if !webhook.Active || !strings.HasPrefix(webhook.Secret, "whsec_") || len(webhook.Filters.EventTypes) != 1 {
+		t.Fatalf("webhook 设置不正确: %s", w.Body.String())
+	}
+	receiver.Secret = webhook.Secret
+
+	// 密钥只在创建时返回
+	w = doJSON(router, "GET", "/webhooks/"+webhook.ID, token, nil)
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), webhook.Secret) {
+		t.Errorf("查询 webhook 不应返回密钥: %d %s", w.Code, w.Body.String())
+	}
+
+	w = doJSON(router, "POST", "/webhooks/"+webhook.ID+"/test", token, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("发送测试投递失败: %d %s", w.Code, w.Body.String())
+	}
+	var delivery models.WebhookDeliveryResponse
+	json.Unmarshal(w.Body.Bytes(), &delivery)
+
+	// 接收端返回 500 时保留投递等待重试
+	dispatcher.Deliver(context.Background())
+	w = doJSON(router, "GET", "/webhooks/"+webhook.ID+"/deliveries?status=pending", token, nil)
+	var log struct {
+		Deliveries []models.WebhookDeliveryResponse `json:"deliveries"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &log)
+	if len(log.Deliveries) != 1 || log.Deliveries[0].Attempts != 1 || log.Deliveries[0].ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("期望 1 个待重试的投递，实际得到 %s", w.Body.String())
+	}
+	if !strings.Contains(out.String(), "signature=valid") || !strings.Contains(out.String(), `"type": "ping"`) {
+		t.Errorf("接收端应收到签名有效的 ping: %s", out.String())
+	}
+
+	if w := doJSON(router, "POST", "/webhooks/"+webhook.ID+"/deliveries/"+delivery.ID+"/retry", token, nil); w.Code != http.StatusConflict {
+		t.Errorf("排队中的投递不能重新投递，实际得到 %d", w.Code)
+	}
+
+	if w := doJSON(router, "GET", "/webhooks/"+webhook.ID+"/deliveries?status=lost", token, nil); w.Code != http.StatusBadRequest {
+		t.Errorf("无效的状态应返回 400，实际得到 %d", w.Code)
+	}
+
+	w = doJSON(router, "PUT", "/webhooks/"+webhook.ID, token, gin.H{"url": server.URL, "active": false})
+	var updated models.WebhookResponse
+	json.Unmarshal(w.Body.Bytes(), &updated)
+	if w.Code != http.StatusOK || updated.Active || len(updated.Filters.EventTypes) != 0 || updated.Secret != "" {
+		t.Errorf("更新 webhook 失败: %d %s", w.Code, w.Body.String())
+	}
+	if w := doJSON(router, "POST", "/webhooks/"+webhook.ID+"/test", token, nil); w.Code != http.StatusConflict {
+		t.Errorf("停用的 webhook 不应发送测试投递，实际得到 %d", w.Code)
+	}
+
+	if w := doJSON(router, "DELETE", "/webhooks/"+webhook.ID, token, nil); w.Code != http.StatusOK {
+		t.Fatalf("删除 webhook 失败: %d", w.Code)
+	}
+	if w := doJSON(router, "GET", "/webhooks/"+webhook.ID+"/deliveries", token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("删除后应返回 404，实际得到 %d", w.Code)
+	}
+}
+
+func TestWebhookPrivateTarget(t *testing.T) {
+	t.Parallel()
+
+	repo := repository.NewMemoryWebhookRepository()
+	webhookHandler := NewWebhookHandler(repo, webhooks.NewDispatcher(repo, false))
+	token, _ := auth.GenerateToken(clipboardUser.ID, clipboardUser.Username, clipboardUser.Email)
+
+	router := gin.New()
+	router.POST("/webhooks", auth.JWTAuthMiddleware(), webhookHandler.CreateWebhook)
+
+	for _, target := range []string{"http://169.254.169.254/latest/meta-data", "http://localhost:9000/", "http://10.0.0.5/hook"} {
+		if w := doJSON(router, "POST", "/webhooks", token, gin.H{"url": target}); w.Code != http.StatusBadRequest {
+			t.Errorf("内网地址 %s 应返回 400，实际得到 %d", target, w.Code)
+		}
+	}
+	if saved, _ := repo.ListWebhooks(clipboardUser.ID); len(saved) != 0 {
+		t.Errorf("不应保存指向内网地址的 webhook，实际得到 %d 个", len(saved))
+	}
+}
+
+func TestWebhookRetryDelivery(t *testing.T) {
+	router, token, dispatcher, repo := setupWebhookRouter(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	w := doJSON(router, "POST", "/webhooks", token, gin.H{"url": server.URL, "secret": "0123456789abcdef"})
+	var webhook models.WebhookResponse
+	json.Unmarshal(w.Body.Bytes(), &webhook)
+	if webhook.Secret != "0123456789abcdef" {
+		t.Errorf("应使用提供的密钥，实际得到 %q", webhook.Secret)
+	}
+
+	// 模拟已用完重试次数的投递
+	failed, _ := webhooks.NewDelivery(webhook.Webhook, events.Event{Type: webhooks.Ping}, time.Now())
+	failed.Status = models.WebhookDeliveryFailed
+	failed.Attempts = 8
+	failed.NextAttemptAt = nil
+	failed.Error = "receiver answered 502 Bad Gateway"
+	repo.CreateDeliveries([]models.WebhookDelivery{failed})
+
+	if w := doJSON(router, "POST", "/webhooks/"+webhook.ID+"/deliveries/missing/retry", token, nil); w.Code != http.StatusNotFound {
+		t.Errorf("不存在的投递应返回 404，实际得到 %d", w.Code)
+	}
+
+	w = doJSON(router, "POST", "/webhooks/"+webhook.ID+"/deliveries/"+failed.ID+"/retry", token, nil)
+	var retried models.WebhookDeliveryResponse
+	json.Unmarshal(w.Body.Bytes(), &retried)
+	if w.Code != http.StatusAccepted || retried.Status != models.WebhookDeliveryPending || retried.Attempts != 0 || retried.Error != "" {
+		t.Fatalf("重新投递失败: %d %s", w.Code, w.Body.String())
+	}
+
+	dispatcher.Deliver(context.Background())
+	delivery, _ := repo.GetDelivery(webhook.ID, failed.ID)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 1 {
+		t.Errorf("重新投递后应成功: %+v", delivery)
+	}
+}
@@ -0,0 +1,68 @@
+package health
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/scheduler"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+)
+
+// Database pings the database
+func Database() Checker {
+	return Checker{Name: "database", Check: database.HealthCheck}
+}
+
+// Migrations fails while schema migrations are pending
+func Migrations(db func() *gorm.DB) Checker {
+	return Checker{Name: "migrations", Check: func(ctx context.Context) error {
+		conn := db()
+		if conn == nil {
+			return fmt.Errorf("database is not connected")
+		}
+		pending, err := database.PendingMigrations(conn.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migrations pending", pending)
+		}
+		return nil
+	}}
+}
+
+// DiskSpace fails when the file system holding path has less than minFree
+// bytes available. A path not created yet is checked on its nearest parent.
+func DiskSpace(path string, minFree uint64) Checker {
+	return Checker{Name: "disk", Check: func(ctx context.Context) error {
+		dir := filepath.Clean(path)
+		for {
+			if _, err := os.Stat(dir); err == nil || !os.IsNotExist(err) {
+				break
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+		free, err := diskFree(dir)
+		if err != nil {
+			return err
+		}
+		if free < minFree {
+			return fmt.Errorf("%d bytes free under %s, at least %d required", free, path, minFree)
+		}
+		return nil
+	}}
+}
+
+// Scheduler fails when the background jobs have stopped running
+func Scheduler(s *scheduler.Scheduler) Checker {
+	return Checker{Name: "scheduler", Check: func(ctx context.Context) error {
+		return s.Alive()
+	}}
+}
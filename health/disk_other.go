@@ -0,0 +1,13 @@
+//go:build !unix
+
+package health
+
+import "math"
+
+// diskSupported whether diskFree reports the free space
+const diskSupported = false
+
+// diskFree isn't implemented on this platform, the check always passes
+func diskFree(path string) (uint64, error) {
+	return math.MaxUint64, nil
+}
@@ -0,0 +1,18 @@
+//go:build unix
+
+package health
+
+import "golang.org/x/sys/unix"
+
+// diskSupported whether diskFree reports the free space
+const diskSupported = true
+
+// diskFree returns the bytes available to unprivileged users on the file
+// system holding path
+func diskFree(path string) (uint64, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,201 @@
+// Package health runs the checks behind the liveness, readiness and startup
+// probes of the server.
+//
+// A probe passes when all of its checks pass. Liveness fails only when the
+// process should be restarted, readiness when it should not receive traffic,
+// and startup until initialisation has completed.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTimeout bounds each check unless another timeout is given
+const DefaultTimeout = 2 * time.Second
+
+// Status of a check or probe
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// Checker a named dependency check
+type Checker struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// Result outcome of one check
+type Result struct {
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// Report outcome of a probe
+type Report struct {
+	Probe     string   `json:"probe"`
+	Status    string   `json:"status"`
+	Checks    []Result `json:"checks"`
+	Timestamp string   `json:"timestamp"`
+}
+
+// Healthy reports whether all checks passed
+func (r Report) Healthy() bool {
+	return r.Status == StatusOK
+}
+
+// Probes holds the checkers of the three probes
+type Probes struct {
+	Timeout time.Duration
+
+	mu        sync.RWMutex
+	liveness  []Checker
+	readiness []Checker
+	startup   []Checker
+
+	started atomic.Bool
+}
+
+// New creates probes without checkers; startup fails until MarkStarted is called
+func New(timeout time.Duration) *Probes {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	p := &Probes{Timeout: timeout}
+	p.AddStartup(Checker{Name: "started", Check: func(ctx context.Context) error {
+		if !p.started.Load() {
+			return fmt.Errorf("server is still starting")
+		}
+		return nil
+	}})
+	return p
+}
+
+// AddLiveness registers a check that fails when the process must be restarted
+func (p *Probes) AddLiveness(c Checker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.liveness = append(p.liveness, c)
+}
+
+// AddReadiness registers a check that fails when the server cannot serve requests
+func (p *Probes) AddReadiness(c Checker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.readiness = append(p.readiness, c)
+}
+
+// AddStartup registers a check that fails until initialisation has completed
+func (p *Probes) AddStartup(c Checker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.startup = append(p.startup, c)
+}
+
+// MarkStarted records that the server has finished starting
+func (p *Probes) MarkStarted() {
+	p.started.Store(true)
+}
+
+// Liveness runs the liveness checks
+func (p *Probes) Liveness(ctx context.Context) Report {
+	p.mu.RLock()
+	checks := p.liveness
+	p.mu.RUnlock()
+	return p.run(ctx, "livez", checks)
+}
+
+// Readiness runs the readiness checks. The server isn't ready before it has
+// started, so the startup checks are included.
+func (p *Probes) Readiness(ctx context.Context) Report {
+	p.mu.RLock()
+	checks := append(append([]Checker{}, p.startup...), p.readiness...)
+	p.mu.RUnlock()
+	return p.run(ctx, "readyz", checks)
+}
+
+// Startup runs the startup checks
+func (p *Probes) Startup(ctx context.Context) Report {
+	p.mu.RLock()
+	checks := p.startup
+	p.mu.RUnlock()
+	return p.run(ctx, "startupz", checks)
+}
+
+// run runs checks concurrently, each bounded by the timeout
+func (p *Probes) run(ctx context.Context, probe string, checks []Checker) Report {
+	results := make([]Result, len(checks))
+
+	var wg sync.WaitGroup
+	for i, checker := range checks {
+		wg.Add(1)
+		go func(i int, checker Checker) {
+			defer wg.Done()
+			results[i] = p.check(ctx, checker)
+		}(i, checker)
+	}
+	wg.Wait()
+
+	report := Report{
+		Probe:     probe,
+		Status:    StatusOK,
+		Checks:    results,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+	for _, result := range results {
+		if result.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+func (p *Probes) check(ctx context.Context, checker Checker) Result {
+	ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = fmt.Errorf("check timed out after %s", p.Timeout)
+	}
+
+	result := Result{
+		Name:       checker.Name,
+		Status:     StatusOK,
+		DurationMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		result.Status = StatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Handler serves the report of a probe, with status 503 when it fails
+func Handler(probe func(context.Context) Report) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := probe(c.Request.Context())
+		status := http.StatusOK
+		if !report.Healthy() {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, report)
+	}
+}
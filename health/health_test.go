@@ -0,0 +1,147 @@
+package health
+
+import (
+	"clipboard-server/database/dbtest"
+	"clipboard-server/scheduler"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func passing(name string) Checker {
+	return Checker{Name: name, Check: func(ctx context.Context) error { return nil }}
+}
+
+func failing(name string) Checker {
+	return Checker{Name: name, Check: func(ctx context.Context) error { return errors.New("down") }}
+}
+
+func serve(t *testing.T, probe func(context.Context) Report) (int, Report) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/probe", Handler(probe))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/probe", nil))
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("响应不是有效的 JSON: %s", w.Body.String())
+	}
+	return w.Code, report
+}
+
+func TestReadinessReportsEachCheck(t *testing.T) {
+	probes := New(time.Second)
+	probes.MarkStarted()
+	probes.AddReadiness(passing("database"))
+	probes.AddReadiness(failing("disk"))
+
+	code, report := serve(t, probes.Readiness)
+	if code != http.StatusServiceUnavailable || report.Status != StatusFail {
+		t.Fatalf("有检查失败时应返回 503，实际得到 %d %s", code, report.Status)
+	}
+
+	statuses := map[string]Result{}
+	for _, result := range report.Checks {
+		statuses[result.Name] = result
+	}
+	if statuses["database"].Status != StatusOK {
+		t.Errorf("database 检查应通过，实际得到 %+v", statuses["database"])
+	}
+	if statuses["disk"].Status != StatusFail || statuses["disk"].Error != "down" {
+		t.Errorf("disk 检查应失败并返回错误信息，实际得到 %+v", statuses["disk"])
+	}
+}
+
+func TestStartupGatesReadiness(t *testing.T) {
+	probes := New(time.Second)
+	probes.AddReadiness(passing("database"))
+
+	if code, _ := serve(t, probes.Startup); code != http.StatusServiceUnavailable {
+		t.Errorf("启动完成前 startupz 应返回 503，实际得到 %d", code)
+	}
+	if code, _ := serve(t, probes.Readiness); code != http.StatusServiceUnavailable {
+		t.Errorf("启动完成前 readyz 应返回 503，实际得到 %d", code)
+	}
+
+	probes.MarkStarted()
+	if code, _ := serve(t, probes.Startup); code != http.StatusOK {
+		t.Errorf("启动完成后 startupz 应返回 200，实际得到 %d", code)
+	}
+	if code, _ := serve(t, probes.Readiness); code != http.StatusOK {
+		t.Errorf("启动完成后 readyz 应返回 200，实际得到 %d", code)
+	}
+	if code, _ := serve(t, probes.Liveness); code != http.StatusOK {
+		t.Errorf("没有存活检查时 livez 应返回 200，实际得到 %d", code)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	probes := New(20 * time.Millisecond)
+	probes.AddLiveness(Checker{Name: "slow", Check: func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}})
+
+	start := time.Now()
+	report := probes.Liveness(context.Background())
+	if report.Healthy() || time.Since(start) > 500*time.Millisecond {
+		t.Errorf("超时的检查应及时失败，实际得到 %+v", report)
+	}
+}
+
+func TestMigrationsCheck(t *testing.T) {
+	db := dbtest.Open(t)
+	check := Migrations(func() *gorm.DB { return db })
+	if err := check.Check(context.Background()); err != nil {
+		t.Errorf("迁移完成后检查应通过: %v", err)
+	}
+
+	check = Migrations(func() *gorm.DB { return nil })
+	if err := check.Check(context.Background()); err == nil {
+		t.Error("数据库未连接时检查应失败")
+	}
+}
+
+func TestDiskSpaceCheck(t *testing.T) {
+	// The upload directory may not have been created yet
+	path := filepath.Join(t.TempDir(), "uploads")
+
+	if err := DiskSpace(path, 0).Check(context.Background()); err != nil {
+		t.Errorf("空间足够时检查应通过: %v", err)
+	}
+	if err := DiskSpace(path, math.MaxUint64).Check(context.Background()); err == nil && diskSupported {
+		t.Error("空间不足时检查应失败")
+	}
+}
+
+func TestSchedulerCheck(t *testing.T) {
+	s := scheduler.New()
+	s.Add(scheduler.Job{Name: "noop", Interval: time.Hour, Run: func(ctx context.Context) error { return nil }})
+
+	check := Scheduler(s)
+	if err := check.Check(context.Background()); err == nil {
+		t.Error("调度器未启动时检查应失败")
+	}
+
+	s.Start()
+	if err := check.Check(context.Background()); err != nil {
+		t.Errorf("调度器运行时检查应通过: %v", err)
+	}
+
+	s.Stop()
+	if err := check.Check(context.Background()); err == nil {
+		t.Error("调度器停止后检查应失败")
+	}
+}
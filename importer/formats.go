@@ -0,0 +1,200 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"clipboard-server/transfer"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// csvImporter reads CSV files with a header row naming the columns
+type csvImporter struct{}
+
+func (csvImporter) Name() string { return "csv" }
+
+func (csvImporter) Description() string {
+	return "CSV with a header row, columns mapped onto item fields"
+}
+
+func (csvImporter) Read(r io.Reader, opts Options, sink Sink) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.ReuseRecord = true
+	if opts.Delimiter != "" {
+		reader.Comma, _ = utf8.DecodeRuneInString(opts.Delimiter)
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read CSV header: %v", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			sink.Fail("", parseErr.Error())
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		record, err := toRecord(func(name string) (string, bool) {
+			i, ok := columns[name]
+			if !ok || i >= len(row) {
+				return "", false
+			}
+			return row[i], true
+		}, opts)
+		if err != nil {
+			sink.Fail("", err.Error())
+			continue
+		}
+		if err := sink.Add(record); err != nil {
+			return err
+		}
+	}
+}
+
+// jsonImporter reads a JSON array of objects, or a stream of objects such as
+// NDJSON. Nested fields are mapped with dotted names like "data.text".
+type jsonImporter struct{}
+
+func (jsonImporter) Name() string { return "json" }
+
+func (jsonImporter) Description() string {
+	return "JSON array or stream of objects, fields mapped onto item fields"
+}
+
+func (jsonImporter) Read(r io.Reader, opts Options, sink Sink) error {
+	br := bufio.NewReader(r)
+	decoder := json.NewDecoder(br)
+	decoder.UseNumber()
+
+	// An array is read element by element rather than as a whole
+	inArray := false
+	if first, err := firstByte(br); err != nil {
+		return err
+	} else if first == '[' {
+		if _, err := decoder.Token(); err != nil {
+			return err
+		}
+		inArray = true
+	}
+
+	for {
+		if inArray && !decoder.More() {
+			return nil
+		}
+
+		var entry interface{}
+		if err := decoder.Decode(&entry); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+
+		object, ok := entry.(map[string]interface{})
+		if !ok {
+			sink.Fail("", "entry is not a JSON object")
+			continue
+		}
+
+		record, err := toRecord(func(name string) (string, bool) {
+			return lookupJSON(object, name)
+		}, opts)
+		if err != nil {
+			sink.Fail("", err.Error())
+			continue
+		}
+		if err := sink.Add(record); err != nil {
+			return err
+		}
+	}
+}
+
+// firstByte returns the first non-whitespace byte without consuming it
+func firstByte(br *bufio.Reader) (byte, error) {
+	for {
+		b, err := br.ReadByte()
+		if err == io.EOF {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+			return b, br.UnreadByte()
+		}
+	}
+}
+
+// lookupJSON returns a dotted field of a JSON object as a string
+func lookupJSON(object map[string]interface{}, name string) (string, bool) {
+	var value interface{} = object
+	for _, part := range strings.Split(name, ".") {
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+		if value, ok = m[part]; !ok {
+			return "", false
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+// textImporter reads plain text with one clipboard item per line
+type textImporter struct{}
+
+func (textImporter) Name() string { return "text" }
+
+func (textImporter) Description() string {
+	return "plain text, one item per non-empty line"
+}
+
+func (textImporter) Read(r io.Reader, opts Options, sink Sink) error {
+	br := bufio.NewReader(r)
+	for {
+		line, readErr := br.ReadString('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		if line = strings.TrimRight(line, "\r\n"); strings.TrimSpace(line) != "" {
+			if err := sink.Add(transfer.Record{Content: line}); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
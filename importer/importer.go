@@ -0,0 +1,152 @@
+package importer
+
+import (
+	"clipboard-server/models"
+	"clipboard-server/transfer"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Sink receives the records read by an importer, implemented by transfer.Importer
+type Sink interface {
+	Add(record transfer.Record) error
+	Fail(clientID, message string)
+}
+
+// Importer reads clipboard history exported by another application
+type Importer interface {
+	// Name identifies the importer in the API and CLI
+	Name() string
+	// Description is shown when listing importers
+	Description() string
+	// Read parses r and passes every entry to the sink
+	Read(r io.Reader, opts Options, sink Sink) error
+}
+
+// Options configure how source entries map onto clipboard items
+type Options struct {
+	// Mapping maps item fields to field names of the source, e.g.
+	// {"content": "text", "timestamp": "copied_at"}. Unmapped item fields are
+	// read from a source field of the same name.
+	Mapping map[string]string
+	// Delimiter separates CSV fields, "," by default
+	Delimiter string
+}
+
+// Fields item fields an import can map
+var Fields = []string{"content", "type", "timestamp", "created_at", "expires_at", "client_id"}
+
+// Validate checks that the mapping only targets known item fields
+func (o Options) Validate() error {
+	for field := range o.Mapping {
+		if !isField(field) {
+			return fmt.Errorf("unknown item field %q, expected one of %s", field, strings.Join(Fields, ", "))
+		}
+	}
+	if len([]rune(o.Delimiter)) > 1 {
+		return fmt.Errorf("delimiter must be a single character")
+	}
+	return nil
+}
+
+// source returns the source field name of an item field
+func (o Options) source(field string) string {
+	if name, ok := o.Mapping[field]; ok && name != "" {
+		return name
+	}
+	return field
+}
+
+func isField(name string) bool {
+	for _, field := range Fields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// toRecord maps the fields of one source entry onto an export record.
+// Timestamps accept every format of models.CustomTime.
+func toRecord(get func(name string) (string, bool), opts Options) (transfer.Record, error) {
+	var record transfer.Record
+
+	content, ok := get(opts.source("content"))
+	if !ok || content == "" {
+		return record, fmt.Errorf("missing content field %q", opts.source("content"))
+	}
+	record.Content = content
+
+	if value, ok := get(opts.source("type")); ok && value != "" {
+		record.Type = models.ClipboardType(strings.ToLower(value))
+	}
+	if value, ok := get(opts.source("client_id")); ok {
+		record.ClientID = value
+	}
+
+	times := []struct {
+		field string
+		set   func(models.CustomTime)
+	}{
+		{"timestamp", func(t models.CustomTime) { record.Timestamp = t }},
+		{"created_at", func(t models.CustomTime) { record.CreatedAt = t }},
+		{"expires_at", func(t models.CustomTime) { record.ExpiresAt = &t }},
+	}
+	for _, tf := range times {
+		value, ok := get(opts.source(tf.field))
+		if !ok || value == "" {
+			continue
+		}
+		parsed, err := models.ParseCustomTime(value)
+		if err != nil {
+			return record, fmt.Errorf("invalid %s: %v", tf.field, err)
+		}
+		tf.set(parsed)
+	}
+
+	return record, nil
+}
+
+var (
+	mu        sync.RWMutex
+	importers = make(map[string]Importer)
+)
+
+// Register adds an importer, replacing any importer with the same name
+func Register(i Importer) {
+	mu.Lock()
+	defer mu.Unlock()
+	importers[i.Name()] = i
+}
+
+// Lookup returns the importer with the given name
+func Lookup(name string) (Importer, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	i, ok := importers[name]
+	return i, ok
+}
+
+// All returns the registered importers sorted by name
+func All() []Importer {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	result := make([]Importer, 0, len(importers))
+	for _, i := range importers {
+		result = append(result, i)
+	}
+	sort.Slice(result, func(a, b int) bool {
+		return result[a].Name() < result[b].Name()
+	})
+	return result
+}
+
+func init() {
+	Register(csvImporter{})
+	Register(jsonImporter{})
+	Register(textImporter{})
+}
@@ -0,0 +1,102 @@
+package importer
+
+import (
+	"clipboard-server/models"
+	"clipboard-server/transfer"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordSink collects the records and failures of an import
+type recordSink struct {
+	records []transfer.Record
+	failed  []string
+}
+
+func (s *recordSink) Add(record transfer.Record) error {
+	s.records = append(s.records, record)
+	return nil
+}
+
+func (s *recordSink) Fail(clientID, message string) {
+	s.failed = append(s.failed, message)
+}
+
+func read(t *testing.T, format, input string, opts Options) *recordSink {
+	imp, ok := Lookup(format)
+	if !ok {
+		t.Fatalf("未注册导入器 %s", format)
+	}
+	sink := &recordSink{}
+	if err := imp.Read(strings.NewReader(input), opts, sink); err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	return sink
+}
+
+func TestCSVImporter(t *testing.T) {
+	input := "text;copied;kind\n" +
+		"hello;1700000000000;TEXT\n" +
+		";1700000000;text\n" +
+		"\"multi\nline\";2024-01-02 03:04:05;text\n" +
+		"bad time;yesterday;text\n"
+
+	sink := read(t, "csv", input, Options{
+		Mapping:   map[string]string{"content": "text", "timestamp": "copied", "type": "kind"},
+		Delimiter: ";",
+	})
+
+	if len(sink.records) != 2 || len(sink.failed) != 2 {
+		t.Fatalf("期望 2 条记录和 2 个失败，实际得到 %d 条记录, 失败: %v", len(sink.records), sink.failed)
+	}
+
+	first := sink.records[0]
+	if first.Content != "hello" || first.Type != models.ClipboardTypeText {
+		t.Errorf("字段映射错误，实际得到 %+v", first)
+	}
+	if !first.Timestamp.Equal(time.UnixMilli(1700000000000)) {
+		t.Errorf("毫秒时间戳解析错误，实际得到 %v", first.Timestamp)
+	}
+	if sink.records[1].Content != "multi\nline" {
+		t.Errorf("引号内的换行应保留，实际得到 %q", sink.records[1].Content)
+	}
+}
+
+func TestJSONImporter(t *testing.T) {
+	opts := Options{Mapping: map[string]string{"content": "data.text", "timestamp": "ts", "client_id": "id"}}
+
+	t.Run("数组", func(t *testing.T) {
+		sink := read(t, "json", `[{"id": 7, "ts": 1700000000, "data": {"text": "one"}}, "oops", {"data": {}}]`, opts)
+		if len(sink.records) != 1 || len(sink.failed) != 2 {
+			t.Fatalf("期望 1 条记录和 2 个失败，实际得到 %d 条记录, 失败: %v", len(sink.records), sink.failed)
+		}
+		record := sink.records[0]
+		if record.Content != "one" || record.ClientID != "7" || !record.Timestamp.Equal(time.Unix(1700000000, 0)) {
+			t.Errorf("字段映射错误，实际得到 %+v", record)
+		}
+	})
+
+	t.Run("对象流", func(t *testing.T) {
+		sink := read(t, "json", "{\"data\": {\"text\": \"a\"}}\n{\"data\": {\"text\": \"b\"}}\n", opts)
+		if len(sink.records) != 2 || sink.records[1].Content != "b" {
+			t.Errorf("期望 2 条记录，实际得到 %+v", sink.records)
+		}
+	})
+}
+
+func TestTextImporter(t *testing.T) {
+	sink := read(t, "text", "first\r\n\n  \nsecond", Options{})
+	if len(sink.records) != 2 || sink.records[0].Content != "first" || sink.records[1].Content != "second" {
+		t.Errorf("期望每个非空行一条记录，实际得到 %+v", sink.records)
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	if err := (Options{Mapping: map[string]string{"body": "text"}}).Validate(); err == nil {
+		t.Error("未知字段的映射应被拒绝")
+	}
+	if err := (Options{Mapping: map[string]string{"content": "text"}, Delimiter: "\t"}).Validate(); err != nil {
+		t.Errorf("有效的选项不应报错: %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"clipboard-server/config"
+	"clipboard-server/database"
+	"clipboard-server/events"
+	"clipboard-server/scheduler"
+	"clipboard-server/webhooks"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// newScheduler registers the background jobs of the server
+func newScheduler(cfg *config.Config, dispatcher *webhooks.Dispatcher) *scheduler.Scheduler {
+	s := scheduler.New()
+	s.Add(scheduler.Job{
+		Name:     "expiry-sweeper",
+		Interval: cfg.ExpirySweepInterval,
+		Run:      sweepExpired,
+	})
+	s.Add(scheduler.Job{
+		Name:     "idempotency-cleanup",
+		Interval: time.Hour,
+		Run:      purgeIdempotencyKeys,
+	})
+	s.Add(scheduler.Job{
+		Name:     "webhook-delivery",
+		Interval: cfg.WebhookPollInterval,
+		Wake:     dispatcher.Wake(),
+		Run:      dispatcher.Deliver,
+	})
+	if cfg.BackupInterval > 0 {
+		s.Add(scheduler.Job{
+			Name:     "database-backup",
+			Interval: cfg.BackupInterval,
+			Run:      backupDatabase,
+		})
+	}
+	return s
+}
+
+// sweepExpired purges expired clipboard items and announces them as deletions,
+// and removes share links that can no longer be opened and old webhook deliveries
+func sweepExpired(ctx context.Context) error {
+	purged, err := database.PurgeExpired(time.Now())
+	events.PublishDeletions(purged, "")
+	if err != nil {
+		return fmt.Errorf("failed to purge expired items: %v", err)
+	}
+	if len(purged) > 0 {
+		slog.Info("已清除过期项目", "job", "expiry-sweep", "purged", len(purged))
+	}
+
+	// Deletion records are kept as long as clipboard items themselves
+	cfg := config.GetConfig()
+	if _, err := database.PurgeDeletions(time.Now().AddDate(0, 0, -cfg.CleanupDays)); err != nil {
+		return fmt.Errorf("failed to purge deletion records: %v", err)
+	}
+	if _, err := database.PurgeShareLinks(time.Now()); err != nil {
+		return fmt.Errorf("failed to purge share links: %v", err)
+	}
+	if _, err := database.PurgeWebhookDeliveries(time.Now().AddDate(0, 0, -cfg.CleanupDays)); err != nil {
+		return fmt.Errorf("failed to purge webhook deliveries: %v", err)
+	}
+	return nil
+}
+
+// idempotencyKeyRetention how long a batch sync can be retried with the same key
+const idempotencyKeyRetention = 24 * time.Hour
+
+// purgeIdempotencyKeys removes expired idempotency records
+func purgeIdempotencyKeys(ctx context.Context) error {
+	if _, err := database.PurgeIdempotencyKeys(time.Now().Add(-idempotencyKeyRetention)); err != nil {
+		return fmt.Errorf("failed to purge idempotency keys: %v", err)
+	}
+	return nil
+}
+
+// backupDatabase writes a scheduled online backup and rotates old ones
+func backupDatabase(ctx context.Context) error {
+	backup, err := database.CreateBackup(database.ConfiguredBackupOptions(config.GetConfig()))
+	if err != nil {
+		return err
+	}
+	slog.Info("已创建定时备份", "job", "database-backup", "backup", backup.Name, "size", backup.Size)
+	return nil
+}
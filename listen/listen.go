@@ -0,0 +1,214 @@
+// Package listen opens the listeners of the HTTP server: TCP addresses, Unix
+// domain sockets and sockets passed by systemd socket activation.
+package listen
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// Network of a listener
+const (
+	TCP     = "tcp"
+	Unix    = "unix"
+	Systemd = "systemd"
+)
+
+// Spec a listener address: tcp://host:port (or host:port), unix:///path/to.sock
+// or systemd, optionally systemd:name for the socket named by FileDescriptorName
+type Spec struct {
+	Network string
+	// Address is host:port, the socket path, or the systemd socket name,
+	// empty for all sockets passed by systemd
+	Address string
+	// Admin listeners serve the admin API when it is restricted to them
+	Admin bool
+}
+
+func (s Spec) String() string {
+	switch s.Network {
+	case Unix:
+		return "unix://" + s.Address
+	case Systemd:
+		if s.Address == "" {
+			return Systemd
+		}
+		return Systemd + ":" + s.Address
+	}
+	return "tcp://" + s.Address
+}
+
+// Parse parses a listener address
+func Parse(addr string) (Spec, error) {
+	addr = strings.TrimSpace(addr)
+	switch {
+	case strings.HasPrefix(addr, "unix:"):
+		path := strings.TrimPrefix(strings.TrimPrefix(addr, "unix:"), "//")
+		if path == "" {
+			return Spec{}, fmt.Errorf("listener %q has no socket path", addr)
+		}
+		return Spec{Network: Unix, Address: path}, nil
+	case addr == Systemd:
+		return Spec{Network: Systemd}, nil
+	case strings.HasPrefix(addr, Systemd+":"):
+		return Spec{Network: Systemd, Address: strings.TrimPrefix(addr, Systemd+":")}, nil
+	}
+
+	hostPort := strings.TrimPrefix(addr, "tcp://")
+	if _, _, err := net.SplitHostPort(hostPort); err != nil || strings.Contains(hostPort, "/") {
+		return Spec{}, fmt.Errorf("invalid listener %q, use host:port, unix:///path or systemd[:name]", addr)
+	}
+	return Spec{Network: TCP, Address: hostPort}, nil
+}
+
+// UnixOptions permissions of the Unix sockets created
+type UnixOptions struct {
+	Mode os.FileMode
+	// Group owning the sockets, empty for the group of the process
+	Group string
+}
+
+// Listener an open listener with its spec
+type Listener struct {
+	net.Listener
+	Spec Spec
+}
+
+// Open opens the listeners of specs. On error, the listeners already opened
+// are closed.
+func Open(specs []Spec, opts UnixOptions) ([]*Listener, error) {
+	var opened []*Listener
+	fail := func(err error) ([]*Listener, error) {
+		for _, l := range opened {
+			l.Close()
+		}
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		var listeners []net.Listener
+		switch spec.Network {
+		case TCP:
+			l, err := net.Listen("tcp", spec.Address)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, l)
+		case Unix:
+			l, err := listenUnix(spec.Address, opts)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = append(listeners, l)
+		case Systemd:
+			inherited, err := systemdListeners(spec.Address)
+			if err != nil {
+				return fail(err)
+			}
+			listeners = inherited
+		default:
+			return fail(fmt.Errorf("unknown listener network %q", spec.Network))
+		}
+
+		for _, l := range listeners {
+			if spec.Admin {
+				l = adminListener{l}
+			}
+			opened = append(opened, &Listener{Listener: l, Spec: spec})
+		}
+	}
+	return opened, nil
+}
+
+// listenUnix creates a Unix socket at path, replacing a stale socket left by
+// a previous run
+func listenUnix(path string, opts UnixOptions) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale socket %s: %v", path, err)
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := setPermissions(path, opts); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+func setPermissions(path string, opts UnixOptions) error {
+	if opts.Group != "" {
+		group, err := user.LookupGroup(opts.Group)
+		if err != nil {
+			return fmt.Errorf("unknown socket group %s: %v", opts.Group, err)
+		}
+		gid, err := strconv.Atoi(group.Gid)
+		if err != nil {
+			return fmt.Errorf("invalid gid %s of group %s", group.Gid, opts.Group)
+		}
+		if err := os.Chown(path, -1, gid); err != nil {
+			return fmt.Errorf("failed to change group of %s: %v", path, err)
+		}
+	}
+	if opts.Mode != 0 {
+		if err := os.Chmod(path, opts.Mode); err != nil {
+			return fmt.Errorf("failed to change mode of %s: %v", path, err)
+		}
+	}
+	return nil
+}
+
+// adminListener marks its connections as accepted on an admin listener
+type adminListener struct {
+	net.Listener
+}
+
+func (l adminListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return adminConn{conn}, nil
+}
+
+type adminConn struct {
+	net.Conn
+}
+
+type adminKey struct{}
+
+// ConnContext is the http.Server ConnContext recording whether a connection
+// was accepted on an admin listener
+func ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn = tlsConn.NetConn()
+	}
+	if _, ok := conn.(adminConn); ok {
+		return context.WithValue(ctx, adminKey{}, true)
+	}
+	return ctx
+}
+
+// IsAdmin reports whether the request context belongs to a connection
+// accepted on an admin listener
+func IsAdmin(ctx context.Context) bool {
+	admin, _ := ctx.Value(adminKey{}).(bool)
+	return admin
+}
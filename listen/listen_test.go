@@ -0,0 +1,168 @@
+package listen
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		addr string
+		want Spec
+	}{
+		{"localhost:8080", Spec{Network: TCP, Address: "localhost:8080"}},
+		{"tcp://0.0.0.0:8080", Spec{Network: TCP, Address: "0.0.0.0:8080"}},
+		{"unix:///run/clipboard.sock", Spec{Network: Unix, Address: "/run/clipboard.sock"}},
+		{"unix:clipboard.sock", Spec{Network: Unix, Address: "clipboard.sock"}},
+		{"systemd", Spec{Network: Systemd}},
+		{"systemd:admin", Spec{Network: Systemd, Address: "admin"}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.addr)
+		if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v，期望 %+v", tt.addr, got, err, tt.want)
+		}
+	}
+
+	for _, addr := range []string{"8080", "unix://", "http://localhost"} {
+		if _, err := Parse(addr); err == nil {
+			t.Errorf("Parse(%q) 应返回错误", addr)
+		}
+	}
+}
+
+func skipWithoutUnixSockets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("需要 Unix 域套接字")
+	}
+}
+
+func TestUnixSocket(t *testing.T) {
+	skipWithoutUnixSockets(t)
+	path := filepath.Join(t.TempDir(), "clipboard.sock")
+	specs := []Spec{{Network: Unix, Address: path}}
+
+	listeners, err := Open(specs, UnixOptions{Mode: 0600})
+	if err != nil {
+		t.Fatalf("创建套接字失败: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("套接字权限应为 0600，实际得到 %v %v", info.Mode().Perm(), err)
+	}
+
+	if _, err := Open(specs, UnixOptions{}); err == nil {
+		t.Error("正在使用的套接字不应被替换")
+	}
+
+	// A socket left behind by a crashed process is replaced
+	listeners[0].Listener.(*net.UnixListener).SetUnlinkOnClose(false)
+	listeners[0].Close()
+	listeners, err = Open(specs, UnixOptions{})
+	if err != nil {
+		t.Fatalf("应替换遗留的套接字: %v", err)
+	}
+	listeners[0].Close()
+
+	notSocket := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(notSocket, nil, 0600)
+	if _, err := Open([]Spec{{Network: Unix, Address: notSocket}}, UnixOptions{}); err == nil {
+		t.Error("不应删除不是套接字的文件")
+	}
+}
+
+func TestAdminListener(t *testing.T) {
+	skipWithoutUnixSockets(t)
+	path := filepath.Join(t.TempDir(), "admin.sock")
+	listeners, err := Open([]Spec{
+		{Network: TCP, Address: "127.0.0.1:0"},
+		{Network: Unix, Address: path, Admin: true},
+	}, UnixOptions{})
+	if err != nil {
+		t.Fatalf("打开监听器失败: %v", err)
+	}
+
+	server := &http.Server{
+		ConnContext: ConnContext,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(strconv.FormatBool(IsAdmin(r.Context()))))
+		}),
+	}
+	for _, l := range listeners {
+		go server.Serve(l)
+	}
+	defer server.Close()
+
+	get := func(client *http.Client, url string) string {
+		resp, err := client.Get(url)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	if got := get(http.DefaultClient, "http://"+listeners[0].Addr().String()); got != "false" {
+		t.Errorf("TCP 连接不应标记为管理连接，实际得到 %s", got)
+	}
+
+	unixClient := &http.Client{Transport: &http.Transport{
+		Dial: func(network, addr string) (net.Conn, error) { return net.Dial("unix", path) },
+	}}
+	if got := get(unixClient, "http://localhost"); got != "true" {
+		t.Errorf("管理套接字的连接应标记为管理连接，实际得到 %s", got)
+	}
+}
+
+func TestSystemdSockets(t *testing.T) {
+	skipWithoutUnixSockets(t)
+	tcp, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tcp.Close()
+	file, err := tcp.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Pretend systemd passed the duplicated descriptor
+	previous := listenFDsStart
+	listenFDsStart = int(file.Fd())
+	systemdOnce, systemdSockets, systemdErr = sync.Once{}, nil, nil
+	t.Cleanup(func() {
+		listenFDsStart = previous
+		systemdOnce, systemdSockets, systemdErr = sync.Once{}, nil, nil
+	})
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "1")
+	t.Setenv("LISTEN_FDNAMES", "web")
+
+	if _, err := Open([]Spec{{Network: Systemd, Address: "admin"}}, UnixOptions{}); err == nil {
+		t.Error("不存在的套接字名称应返回错误")
+	}
+
+	listeners, err := Open([]Spec{{Network: Systemd, Address: "web"}}, UnixOptions{})
+	if err != nil {
+		t.Fatalf("应使用 systemd 传入的套接字: %v", err)
+	}
+	defer listeners[0].Close()
+	if listeners[0].Addr().String() != tcp.Addr().String() {
+		t.Errorf("地址应为 %s，实际得到 %s", tcp.Addr(), listeners[0].Addr())
+	}
+	if os.Getenv("LISTEN_FDS") != "" {
+		t.Error("应清除 LISTEN_FDS，避免子进程继承")
+	}
+
+	if _, err := Open([]Spec{{Network: Systemd}}, UnixOptions{}); err == nil {
+		t.Error("套接字只能使用一次")
+	}
+}
@@ -0,0 +1,99 @@
+package listen
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// listenFDsStart first file descriptor passed by systemd
+var listenFDsStart = 3
+
+// inheritedSocket a socket passed by systemd
+type inheritedSocket struct {
+	file *os.File
+	name string
+	used bool
+}
+
+var (
+	systemdOnce    sync.Once
+	systemdMu      sync.Mutex
+	systemdSockets []*inheritedSocket
+	systemdErr     error
+)
+
+// loadSystemdSockets reads the sockets passed through LISTEN_FDS, as described
+// in sd_listen_fds(3). The variables are unset so that child processes don't
+// inherit them.
+func loadSystemdSockets() {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		systemdErr = fmt.Errorf("no sockets passed by systemd (LISTEN_PID is not this process)")
+		return
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		systemdErr = fmt.Errorf("no sockets passed by systemd (LISTEN_FDS is not set)")
+		return
+	}
+
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	for i := 0; i < count; i++ {
+		name := ""
+		if i < len(names) {
+			name = names[i]
+		}
+		fd := listenFDsStart + i
+		systemdSockets = append(systemdSockets, &inheritedSocket{
+			file: os.NewFile(uintptr(fd), "systemd:"+name),
+			name: name,
+		})
+	}
+}
+
+// systemdListeners returns the unused sockets passed by systemd, only the
+// ones named name unless name is empty
+func systemdListeners(name string) ([]net.Listener, error) {
+	systemdOnce.Do(loadSystemdSockets)
+	if systemdErr != nil {
+		return nil, systemdErr
+	}
+
+	systemdMu.Lock()
+	defer systemdMu.Unlock()
+
+	var listeners []net.Listener
+	for _, socket := range systemdSockets {
+		if socket.used || (name != "" && socket.name != name) {
+			continue
+		}
+		l, err := net.FileListener(socket.file)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("systemd socket %s is not a listening socket: %v", socket.file.Name(), err)
+		}
+		// FileListener duplicates the descriptor
+		socket.file.Close()
+		socket.used = true
+		listeners = append(listeners, l)
+	}
+
+	if len(listeners) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("no unused socket named %s passed by systemd", name)
+		}
+		return nil, fmt.Errorf("no unused sockets passed by systemd")
+	}
+	return listeners, nil
+}
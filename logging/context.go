@@ -0,0 +1,20 @@
+package logging
+
+import (
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+)
+
+// For returns the default logger with the request ID of the request and, once
+// authenticated, the ID of the user
+func For(c *gin.Context) *slog.Logger {
+	logger := slog.Default()
+	if requestID := c.GetString("RequestID"); requestID != "" {
+		logger = logger.With("request_id", requestID)
+	}
+	if userID := c.GetString("user_id"); userID != "" {
+		logger = logger.With("user_id", userID)
+	}
+	return logger
+}
@@ -0,0 +1,162 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxHTTPDebugDuration longest time HTTP debug logging may stay enabled
+const MaxHTTPDebugDuration = 24 * time.Hour
+
+// DefaultHTTPDebugMaskFields JSON fields masked in logged bodies unless others are given
+var DefaultHTTPDebugMaskFields = []string{"password", "token", "content"}
+
+// HTTPDebugSettings selects the requests whose headers and bodies are logged.
+// Empty filters match every request; a request is logged when it matches all
+// filters and is sampled.
+type HTTPDebugSettings struct {
+	Enabled bool `json:"enabled"`
+	// UserIDs of the authenticated user
+	UserIDs []string `json:"user_ids,omitempty"`
+	// Routes are route templates, such as /api/v1/clipboard/sync, or path prefixes
+	Routes []string `json:"routes,omitempty"`
+	// RequestIDs sent by clients in the X-Request-ID header
+	RequestIDs []string `json:"request_ids,omitempty"`
+	// SampleRate fraction of the matching requests logged, between 0 and 1
+	SampleRate float64 `json:"sample_rate"`
+	// MaskFields JSON fields masked in bodies, besides credentials which are always masked
+	MaskFields []string `json:"mask_fields"`
+	// ExpiresAt when logging turns itself off, nil for never
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Validate checks the sample rate and expiry
+func (s HTTPDebugSettings) Validate(now time.Time) error {
+	if s.SampleRate < 0 || s.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+	if s.ExpiresAt != nil && !s.ExpiresAt.After(now) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	return nil
+}
+
+// HTTPDebugger holds the HTTP debug logging settings, which can be changed at runtime
+type HTTPDebugger struct {
+	mu       sync.RWMutex
+	settings HTTPDebugSettings
+	now      func() time.Time
+	sample   func() float64
+}
+
+// NewHTTPDebugger returns a debugger with logging disabled
+func NewHTTPDebugger() *HTTPDebugger {
+	return &HTTPDebugger{
+		settings: HTTPDebugSettings{SampleRate: 1, MaskFields: DefaultHTTPDebugMaskFields},
+		now:      time.Now,
+		sample:   rand.Float64,
+	}
+}
+
+// HTTPDebug is the debugger used by the HTTP middleware and the admin API
+var HTTPDebug = NewHTTPDebugger()
+
+// Settings returns the current settings; expired settings are reported disabled
+func (d *HTTPDebugger) Settings() HTTPDebugSettings {
+	d.expire()
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.settings
+}
+
+// Configure replaces the settings
+func (d *HTTPDebugger) Configure(settings HTTPDebugSettings) error {
+	if err := settings.Validate(d.now()); err != nil {
+		return err
+	}
+	if settings.MaskFields == nil {
+		settings.MaskFields = DefaultHTTPDebugMaskFields
+	}
+
+	d.mu.Lock()
+	d.settings = settings
+	d.mu.Unlock()
+
+	if settings.Enabled {
+		attrs := []any{"sample_rate", settings.SampleRate, "user_ids", settings.UserIDs,
+			"routes", settings.Routes, "request_ids", settings.RequestIDs}
+		if settings.ExpiresAt != nil {
+			attrs = append(attrs, "expires_at", settings.ExpiresAt)
+		}
+		slog.Warn("HTTP 调试日志已开启", attrs...)
+	} else {
+		slog.Info("HTTP 调试日志已关闭")
+	}
+	return nil
+}
+
+// expire turns logging off once its time limit has passed
+func (d *HTTPDebugger) expire() {
+	d.mu.RLock()
+	expired := d.settings.Enabled && d.settings.ExpiresAt != nil && !d.now().Before(*d.settings.ExpiresAt)
+	d.mu.RUnlock()
+	if !expired {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.settings.Enabled && d.settings.ExpiresAt != nil && !d.now().Before(*d.settings.ExpiresAt) {
+		d.settings.Enabled = false
+		slog.Info("HTTP 调试日志已到期，自动关闭")
+	}
+}
+
+// Sample decides whether a request is a candidate for logging, before the user
+// is known. The returned settings decide the rest with MatchesUser and Mask.
+func (d *HTTPDebugger) Sample(route, path, requestID string) (HTTPDebugSettings, bool) {
+	settings := d.Settings()
+	if !settings.Enabled {
+		return settings, false
+	}
+	if len(settings.Routes) > 0 && !matchesRoute(settings.Routes, route, path) {
+		return settings, false
+	}
+	if len(settings.RequestIDs) > 0 && !contains(settings.RequestIDs, requestID) {
+		return settings, false
+	}
+	return settings, settings.SampleRate >= 1 || d.sample() < settings.SampleRate
+}
+
+// MatchesUser reports whether requests of the user are logged
+func (s HTTPDebugSettings) MatchesUser(userID string) bool {
+	return len(s.UserIDs) == 0 || contains(s.UserIDs, userID)
+}
+
+// Mask returns a body for logging with the masked fields and credentials redacted
+func (s HTTPDebugSettings) Mask(body []byte) string {
+	return MaskJSON(body, s.MaskFields)
+}
+
+func matchesRoute(routes []string, route, path string) bool {
+	for _, r := range routes {
+		if r == route || strings.HasPrefix(path, r) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package logging
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestDebugger returns a debugger with a fixed clock and sample value
+func newTestDebugger(now time.Time, sample float64) *HTTPDebugger {
+	d := NewHTTPDebugger()
+	d.now = func() time.Time { return now }
+	d.sample = func() float64 { return sample }
+	return d
+}
+
+func TestHTTPDebugDisabledByDefault(t *testing.T) {
+	d := NewHTTPDebugger()
+	if _, ok := d.Sample("/api/v1/clipboard/items", "/api/v1/clipboard/items", ""); ok {
+		t.Error("默认不应记录 HTTP 调试日志")
+	}
+}
+
+func TestHTTPDebugFilters(t *testing.T) {
+	d := newTestDebugger(time.Now(), 0)
+	err := d.Configure(HTTPDebugSettings{
+		Enabled:    true,
+		SampleRate: 1,
+		Routes:     []string{"/api/v1/clipboard/sync"},
+		RequestIDs: []string{"req-1"},
+		UserIDs:    []string{"42"},
+	})
+	if err != nil {
+		t.Fatalf("配置失败: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		route     string
+		path      string
+		requestID string
+		want      bool
+	}{
+		{"匹配路由模板", "/api/v1/clipboard/sync", "/api/v1/clipboard/sync", "req-1", true},
+		{"匹配路径前缀", "", "/api/v1/clipboard/sync/extra", "req-1", true},
+		{"其他路由", "/api/v1/clipboard/items", "/api/v1/clipboard/items", "req-1", false},
+		{"其他请求 ID", "/api/v1/clipboard/sync", "/api/v1/clipboard/sync", "req-2", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := d.Sample(tt.route, tt.path, tt.requestID); ok != tt.want {
+				t.Errorf("期望 %v，实际得到 %v", tt.want, ok)
+			}
+		})
+	}
+
+	settings := d.Settings()
+	if !settings.MatchesUser("42") || settings.MatchesUser("7") {
+		t.Error("应只记录指定用户的请求")
+	}
+}
+
+func TestHTTPDebugSampling(t *testing.T) {
+	d := newTestDebugger(time.Now(), 0.3)
+	if err := d.Configure(HTTPDebugSettings{Enabled: true, SampleRate: 0.5}); err != nil {
+		t.Fatalf("配置失败: %v", err)
+	}
+	if _, ok := d.Sample("/", "/", ""); !ok {
+		t.Error("采样值低于采样率时应记录")
+	}
+
+	d.sample = func() float64 { return 0.7 }
+	if _, ok := d.Sample("/", "/", ""); ok {
+		t.Error("采样值高于采样率时不应记录")
+	}
+
+	if err := d.Configure(HTTPDebugSettings{Enabled: true, SampleRate: 1.5}); err == nil {
+		t.Error("采样率超出 0 到 1 应返回错误")
+	}
+}
+
+func TestHTTPDebugExpires(t *testing.T) {
+	now := time.Now()
+	d := newTestDebugger(now, 0)
+	expiresAt := now.Add(time.Minute)
+	if err := d.Configure(HTTPDebugSettings{Enabled: true, SampleRate: 1, ExpiresAt: &expiresAt}); err != nil {
+		t.Fatalf("配置失败: %v", err)
+	}
+	if _, ok := d.Sample("/", "/", ""); !ok {
+		t.Error("到期前应记录")
+	}
+
+	d.now = func() time.Time { return now.Add(2 * time.Minute) }
+	if _, ok := d.Sample("/", "/", ""); ok {
+		t.Error("到期后应自动关闭")
+	}
+	if d.Settings().Enabled {
+		t.Error("到期后设置应为关闭")
+	}
+
+	past := now.Add(-time.Minute)
+	d.now = func() time.Time { return now }
+	if err := d.Configure(HTTPDebugSettings{Enabled: true, SampleRate: 1, ExpiresAt: &past}); err == nil {
+		t.Error("过去的到期时间应返回错误")
+	}
+}
+
+func TestHTTPDebugMask(t *testing.T) {
+	body := []byte(`{"username":"alice","password":"hunter2","device":"laptop","items":[{"content":"secret"}]}`)
+
+	settings := NewHTTPDebugger().Settings()
+	got := settings.Mask(body)
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "secret") || !strings.Contains(got, "laptop") {
+		t.Errorf("默认应屏蔽密码和内容，实际得到 %s", got)
+	}
+
+	settings.MaskFields = []string{"device"}
+	got = settings.Mask(body)
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "laptop") {
+		t.Errorf("应屏蔽指定字段和凭据，实际得到 %s", got)
+	}
+}
@@ -0,0 +1,96 @@
+// Package logging configures the structured logger of the server.
+//
+// Records are written with log/slog, as JSON by default, to stdout and to the
+// rotated LOG_FILE. Output of the standard log package goes through the same
+// handler at info level. Clipboard content is only logged at debug level and
+// credentials never, see Content and the redacted keys.
+package logging
+
+import (
+	"clipboard-server/config"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Level is the minimum level logged, changed by Setup
+var Level = new(slog.LevelVar)
+
+// ParseLevel parses debug, info, warn or error
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return slog.LevelInfo, fmt.Errorf("invalid log level %q, use debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// Setup installs the configured logger as the slog and log default. The
+// returned closer closes the log file.
+func Setup(cfg *config.Config) (io.Closer, error) {
+	level, err := ParseLevel(cfg.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	Level.Set(level)
+	config.OnReload(func(cfg *config.Config) {
+		if level, err := ParseLevel(cfg.LogLevel); err == nil {
+			Level.Set(level)
+		}
+	})
+
+	var out io.Writer = os.Stdout
+	var closer io.Closer = io.NopCloser(nil)
+	if cfg.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(cfg.LogFile), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create log directory: %v", err)
+		}
+		file := &lumberjack.Logger{
+			Filename:   cfg.LogFile,
+			MaxSize:    cfg.LogMaxSize,
+			MaxBackups: cfg.LogMaxBackups,
+			MaxAge:     cfg.LogMaxAge,
+			Compress:   true,
+		}
+		out = io.MultiWriter(os.Stdout, file)
+		closer = file
+	}
+
+	slog.SetDefault(slog.New(NewHandler(out, cfg.LogFormat)))
+
+	if cfg.HTTPDebugLog {
+		settings := HTTPDebugSettings{Enabled: true, SampleRate: cfg.HTTPDebugSampleRate}
+		if cfg.HTTPDebugDuration > 0 {
+			expiresAt := time.Now().Add(cfg.HTTPDebugDuration)
+			settings.ExpiresAt = &expiresAt
+		}
+		if err := HTTPDebug.Configure(settings); err != nil {
+			return closer, err
+		}
+	}
+	return closer, nil
+}
+
+// NewHandler returns a JSON, or with format "text" a text, handler writing to
+// w at Level, with credentials redacted
+func NewHandler(w io.Writer, format string) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level:       Level,
+		ReplaceAttr: redactAttr,
+	}
+	if format == "text" {
+		return slog.NewTextHandler(w, opts)
+	}
+	return slog.NewJSONHandler(w, opts)
+}
+
+// DebugEnabled reports whether debug records are logged
+func DebugEnabled() bool {
+	return Level.Level() <= slog.LevelDebug
+}
@@ -0,0 +1,126 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// capture logs through a JSON handler at level and decodes the records
+func capture(t *testing.T, level slog.Level, log func(*slog.Logger)) []map[string]interface{} {
+	t.Helper()
+
+	previous := Level.Level()
+	Level.Set(level)
+	t.Cleanup(func() { Level.Set(previous) })
+
+	var buf bytes.Buffer
+	log(slog.New(NewHandler(&buf, "json")))
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("日志不是 JSON: %s", line)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestContentOnlyLoggedAtDebug(t *testing.T) {
+	log := func(logger *slog.Logger) {
+		logger.Info("item", "preview", Content("secret clipboard"), "content", "raw clipboard")
+	}
+
+	records := capture(t, slog.LevelInfo, log)
+	if records[0]["preview"] != "[REDACTED] 16 bytes" || records[0]["content"] != redacted {
+		t.Errorf("info 级别不应记录剪贴板内容，实际得到 %v", records[0])
+	}
+
+	records = capture(t, slog.LevelDebug, log)
+	if records[0]["preview"] != "secret clipboard" || records[0]["content"] != "raw clipboard" {
+		t.Errorf("debug 级别应记录剪贴板内容，实际得到 %v", records[0])
+	}
+}
+
+func TestSecretsAlwaysRedacted(t *testing.T) {
+	records := capture(t, slog.LevelDebug, func(logger *slog.Logger) {
+		logger.Debug("request", "password", "hunter2", slog.Group("headers", slog.String("authorization", "Bearer abc")))
+	})
+
+	headers, _ := records[0]["headers"].(map[string]interface{})
+	if records[0]["password"] != redacted || headers["authorization"] != redacted {
+		t.Errorf("凭据在任何级别都应脱敏，实际得到 %v", records[0])
+	}
+}
+
+func TestLevelFiltersRecords(t *testing.T) {
+	records := capture(t, slog.LevelWarn, func(logger *slog.Logger) {
+		logger.Info("dropped")
+		logger.Warn("kept")
+	})
+	if len(records) != 1 || records[0]["msg"] != "kept" {
+		t.Errorf("warn 级别应只记录 warn 及以上的日志，实际得到 %v", records)
+	}
+}
+
+func TestRedactJSON(t *testing.T) {
+	body := []byte(`{"username":"alice","password":"hunter2","items":[{"content":"secret","type":"text"}]}`)
+
+	previous := Level.Level()
+	defer Level.Set(previous)
+
+	Level.Set(slog.LevelInfo)
+	got := RedactJSON(body)
+	if strings.Contains(got, "hunter2") || strings.Contains(got, "secret") || !strings.Contains(got, "alice") {
+		t.Errorf("info 级别应脱敏密码和内容，实际得到 %s", got)
+	}
+
+	Level.Set(slog.LevelDebug)
+	got = RedactJSON(body)
+	if strings.Contains(got, "hunter2") || !strings.Contains(got, "secret") {
+		t.Errorf("debug 级别应只脱敏密码，实际得到 %s", got)
+	}
+
+	if got := RedactJSON([]byte("not json")); got != "[REDACTED] 8 bytes" {
+		t.Errorf("非 JSON 的请求体应整体脱敏，实际得到 %s", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for input, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		if got, err := ParseLevel(input); err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v", input, got, err)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("无效的日志级别应返回错误")
+	}
+}
+
+func TestRedactPath(t *testing.T) {
+	tests := []struct {
+		route, path, want string
+	}{
+		{"/s/:token", "/s/abc123", "/s/[REDACTED]"},
+		{"/s/:token/raw", "/s/abc123/raw", "/s/[REDACTED]/raw"},
+		{"/api/v1/clipboard/items/:id", "/api/v1/clipboard/items/42", "/api/v1/clipboard/items/42"},
+		{"", "/unknown/path", "/unknown/path"},
+	}
+	for _, tt := range tests {
+		if got := RedactPath(tt.route, tt.path); got != tt.want {
+			t.Errorf("RedactPath(%q, %q) = %q，期望 %q", tt.route, tt.path, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces the values of credentials and of content above debug level
+const redacted = "[REDACTED]"
+
+// secretKeys attributes and JSON fields that are never logged
+var secretKeys = map[string]bool{
+	"password":      true,
+	"old_password":  true,
+	"new_password":  true,
+	"token":         true,
+	"refresh_token": true,
+	"authorization": true,
+	"cookie":        true,
+	"x-admin-token": true,
+	"x-api-key":     true,
+	"api_key":       true,
+	"wrapped_key":   true,
+}
+
+// contentKeys JSON fields holding clipboard content, logged at debug level only
+var contentKeys = map[string]bool{
+	"content":    true,
+	"ciphertext": true,
+}
+
+func isSecret(key string) bool {
+	return secretKeys[strings.ToLower(key)]
+}
+
+// redactAttr replaces secret attribute values whatever the level, and content
+// attributes not wrapped with Content above debug level
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	switch {
+	case isSecret(a.Key):
+		return slog.String(a.Key, redacted)
+	case contentKeys[strings.ToLower(a.Key)] && !DebugEnabled():
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// RedactPath returns a request path for logging, with the segments matched by
+// secret route parameters redacted, e.g. the share token of /s/:token.
+// Paths not matching the route are returned unchanged.
+func RedactPath(route, path string) string {
+	if !strings.Contains(route, ":") {
+		return path
+	}
+	routeSegments := strings.Split(route, "/")
+	pathSegments := strings.Split(path, "/")
+	if len(routeSegments) != len(pathSegments) {
+		return path
+	}
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, ":") && isSecret(segment[1:]) {
+			pathSegments[i] = redacted
+		}
+	}
+	return strings.Join(pathSegments, "/")
+}
+
+// content is clipboard content that is only logged at debug level
+type content string
+
+// Content wraps clipboard content for logging. Above debug level only its
+// length is logged.
+func Content(s string) slog.LogValuer {
+	return content(s)
+}
+
+func (c content) LogValue() slog.Value {
+	if DebugEnabled() {
+		return slog.StringValue(string(c))
+	}
+	return slog.StringValue(fmt.Sprintf("%s %d bytes", redacted, len(c)))
+}
+
+// RedactJSON returns a JSON body for logging, with secret fields redacted and
+// clipboard content redacted above debug level. Bodies which aren't JSON are
+// replaced entirely.
+func RedactJSON(body []byte) string {
+	return MaskJSON(body, nil)
+}
+
+// MaskJSON is RedactJSON additionally redacting the given fields, at any depth
+func MaskJSON(body []byte, fields []string) string {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return fmt.Sprintf("%s %d bytes", redacted, len(body))
+	}
+
+	masked := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		masked[strings.ToLower(field)] = true
+	}
+	showContent := DebugEnabled()
+
+	data, err := json.Marshal(redactValue(value, func(key string) bool {
+		key = strings.ToLower(key)
+		return secretKeys[key] || masked[key] || (contentKeys[key] && !showContent)
+	}))
+	if err != nil {
+		return redacted
+	}
+	return string(data)
+}
+
+func redactValue(value interface{}, redact func(key string) bool) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redact(key) {
+				v[key] = redacted
+			} else {
+				v[key] = redactValue(field, redact)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = redactValue(v[i], redact)
+		}
+	}
+	return value
+}
@@ -2,13 +2,28 @@ package main
 
 import (
 	"clipboard-server/auth"
+	"clipboard-server/certs"
 	"clipboard-server/config"
 	"clipboard-server/database"
+	"clipboard-server/events"
 	"clipboard-server/handlers"
+	"clipboard-server/health"
+	"clipboard-server/listen"
+	"clipboard-server/logging"
+	"clipboard-server/metrics"
 	"clipboard-server/middleware"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"clipboard-server/scheduler"
+	"clipboard-server/tracing"
+	"clipboard-server/webhooks"
 	"context"
+	"crypto/tls"
+	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -21,21 +36,65 @@ import (
 var startTime = time.Now()
 
 func main() {
-	cfg := config.LoadConfig()
+	os.Exit(run(os.Args[1:]))
+}
+
+// runServe starts the HTTP server and blocks until it is shut down
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
 
 	if err := cfg.Validate(); err != nil {
-		log.Fatal("Configuration validation failed:", err)
+		return fmt.Errorf("configuration validation failed: %v", err)
 	}
 
 	cfg.Print()
 
+	logFile, err := logging.Setup(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to set up logging: %v", err)
+	}
+	defer logFile.Close()
+
+	if cfg.TracingEnabled {
+		shutdown, err := tracing.Setup(context.Background(), cfg)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			if err := shutdown(ctx); err != nil {
+				slog.Error("导出剩余的追踪数据失败", "error", err)
+			}
+		}()
+	}
+
+	// Data migrations read and write content through the encryption hooks
+	if _, err := setupEncryption(cfg); err != nil {
+		return err
+	}
+
 	if err := database.Initialize(); err != nil {
-		log.Fatal("Database initialization failed:", err)
+		return fmt.Errorf("database initialization failed: %v", err)
 	}
 	defer database.Close()
 
-	if err := database.CreateIndexes(); err != nil {
-		log.Printf("Failed to create database indexes: %v", err)
+	if cfg.MetricsEnabled {
+		sqlDB, err := database.GetDB().DB()
+		if err != nil {
+			return fmt.Errorf("failed to get database connection: %v", err)
+		}
+		if err := metrics.RegisterDB(sqlDB, database.Driver()); err != nil {
+			return fmt.Errorf("failed to register database metrics: %v", err)
+		}
 	}
 
 	if cfg.IsProduction() {
@@ -44,44 +103,199 @@ func main() {
 		gin.SetMode(gin.DebugMode)
 	}
 
+	// Events published by the handlers are queued for the webhooks of their user
+	dispatcher := webhooks.NewDispatcher(repository.NewWebhookRepository(database.GetDB()), cfg.WebhookAllowPrivateTargets)
+	events.Default.OnPublish(dispatcher.Enqueue)
+	dispatcher.Start()
+	defer dispatcher.Stop()
+
+	jobs := newScheduler(cfg, dispatcher)
+	probes := newProbes(cfg, jobs)
+
 	router := gin.New()
 
-	setupMiddleware(router)
-	setupRoutes(router)
+	setupMiddleware(router, probes)
+	setupRoutes(router, probes, dispatcher)
 
 	server := &http.Server{
-		Addr:           cfg.GetAddress(),
 		Handler:        router,
+		ConnContext:    listen.ConnContext,
 		ReadTimeout:    30 * time.Second,
 		WriteTimeout:   30 * time.Second,
 		MaxHeaderBytes: 1 << 20, // 1MB
 	}
 
-	go func() {
-		fmt.Printf("Server starting on http://%s\n", cfg.GetAddress())
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatal("Server failed to start:", err)
+	// Close open event streams so that shutdown doesn't wait for them
+	server.RegisterOnShutdown(events.Default.Close)
+
+	var certStore *certs.Store
+	if cfg.TLSEnabled() {
+		if certStore, err = setupTLS(cfg, server); err != nil {
+			return err
 		}
-	}()
+	}
 
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	listeners, err := openListeners(cfg)
+	if err != nil {
+		return fmt.Errorf("server failed to start: %v", err)
+	}
+
+	var redirect *http.Server
+	if cfg.TLSRedirectAddr != "" {
+		redirectListener, err := net.Listen("tcp", cfg.TLSRedirectAddr)
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return fmt.Errorf("HTTP redirect server failed to start: %v", err)
+		}
+		redirect = &http.Server{
+			Handler:      certs.RedirectHandler(cfg.ServerPort),
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+		}
+		go func() {
+			if err := redirect.Serve(redirectListener); err != nil && err != http.ErrServerClosed {
+				log.Fatal("HTTP redirect server failed:", err)
+			}
+		}()
+	}
+
+	jobs.Start()
+	defer jobs.Stop()
+
+	for _, l := range listeners {
+		go serve(server, l, certStore != nil)
+	}
+	probes.MarkStarted()
+
+	// The configuration and certificates are reloaded on SIGHUP and when their
+	// files change
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	go config.Watch(watchCtx, cfg.WatchInterval)
+	if certStore != nil {
+		go certStore.Watch(watchCtx, cfg.TLSWatchInterval)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range signals {
+		if sig != syscall.SIGHUP {
+			break
+		}
+		if _, err := config.Reload(); err != nil {
+			slog.Error("重新加载配置失败，继续使用当前配置", "error", err)
+		}
+		if certStore != nil {
+			if err := certStore.Reload(); err != nil {
+				slog.Error("重新加载 TLS 证书失败，继续使用当前证书", "error", err)
+			}
+		}
+	}
 
 	fmt.Println("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
+	if redirect != nil {
+		redirect.Shutdown(ctx)
+	}
 	if err := server.Shutdown(ctx); err != nil {
-		log.Printf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
 	} else {
 		fmt.Println("Server gracefully stopped")
 	}
+	return nil
+}
+
+// openListeners opens the listeners of LISTEN, or the server address, and of ADMIN_LISTEN
+func openListeners(cfg *config.Config) ([]*listen.Listener, error) {
+	var specs []listen.Spec
+	for _, addr := range cfg.Listeners() {
+		spec, err := listen.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		specs = append(specs, spec)
+	}
+	for _, addr := range cfg.AdminListen {
+		spec, err := listen.Parse(addr)
+		if err != nil {
+			return nil, err
+		}
+		spec.Admin = true
+		specs = append(specs, spec)
+	}
+
+	return listen.Open(specs, listen.UnixOptions{
+		Mode:  cfg.UnixSocketMode,
+		Group: cfg.UnixSocketGroup,
+	})
+}
+
+// serve serves HTTP on l until the server is shut down. TLS is used on TCP
+// and systemd listeners, not on local Unix sockets.
+func serve(server *http.Server, l *listen.Listener, useTLS bool) {
+	var err error
+	if useTLS && l.Spec.Network != listen.Unix {
+		fmt.Printf("Server starting on https://%s\n", l.Addr())
+		err = server.ServeTLS(l, "", "")
+	} else {
+		fmt.Printf("Server starting on http://%s (%s)\n", l.Addr(), l.Spec)
+		err = server.Serve(l)
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Fatal("Server failed to start:", err)
+	}
+}
+
+// setupTLS loads the certificates and configures server for HTTPS
+func setupTLS(cfg *config.Config, server *http.Server) (*certs.Store, error) {
+	store, err := certs.Load(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := certs.ParseVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := store.TLSConfig(certs.Options{
+		ClientAuth: cfg.TLSClientAuth,
+		MinVersion: minVersion,
+		HTTP2:      cfg.TLSHTTP2,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	server.TLSConfig = tlsConfig
+	if !cfg.TLSHTTP2 {
+		// A non-nil empty map disables the automatic HTTP/2 support
+		server.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+	return store, nil
 }
 
-func setupMiddleware(router *gin.Engine) {
-	router.Use(middleware.HealthCheck())
+// newProbes registers the checks of the health probes
+func newProbes(cfg *config.Config, jobs *scheduler.Scheduler) *health.Probes {
+	probes := health.New(cfg.HealthCheckTimeout)
+	probes.AddLiveness(health.Scheduler(jobs))
+	probes.AddStartup(health.Migrations(database.GetDB))
+	probes.AddReadiness(health.Database())
+	probes.AddReadiness(health.DiskSpace(cfg.UploadPath, uint64(cfg.HealthMinDiskFreeMB)<<20))
+	return probes
+}
+
+func setupMiddleware(router *gin.Engine, probes *health.Probes) {
+	router.Use(middleware.HealthCheck(probes))
+	if config.GetConfig().MetricsEnabled {
+		router.Use(metrics.Middleware())
+	}
+	if config.GetConfig().TracingEnabled {
+		router.Use(tracing.Middleware())
+	}
 	router.Use(middleware.RequestID())
 	router.Use(middleware.ErrorHandler())
 	router.Use(middleware.Security())
@@ -90,16 +304,23 @@ func setupMiddleware(router *gin.Engine) {
 	router.Use(middleware.ContentSizeLimit())
 	router.Use(middleware.RequestLogger())
 
-	// 添加详细的HTTP请求和响应日志中间件
-	// 注意：这会产生大量日志输出，建议仅在开发或调试时使用
+	// 详细的HTTP请求和响应日志，由 HTTP_DEBUG_LOG 或管理接口开启
 	router.Use(middleware.DetailedHTTPLogger())
 }
 
-func setupRoutes(router *gin.Engine) {
+func setupRoutes(router *gin.Engine, probes *health.Probes, dispatcher *webhooks.Dispatcher) {
 	v1 := router.Group("/api/v1")
 
-	authHandler := handlers.NewAuthHandler()
-	clipboardHandler := handlers.NewClipboardHandler()
+	db := database.GetDB()
+	authHandler := handlers.NewAuthHandler(repository.NewUserRepository(db))
+	clipboardHandler := handlers.NewClipboardHandler(repository.NewClipboardRepository(db))
+	shareHandler := handlers.NewShareHandler(repository.NewClipboardRepository(db), repository.NewShareRepository(db))
+	keyHandler := handlers.NewKeyHandler(repository.NewKeyRepository(db), repository.NewUserRepository(db), repository.NewClipboardRepository(db))
+	policyHandler := handlers.NewPolicyHandler(repository.NewClipboardRepository(db))
+	transferHandler := handlers.NewTransferHandler(repository.NewTransferRepository(db), repository.NewClipboardRepository(db))
+	adminHandler := handlers.NewAdminHandler()
+	channelHandler := handlers.NewChannelHandler(repository.NewChannelRepository(db), repository.NewUserRepository(db))
+	webhookHandler := handlers.NewWebhookHandler(repository.NewWebhookRepository(db), dispatcher)
 
 	authGroup := v1.Group("/auth")
 	{
@@ -116,6 +337,17 @@ func setupRoutes(router *gin.Engine) {
 			userGroup.GET("/profile", authHandler.GetProfile)
 			userGroup.POST("/logout", authHandler.Logout)
 			userGroup.PUT("/password", authHandler.ChangePassword)
+			userGroup.PUT("/e2ee", keyHandler.SetE2EEMode)
+			userGroup.GET("/sensitive-policy", policyHandler.GetSensitivePolicy)
+			userGroup.PUT("/sensitive-policy", policyHandler.UpdateSensitivePolicy)
+		}
+
+		keyGroup := authenticatedGroup.Group("/keys")
+		{
+			keyGroup.GET("", keyHandler.ListKeys)
+			keyGroup.POST("", keyHandler.AddKey)
+			keyGroup.POST("/rotate", keyHandler.RotateKey)
+			keyGroup.DELETE("/:id", keyHandler.DeleteKey)
 		}
 
 		clipboardGroup := authenticatedGroup.Group("/clipboard")
@@ -125,21 +357,108 @@ func setupRoutes(router *gin.Engine) {
 			clipboardGroup.GET("/items/:id", clipboardHandler.GetItem)
 			clipboardGroup.PUT("/items/:id", clipboardHandler.UpdateItem)
 			clipboardGroup.DELETE("/items/:id", clipboardHandler.DeleteItem)
+			clipboardGroup.GET("/items/:id/shares", shareHandler.ListShares)
+			clipboardGroup.POST("/items/:id/shares", shareHandler.CreateShare)
+			clipboardGroup.GET("/shares", shareHandler.ListShares)
+			clipboardGroup.DELETE("/shares/:id", shareHandler.RevokeShare)
 			clipboardGroup.POST("/sync", clipboardHandler.BatchSync)
 			clipboardGroup.POST("/sync-single", clipboardHandler.SyncSingleItem) // 新增单项同步接口
 			clipboardGroup.GET("/statistics", clipboardHandler.GetStatistics)
 			clipboardGroup.GET("/recent", clipboardHandler.GetRecentSyncItems) // 新增最近同步接口
 			clipboardGroup.GET("/latest", clipboardHandler.GetLatestSyncItem)  // 新增获取最新单条记录接口
+			clipboardGroup.GET("/events", clipboardHandler.Events)
+			clipboardGroup.GET("/deletions", clipboardHandler.GetDeletions)
+			clipboardGroup.GET("/conflicts", clipboardHandler.GetConflicts)
+			clipboardGroup.GET("/export", transferHandler.Export)
+			clipboardGroup.POST("/import", transferHandler.Import)
+			clipboardGroup.POST("/import/:format", transferHandler.ImportFormat)
+			clipboardGroup.GET("/importers", transferHandler.ListImporters)
 		}
+
+		channelGroup := authenticatedGroup.Group("/channels")
+		{
+			channelGroup.GET("", channelHandler.ListChannels)
+			channelGroup.POST("", channelHandler.CreateChannel)
+
+			// Each group requires at least its role in the channel
+			reader := channelGroup.Group("/:channel_id", channelHandler.RequireRole(models.ChannelRoleReader))
+			writer := channelGroup.Group("/:channel_id", channelHandler.RequireRole(models.ChannelRoleWriter))
+			owner := channelGroup.Group("/:channel_id", channelHandler.RequireRole(models.ChannelRoleOwner))
+
+			reader.GET("", channelHandler.GetChannel)
+			owner.PUT("", channelHandler.UpdateChannel)
+			owner.DELETE("", channelHandler.DeleteChannel)
+			reader.GET("/members", channelHandler.ListMembers)
+			owner.PUT("/members/:user_id", channelHandler.UpdateMember)
+			reader.DELETE("/members/:user_id", channelHandler.RemoveMember) // members can leave
+			owner.GET("/invitations", channelHandler.ListChannelInvitations)
+			owner.POST("/invitations", channelHandler.CreateInvitation)
+			owner.DELETE("/invitations/:invitation_id", channelHandler.RevokeInvitation)
+
+			// The clipboard handlers serve the channel clipboard on these routes
+			reader.GET("/clipboard/items", clipboardHandler.GetItems)
+			writer.POST("/clipboard/items", clipboardHandler.CreateItem)
+			reader.GET("/clipboard/items/:id", clipboardHandler.GetItem)
+			writer.PUT("/clipboard/items/:id", clipboardHandler.UpdateItem)
+			writer.DELETE("/clipboard/items/:id", clipboardHandler.DeleteItem)
+			writer.POST("/clipboard/sync", clipboardHandler.BatchSync)
+			writer.POST("/clipboard/sync-single", clipboardHandler.SyncSingleItem)
+			reader.GET("/clipboard/statistics", clipboardHandler.GetStatistics)
+			reader.GET("/clipboard/recent", clipboardHandler.GetRecentSyncItems)
+			reader.GET("/clipboard/latest", clipboardHandler.GetLatestSyncItem)
+			reader.GET("/clipboard/events", clipboardHandler.Events)
+			reader.GET("/clipboard/deletions", clipboardHandler.GetDeletions)
+			reader.GET("/clipboard/conflicts", clipboardHandler.GetConflicts)
+		}
+
+		invitationGroup := authenticatedGroup.Group("/invitations")
+		{
+			invitationGroup.GET("", channelHandler.ListInvitations)
+			invitationGroup.POST("/:id/accept", channelHandler.AcceptInvitation)
+			invitationGroup.POST("/:id/decline", channelHandler.DeclineInvitation)
+		}
+
+		webhookGroup := authenticatedGroup.Group("/webhooks")
+		{
+			webhookGroup.GET("", webhookHandler.ListWebhooks)
+			webhookGroup.POST("", webhookHandler.CreateWebhook)
+			webhookGroup.GET("/:id", webhookHandler.GetWebhook)
+			webhookGroup.PUT("/:id", webhookHandler.UpdateWebhook)
+			webhookGroup.DELETE("/:id", webhookHandler.DeleteWebhook)
+			webhookGroup.POST("/:id/test", webhookHandler.TestWebhook)
+			webhookGroup.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+			webhookGroup.POST("/:id/deliveries/:delivery_id/retry", webhookHandler.RetryDelivery)
+		}
+	}
+
+	adminGroup := v1.Group("/admin")
+	adminGroup.Use(middleware.AdminListenerOnly(), auth.AdminTokenMiddleware())
+	{
+		adminGroup.GET("/backups", adminHandler.ListBackups)
+		adminGroup.POST("/backups", adminHandler.CreateBackup)
+		adminGroup.GET("/debug-logging", adminHandler.GetDebugLogging)
+		adminGroup.PUT("/debug-logging", adminHandler.UpdateDebugLogging)
+		adminGroup.DELETE("/debug-logging", adminHandler.DisableDebugLogging)
+	}
+
+	// Public share links, opened without an account
+	shareGroup := router.Group("/s")
+	{
+		shareGroup.GET("/:token", shareHandler.ViewShare)
+		shareGroup.GET("/:token/raw", shareHandler.DownloadShare)
 	}
 
 	systemGroup := v1.Group("/system")
 	{
-		systemGroup.GET("/health", healthCheck)
+		systemGroup.GET("/health", health.Handler(probes.Readiness))
 		systemGroup.GET("/info", systemInfo)
 		systemGroup.GET("/stats", systemStats)
 	}
 
+	if config.GetConfig().MetricsEnabled {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+
 	router.GET("/", rootHandler)
 	router.NoRoute(notFoundHandler)
 }
@@ -154,28 +473,18 @@ func rootHandler(c *gin.Context) {
 		"endpoints": gin.H{
 			"auth":      "/api/v1/auth",
 			"clipboard": "/api/v1/clipboard",
+			"channels":  "/api/v1/channels",
+			"webhooks":  "/api/v1/webhooks",
+			"shares":    "/s",
 			"system":    "/api/v1/system",
 			"health":    "/api/v1/system/health",
+			"livez":     "/livez",
+			"readyz":    "/readyz",
+			"startupz":  "/startupz",
 		},
 	})
 }
 
-func healthCheck(c *gin.Context) {
-	dbStatus := "ok"
-	if err := database.HealthCheck(); err != nil {
-		dbStatus = "error: " + err.Error()
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":    "ok",
-		"timestamp": time.Now().Format(time.RFC3339),
-		"service":   "clipboard-sync-server",
-		"version":   "1.0.0",
-		"database":  dbStatus,
-		"uptime":    time.Since(startTime).String(),
-	})
-}
-
 func systemInfo(c *gin.Context) {
 	cfg := config.GetConfig()
 
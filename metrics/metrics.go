@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"clipboard-server/events"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "clipboard"
+
+// unmatchedRoute labels requests that matched no route, so that scanners
+// probing random paths cannot create unbounded label values
+const unmatchedRoute = "unmatched"
+
+// Batch sync item outcomes
+const (
+	BatchItemCreated      = "created"
+	BatchItemUpdated      = "updated"
+	BatchItemDeduplicated = "deduplicated"
+	BatchItemFailed       = "failed"
+)
+
+// Registry holds the server metrics, including Go runtime and process metrics
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	batchSyncItems = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "batch_sync_items_total",
+		Help:      "Items processed by batch sync by outcome.",
+	}, []string{"result"})
+
+	rateLimitRejections = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests rejected by the rate limiter.",
+	})
+
+	pushConnections = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "push_connections",
+		Help:      "Open event stream connections.",
+	}, func() float64 {
+		return float64(events.Default.Connections())
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		batchSyncItems,
+		rateLimitRejections,
+		pushConnections,
+	)
+
+	// Outcomes are reported as zero before the first batch
+	for _, result := range []string{BatchItemCreated, BatchItemUpdated, BatchItemDeduplicated, BatchItemFailed} {
+		batchSyncItems.WithLabelValues(result)
+	}
+}
+
+// RegisterDB exposes the connection pool statistics of the database
+func RegisterDB(db *sql.DB, name string) error {
+	return Registry.Register(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware records the count and latency of requests by route template
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		status := strconv.Itoa(c.Writer.Status())
+
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// BatchSyncItems counts batch sync items with the given outcome
+func BatchSyncItems(result string, count int) {
+	if count > 0 {
+		batchSyncItems.WithLabelValues(result).Add(float64(count))
+	}
+}
+
+// RateLimitRejected counts a request rejected by the rate limiter
+func RateLimitRejected() {
+	rateLimitRejections.Inc()
+}
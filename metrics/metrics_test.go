@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	router.GET("/items/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/items/1", "/items/2", "/unknown/path"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", "/items/:id", "204")); got != 2 {
+		t.Errorf("按路由模板应记录 2 个请求，实际为 %v", got)
+	}
+	if got := testutil.ToFloat64(httpRequests.WithLabelValues("GET", unmatchedRoute, "404")); got != 1 {
+		t.Errorf("未匹配的路径应归入 %q，实际为 %v", unmatchedRoute, got)
+	}
+}
+
+func TestHandlerExposesMetrics(t *testing.T) {
+	BatchSyncItems(BatchItemDeduplicated, 3)
+	RateLimitRejected()
+
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := w.Body.String()
+	for _, want := range []string{
+		`clipboard_batch_sync_items_total{result="deduplicated"} 3`,
+		`clipboard_batch_sync_items_total{result="failed"} 0`,
+		"clipboard_rate_limit_rejections_total 1",
+		"clipboard_push_connections 0",
+		"go_goroutines",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("指标输出中缺少 %q", want)
+		}
+	}
+}
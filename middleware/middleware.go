@@ -3,12 +3,18 @@ package middleware
 import (
 	"bytes"
 	"clipboard-server/config"
+	"clipboard-server/health"
+	"clipboard-server/listen"
+	"clipboard-server/logging"
+	"clipboard-server/metrics"
+	"clipboard-server/tracing"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -17,10 +23,20 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// SetupCORS configures CORS middleware
+// SetupCORS configures CORS middleware, rebuilt when the configuration is reloaded
 func SetupCORS() gin.HandlerFunc {
-	cfg := config.GetConfig()
+	var handler atomic.Value
+	handler.Store(newCORS(config.GetConfig()))
+	config.OnReload(func(cfg *config.Config) {
+		handler.Store(newCORS(cfg))
+	})
 
+	return func(c *gin.Context) {
+		handler.Load().(gin.HandlerFunc)(c)
+	}
+}
+
+func newCORS(cfg *config.Config) gin.HandlerFunc {
 	corsConfig := cors.Config{
 		AllowOrigins:     cfg.CORSAllowOrigins,
 		AllowMethods:     cfg.CORSAllowMethods,
@@ -39,13 +55,18 @@ func SetupCORS() gin.HandlerFunc {
 	return cors.New(corsConfig)
 }
 
-// RateLimit middleware for rate limiting
+// RateLimit middleware for rate limiting, the limits follow configuration reloads
 func RateLimit() gin.HandlerFunc {
 	cfg := config.GetConfig()
 	limiter := rate.NewLimiter(rate.Limit(cfg.RateLimitRPS), cfg.RateLimitBurst)
+	config.OnReload(func(cfg *config.Config) {
+		limiter.SetLimit(rate.Limit(cfg.RateLimitRPS))
+		limiter.SetBurst(cfg.RateLimitBurst)
+	})
 
 	return gin.HandlerFunc(func(c *gin.Context) {
 		if !limiter.Allow() {
+			metrics.RateLimitRejected()
 			c.JSON(http.StatusTooManyRequests, gin.H{
 				"error":   "rate limit exceeded",
 				"message": "too many requests, please slow down",
@@ -57,20 +78,31 @@ func RateLimit() gin.HandlerFunc {
 	})
 }
 
-// RequestLogger middleware for request logging
+// RequestLogger middleware logging one record per request
 func RequestLogger() gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		return fmt.Sprintf("[%s] \"%s %s %s %d %s \"%s\" %s\"\n",
-			param.TimeStamp.Format("2006/01/02 15:04:05"),
-			param.Method,
-			param.Path,
-			param.Request.Proto,
-			param.StatusCode,
-			param.Latency,
-			param.Request.UserAgent(),
-			param.ClientIP,
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		logging.For(c).Log(c.Request.Context(), level, "HTTP 请求",
+			"method", c.Request.Method,
+			"route", c.FullPath(),
+			"path", logging.RedactPath(c.FullPath(), c.Request.URL.Path),
+			"status", status,
+			"latency_ms", float64(time.Since(start).Microseconds())/1000,
+			"client_ip", c.ClientIP(),
+			"user_agent", c.Request.UserAgent(),
 		)
-	})
+	}
 }
 
 // ErrorHandler middleware for error handling
@@ -99,17 +131,25 @@ func Security() gin.HandlerFunc {
 		c.Header("X-XSS-Protection", "1; mode=block")
 		c.Header("Referrer-Policy", "strict-origin-when-cross-origin")
 		c.Header("Content-Security-Policy", "default-src 'self'")
+		if c.Request.TLS != nil {
+			c.Header("Strict-Transport-Security", "max-age=31536000")
+		}
 		c.Next()
 	}
 }
 
+// uploadRoutePrefix routes accepting uploads up to UploadMaxSize instead of MaxContentSize
+const uploadRoutePrefix = "/api/v1/clipboard/import"
+
 // ContentSizeLimit middleware for content size limiting
 func ContentSizeLimit() gin.HandlerFunc {
-	cfg := config.GetConfig()
-	maxSize := cfg.MaxContentSize
-
 	return func(c *gin.Context) {
 		if c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH" {
+			cfg := config.GetConfig()
+			maxSize := cfg.MaxContentSize
+			if strings.HasPrefix(c.FullPath(), uploadRoutePrefix) {
+				maxSize = cfg.UploadMaxSize
+			}
 			if c.Request.ContentLength > maxSize {
 				c.JSON(http.StatusRequestEntityTooLarge, gin.H{
 					"error":   "request entity too large",
@@ -123,6 +163,23 @@ func ContentSizeLimit() gin.HandlerFunc {
 	}
 }
 
+// AdminListenerOnly hides the admin API on listeners other than ADMIN_LISTEN,
+// when it is set
+func AdminListenerOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(config.GetConfig().AdminListen) > 0 && !listen.IsAdmin(c.Request.Context()) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "not found",
+				"message": "the requested resource was not found",
+				"path":    c.Request.URL.Path,
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 // APIKeyAuth middleware for API key authentication (optional)
 func APIKeyAuth() gin.HandlerFunc {
 	apiKey := os.Getenv("API_KEY")
@@ -151,10 +208,15 @@ func APIKeyAuth() gin.HandlerFunc {
 	}
 }
 
-// RequestID middleware to generate unique request IDs
+// RequestID middleware to generate unique request IDs.
+// Traced requests without an X-Request-ID use their trace ID, so that the
+// request ID in logs and responses finds the trace.
 func RequestID() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		requestID := c.GetHeader("X-Request-ID")
+		if requestID == "" {
+			requestID = tracing.TraceID(c.Request.Context())
+		}
 		if requestID == "" {
 			requestID = generateRequestID()
 		}
@@ -173,15 +235,19 @@ func generateRequestID() string {
 	)
 }
 
-// HealthCheck middleware for health checks
-func HealthCheck() gin.HandlerFunc {
+// HealthCheck serves the health probes ahead of the other middleware, so that
+// probes are neither rate limited nor logged. /health is kept as an alias of
+// /livez.
+func HealthCheck(probes *health.Probes) gin.HandlerFunc {
+	endpoints := map[string]gin.HandlerFunc{
+		"/livez":    health.Handler(probes.Liveness),
+		"/readyz":   health.Handler(probes.Readiness),
+		"/startupz": health.Handler(probes.Startup),
+		"/health":   health.Handler(probes.Liveness),
+	}
 	return func(c *gin.Context) {
-		if c.Request.URL.Path == "/health" {
-			c.JSON(http.StatusOK, gin.H{
-				"status":    "ok",
-				"timestamp": time.Now().Format(time.RFC3339),
-				"service":   "clipboard-sync-server",
-			})
+		if handler, ok := endpoints[c.Request.URL.Path]; ok {
+			handler(c)
 			c.Abort()
 			return
 		}
@@ -189,21 +255,23 @@ func HealthCheck() gin.HandlerFunc {
 	}
 }
 
-// DetailedHTTPLogger 详细的HTTP请求和响应日志中间件
+// DetailedHTTPLogger 详细的HTTP请求和响应日志中间件。
+// 是否记录由 logging.HTTPDebug 决定，可通过配置或管理接口在运行时开启，
+// 按用户、路由、请求 ID 过滤并采样；请求和响应体中的敏感字段会被屏蔽。
 func DetailedHTTPLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		startTime := time.Now()
-
-		// 获取请求ID
-		requestID := c.GetString("RequestID")
-		if requestID == "" {
-			requestID = generateRequestID()
-			c.Set("RequestID", requestID)
+		settings, ok := logging.HTTPDebug.Sample(c.FullPath(), c.Request.URL.Path, c.GetHeader("X-Request-ID"))
+		if !ok {
+			c.Next()
+			return
 		}
 
-		// 读取请求体
+		startTime := time.Now()
+
+		// 读取请求体（仅 JSON，避免将流式上传读入内存）
 		var requestBody []byte
-		if c.Request.Body != nil && (c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH") {
+		if c.Request.Body != nil && (c.Request.Method == "POST" || c.Request.Method == "PUT" || c.Request.Method == "PATCH") &&
+			strings.HasPrefix(c.ContentType(), "application/json") && !strings.HasPrefix(c.FullPath(), uploadRoutePrefix) {
 			requestBody, _ = io.ReadAll(c.Request.Body)
 			c.Request.Body = io.NopCloser(bytes.NewBuffer(requestBody))
 		}
@@ -215,70 +283,56 @@ func DetailedHTTPLogger() gin.HandlerFunc {
 		}
 		c.Writer = responseWriter
 
-		// 记录请求信息
-		log.Printf("[HTTP-REQ][%s] ============ Request Start ============", requestID)
-		log.Printf("[HTTP-REQ][%s] Method: %s", requestID, c.Request.Method)
-		log.Printf("[HTTP-REQ][%s] URL: %s", requestID, c.Request.URL.String())
-		log.Printf("[HTTP-REQ][%s] Proto: %s", requestID, c.Request.Proto)
-		log.Printf("[HTTP-REQ][%s] Host: %s", requestID, c.Request.Host)
-		log.Printf("[HTTP-REQ][%s] RemoteAddr: %s", requestID, c.Request.RemoteAddr)
-		log.Printf("[HTTP-REQ][%s] UserAgent: %s", requestID, c.Request.UserAgent())
-
-		// 记录请求头
-		log.Printf("[HTTP-REQ][%s] Headers:", requestID)
-		for name, values := range c.Request.Header {
-			for _, value := range values {
-				// 隐藏敏感信息
-				if strings.ToLower(name) == "authorization" {
-					if len(value) > 20 {
-						value = value[:20] + "..."
-					}
-				}
-				log.Printf("[HTTP-REQ][%s]   %s: %s", requestID, name, value)
-			}
-		}
-
-		// 记录请求体（如果存在且不为空）
-		if len(requestBody) > 0 {
-			bodyStr := string(requestBody)
-			// 限制日志长度，避免过长的内容
-			if len(bodyStr) > 1000 {
-				bodyStr = bodyStr[:1000] + "... (truncated)"
-			}
-			log.Printf("[HTTP-REQ][%s] Body: %s", requestID, bodyStr)
-		}
-
 		// 处理请求
 		c.Next()
 
-		// 计算处理时间
-		duration := time.Since(startTime)
-
-		// 记录响应信息
-		log.Printf("[HTTP-RESP][%s] ============ Response Start ============", requestID)
-		log.Printf("[HTTP-RESP][%s] Status: %d", requestID, responseWriter.status)
-		log.Printf("[HTTP-RESP][%s] Duration: %v", requestID, duration)
-
-		// 记录响应头
-		log.Printf("[HTTP-RESP][%s] Headers:", requestID)
-		for name, values := range c.Writer.Header() {
-			for _, value := range values {
-				log.Printf("[HTTP-RESP][%s]   %s: %s", requestID, name, value)
-			}
+		// 用户在认证后才能确定，因此请求和响应在处理完成后一起记录
+		if !settings.MatchesUser(c.GetString("user_id")) {
+			return
 		}
 
-		// 记录响应体
-		responseBody := responseWriter.body.String()
-		if responseBody != "" {
-			// 限制响应体日志长度
-			if len(responseBody) > 1000 {
-				responseBody = responseBody[:1000] + "... (truncated)"
-			}
-			log.Printf("[HTTP-RESP][%s] Body: %s", requestID, responseBody)
-		}
+		url := *c.Request.URL
+		url.Path = logging.RedactPath(c.FullPath(), url.Path)
+		url.RawPath = ""
+
+		logger := logging.For(c)
+		logger.Info("HTTP 调试日志: 请求",
+			"method", c.Request.Method,
+			"url", url.String(),
+			"proto", c.Request.Proto,
+			"host", c.Request.Host,
+			"remote_addr", c.Request.RemoteAddr,
+			slog.Any("headers", headerAttrs(c.Request.Header)),
+			"body", loggedBody(settings, requestBody),
+		)
+		logger.Info("HTTP 调试日志: 响应",
+			"status", responseWriter.status,
+			"duration", time.Since(startTime),
+			slog.Any("headers", headerAttrs(c.Writer.Header())),
+			"body", loggedBody(settings, responseWriter.body.Bytes()),
+		)
+	}
+}
+
+// headerAttrs groups headers for logging, secret headers are redacted by the handler
+func headerAttrs(header http.Header) slog.Value {
+	attrs := make([]slog.Attr, 0, len(header))
+	for name, values := range header {
+		attrs = append(attrs, slog.String(strings.ToLower(name), strings.Join(values, ", ")))
+	}
+	return slog.GroupValue(attrs...)
+}
 
-		log.Printf("[HTTP-RESP][%s] ============ Response End ============", requestID)
+// loggedBody 屏蔽并截断记录的请求或响应体，避免过长的内容
+func loggedBody(settings logging.HTTPDebugSettings, body []byte) string {
+	if len(body) == 0 {
+		return ""
 	}
+	logged := settings.Mask(body)
+	if len(logged) > 1000 {
+		logged = logged[:1000] + "... (truncated)"
+	}
+	return logged
 }
 
 // responseWriter 包装gin.ResponseWriter以捕获响应体
@@ -289,7 +343,9 @@ type responseWriter struct {
 }
 
 func (w *responseWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if !w.streaming() {
+		w.body.Write(b)
+	}
 	return w.ResponseWriter.Write(b)
 }
 
@@ -299,6 +355,22 @@ func (w *responseWriter) WriteHeader(statusCode int) {
 }
 
 func (w *responseWriter) WriteString(s string) (int, error) {
-	w.body.WriteString(s)
+	if !w.streaming() {
+		w.body.WriteString(s)
+	}
 	return w.ResponseWriter.WriteString(s)
 }
+
+// streaming 事件流和导出等流式响应体不被捕获，避免无限占用内存
+func (w *responseWriter) streaming() bool {
+	switch w.Header().Get("Content-Type") {
+	case "text/event-stream", "application/x-ndjson", "application/gzip":
+		return true
+	}
+	return false
+}
+
+// Unwrap 供 http.ResponseController 访问底层连接
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -1,8 +1,13 @@
 package models
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,52 +27,10 @@ func (ct *CustomTime) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
-	// 尝试多种时间格式，按常见程度排序
-	formats := []string{
-		// Flutter/Dart 常用格式
-		"2006-01-02T15:04:05.000000",     // 微秒格式（Dart默认）
-		"2006-01-02T15:04:05.000",        // 毫秒格式
-		"2006-01-02T15:04:05.000000Z",    // 带Z的微秒格式
-		"2006-01-02T15:04:05.000Z",       // 带Z的毫秒格式
-		"2006-01-02T15:04:05.000000000",  // 纳秒格式
-		"2006-01-02T15:04:05.000000000Z", // 带Z的纳秒格式
-
-		// 标准ISO 8601格式
-		time.RFC3339Nano,       // RFC3339 with nanoseconds
-		time.RFC3339,           // 标准RFC3339
-		"2006-01-02T15:04:05Z", // 不带毫秒的Z格式
-		"2006-01-02T15:04:05",  // 基础ISO格式
-
-		// 带时区的格式
-		"2006-01-02T15:04:05.000000Z07:00", // 微秒+时区
-		"2006-01-02T15:04:05.000Z07:00",    // 毫秒+时区
-		"2006-01-02T15:04:05Z07:00",        // 秒+时区
-		"2006-01-02T15:04:05.000000-07:00", // 微秒+负时区
-		"2006-01-02T15:04:05.000-07:00",    // 毫秒+负时区
-		"2006-01-02T15:04:05-07:00",        // 秒+负时区
-
-		// 其他常见格式
-		"2006-01-02 15:04:05.000000", // 空格分隔的微秒
-		"2006-01-02 15:04:05.000",    // 空格分隔的毫秒
-		"2006-01-02 15:04:05",        // 空格分隔的秒
-		"2006/01/02 15:04:05.000000", // 斜杠分隔的微秒
-		"2006/01/02 15:04:05.000",    // 斜杠分隔的毫秒
-		"2006/01/02 15:04:05",        // 斜杠分隔的秒
-
-		// Unix时间戳
-		"1136239445.000000", // Unix微秒时间戳
-		"1136239445.000",    // Unix毫秒时间戳
-		"1136239445",        // Unix秒时间戳
-	}
-
-	var lastErr error
-	for _, format := range formats {
-		if t, err := time.Parse(format, str); err == nil {
-			ct.Time = t
-			return nil
-		} else {
-			lastErr = err
-		}
+	parsed, err := ParseCustomTime(str)
+	if err == nil {
+		*ct = parsed
+		return nil
 	}
 
 	// 如果都解析失败，尝试使用标准库的JSON解析
@@ -75,8 +38,67 @@ func (ct *CustomTime) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
+	return err
+}
+
+// customTimeFormats 按常见程度排序的时间格式
+var customTimeFormats = []string{
+	// Flutter/Dart 常用格式
+	"2006-01-02T15:04:05.000000",     // 微秒格式（Dart默认）
+	"2006-01-02T15:04:05.000",        // 毫秒格式
+	"2006-01-02T15:04:05.000000Z",    // 带Z的微秒格式
+	"2006-01-02T15:04:05.000Z",       // 带Z的毫秒格式
+	"2006-01-02T15:04:05.000000000",  // 纳秒格式
+	"2006-01-02T15:04:05.000000000Z", // 带Z的纳秒格式
+
+	// 标准ISO 8601格式
+	time.RFC3339Nano,       // RFC3339 with nanoseconds
+	time.RFC3339,           // 标准RFC3339
+	"2006-01-02T15:04:05Z", // 不带毫秒的Z格式
+	"2006-01-02T15:04:05",  // 基础ISO格式
+
+	// 带时区的格式
+	"2006-01-02T15:04:05.000000Z07:00", // 微秒+时区
+	"2006-01-02T15:04:05.000Z07:00",    // 毫秒+时区
+	"2006-01-02T15:04:05Z07:00",        // 秒+时区
+	"2006-01-02T15:04:05.000000-07:00", // 微秒+负时区
+	"2006-01-02T15:04:05.000-07:00",    // 毫秒+负时区
+	"2006-01-02T15:04:05-07:00",        // 秒+负时区
+
+	// 其他常见格式
+	"2006-01-02 15:04:05.000000", // 空格分隔的微秒
+	"2006-01-02 15:04:05.000",    // 空格分隔的毫秒
+	"2006-01-02 15:04:05",        // 空格分隔的秒
+	"2006/01/02 15:04:05.000000", // 斜杠分隔的微秒
+	"2006/01/02 15:04:05.000",    // 斜杠分隔的毫秒
+	"2006/01/02 15:04:05",        // 斜杠分隔的秒
+}
+
+// ParseCustomTime 解析 CustomTime 支持的任意时间格式，包括秒或毫秒级 Unix 时间戳
+func ParseCustomTime(str string) (CustomTime, error) {
+	str = strings.TrimSpace(str)
+
+	// Unix时间戳，13位及以上视为毫秒
+	if seconds, err := strconv.ParseFloat(str, 64); err == nil && strings.Trim(str, "0123456789.") == "" {
+		if len(strings.SplitN(str, ".", 2)[0]) >= 13 {
+			seconds /= 1000
+		}
+		whole := int64(seconds)
+		nanos := int64((seconds - float64(whole)) * 1e9)
+		return CustomTime{Time: time.Unix(whole, nanos).UTC()}, nil
+	}
+
+	var lastErr error
+	for _, format := range customTimeFormats {
+		t, err := time.Parse(format, str)
+		if err == nil {
+			return CustomTime{Time: t}, nil
+		}
+		lastErr = err
+	}
+
 	// 所有方法都失败了，返回一个有意义的错误信息
-	return fmt.Errorf("无法解析时间格式 '%s'，尝试了 %d 种格式，最后一个错误: %v", str, len(formats), lastErr)
+	return CustomTime{}, fmt.Errorf("无法解析时间格式 '%s'，尝试了 %d 种格式，最后一个错误: %v", str, len(customTimeFormats), lastErr)
 }
 
 // ClipboardType enum for clipboard types
@@ -88,16 +110,108 @@ const (
 	ClipboardTypeFile  ClipboardType = "file"
 )
 
+// ClipboardSubtype finer classification of clipboard content, detected by the
+// server from the content itself
+type ClipboardSubtype string
+
+const (
+	// Text content
+	ClipboardSubtypePlain ClipboardSubtype = "plain"
+	ClipboardSubtypeURL   ClipboardSubtype = "url"
+	ClipboardSubtypeEmail ClipboardSubtype = "email"
+	ClipboardSubtypePhone ClipboardSubtype = "phone"
+	ClipboardSubtypeColor ClipboardSubtype = "color"
+	ClipboardSubtypeJSON  ClipboardSubtype = "json"
+	ClipboardSubtypeCode  ClipboardSubtype = "code"
+
+	// Binary payloads, by their sniffed MIME type
+	ClipboardSubtypeImage   ClipboardSubtype = "image"
+	ClipboardSubtypeAudio   ClipboardSubtype = "audio"
+	ClipboardSubtypeVideo   ClipboardSubtype = "video"
+	ClipboardSubtypePDF     ClipboardSubtype = "pdf"
+	ClipboardSubtypeArchive ClipboardSubtype = "archive"
+	ClipboardSubtypeBinary  ClipboardSubtype = "binary"
+)
+
 // ClipboardItem model
 type ClipboardItem struct {
-	ID        string        `json:"id" gorm:"primaryKey"`
-	UserID    string        `json:"user_id" gorm:"index"`
-	ClientID  string        `json:"client_id" gorm:"index"` // 客户端唯一ID
-	Content   string        `json:"content" gorm:"type:text"`
+	ID       string `json:"id" gorm:"primaryKey"`
+	UserID   string `json:"user_id" gorm:"index"`
+	ClientID string `json:"client_id" gorm:"index"` // 客户端唯一ID
+	Content  string `json:"content"`                // text, longtext on MySQL
+
+	// ChannelID is set on items of a shared channel clipboard, where UserID is
+	// the member who posted the item
+	ChannelID string `json:"channel_id,omitempty" gorm:"size:36;not null;default:'';index"`
+
+	// ContentHash identifies identical content for deduplication, keyed per
+	// user when encryption at rest is enabled
+	ContentHash string `json:"-" gorm:"size:64;index"`
+
+	// ContentSize bytes of the content before encryption at rest, the
+	// ciphertext size for end-to-end encrypted items
+	ContentSize int64 `json:"-" gorm:"not null;default:0"`
+	// EncryptedAtRest is set when Content holds ciphertext of the server's
+	// encryption at rest, the content itself is never used to tell
+	EncryptedAtRest bool `json:"-" gorm:"not null;default:false"`
+
 	Type      ClipboardType `json:"type" gorm:"type:varchar(20);default:'text'"`
 	Timestamp time.Time     `json:"timestamp" gorm:"index"`
 	CreatedAt time.Time     `json:"created_at" gorm:"autoCreateTime:nano"`
 	UpdatedAt time.Time     `json:"updated_at" gorm:"autoUpdateTime:nano"`
+	Version   int64         `json:"version" gorm:"not null;default:1"` // incremented on every update
+
+	// Detected from the content by the server, empty for end-to-end encrypted items
+	Subtype  ClipboardSubtype `json:"subtype,omitempty" gorm:"size:20;not null;default:'';index"`
+	MimeType string           `json:"mime_type,omitempty" gorm:"size:100;not null;default:''"`
+
+	// End-to-end encryption envelope; Content holds ciphertext when Encrypted is set
+	Encrypted bool   `json:"encrypted" gorm:"default:false;index"`
+	KeyID     string `json:"key_id,omitempty" gorm:"size:100;index"`
+	Nonce     string `json:"nonce,omitempty" gorm:"size:255"`
+	Algorithm string `json:"algorithm,omitempty" gorm:"size:50"`
+
+	// Sensitive content detection results
+	Sensitive     bool       `json:"sensitive" gorm:"default:false;index"`
+	SensitiveTags string     `json:"sensitive_tags,omitempty" gorm:"size:255"`
+	ExpiresAt     *time.Time `json:"expires_at,omitempty" gorm:"index"`
+
+	// plaintext kept while the encrypted content is being written
+	plainContent string
+}
+
+// EncryptionEnvelope describes end-to-end encrypted content.
+// The server stores and returns it as-is and never interprets the ciphertext.
+type EncryptionEnvelope struct {
+	KeyID     string `json:"key_id" binding:"required,max=100"`
+	Nonce     string `json:"nonce" binding:"required,max=255"`
+	Algorithm string `json:"algorithm" binding:"required,max=50"`
+}
+
+// SetEnvelope marks the item as end-to-end encrypted with the given envelope,
+// or as plaintext when env is nil
+func (c *ClipboardItem) SetEnvelope(env *EncryptionEnvelope) {
+	if env == nil {
+		c.Encrypted = false
+		c.KeyID, c.Nonce, c.Algorithm = "", "", ""
+		return
+	}
+	c.Encrypted = true
+	c.KeyID = env.KeyID
+	c.Nonce = env.Nonce
+	c.Algorithm = env.Algorithm
+}
+
+// Envelope returns the encryption envelope of the item, or nil for plaintext items
+func (c *ClipboardItem) Envelope() *EncryptionEnvelope {
+	if !c.Encrypted {
+		return nil
+	}
+	return &EncryptionEnvelope{
+		KeyID:     c.KeyID,
+		Nonce:     c.Nonce,
+		Algorithm: c.Algorithm,
+	}
 }
 
 // User model
@@ -112,6 +226,9 @@ type User struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 
+	// E2EERequired rejects plaintext uploads once the user has opted in to end-to-end encryption
+	E2EERequired bool `json:"e2ee_required" gorm:"column:e2ee_required;default:false"`
+
 	// Associated clipboard items
 	ClipboardItems []ClipboardItem `json:"clipboard_items,omitempty" gorm:"foreignKey:UserID"`
 }
@@ -127,6 +244,9 @@ func (c *ClipboardItem) BeforeCreate(tx *gorm.DB) error {
 	if c.CreatedAt.IsZero() {
 		c.CreatedAt = time.Now()
 	}
+	if c.Version == 0 {
+		c.Version = 1
+	}
 	c.UpdatedAt = time.Now()
 	return nil
 }
@@ -137,6 +257,308 @@ func (c *ClipboardItem) BeforeUpdate(tx *gorm.DB) error {
 	return nil
 }
 
+// ContentCipher encrypts ClipboardItem.Content at rest
+type ContentCipher interface {
+	Encrypt(tx *gorm.DB, userID, plaintext string) (string, error)
+	Decrypt(tx *gorm.DB, userID, stored string) (string, error)
+}
+
+var contentCipher ContentCipher
+
+// SetContentCipher enables encryption at rest of clipboard content, nil disables it
+func SetContentCipher(c ContentCipher) {
+	contentCipher = c
+}
+
+// ContentEncryptionEnabled reports whether clipboard content is encrypted at rest
+func ContentEncryptionEnabled() bool {
+	return contentCipher != nil
+}
+
+// ContentHasher is implemented by content ciphers that compute a keyed content hash,
+// so that the hash doesn't reveal content encrypted at rest
+type ContentHasher interface {
+	Hash(tx *gorm.DB, userID, plaintext string) (string, error)
+}
+
+// HashContent returns the deduplication hash of a user's content
+func HashContent(tx *gorm.DB, userID, content string, encrypted bool) (string, error) {
+	if hasher, ok := contentCipher.(ContentHasher); ok && !encrypted {
+		return hasher.Hash(tx, userID, content)
+	}
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// BeforeSave hook to hash and measure the content and encrypt it at rest.
+// End-to-end encrypted items are already ciphertext and stored as-is.
+func (c *ClipboardItem) BeforeSave(tx *gorm.DB) error {
+	hash, err := HashContent(tx, c.UserID, c.Content, c.Encrypted)
+	if err != nil {
+		return err
+	}
+	c.ContentHash = hash
+	c.ContentSize = int64(len(c.Content))
+
+	c.EncryptedAtRest = false
+	if contentCipher == nil || c.Encrypted {
+		return nil
+	}
+
+	encrypted, err := contentCipher.Encrypt(tx, c.UserID, c.Content)
+	if err != nil {
+		return err
+	}
+	c.plainContent = c.Content
+	c.Content = encrypted
+	c.EncryptedAtRest = true
+	return nil
+}
+
+// AfterSave hook to restore the plaintext on the saved item
+func (c *ClipboardItem) AfterSave(tx *gorm.DB) error {
+	if !c.EncryptedAtRest {
+		return nil
+	}
+	c.Content = c.plainContent
+	return nil
+}
+
+// AfterFind hook to decrypt content encrypted at rest
+func (c *ClipboardItem) AfterFind(tx *gorm.DB) error {
+	if !c.EncryptedAtRest {
+		return nil
+	}
+	if contentCipher == nil {
+		return fmt.Errorf("item %s is encrypted at rest but no encryption key is configured", c.ID)
+	}
+
+	plaintext, err := contentCipher.Decrypt(tx, c.UserID, c.Content)
+	if err != nil {
+		return err
+	}
+	c.Content = plaintext
+	return nil
+}
+
+// SensitiveAction what happens when sensitive content is detected
+type SensitiveAction string
+
+const (
+	SensitiveActionKeep   SensitiveAction = "keep"
+	SensitiveActionTag    SensitiveAction = "tag"
+	SensitiveActionMask   SensitiveAction = "mask"
+	SensitiveActionExpire SensitiveAction = "expire"
+	SensitiveActionReject SensitiveAction = "reject"
+)
+
+// SensitivePolicy per-user handling of detected sensitive content
+type SensitivePolicy struct {
+	UserID        string                     `json:"-" gorm:"primaryKey"`
+	DefaultAction SensitiveAction            `json:"default_action" gorm:"size:20;default:'tag'"`
+	Actions       map[string]SensitiveAction `json:"actions" gorm:"serializer:json"` // per detector overrides
+	ExpireMinutes int                        `json:"expire_minutes" gorm:"default:10"`
+	UpdatedAt     time.Time                  `json:"updated_at"`
+}
+
+// ActionFor returns the action configured for a detector
+func (p SensitivePolicy) ActionFor(detector string) SensitiveAction {
+	if action, ok := p.Actions[detector]; ok {
+		return action
+	}
+	if p.DefaultAction != "" {
+		return p.DefaultAction
+	}
+	return SensitiveActionTag
+}
+
+// Deletion reasons of a DeletedItem
+const (
+	DeletionReasonDeleted = "deleted"
+	DeletionReasonExpired = "expired"
+)
+
+// DeletedItem records a deleted or expired clipboard item so that
+// clients can remove their local copy
+type DeletedItem struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	ItemID    string    `json:"item_id" gorm:"index"`
+	UserID    string    `json:"-" gorm:"index"`
+	ChannelID string    `json:"channel_id,omitempty" gorm:"size:36;not null;default:'';index"`
+	ClientID  string    `json:"client_id,omitempty"`
+	Reason    string    `json:"reason" gorm:"size:20"`
+	DeletedAt time.Time `json:"deleted_at" gorm:"index"`
+}
+
+// ConflictStrategy how SyncSingleItem resolves concurrent edits
+type ConflictStrategy string
+
+const (
+	ConflictStrategyLastWriterWins ConflictStrategy = "lww"         // newest client timestamp wins
+	ConflictStrategyServerWins     ConflictStrategy = "server_wins" // the server copy is kept
+	ConflictStrategyConflict       ConflictStrategy = "conflict"    // both versions are returned to the client
+)
+
+// ConflictResolution outcome of a detected conflict
+type ConflictResolution string
+
+const (
+	ConflictResolutionClientWon ConflictResolution = "client_won"
+	ConflictResolutionServerWon ConflictResolution = "server_won"
+	ConflictResolutionRejected  ConflictResolution = "rejected"
+)
+
+// SyncConflict records a concurrent edit of an item detected on write
+type SyncConflict struct {
+	ID              string             `json:"id" gorm:"primaryKey"`
+	UserID          string             `json:"-" gorm:"index"`
+	ChannelID       string             `json:"channel_id,omitempty" gorm:"size:36;not null;default:'';index"`
+	ItemID          string             `json:"item_id" gorm:"index"`
+	ClientID        string             `json:"client_id,omitempty"`
+	Strategy        ConflictStrategy   `json:"strategy" gorm:"size:20"`
+	Resolution      ConflictResolution `json:"resolution" gorm:"size:20"`
+	BaseVersion     *int64             `json:"base_version,omitempty"` // version the client edited
+	ServerVersion   int64              `json:"server_version"`
+	ServerTimestamp time.Time          `json:"server_timestamp"`
+	ClientTimestamp time.Time          `json:"client_timestamp"`
+	CreatedAt       time.Time          `json:"created_at" gorm:"index"`
+}
+
+// BeforeCreate hook to set ID
+func (s *SyncConflict) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// UserDataKey per-user key encrypting clipboard content at rest,
+// stored wrapped by the server master key
+type UserDataKey struct {
+	UserID      string    `json:"user_id" gorm:"primaryKey"`
+	WrappedKey  string    `json:"-" gorm:"type:text"`
+	MasterKeyID string    `json:"master_key_id" gorm:"size:32;index"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// KeyStatus state of a wrapped device key
+type KeyStatus string
+
+const (
+	KeyStatusActive  KeyStatus = "active"
+	KeyStatusRetired KeyStatus = "retired"
+)
+
+// DeviceKey stores a content key wrapped for one device.
+// Only the wrapped form ever reaches the server; KeyID is shared by every
+// device copy of the same content key.
+type DeviceKey struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	UserID     string     `json:"user_id" gorm:"index"`
+	DeviceID   string     `json:"device_id" gorm:"size:100;index"`
+	KeyID      string     `json:"key_id" gorm:"size:100;index"`
+	WrappedKey string     `json:"wrapped_key" gorm:"type:text"`
+	Algorithm  string     `json:"algorithm" gorm:"size:50"`
+	Status     KeyStatus  `json:"status" gorm:"size:20;default:'active'"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to set ID
+func (k *DeviceKey) BeforeCreate(tx *gorm.DB) error {
+	if k.ID == "" {
+		k.ID = uuid.New().String()
+	}
+	if k.Status == "" {
+		k.Status = KeyStatusActive
+	}
+	return nil
+}
+
+// ChannelRole permissions of a channel member, each role including the
+// permissions of the roles below it
+type ChannelRole string
+
+const (
+	ChannelRoleOwner  ChannelRole = "owner"  // manages the channel, its members and invitations
+	ChannelRoleWriter ChannelRole = "writer" // pushes, updates and deletes items
+	ChannelRoleReader ChannelRole = "reader" // reads items
+)
+
+var channelRoleRanks = map[ChannelRole]int{
+	ChannelRoleReader: 1,
+	ChannelRoleWriter: 2,
+	ChannelRoleOwner:  3,
+}
+
+// Valid reports whether the role is one of the channel roles
+func (r ChannelRole) Valid() bool {
+	return channelRoleRanks[r] > 0
+}
+
+// Allows reports whether the role grants the permissions of required
+func (r ChannelRole) Allows(required ChannelRole) bool {
+	return r.Valid() && channelRoleRanks[r] >= channelRoleRanks[required]
+}
+
+// Channel a clipboard shared between its members
+type Channel struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	Name        string    `json:"name" gorm:"size:100"`
+	Description string    `json:"description,omitempty" gorm:"size:500"`
+	CreatedBy   string    `json:"created_by" gorm:"size:36"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// ChannelMember grants a user a role in a channel
+type ChannelMember struct {
+	ChannelID string      `json:"channel_id" gorm:"primaryKey;size:36"`
+	UserID    string      `json:"user_id" gorm:"primaryKey;size:36;index"`
+	Role      ChannelRole `json:"role" gorm:"size:20"`
+	CreatedAt time.Time   `json:"joined_at"`
+
+	Username string `json:"username,omitempty" gorm:"-"`
+}
+
+// ChannelInvitation invites a user to join a channel with a role.
+// It is deleted once accepted or declined.
+type ChannelInvitation struct {
+	ID        string      `json:"id" gorm:"primaryKey"`
+	ChannelID string      `json:"channel_id" gorm:"size:36;index"`
+	InviteeID string      `json:"invitee_id" gorm:"size:36;index"`
+	InviterID string      `json:"inviter_id" gorm:"size:36"`
+	Role      ChannelRole `json:"role" gorm:"size:20"`
+	ExpiresAt time.Time   `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time   `json:"created_at"`
+
+	ChannelName string `json:"channel_name,omitempty" gorm:"-"`
+}
+
+// ChannelMembership a channel with the role of the current user
+type ChannelMembership struct {
+	Channel
+	Role ChannelRole `json:"role"`
+}
+
+// BeforeCreate hook to set ID
+func (ch *Channel) BeforeCreate(tx *gorm.DB) error {
+	if ch.ID == "" {
+		ch.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate hook to set ID
+func (i *ChannelInvitation) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
 // BeforeCreate hook to set ID
 func (u *User) BeforeCreate(tx *gorm.DB) error {
 	if u.ID == "" {
@@ -154,43 +576,361 @@ func (User) TableName() string {
 	return "users"
 }
 
+func (DeviceKey) TableName() string {
+	return "device_keys"
+}
+
+func (UserDataKey) TableName() string {
+	return "user_data_keys"
+}
+
+func (SensitivePolicy) TableName() string {
+	return "sensitive_policies"
+}
+
+func (DeletedItem) TableName() string {
+	return "deleted_items"
+}
+
+func (SyncConflict) TableName() string {
+	return "sync_conflicts"
+}
+
+func (IdempotencyRecord) TableName() string {
+	return "idempotency_records"
+}
+
+func (Channel) TableName() string {
+	return "channels"
+}
+
+func (ChannelMember) TableName() string {
+	return "channel_members"
+}
+
+func (ChannelInvitation) TableName() string {
+	return "channel_invitations"
+}
+
+// ShareLink public link to one clipboard item, opened without an account by
+// anyone who knows its token
+type ShareLink struct {
+	ID     string `json:"id" gorm:"primaryKey"`
+	Token  string `json:"token" gorm:"size:64;uniqueIndex"`
+	ItemID string `json:"item_id" gorm:"size:36;index"`
+	UserID string `json:"user_id" gorm:"index"`
+
+	ExpiresAt *time.Time `json:"expires_at,omitempty" gorm:"index"`
+	MaxViews  int        `json:"max_views,omitempty" gorm:"not null;default:0"` // 0 for unlimited
+	Views     int        `json:"views" gorm:"not null;default:0"`
+
+	// Optional password, hashed like account passwords
+	PasswordHash string `json:"-" gorm:"size:255"`
+	PasswordSalt string `json:"-" gorm:"size:64"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// shareTokenBytes random bytes in a share link token
+const shareTokenBytes = 32
+
+// BeforeCreate hook to set ID and the unguessable token
+func (s *ShareLink) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Token == "" {
+		token := make([]byte, shareTokenBytes)
+		if _, err := rand.Read(token); err != nil {
+			return fmt.Errorf("failed to generate share token: %v", err)
+		}
+		s.Token = base64.RawURLEncoding.EncodeToString(token)
+	}
+	return nil
+}
+
+// Protected reports whether the link requires a password
+func (s *ShareLink) Protected() bool {
+	return s.PasswordHash != ""
+}
+
+// Expired reports whether the link expired at now
+func (s *ShareLink) Expired(now time.Time) bool {
+	return s.ExpiresAt != nil && !s.ExpiresAt.After(now)
+}
+
+// Exhausted reports whether the link has no views left
+func (s *ShareLink) Exhausted() bool {
+	return s.MaxViews > 0 && s.Views >= s.MaxViews
+}
+
+func (ShareLink) TableName() string {
+	return "share_links"
+}
+
+// Webhook a user's subscription to clipboard events, delivered as signed JSON
+// POST requests to URL. Filters are stored comma separated, an empty filter
+// matches every event.
+type Webhook struct {
+	ID          string `json:"id" gorm:"primaryKey"`
+	UserID      string `json:"user_id" gorm:"index"`
+	URL         string `json:"url" gorm:"size:2048"`
+	Description string `json:"description,omitempty" gorm:"size:255"`
+	Secret      string `json:"-" gorm:"size:128"` // HMAC key signing the payloads
+	Active      bool   `json:"active" gorm:"not null"`
+
+	EventTypes   string `json:"-" gorm:"size:255"`
+	ContentTypes string `json:"-" gorm:"size:255"`
+	Tags         string `json:"-" gorm:"size:255"`
+	Devices      string `json:"-" gorm:"size:1024"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WebhookFilters selects the events delivered to a webhook. Each non-empty
+// filter must match; content type and tag filters only match events carrying
+// the item, so they exclude deletions.
+type WebhookFilters struct {
+	EventTypes   []string `json:"event_types,omitempty" binding:"dive,oneof=item.created item.updated item.deleted"`
+	ContentTypes []string `json:"content_types,omitempty" binding:"dive,oneof=text image file"`
+	Tags         []string `json:"tags,omitempty" binding:"dive,max=50,excludesall=0x2C"` // sensitive content tags
+	Devices      []string `json:"devices,omitempty" binding:"dive,max=100,excludesall=0x2C"`
+}
+
+// BeforeCreate hook to set ID
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Filters returns the event filters of the webhook
+func (w *Webhook) Filters() WebhookFilters {
+	return WebhookFilters{
+		EventTypes:   splitList(w.EventTypes),
+		ContentTypes: splitList(w.ContentTypes),
+		Tags:         splitList(w.Tags),
+		Devices:      splitList(w.Devices),
+	}
+}
+
+// SetFilters replaces the event filters of the webhook
+func (w *Webhook) SetFilters(f WebhookFilters) {
+	w.EventTypes = strings.Join(f.EventTypes, ",")
+	w.ContentTypes = strings.Join(f.ContentTypes, ",")
+	w.Tags = strings.Join(f.Tags, ",")
+	w.Devices = strings.Join(f.Devices, ",")
+}
+
+// splitList splits a comma separated list, returning nil for an empty one
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
+// WebhookDeliveryStatus state of a webhook delivery
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"   // waiting for its next attempt
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded" // the receiver answered 2xx
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"    // out of attempts
+)
+
+// WebhookDelivery one event queued for a webhook, with the outcome of its
+// latest attempt. Payload is the exact JSON body sent on every attempt.
+type WebhookDelivery struct {
+	ID        string                `json:"id" gorm:"primaryKey"`
+	WebhookID string                `json:"webhook_id" gorm:"size:36;index"`
+	UserID    string                `json:"-" gorm:"index"`
+	EventType string                `json:"event_type" gorm:"size:50"`
+	Payload   string                `json:"-" gorm:"type:text"`
+	Status    WebhookDeliveryStatus `json:"status" gorm:"size:20;index"`
+
+	// EncryptedAtRest is set when Payload holds ciphertext
+	EncryptedAtRest bool `json:"-" gorm:"not null;default:false"`
+
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty" gorm:"index"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	Error          string     `json:"error,omitempty" gorm:"size:1024"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// plaintext kept while the encrypted payload is being written
+	plainPayload string
+}
+
+// BeforeCreate hook to set ID
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeSave hook to encrypt the payload at rest, it holds the clipboard
+// content of item events
+func (d *WebhookDelivery) BeforeSave(tx *gorm.DB) error {
+	d.EncryptedAtRest = false
+	if contentCipher == nil || d.Payload == "" {
+		return nil
+	}
+
+	encrypted, err := contentCipher.Encrypt(tx, d.UserID, d.Payload)
+	if err != nil {
+		return err
+	}
+	d.plainPayload = d.Payload
+	d.Payload = encrypted
+	d.EncryptedAtRest = true
+	return nil
+}
+
+// AfterSave hook to restore the plaintext on the saved delivery
+func (d *WebhookDelivery) AfterSave(tx *gorm.DB) error {
+	if !d.EncryptedAtRest {
+		return nil
+	}
+	d.Payload = d.plainPayload
+	return nil
+}
+
+// AfterFind hook to decrypt the payload encrypted at rest
+func (d *WebhookDelivery) AfterFind(tx *gorm.DB) error {
+	if !d.EncryptedAtRest {
+		return nil
+	}
+	if contentCipher == nil {
+		return fmt.Errorf("webhook delivery %s is encrypted at rest but no encryption key is configured", d.ID)
+	}
+
+	plaintext, err := contentCipher.Decrypt(tx, d.UserID, d.Payload)
+	if err != nil {
+		return err
+	}
+	d.Payload = plaintext
+	return nil
+}
+
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
+
 // ClipboardItemRequest for creating clipboard items
 type ClipboardItemRequest struct {
-	Content   string        `json:"content" binding:"required"`
-	Type      ClipboardType `json:"type" binding:"omitempty"`
-	Timestamp *CustomTime   `json:"timestamp"`
+	ClientID   string              `json:"client_id" binding:"max=100"` // deduplicates retried batch items
+	Content    string              `json:"content" binding:"required"`
+	Type       ClipboardType       `json:"type" binding:"omitempty"`
+	Timestamp  *CustomTime         `json:"timestamp"`
+	Encryption *EncryptionEnvelope `json:"encryption,omitempty"`
+
+	// Optional expiry, either an absolute time or a TTL in seconds. On update
+	// it replaces the current expiry, which ClearExpiry removes instead.
+	ExpiresAt   *CustomTime `json:"expires_at,omitempty"`
+	TTL         *int        `json:"ttl,omitempty" binding:"omitempty,min=1"`
+	ClearExpiry bool        `json:"clear_expiry,omitempty"`
 }
 
 // ClipboardItemResponse response structure
 type ClipboardItemResponse struct {
-	ID        string        `json:"id"`
-	Content   string        `json:"content"`
-	Type      ClipboardType `json:"type"`
-	Timestamp time.Time     `json:"timestamp"`
-	CreatedAt time.Time     `json:"created_at"`
-	UpdatedAt time.Time     `json:"updated_at"`
+	ID         string              `json:"id"`
+	Content    string              `json:"content"`
+	Type       ClipboardType       `json:"type"`
+	Subtype    ClipboardSubtype    `json:"subtype,omitempty"`
+	MimeType   string              `json:"mime_type,omitempty"`
+	Timestamp  time.Time           `json:"timestamp"`
+	CreatedAt  time.Time           `json:"created_at"`
+	UpdatedAt  time.Time           `json:"updated_at"`
+	Version    int64               `json:"version"`
+	Encryption *EncryptionEnvelope `json:"encryption,omitempty"`
+
+	// Set on items of a channel, AuthorID is the member who posted the item
+	ChannelID string `json:"channel_id,omitempty"`
+	AuthorID  string `json:"author_id,omitempty"`
+
+	Sensitive     bool               `json:"sensitive"`
+	SensitiveTags []string           `json:"sensitive_tags,omitempty"`
+	ExpiresAt     *time.Time         `json:"expires_at,omitempty"`
+	Sensitivity   *SensitivityReport `json:"sensitivity,omitempty"` // detection decision, only on writes
+	Conflict      *SyncConflict      `json:"conflict,omitempty"`    // conflict resolved during sync
+}
+
+// SensitivityReport describes the decision taken for detected sensitive content
+type SensitivityReport struct {
+	Action    SensitiveAction    `json:"action"`
+	Findings  []SensitiveFinding `json:"findings"`
+	ExpiresAt *time.Time         `json:"expires_at,omitempty"`
+}
+
+// SensitiveFinding a detector that matched and the action taken for it
+type SensitiveFinding struct {
+	Detector string          `json:"detector"`
+	Action   SensitiveAction `json:"action"`
+	Count    int             `json:"count"`
 }
 
 // ToResponse converts to response structure
 func (c *ClipboardItem) ToResponse() ClipboardItemResponse {
-	return ClipboardItemResponse{
-		ID:        c.ID,
-		Content:   c.Content,
-		Type:      c.Type,
-		Timestamp: c.Timestamp,
-		CreatedAt: c.CreatedAt,
-		UpdatedAt: c.UpdatedAt,
+	response := ClipboardItemResponse{
+		ID:         c.ID,
+		Content:    c.Content,
+		Type:       c.Type,
+		Subtype:    c.Subtype,
+		MimeType:   c.MimeType,
+		Timestamp:  c.Timestamp,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Version:    c.Version,
+		Encryption: c.Envelope(),
+
+		Sensitive:     c.Sensitive,
+		SensitiveTags: c.Tags(),
+		ExpiresAt:     c.ExpiresAt,
+	}
+	if c.ChannelID != "" {
+		response.ChannelID = c.ChannelID
+		response.AuthorID = c.UserID
+	}
+	return response
+}
+
+// Tags returns the sensitive content tags of the item
+func (c *ClipboardItem) Tags() []string {
+	if c.SensitiveTags == "" {
+		return nil
 	}
+	return strings.Split(c.SensitiveTags, ",")
 }
 
+// BatchMode transaction mode of a batch sync
+type BatchMode string
+
+const (
+	BatchModeBestEffort BatchMode = "best_effort" // valid items are stored even if others fail
+	BatchModeAtomic     BatchMode = "atomic"      // nothing is stored if any item fails
+)
+
 // BatchSyncRequest for batch sync
 type BatchSyncRequest struct {
 	DeviceID string                 `json:"device_id"`
+	Mode     BatchMode              `json:"mode" binding:"omitempty,oneof=best_effort atomic"`
 	Items    []ClipboardItemRequest `json:"items" binding:"required"`
 }
 
 // BatchSyncResponse for batch sync response
 type BatchSyncResponse struct {
+	Mode   BatchMode               `json:"mode"`
 	Synced []ClipboardItemResponse `json:"synced"`
 	Failed []FailedItem            `json:"failed"`
 	Total  int                     `json:"total"`
@@ -198,8 +938,25 @@ type BatchSyncResponse struct {
 
 // FailedItem for sync failures
 type FailedItem struct {
-	Content string `json:"content"`
-	Error   string `json:"error"`
+	Index       int                `json:"index"` // position of the item in the request
+	ClientID    string             `json:"client_id,omitempty"`
+	Error       string             `json:"error"`
+	Sensitivity *SensitivityReport `json:"sensitivity,omitempty"`
+}
+
+// IdempotencyRecord remembers the outcome of a batch sync so that a retry with
+// the same Idempotency-Key returns it instead of storing the items again.
+// Only item IDs are kept, never content.
+type IdempotencyRecord struct {
+	UserID      string       `gorm:"primaryKey;size:36"`
+	Key         string       `gorm:"column:idempotency_key;primaryKey;size:255"`
+	RequestHash string       `gorm:"size:64"`
+	StatusCode  int          // 0 while the request is in progress
+	Mode        BatchMode    `gorm:"size:20"`
+	ItemIDs     []string     `gorm:"serializer:json"`
+	Failed      []FailedItem `gorm:"serializer:json"`
+	Total       int
+	CreatedAt   time.Time `gorm:"index"`
 }
 
 // LoginRequest for login
@@ -242,11 +999,13 @@ type SuccessResponse struct {
 
 // PaginationQuery for pagination
 type PaginationQuery struct {
-	Page     int    `form:"page,default=1"`
-	PageSize int    `form:"page_size,default=20"`
-	Since    string `form:"since"`  // ISO 8601 time format
-	Type     string `form:"type"`   // Filter by type
-	Search   string `form:"search"` // Search content
+	Page      int    `form:"page,default=1"`
+	PageSize  int    `form:"page_size,default=20"`
+	Since     string `form:"since"`     // ISO 8601 time format
+	Type      string `form:"type"`      // Filter by type
+	Subtype   string `form:"subtype"`   // Filter by detected subtype
+	Search    string `form:"search"`    // Search content (plaintext items only)
+	Encrypted *bool  `form:"encrypted"` // Filter by end-to-end encryption
 }
 
 // PaginationResponse for pagination response
@@ -262,12 +1021,14 @@ type PaginationResponse struct {
 
 // StatisticsResponse for statistics
 type StatisticsResponse struct {
-	TotalItems       int64            `json:"total_items"`
-	SyncedItems      int64            `json:"synced_items"`
-	UnsyncedItems    int64            `json:"unsynced_items"`
-	TotalContentSize int64            `json:"total_content_size"`
-	TypeDistribution map[string]int64 `json:"type_distribution"`
-	RecentActivity   []DailyActivity  `json:"recent_activity"`
+	TotalItems          int64            `json:"total_items"`
+	SyncedItems         int64            `json:"synced_items"`
+	UnsyncedItems       int64            `json:"unsynced_items"`
+	TotalContentSize    int64            `json:"total_content_size"`
+	EncryptedItems      int64            `json:"encrypted_items"`
+	TypeDistribution    map[string]int64 `json:"type_distribution"`
+	SubtypeDistribution map[string]int64 `json:"subtype_distribution"`
+	RecentActivity      []DailyActivity  `json:"recent_activity"`
 }
 
 // DailyActivity for daily activity stats
@@ -276,8 +1037,152 @@ type DailyActivity struct {
 	Count int64  `json:"count"`
 }
 
+// WrappedKeyRequest uploads a content key wrapped for one device
+type WrappedKeyRequest struct {
+	DeviceID   string `json:"device_id" binding:"required,max=100"`
+	KeyID      string `json:"key_id" binding:"required,max=100"`
+	WrappedKey string `json:"wrapped_key" binding:"required"`
+	Algorithm  string `json:"algorithm" binding:"required,max=50"`
+}
+
+// DeviceWrappedKey is one device copy of a rotated key
+type DeviceWrappedKey struct {
+	DeviceID   string `json:"device_id" binding:"required,max=100"`
+	WrappedKey string `json:"wrapped_key" binding:"required"`
+}
+
+// KeyRotationRequest replaces the active content key with a new one
+type KeyRotationRequest struct {
+	KeyID       string             `json:"key_id" binding:"required,max=100"`
+	Algorithm   string             `json:"algorithm" binding:"required,max=50"`
+	WrappedKeys []DeviceWrappedKey `json:"wrapped_keys" binding:"required,min=1,dive"`
+}
+
+// KeyRotationResponse for key rotation
+type KeyRotationResponse struct {
+	KeyID        string      `json:"key_id"`
+	Keys         []DeviceKey `json:"keys"`
+	RetiredKeys  []string    `json:"retired_key_ids"`
+	PendingItems int64       `json:"pending_items"` // items still encrypted under retired keys
+}
+
+// SensitivePolicyRequest updates the sensitive content policy
+type SensitivePolicyRequest struct {
+	DefaultAction SensitiveAction            `json:"default_action" binding:"required"`
+	Actions       map[string]SensitiveAction `json:"actions"`
+	ExpireMinutes int                        `json:"expire_minutes" binding:"min=0,max=10080"`
+}
+
+// E2EEModeRequest toggles the end-to-end encryption requirement
+type E2EEModeRequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// ChannelRequest creates or updates a channel
+type ChannelRequest struct {
+	Name        string `json:"name" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+// ChannelInvitationRequest invites a user to a channel
+type ChannelInvitationRequest struct {
+	Username string      `json:"username" binding:"required"`
+	Role     ChannelRole `json:"role" binding:"required,oneof=owner writer reader"`
+}
+
+// ChannelMemberRequest changes the role of a channel member
+type ChannelMemberRequest struct {
+	Role ChannelRole `json:"role" binding:"required,oneof=owner writer reader"`
+}
+
+// ShareLinkRequest for creating a share link, every field is optional
+type ShareLinkRequest struct {
+	// Optional expiry, either an absolute time or a TTL in seconds
+	ExpiresAt *CustomTime `json:"expires_at,omitempty"`
+	TTL       *int        `json:"ttl,omitempty" binding:"omitempty,min=1"`
+
+	MaxViews int    `json:"max_views" binding:"omitempty,min=1"`
+	Password string `json:"password" binding:"max=128"`
+}
+
+// WebhookRequest for creating or replacing a webhook. A secret is generated
+// when none is given on creation.
+type WebhookRequest struct {
+	URL         string         `json:"url" binding:"required,url,max=2048"`
+	Description string         `json:"description" binding:"max=255"`
+	Secret      string         `json:"secret" binding:"omitempty,min=16,max=128"`
+	Active      *bool          `json:"active"`
+	Filters     WebhookFilters `json:"filters"`
+}
+
+// WebhookResponse a webhook with its filters. Secret is only returned when it
+// was generated or changed by the request.
+type WebhookResponse struct {
+	Webhook
+	Filters WebhookFilters `json:"filters"`
+	Secret  string         `json:"secret,omitempty"`
+}
+
+// ToResponse converts to response structure, without the secret
+func (w *Webhook) ToResponse() WebhookResponse {
+	return WebhookResponse{Webhook: *w, Filters: w.Filters()}
+}
+
+// WebhookDeliveryResponse a delivery with its payload as JSON
+type WebhookDeliveryResponse struct {
+	WebhookDelivery
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ToResponse converts to response structure
+func (d *WebhookDelivery) ToResponse() WebhookDeliveryResponse {
+	return WebhookDeliveryResponse{WebhookDelivery: *d, Payload: json.RawMessage(d.Payload)}
+}
+
+// ShareLinkResponse a share link with the path and URL it is opened at
+type ShareLinkResponse struct {
+	ShareLink
+	PasswordProtected bool   `json:"password_protected"`
+	Path              string `json:"path"`
+	URL               string `json:"url,omitempty"`
+}
+
+// ToResponse converts to response structure, with URLs relative to baseURL
+func (s *ShareLink) ToResponse(baseURL string) ShareLinkResponse {
+	response := ShareLinkResponse{
+		ShareLink:         *s,
+		PasswordProtected: s.Protected(),
+		Path:              "/s/" + s.Token,
+	}
+	if baseURL != "" {
+		response.URL = baseURL + response.Path
+	}
+	return response
+}
+
 // RecentSyncResponse for recent sync clipboard items
 type RecentSyncResponse struct {
 	Items []ClipboardItemResponse `json:"items"`
 	Total int64                   `json:"total"`
 }
+
+// DeletionsResponse for deleted items since a point in time
+type DeletionsResponse struct {
+	Items []DeletedItem `json:"items"`
+	Total int           `json:"total"`
+}
+
+// SyncConflictResponse returned when a sync is rejected because of a conflict
+type SyncConflictResponse struct {
+	Error    string                `json:"error"`
+	Message  string                `json:"message"`
+	Server   ClipboardItemResponse `json:"server"`
+	Client   ClipboardItemResponse `json:"client"`
+	Conflict *SyncConflict         `json:"conflict,omitempty"`
+}
+
+// ConflictListResponse for the conflict log
+type ConflictListResponse struct {
+	Items []SyncConflict `json:"items"`
+	Total int64          `json:"total"`
+}
@@ -0,0 +1,853 @@
+package repository
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/models"
+	"clipboard-server/sensitive"
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// notFound maps the GORM not found error to ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormClipboardRepository struct {
+	db *gorm.DB
+
+	channelID string // shared channel clipboard, empty for personal clipboards
+}
+
+// NewClipboardRepository returns a ClipboardRepository backed by db
+func NewClipboardRepository(db *gorm.DB) ClipboardRepository {
+	return &gormClipboardRepository{db: db}
+}
+
+func (r *gormClipboardRepository) WithContext(ctx context.Context) ClipboardRepository {
+	return &gormClipboardRepository{db: r.db.WithContext(ctx), channelID: r.channelID}
+}
+
+func (r *gormClipboardRepository) InChannel(channelID string) ClipboardRepository {
+	return &gormClipboardRepository{db: r.db, channelID: channelID}
+}
+
+// scope selects the records of the user's clipboard, or of the channel
+func (r *gormClipboardRepository) scope(userID string) func(*gorm.DB) *gorm.DB {
+	if r.channelID != "" {
+		return database.InChannel(r.channelID)
+	}
+	return database.Personal(userID)
+}
+
+func (r *gormClipboardRepository) items(userID string) *gorm.DB {
+	return r.db.Model(&models.ClipboardItem{}).Scopes(database.NotExpired, r.scope(userID))
+}
+
+func (r *gormClipboardRepository) CreateItem(item *models.ClipboardItem) error {
+	item.ChannelID = r.channelID
+	return r.db.Create(item).Error
+}
+
+func (r *gormClipboardRepository) GetItem(userID, id string) (models.ClipboardItem, error) {
+	var item models.ClipboardItem
+	err := r.db.Scopes(database.NotExpired, r.scope(userID)).Where("id = ?", id).First(&item).Error
+	return item, notFound(err)
+}
+
+func (r *gormClipboardRepository) FindByClientID(userID, clientID string) (models.ClipboardItem, error) {
+	var item models.ClipboardItem
+	result := r.db.Scopes(database.NotExpired, r.scope(userID)).Where("client_id = ?", clientID).Limit(1).Find(&item)
+	if result.Error != nil {
+		return item, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return item, ErrNotFound
+	}
+	return item, nil
+}
+
+func (r *gormClipboardRepository) ListItems(userID string, filter ItemFilter) ([]models.ClipboardItem, int64, error) {
+	query := r.items(userID)
+	if filter.Since != nil {
+		query = query.Where("timestamp >= ?", *filter.Since)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Subtype != "" {
+		query = query.Where("subtype = ?", filter.Subtype)
+	}
+	if filter.Encrypted != nil {
+		query = query.Where("encrypted = ?", *filter.Encrypted)
+	}
+
+	// End-to-end encrypted items cannot be searched by the server
+	search := strings.ToLower(filter.Search)
+	if search != "" {
+		query = query.Where("encrypted = ?", false)
+		if !models.ContentEncryptionEnabled() {
+			// LOWER keeps the search case-insensitive on every database driver
+			query = query.Where("LOWER(content) LIKE ?", "%"+search+"%")
+		}
+	}
+
+	var items []models.ClipboardItem
+	if search != "" && models.ContentEncryptionEnabled() {
+		// Content is encrypted at rest, so search runs on the decrypted items
+		if err := query.Order("timestamp DESC").Find(&items).Error; err != nil {
+			return nil, 0, err
+		}
+		matched := items[:0]
+		for _, item := range items {
+			if strings.Contains(strings.ToLower(item.Content), search) {
+				matched = append(matched, item)
+			}
+		}
+		return paginate(matched, filter.Offset, filter.Limit), int64(len(matched)), nil
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	if filter.Offset > 0 {
+		query = query.Offset(filter.Offset)
+	}
+	if filter.Limit > 0 {
+		query = query.Limit(filter.Limit)
+	}
+	if err := query.Order("timestamp DESC").Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (r *gormClipboardRepository) RecentItems(userID string, limit int) ([]models.ClipboardItem, int64, error) {
+	var items []models.ClipboardItem
+	if err := r.items(userID).Order("created_at DESC").Limit(limit).Find(&items).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	if err := r.items(userID).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	return items, total, nil
+}
+
+func (r *gormClipboardRepository) LatestItem(userID string) (models.ClipboardItem, error) {
+	var item models.ClipboardItem
+	err := r.items(userID).Order("updated_at DESC").First(&item).Error
+	return item, notFound(err)
+}
+
+func (r *gormClipboardRepository) ItemsByID(userID string, ids []string) ([]models.ClipboardItem, error) {
+	return database.ItemsByID(r.db, r.scope(userID), ids)
+}
+
+func (r *gormClipboardRepository) SaveItem(item *models.ClipboardItem) error {
+	return database.SaveItem(r.db, item)
+}
+
+func (r *gormClipboardRepository) DeleteItem(userID, id string) ([]models.DeletedItem, error) {
+	var item models.ClipboardItem
+	if err := r.db.Select("id", "user_id", "channel_id", "client_id").
+		Scopes(r.scope(userID)).Where("id = ?", id).
+		First(&item).Error; err != nil {
+		return nil, notFound(err)
+	}
+	return database.DeleteItems(r.db, []models.ClipboardItem{item}, models.DeletionReasonDeleted)
+}
+
+func (r *gormClipboardRepository) DeletedSince(userID string, since time.Time, limit int) ([]models.DeletedItem, error) {
+	return database.DeletedSince(r.db, r.scope(userID), since, limit)
+}
+
+func (r *gormClipboardRepository) Statistics(userID string, activitySince time.Time) (models.StatisticsResponse, error) {
+	stats := models.StatisticsResponse{
+		TypeDistribution:    make(map[string]int64),
+		SubtypeDistribution: make(map[string]int64),
+	}
+
+	if err := r.items(userID).Count(&stats.TotalItems).Error; err != nil {
+		return stats, err
+	}
+	if err := r.items(userID).Where("encrypted = ?", true).Count(&stats.EncryptedItems).Error; err != nil {
+		return stats, err
+	}
+	if err := r.items(userID).Select("COALESCE(SUM(content_size), 0)").Scan(&stats.TotalContentSize).Error; err != nil {
+		return stats, err
+	}
+
+	// All items stored on the server are considered synced
+	stats.SyncedItems = stats.TotalItems
+
+	// Counted per type, and separately per "type.subtype" for classified items
+	var types []struct {
+		Type    string
+		Subtype string
+		Count   int64
+	}
+	if err := r.items(userID).Select("type, subtype, COUNT(*) AS count").Group("type, subtype").Scan(&types).Error; err != nil {
+		return stats, err
+	}
+	for _, t := range types {
+		stats.TypeDistribution[t.Type] += t.Count
+		if t.Subtype != "" {
+			stats.SubtypeDistribution[t.Type+"."+t.Subtype] += t.Count
+		}
+	}
+
+	date := database.DateExpr(r.db, "timestamp")
+	err := r.items(userID).
+		Select(date+" AS date, COUNT(*) AS count").
+		Where("timestamp >= ?", activitySince).
+		Group(date).
+		Order("date DESC").
+		Scan(&stats.RecentActivity).Error
+	return stats, err
+}
+
+func (r *gormClipboardRepository) CreateConflict(conflict *models.SyncConflict) error {
+	conflict.ChannelID = r.channelID
+	return r.db.Create(conflict).Error
+}
+
+func (r *gormClipboardRepository) ListConflicts(userID, itemID string, limit int) ([]models.SyncConflict, int64, error) {
+	query := r.db.Model(&models.SyncConflict{}).Scopes(r.scope(userID))
+	if itemID != "" {
+		query = query.Where("item_id = ?", itemID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var conflicts []models.SyncConflict
+	if err := query.Order("created_at DESC").Limit(limit).Find(&conflicts).Error; err != nil {
+		return nil, 0, err
+	}
+	return conflicts, total, nil
+}
+
+func (r *gormClipboardRepository) SensitivePolicy(userID string) models.SensitivePolicy {
+	var policy models.SensitivePolicy
+	result := r.db.Where("user_id = ?", userID).Limit(1).Find(&policy)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return sensitive.DefaultPolicy()
+	}
+	return policy
+}
+
+func (r *gormClipboardRepository) SaveSensitivePolicy(policy *models.SensitivePolicy) error {
+	return r.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(policy).Error
+}
+
+func (r *gormClipboardRepository) RequiresEncryption(userID string) bool {
+	var user models.User
+	if err := r.db.Select("e2ee_required").Where("id = ?", userID).First(&user).Error; err != nil {
+		return false
+	}
+	return user.E2EERequired
+}
+
+func (r *gormClipboardRepository) CountEncryptedItems(userID string, keyIDs []string) (int64, error) {
+	if len(keyIDs) == 0 {
+		return 0, nil
+	}
+	var count int64
+	err := r.db.Model(&models.ClipboardItem{}).Scopes(r.scope(userID)).
+		Where("encrypted = ? AND key_id IN ?", true, keyIDs).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *gormClipboardRepository) ReserveIdempotencyKey(userID, key, requestHash string) (*models.IdempotencyRecord, error) {
+	return database.ReserveIdempotencyKey(r.db, userID, key, requestHash)
+}
+
+func (r *gormClipboardRepository) CompleteIdempotencyKey(record *models.IdempotencyRecord) error {
+	return database.CompleteIdempotencyKey(r.db, record)
+}
+
+func (r *gormClipboardRepository) ReleaseIdempotencyKey(userID, key string) error {
+	return database.ReleaseIdempotencyKey(r.db, userID, key)
+}
+
+func (r *gormClipboardRepository) Transaction(fn func(tx ClipboardRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&gormClipboardRepository{db: tx, channelID: r.channelID})
+	})
+}
+
+func (r *gormClipboardRepository) SavePoint(name string) error {
+	return r.db.SavePoint(name).Error
+}
+
+func (r *gormClipboardRepository) RollbackTo(name string) error {
+	return r.db.RollbackTo(name).Error
+}
+
+type gormUserRepository struct {
+	db *gorm.DB
+}
+
+// NewUserRepository returns a UserRepository backed by db
+func NewUserRepository(db *gorm.DB) UserRepository {
+	return &gormUserRepository{db: db}
+}
+
+func (r *gormUserRepository) WithContext(ctx context.Context) UserRepository {
+	return &gormUserRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *gormUserRepository) first(query string, args ...interface{}) (models.User, error) {
+	var user models.User
+	err := r.db.Where(query, args...).First(&user).Error
+	return user, notFound(err)
+}
+
+func (r *gormUserRepository) CreateUser(user *models.User) error {
+	return r.db.Create(user).Error
+}
+
+func (r *gormUserRepository) GetUser(id string) (models.User, error) {
+	return r.first("id = ?", id)
+}
+
+func (r *gormUserRepository) FindByUsername(username string) (models.User, error) {
+	return r.first("username = ?", username)
+}
+
+func (r *gormUserRepository) FindByEmail(email string) (models.User, error) {
+	return r.first("email = ?", email)
+}
+
+func (r *gormUserRepository) FindByLogin(login string) (models.User, error) {
+	return r.first("username = ? OR email = ?", login, login)
+}
+
+func (r *gormUserRepository) SaveUser(user *models.User) error {
+	return r.db.Save(user).Error
+}
+
+func (r *gormUserRepository) UpdateToken(id, token string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("token", token).Error
+}
+
+func (r *gormUserRepository) UpdatePassword(id, password, salt string) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Updates(models.User{
+		Password: password,
+		Salt:     salt,
+	}).Error
+}
+
+func (r *gormUserRepository) SetE2EERequired(id string, required bool) error {
+	return r.db.Model(&models.User{}).Where("id = ?", id).Update("e2ee_required", required).Error
+}
+
+type gormKeyRepository struct {
+	db *gorm.DB
+}
+
+// NewKeyRepository returns a KeyRepository backed by db
+func NewKeyRepository(db *gorm.DB) KeyRepository {
+	return &gormKeyRepository{db: db}
+}
+
+func (r *gormKeyRepository) WithContext(ctx context.Context) KeyRepository {
+	return &gormKeyRepository{db: r.db.WithContext(ctx)}
+}
+
+// keys selects the keys of a user, optionally only those matching column = value
+// for the non-empty values
+func (r *gormKeyRepository) keys(userID string, filters map[string]string) *gorm.DB {
+	query := r.db.Model(&models.DeviceKey{}).Where("user_id = ?", userID)
+	for column, value := range filters {
+		if value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	return query
+}
+
+func (r *gormKeyRepository) ListKeys(userID, deviceID string, status models.KeyStatus) ([]models.DeviceKey, error) {
+	var keys []models.DeviceKey
+	err := r.keys(userID, map[string]string{"device_id": deviceID, "status": string(status)}).
+		Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+func (r *gormKeyRepository) CountKeys(userID, keyID string, status models.KeyStatus) (int64, error) {
+	var count int64
+	err := r.keys(userID, map[string]string{"key_id": keyID, "status": string(status)}).Count(&count).Error
+	return count, err
+}
+
+func (r *gormKeyRepository) ReplaceKey(key *models.DeviceKey) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND device_id = ? AND key_id = ?", key.UserID, key.DeviceID, key.KeyID).
+			Delete(&models.DeviceKey{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(key).Error
+	})
+}
+
+func (r *gormKeyRepository) RotateKey(userID string, keys []models.DeviceKey) ([]string, error) {
+	var retired []string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		active := tx.Model(&models.DeviceKey{}).Where("user_id = ? AND status = ?", userID, models.KeyStatusActive)
+		if err := active.Session(&gorm.Session{}).Distinct().Pluck("key_id", &retired).Error; err != nil {
+			return err
+		}
+		if err := active.Session(&gorm.Session{}).Updates(map[string]interface{}{
+			"status":     models.KeyStatusRetired,
+			"retired_at": time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		for i := range keys {
+			if err := tx.Create(&keys[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return retired, err
+}
+
+func (r *gormKeyRepository) DeleteKey(userID, id string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.DeviceKey{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+type gormTransferRepository struct {
+	db *gorm.DB
+}
+
+// NewTransferRepository returns a TransferRepository backed by db
+func NewTransferRepository(db *gorm.DB) TransferRepository {
+	return &gormTransferRepository{db: db}
+}
+
+func (r *gormTransferRepository) WithContext(ctx context.Context) TransferRepository {
+	return &gormTransferRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *gormTransferRepository) ExportItems(userID string, batchSize int, fn func([]models.ClipboardItem) error) (int, error) {
+	var items []models.ClipboardItem
+	count := 0
+	result := r.db.Scopes(database.NotExpired, database.Personal(userID)).
+		FindInBatches(&items, batchSize, func(tx *gorm.DB, batch int) error {
+			count += len(items)
+			return fn(items)
+		})
+	return count, result.Error
+}
+
+func (r *gormTransferRepository) ImportItems(userID string, items []models.ClipboardItem) ([]bool, error) {
+	stored := make([]bool, len(items))
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		for i := range items {
+			item := &items[i]
+			query := tx.Model(&models.ClipboardItem{}).Scopes(database.Personal(userID))
+			if item.ClientID != "" {
+				query = query.Where("client_id = ?", item.ClientID)
+			} else {
+				hash, err := models.HashContent(tx, userID, item.Content, item.Encrypted)
+				if err != nil {
+					return err
+				}
+				query = query.Where("content_hash = ?", hash)
+			}
+
+			var count int64
+			if err := query.Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				continue
+			}
+
+			item.UserID = userID
+			if err := tx.Create(item).Error; err != nil {
+				return err
+			}
+			stored[i] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+type gormChannelRepository struct {
+	db *gorm.DB
+}
+
+// NewChannelRepository returns a ChannelRepository backed by db
+func NewChannelRepository(db *gorm.DB) ChannelRepository {
+	return &gormChannelRepository{db: db}
+}
+
+func (r *gormChannelRepository) WithContext(ctx context.Context) ChannelRepository {
+	return &gormChannelRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *gormChannelRepository) CreateChannel(channel *models.Channel) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(channel).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.ChannelMember{
+			ChannelID: channel.ID,
+			UserID:    channel.CreatedBy,
+			Role:      models.ChannelRoleOwner,
+		}).Error
+	})
+}
+
+func (r *gormChannelRepository) GetChannel(id string) (models.Channel, error) {
+	var channel models.Channel
+	err := r.db.Where("id = ?", id).First(&channel).Error
+	return channel, notFound(err)
+}
+
+func (r *gormChannelRepository) ListChannels(userID string) ([]models.ChannelMembership, error) {
+	var memberships []models.ChannelMembership
+	err := r.db.Model(&models.Channel{}).
+		Select("channels.*, channel_members.role").
+		Joins("JOIN channel_members ON channel_members.channel_id = channels.id").
+		Where("channel_members.user_id = ?", userID).
+		Order("channels.name").
+		Scan(&memberships).Error
+	return memberships, err
+}
+
+func (r *gormChannelRepository) UpdateChannel(channel *models.Channel) error {
+	return r.db.Model(channel).Select("name", "description").Updates(channel).Error
+}
+
+func (r *gormChannelRepository) DeleteChannel(id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.ClipboardItem{},
+			&models.DeletedItem{},
+			&models.SyncConflict{},
+			&models.ChannelInvitation{},
+			&models.ChannelMember{},
+		} {
+			if err := tx.Where("channel_id = ?", id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		result := tx.Where("id = ?", id).Delete(&models.Channel{})
+		if result.Error == nil && result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return result.Error
+	})
+}
+
+func (r *gormChannelRepository) Member(channelID, userID string) (models.ChannelMember, error) {
+	var member models.ChannelMember
+	err := r.db.Where("channel_id = ? AND user_id = ?", channelID, userID).First(&member).Error
+	return member, notFound(err)
+}
+
+func (r *gormChannelRepository) Members(channelID string) ([]models.ChannelMember, error) {
+	var members []models.ChannelMember
+	err := r.db.Where("channel_id = ?", channelID).Order("created_at").Find(&members).Error
+	return members, err
+}
+
+// changeMember applies change to a member, unless it leaves the channel without an owner
+func (r *gormChannelRepository) changeMember(channelID, userID string, stillOwner bool, change func(tx *gorm.DB) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var member models.ChannelMember
+		if err := tx.Where("channel_id = ? AND user_id = ?", channelID, userID).First(&member).Error; err != nil {
+			return notFound(err)
+		}
+		if member.Role == models.ChannelRoleOwner && !stillOwner {
+			var owners int64
+			if err := tx.Model(&models.ChannelMember{}).
+				Where("channel_id = ? AND role = ?", channelID, models.ChannelRoleOwner).
+				Count(&owners).Error; err != nil {
+				return err
+			}
+			if owners <= 1 {
+				return ErrLastOwner
+			}
+		}
+		return change(tx.Where("channel_id = ? AND user_id = ?", channelID, userID))
+	})
+}
+
+func (r *gormChannelRepository) SetRole(channelID, userID string, role models.ChannelRole) error {
+	return r.changeMember(channelID, userID, role == models.ChannelRoleOwner, func(tx *gorm.DB) error {
+		return tx.Model(&models.ChannelMember{}).Update("role", role).Error
+	})
+}
+
+func (r *gormChannelRepository) RemoveMember(channelID, userID string) error {
+	return r.changeMember(channelID, userID, false, func(tx *gorm.DB) error {
+		return tx.Delete(&models.ChannelMember{}).Error
+	})
+}
+
+func (r *gormChannelRepository) CreateInvitation(invitation *models.ChannelInvitation) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var members int64
+		if err := tx.Model(&models.ChannelMember{}).
+			Where("channel_id = ? AND user_id = ?", invitation.ChannelID, invitation.InviteeID).
+			Count(&members).Error; err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyMember
+		}
+		if err := tx.Where("channel_id = ? AND invitee_id = ?", invitation.ChannelID, invitation.InviteeID).
+			Delete(&models.ChannelInvitation{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(invitation).Error
+	})
+}
+
+func (r *gormChannelRepository) invitations() *gorm.DB {
+	return r.db.Model(&models.ChannelInvitation{}).Where("expires_at > ?", time.Now())
+}
+
+func (r *gormChannelRepository) GetInvitation(id string) (models.ChannelInvitation, error) {
+	var invitation models.ChannelInvitation
+	err := r.invitations().Where("id = ?", id).First(&invitation).Error
+	return invitation, notFound(err)
+}
+
+func (r *gormChannelRepository) ChannelInvitations(channelID string) ([]models.ChannelInvitation, error) {
+	var invitations []models.ChannelInvitation
+	err := r.invitations().Where("channel_id = ?", channelID).Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *gormChannelRepository) UserInvitations(userID string) ([]models.ChannelInvitation, error) {
+	var invitations []models.ChannelInvitation
+	err := r.invitations().Where("invitee_id = ?", userID).Order("created_at DESC").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r *gormChannelRepository) AcceptInvitation(invitation models.ChannelInvitation) (models.ChannelMember, error) {
+	member := models.ChannelMember{
+		ChannelID: invitation.ChannelID,
+		UserID:    invitation.InviteeID,
+		Role:      invitation.Role,
+	}
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", invitation.ID).Delete(&models.ChannelInvitation{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		var members int64
+		if err := tx.Model(&models.ChannelMember{}).
+			Where("channel_id = ? AND user_id = ?", member.ChannelID, member.UserID).
+			Count(&members).Error; err != nil {
+			return err
+		}
+		if members > 0 {
+			return ErrAlreadyMember
+		}
+		return tx.Create(&member).Error
+	})
+	return member, err
+}
+
+func (r *gormChannelRepository) DeleteInvitation(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&models.ChannelInvitation{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+type gormShareRepository struct {
+	db *gorm.DB
+}
+
+// NewShareRepository returns a ShareRepository backed by db
+func NewShareRepository(db *gorm.DB) ShareRepository {
+	return &gormShareRepository{db: db}
+}
+
+func (r *gormShareRepository) WithContext(ctx context.Context) ShareRepository {
+	return &gormShareRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *gormShareRepository) CreateShare(share *models.ShareLink) error {
+	return r.db.Create(share).Error
+}
+
+func (r *gormShareRepository) FindByToken(token string) (models.ShareLink, error) {
+	var share models.ShareLink
+	err := r.db.Where("token = ?", token).First(&share).Error
+	return share, notFound(err)
+}
+
+func (r *gormShareRepository) ListShares(userID, itemID string) ([]models.ShareLink, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if itemID != "" {
+		query = query.Where("item_id = ?", itemID)
+	}
+	var shares []models.ShareLink
+	err := query.Order("created_at DESC").Find(&shares).Error
+	return shares, err
+}
+
+func (r *gormShareRepository) DeleteShare(userID, id string) error {
+	result := r.db.Where("id = ? AND user_id = ?", id, userID).Delete(&models.ShareLink{})
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return result.Error
+}
+
+// RecordView increments the count with a conditional update, so that
+// concurrent views cannot exceed the limit
+func (r *gormShareRepository) RecordView(id string) error {
+	result := r.db.Model(&models.ShareLink{}).
+		Where("id = ? AND (max_views = 0 OR views < max_views)", id).
+		UpdateColumn("views", gorm.Expr("views + 1"))
+	if result.Error == nil && result.RowsAffected == 0 {
+		return ErrViewLimitReached
+	}
+	return result.Error
+}
+
+type gormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewWebhookRepository returns a WebhookRepository backed by db
+func NewWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &gormWebhookRepository{db: db}
+}
+
+func (r *gormWebhookRepository) WithContext(ctx context.Context) WebhookRepository {
+	return &gormWebhookRepository{db: r.db.WithContext(ctx)}
+}
+
+func (r *gormWebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	return r.db.Create(webhook).Error
+}
+
+func (r *gormWebhookRepository) GetWebhook(userID, id string) (models.Webhook, error) {
+	var webhook models.Webhook
+	err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&webhook).Error
+	return webhook, notFound(err)
+}
+
+func (r *gormWebhookRepository) ListWebhooks(userID string) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *gormWebhookRepository) ActiveWebhooks(userID string) ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := r.db.Where("user_id = ? AND active = ?", userID, true).Find(&webhooks).Error
+	return webhooks, err
+}
+
+func (r *gormWebhookRepository) SaveWebhook(webhook *models.Webhook) error {
+	return r.db.Save(webhook).Error
+}
+
+func (r *gormWebhookRepository) DeleteWebhook(userID, id string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? AND user_id = ?", id, userID).Delete(&models.Webhook{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Where("webhook_id = ?", id).Delete(&models.WebhookDelivery{}).Error
+	})
+}
+
+func (r *gormWebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Create(&deliveries).Error
+}
+
+func (r *gormWebhookRepository) GetDelivery(webhookID, id string) (models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	err := r.db.Where("id = ? AND webhook_id = ?", id, webhookID).First(&delivery).Error
+	return delivery, notFound(err)
+}
+
+func (r *gormWebhookRepository) ListDeliveries(webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	query := r.db.Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var deliveries []models.WebhookDelivery
+	err := query.Order("created_at DESC").Limit(limit).Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *gormWebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+		Order("next_attempt_at ASC").
+		Limit(limit).
+		Find(&deliveries).Error
+	return deliveries, err
+}
+
+func (r *gormWebhookRepository) ClaimDelivery(id string, now, until time.Time) (bool, error) {
+	result := r.db.Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", id, models.WebhookDeliveryPending, now).
+		UpdateColumn("next_attempt_at", until)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *gormWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery) error {
+	return r.db.Save(delivery).Error
+}
+
+// paginate returns the items of one page of an ordered list
+func paginate(items []models.ClipboardItem, offset, limit int) []models.ClipboardItem {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
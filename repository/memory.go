@@ -0,0 +1,1238 @@
+package repository
+
+import (
+	"clipboard-server/models"
+	"clipboard-server/sensitive"
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore the data shared by a MemoryClipboardRepository and its transactions
+type memoryStore struct {
+	mu   sync.Mutex
+	txMu sync.Mutex // serializes transactions
+
+	items       map[string]models.ClipboardItem
+	deletions   []models.DeletedItem
+	conflicts   []models.SyncConflict
+	idempotency map[[2]string]models.IdempotencyRecord
+	policies    map[string]models.SensitivePolicy
+	e2ee        map[string]bool
+}
+
+// memorySnapshot a copy of the mutable records, restored on rollback
+type memorySnapshot struct {
+	items       map[string]models.ClipboardItem
+	deletions   []models.DeletedItem
+	conflicts   []models.SyncConflict
+	idempotency map[[2]string]models.IdempotencyRecord
+}
+
+func (s *memoryStore) snapshot() memorySnapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	snap := memorySnapshot{
+		items:       make(map[string]models.ClipboardItem, len(s.items)),
+		deletions:   append([]models.DeletedItem(nil), s.deletions...),
+		conflicts:   append([]models.SyncConflict(nil), s.conflicts...),
+		idempotency: make(map[[2]string]models.IdempotencyRecord, len(s.idempotency)),
+	}
+	for id, item := range s.items {
+		snap.items[id] = item
+	}
+	for key, record := range s.idempotency {
+		snap.idempotency[key] = record
+	}
+	return snap
+}
+
+func (s *memoryStore) restore(snap memorySnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.items = snap.items
+	s.deletions = snap.deletions
+	s.conflicts = snap.conflicts
+	s.idempotency = snap.idempotency
+}
+
+// MemoryClipboardRepository is an in-memory ClipboardRepository for tests.
+// Transactions are serialized and rolled back on error, but not isolated from
+// reads outside of them. Content is never encrypted at rest.
+type MemoryClipboardRepository struct {
+	store *memoryStore
+
+	// savepoints of the current transaction, nil outside of a transaction
+	savepoints map[string]memorySnapshot
+
+	channelID string // shared channel clipboard, empty for personal clipboards
+}
+
+// NewMemoryClipboardRepository returns an empty in-memory ClipboardRepository
+func NewMemoryClipboardRepository() *MemoryClipboardRepository {
+	return &MemoryClipboardRepository{store: &memoryStore{
+		items:       make(map[string]models.ClipboardItem),
+		idempotency: make(map[[2]string]models.IdempotencyRecord),
+		policies:    make(map[string]models.SensitivePolicy),
+		e2ee:        make(map[string]bool),
+	}}
+}
+
+// SetE2EERequired sets whether a user only accepts end-to-end encrypted items
+func (r *MemoryClipboardRepository) SetE2EERequired(userID string, required bool) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.e2ee[userID] = required
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryClipboardRepository) WithContext(ctx context.Context) ClipboardRepository {
+	return r
+}
+
+func (r *MemoryClipboardRepository) InChannel(channelID string) ClipboardRepository {
+	return &MemoryClipboardRepository{store: r.store, savepoints: r.savepoints, channelID: channelID}
+}
+
+// owns reports whether a record posted by recordUserID, to recordChannelID if
+// set, belongs to the user's clipboard or to the channel of the repository
+func (r *MemoryClipboardRepository) owns(userID, recordUserID, recordChannelID string) bool {
+	if r.channelID != "" {
+		return recordChannelID == r.channelID
+	}
+	return recordUserID == userID && recordChannelID == ""
+}
+
+func (r *MemoryClipboardRepository) CreateItem(item *models.ClipboardItem) error {
+	item.BeforeCreate(nil)
+	item.ChannelID = r.channelID
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.items[item.ID] = *item
+	return nil
+}
+
+func (r *MemoryClipboardRepository) GetItem(userID, id string) (models.ClipboardItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, ok := r.store.items[id]
+	if !ok || !r.owns(userID, item.UserID, item.ChannelID) || expired(item, time.Now()) {
+		return models.ClipboardItem{}, ErrNotFound
+	}
+	return item, nil
+}
+
+func (r *MemoryClipboardRepository) FindByClientID(userID, clientID string) (models.ClipboardItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	now := time.Now()
+	for _, item := range r.store.items {
+		if r.owns(userID, item.UserID, item.ChannelID) && item.ClientID == clientID && !expired(item, now) {
+			return item, nil
+		}
+	}
+	return models.ClipboardItem{}, ErrNotFound
+}
+
+// userItems returns the unexpired items of a user matching keep, sorted by less.
+// The caller must hold the store lock.
+func (r *MemoryClipboardRepository) userItems(userID string, keep func(models.ClipboardItem) bool, less func(a, b models.ClipboardItem) bool) []models.ClipboardItem {
+	now := time.Now()
+	var items []models.ClipboardItem
+	for _, item := range r.store.items {
+		if r.owns(userID, item.UserID, item.ChannelID) && !expired(item, now) && (keep == nil || keep(item)) {
+			items = append(items, item)
+		}
+	}
+	if less != nil {
+		sort.SliceStable(items, func(i, j int) bool { return less(items[i], items[j]) })
+	}
+	return items
+}
+
+func (r *MemoryClipboardRepository) ListItems(userID string, filter ItemFilter) ([]models.ClipboardItem, int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	search := strings.ToLower(filter.Search)
+	items := r.userItems(userID, func(item models.ClipboardItem) bool {
+		switch {
+		case filter.Since != nil && item.Timestamp.Before(*filter.Since):
+			return false
+		case filter.Type != "" && item.Type != filter.Type:
+			return false
+		case filter.Subtype != "" && item.Subtype != filter.Subtype:
+			return false
+		case filter.Encrypted != nil && item.Encrypted != *filter.Encrypted:
+			return false
+		case search != "":
+			return !item.Encrypted && strings.Contains(strings.ToLower(item.Content), search)
+		}
+		return true
+	}, func(a, b models.ClipboardItem) bool {
+		return a.Timestamp.After(b.Timestamp)
+	})
+	return paginate(items, filter.Offset, filter.Limit), int64(len(items)), nil
+}
+
+func (r *MemoryClipboardRepository) RecentItems(userID string, limit int) ([]models.ClipboardItem, int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	items := r.userItems(userID, nil, func(a, b models.ClipboardItem) bool {
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return paginate(items, 0, limit), int64(len(items)), nil
+}
+
+func (r *MemoryClipboardRepository) LatestItem(userID string) (models.ClipboardItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	items := r.userItems(userID, nil, func(a, b models.ClipboardItem) bool {
+		return a.UpdatedAt.After(b.UpdatedAt)
+	})
+	if len(items) == 0 {
+		return models.ClipboardItem{}, ErrNotFound
+	}
+	return items[0], nil
+}
+
+func (r *MemoryClipboardRepository) ItemsByID(userID string, ids []string) ([]models.ClipboardItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var items []models.ClipboardItem
+	for _, id := range ids {
+		if item, ok := r.store.items[id]; ok && r.owns(userID, item.UserID, item.ChannelID) {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+func (r *MemoryClipboardRepository) SaveItem(item *models.ClipboardItem) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stored, ok := r.store.items[item.ID]
+	if !ok || stored.Version != item.Version {
+		return ErrVersionConflict
+	}
+	item.Version++
+	item.UpdatedAt = time.Now()
+	r.store.items[item.ID] = *item
+	return nil
+}
+
+func (r *MemoryClipboardRepository) DeleteItem(userID, id string) ([]models.DeletedItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	item, ok := r.store.items[id]
+	if !ok || !r.owns(userID, item.UserID, item.ChannelID) {
+		return nil, ErrNotFound
+	}
+	delete(r.store.items, id)
+
+	deleted := models.DeletedItem{
+		ID:        uint(len(r.store.deletions) + 1),
+		ItemID:    item.ID,
+		UserID:    item.UserID,
+		ChannelID: item.ChannelID,
+		ClientID:  item.ClientID,
+		Reason:    models.DeletionReasonDeleted,
+		DeletedAt: time.Now(),
+	}
+	r.store.deletions = append(r.store.deletions, deleted)
+	return []models.DeletedItem{deleted}, nil
+}
+
+func (r *MemoryClipboardRepository) DeletedSince(userID string, since time.Time, limit int) ([]models.DeletedItem, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var deleted []models.DeletedItem
+	for _, d := range r.store.deletions {
+		if r.owns(userID, d.UserID, d.ChannelID) && d.DeletedAt.After(since) && len(deleted) < limit {
+			deleted = append(deleted, d)
+		}
+	}
+	return deleted, nil
+}
+
+func (r *MemoryClipboardRepository) Statistics(userID string, activitySince time.Time) (models.StatisticsResponse, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	stats := models.StatisticsResponse{
+		TypeDistribution:    make(map[string]int64),
+		SubtypeDistribution: make(map[string]int64),
+	}
+	daily := make(map[string]int64)
+	for _, item := range r.userItems(userID, nil, nil) {
+		stats.TotalItems++
+		stats.TotalContentSize += int64(len(item.Content))
+		stats.TypeDistribution[string(item.Type)]++
+		if item.Subtype != "" {
+			stats.SubtypeDistribution[string(item.Type)+"."+string(item.Subtype)]++
+		}
+		if item.Encrypted {
+			stats.EncryptedItems++
+		}
+		if !item.Timestamp.Before(activitySince) {
+			daily[item.Timestamp.Format("2006-01-02")]++
+		}
+	}
+	stats.SyncedItems = stats.TotalItems
+
+	for date, count := range daily {
+		stats.RecentActivity = append(stats.RecentActivity, models.DailyActivity{Date: date, Count: count})
+	}
+	sort.Slice(stats.RecentActivity, func(i, j int) bool {
+		return stats.RecentActivity[i].Date > stats.RecentActivity[j].Date
+	})
+	return stats, nil
+}
+
+func (r *MemoryClipboardRepository) CreateConflict(conflict *models.SyncConflict) error {
+	conflict.BeforeCreate(nil)
+	conflict.ChannelID = r.channelID
+	if conflict.CreatedAt.IsZero() {
+		conflict.CreatedAt = time.Now()
+	}
+
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.conflicts = append(r.store.conflicts, *conflict)
+	return nil
+}
+
+func (r *MemoryClipboardRepository) ListConflicts(userID, itemID string, limit int) ([]models.SyncConflict, int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var conflicts []models.SyncConflict
+	for i := len(r.store.conflicts) - 1; i >= 0; i-- {
+		conflict := r.store.conflicts[i]
+		if r.owns(userID, conflict.UserID, conflict.ChannelID) && (itemID == "" || conflict.ItemID == itemID) {
+			conflicts = append(conflicts, conflict)
+		}
+	}
+
+	total := int64(len(conflicts))
+	if limit > 0 && limit < len(conflicts) {
+		conflicts = conflicts[:limit]
+	}
+	return conflicts, total, nil
+}
+
+func (r *MemoryClipboardRepository) SensitivePolicy(userID string) models.SensitivePolicy {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	if policy, ok := r.store.policies[userID]; ok {
+		return policy
+	}
+	return sensitive.DefaultPolicy()
+}
+
+func (r *MemoryClipboardRepository) SaveSensitivePolicy(policy *models.SensitivePolicy) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	r.store.policies[policy.UserID] = *policy
+	return nil
+}
+
+func (r *MemoryClipboardRepository) RequiresEncryption(userID string) bool {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	return r.store.e2ee[userID]
+}
+
+func (r *MemoryClipboardRepository) CountEncryptedItems(userID string, keyIDs []string) (int64, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	var count int64
+	for _, item := range r.store.items {
+		if r.owns(userID, item.UserID, item.ChannelID) && item.Encrypted && slices.Contains(keyIDs, item.KeyID) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryClipboardRepository) ReserveIdempotencyKey(userID, key, requestHash string) (*models.IdempotencyRecord, error) {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	existing, ok := r.store.idempotency[[2]string{userID, key}]
+	switch {
+	case !ok:
+		r.store.idempotency[[2]string{userID, key}] = models.IdempotencyRecord{
+			UserID:      userID,
+			Key:         key,
+			RequestHash: requestHash,
+			CreatedAt:   time.Now(),
+		}
+		return nil, nil
+	case existing.RequestHash != requestHash:
+		return nil, ErrIdempotencyKeyReused
+	case existing.StatusCode == 0:
+		return nil, ErrIdempotencyInProgress
+	}
+	return &existing, nil
+}
+
+func (r *MemoryClipboardRepository) CompleteIdempotencyKey(record *models.IdempotencyRecord) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+
+	key := [2]string{record.UserID, record.Key}
+	existing, ok := r.store.idempotency[key]
+	if !ok {
+		return nil
+	}
+	existing.StatusCode = record.StatusCode
+	existing.Mode = record.Mode
+	existing.ItemIDs = record.ItemIDs
+	existing.Failed = record.Failed
+	existing.Total = record.Total
+	r.store.idempotency[key] = existing
+	return nil
+}
+
+func (r *MemoryClipboardRepository) ReleaseIdempotencyKey(userID, key string) error {
+	r.store.mu.Lock()
+	defer r.store.mu.Unlock()
+	delete(r.store.idempotency, [2]string{userID, key})
+	return nil
+}
+
+func (r *MemoryClipboardRepository) Transaction(fn func(tx ClipboardRepository) error) error {
+	// A nested transaction behaves like a savepoint of the outer one
+	if r.savepoints == nil {
+		r.store.txMu.Lock()
+		defer r.store.txMu.Unlock()
+	}
+
+	snap := r.store.snapshot()
+	tx := &MemoryClipboardRepository{store: r.store, savepoints: make(map[string]memorySnapshot), channelID: r.channelID}
+	if err := fn(tx); err != nil {
+		r.store.restore(snap)
+		return err
+	}
+	return nil
+}
+
+func (r *MemoryClipboardRepository) SavePoint(name string) error {
+	if r.savepoints == nil {
+		return ErrNoTransaction
+	}
+	r.savepoints[name] = r.store.snapshot()
+	return nil
+}
+
+func (r *MemoryClipboardRepository) RollbackTo(name string) error {
+	snap, ok := r.savepoints[name]
+	if !ok {
+		return ErrNoTransaction
+	}
+	r.store.restore(snap)
+	return nil
+}
+
+// expired reports whether the expiry time of an item has passed
+func expired(item models.ClipboardItem, now time.Time) bool {
+	return item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+}
+
+// MemoryUserRepository is an in-memory UserRepository for tests
+type MemoryUserRepository struct {
+	mu    sync.Mutex
+	users map[string]models.User
+}
+
+// NewMemoryUserRepository returns an in-memory UserRepository holding users
+func NewMemoryUserRepository(users ...models.User) *MemoryUserRepository {
+	r := &MemoryUserRepository{users: make(map[string]models.User)}
+	for _, user := range users {
+		user.BeforeCreate(nil)
+		r.users[user.ID] = user
+	}
+	return r
+}
+
+// find returns the first user matching match. The caller must hold the lock.
+func (r *MemoryUserRepository) find(match func(models.User) bool) (models.User, error) {
+	for _, user := range r.users {
+		if match(user) {
+			return user, nil
+		}
+	}
+	return models.User{}, ErrNotFound
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryUserRepository) WithContext(ctx context.Context) UserRepository {
+	return r
+}
+
+func (r *MemoryUserRepository) CreateUser(user *models.User) error {
+	user.BeforeCreate(nil)
+	now := time.Now()
+	user.CreatedAt, user.UpdatedAt = now, now
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = *user
+	return nil
+}
+
+func (r *MemoryUserRepository) GetUser(id string) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if user, ok := r.users[id]; ok {
+		return user, nil
+	}
+	return models.User{}, ErrNotFound
+}
+
+func (r *MemoryUserRepository) FindByUsername(username string) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.find(func(u models.User) bool { return u.Username == username })
+}
+
+func (r *MemoryUserRepository) FindByEmail(email string) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.find(func(u models.User) bool { return u.Email == email })
+}
+
+func (r *MemoryUserRepository) FindByLogin(login string) (models.User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.find(func(u models.User) bool { return u.Username == login || u.Email == login })
+}
+
+func (r *MemoryUserRepository) SaveUser(user *models.User) error {
+	user.UpdatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.ID] = *user
+	return nil
+}
+
+// update applies change to a stored user
+func (r *MemoryUserRepository) update(id string, change func(*models.User)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil
+	}
+	change(&user)
+	user.UpdatedAt = time.Now()
+	r.users[id] = user
+	return nil
+}
+
+func (r *MemoryUserRepository) UpdateToken(id, token string) error {
+	return r.update(id, func(u *models.User) { u.Token = token })
+}
+
+func (r *MemoryUserRepository) UpdatePassword(id, password, salt string) error {
+	return r.update(id, func(u *models.User) {
+		u.Password = password
+		u.Salt = salt
+	})
+}
+
+func (r *MemoryUserRepository) SetE2EERequired(id string, required bool) error {
+	return r.update(id, func(u *models.User) { u.E2EERequired = required })
+}
+
+// MemoryKeyRepository is an in-memory KeyRepository for tests
+type MemoryKeyRepository struct {
+	mu   sync.Mutex
+	keys map[string]models.DeviceKey
+}
+
+// NewMemoryKeyRepository returns an empty in-memory KeyRepository
+func NewMemoryKeyRepository() *MemoryKeyRepository {
+	return &MemoryKeyRepository{keys: make(map[string]models.DeviceKey)}
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryKeyRepository) WithContext(ctx context.Context) KeyRepository {
+	return r
+}
+
+// userKeys returns the keys of a user matching keep, newest first. The caller
+// must hold the lock.
+func (r *MemoryKeyRepository) userKeys(userID string, keep func(models.DeviceKey) bool) []models.DeviceKey {
+	var keys []models.DeviceKey
+	for _, key := range r.keys {
+		if key.UserID == userID && keep(key) {
+			keys = append(keys, key)
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys
+}
+
+func (r *MemoryKeyRepository) ListKeys(userID, deviceID string, status models.KeyStatus) ([]models.DeviceKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.userKeys(userID, func(key models.DeviceKey) bool {
+		return (deviceID == "" || key.DeviceID == deviceID) && (status == "" || key.Status == status)
+	}), nil
+}
+
+func (r *MemoryKeyRepository) CountKeys(userID, keyID string, status models.KeyStatus) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return int64(len(r.userKeys(userID, func(key models.DeviceKey) bool {
+		return (keyID == "" || key.KeyID == keyID) && (status == "" || key.Status == status)
+	}))), nil
+}
+
+// create stores a new key. The caller must hold the lock.
+func (r *MemoryKeyRepository) create(key *models.DeviceKey) {
+	key.BeforeCreate(nil)
+	if key.CreatedAt.IsZero() {
+		key.CreatedAt = time.Now()
+	}
+	r.keys[key.ID] = *key
+}
+
+func (r *MemoryKeyRepository) ReplaceKey(key *models.DeviceKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, existing := range r.keys {
+		if existing.UserID == key.UserID && existing.DeviceID == key.DeviceID && existing.KeyID == key.KeyID {
+			delete(r.keys, id)
+		}
+	}
+	r.create(key)
+	return nil
+}
+
+func (r *MemoryKeyRepository) RotateKey(userID string, keys []models.DeviceKey) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var retired []string
+	now := time.Now()
+	for id, key := range r.keys {
+		if key.UserID != userID || key.Status != models.KeyStatusActive {
+			continue
+		}
+		if !slices.Contains(retired, key.KeyID) {
+			retired = append(retired, key.KeyID)
+		}
+		key.Status = models.KeyStatusRetired
+		key.RetiredAt = &now
+		r.keys[id] = key
+	}
+	for i := range keys {
+		r.create(&keys[i])
+	}
+	return retired, nil
+}
+
+func (r *MemoryKeyRepository) DeleteKey(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := r.keys[id]; !ok || key.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.keys, id)
+	return nil
+}
+
+// MemoryTransferRepository is an in-memory TransferRepository for tests,
+// transferring the items of a MemoryClipboardRepository
+type MemoryTransferRepository struct {
+	items *MemoryClipboardRepository
+}
+
+// NewMemoryTransferRepository returns a TransferRepository for the items of a MemoryClipboardRepository
+func NewMemoryTransferRepository(items *MemoryClipboardRepository) *MemoryTransferRepository {
+	return &MemoryTransferRepository{items: items}
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryTransferRepository) WithContext(ctx context.Context) TransferRepository {
+	return r
+}
+
+func (r *MemoryTransferRepository) ExportItems(userID string, batchSize int, fn func([]models.ClipboardItem) error) (int, error) {
+	r.items.store.mu.Lock()
+	items := r.items.userItems(userID, nil, func(a, b models.ClipboardItem) bool {
+		return a.ID < b.ID
+	})
+	r.items.store.mu.Unlock()
+
+	for start := 0; start < len(items); start += batchSize {
+		if err := fn(items[start:min(start+batchSize, len(items))]); err != nil {
+			return start, err
+		}
+	}
+	return len(items), nil
+}
+
+func (r *MemoryTransferRepository) ImportItems(userID string, items []models.ClipboardItem) ([]bool, error) {
+	r.items.store.mu.Lock()
+	defer r.items.store.mu.Unlock()
+
+	stored := make([]bool, len(items))
+	for i, item := range items {
+		duplicate := false
+		for _, existing := range r.items.store.items {
+			if existing.UserID != userID || existing.ChannelID != "" {
+				continue
+			}
+			if item.ClientID != "" && existing.ClientID == item.ClientID ||
+				item.ClientID == "" && existing.Content == item.Content && existing.Encrypted == item.Encrypted {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			continue
+		}
+
+		item.UserID = userID
+		item.BeforeCreate(nil)
+		r.items.store.items[item.ID] = item
+		stored[i] = true
+	}
+	return stored, nil
+}
+
+// MemoryChannelRepository is an in-memory ChannelRepository for tests.
+// Deleting a channel does not delete its items, which are stored by a
+// ClipboardRepository.
+type MemoryChannelRepository struct {
+	mu          sync.Mutex
+	channels    map[string]models.Channel
+	members     map[[2]string]models.ChannelMember
+	invitations map[string]models.ChannelInvitation
+}
+
+// NewMemoryChannelRepository returns an empty in-memory ChannelRepository
+func NewMemoryChannelRepository() *MemoryChannelRepository {
+	return &MemoryChannelRepository{
+		channels:    make(map[string]models.Channel),
+		members:     make(map[[2]string]models.ChannelMember),
+		invitations: make(map[string]models.ChannelInvitation),
+	}
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryChannelRepository) WithContext(ctx context.Context) ChannelRepository {
+	return r
+}
+
+func (r *MemoryChannelRepository) CreateChannel(channel *models.Channel) error {
+	channel.BeforeCreate(nil)
+	now := time.Now()
+	channel.CreatedAt, channel.UpdatedAt = now, now
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[channel.ID] = *channel
+	r.members[[2]string{channel.ID, channel.CreatedBy}] = models.ChannelMember{
+		ChannelID: channel.ID,
+		UserID:    channel.CreatedBy,
+		Role:      models.ChannelRoleOwner,
+		CreatedAt: now,
+	}
+	return nil
+}
+
+func (r *MemoryChannelRepository) GetChannel(id string) (models.Channel, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if channel, ok := r.channels[id]; ok {
+		return channel, nil
+	}
+	return models.Channel{}, ErrNotFound
+}
+
+func (r *MemoryChannelRepository) ListChannels(userID string) ([]models.ChannelMembership, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var memberships []models.ChannelMembership
+	for key, member := range r.members {
+		if key[1] == userID {
+			memberships = append(memberships, models.ChannelMembership{Channel: r.channels[key[0]], Role: member.Role})
+		}
+	}
+	sort.Slice(memberships, func(i, j int) bool { return memberships[i].Name < memberships[j].Name })
+	return memberships, nil
+}
+
+func (r *MemoryChannelRepository) UpdateChannel(channel *models.Channel) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.channels[channel.ID]
+	if !ok {
+		return nil
+	}
+	stored.Name = channel.Name
+	stored.Description = channel.Description
+	stored.UpdatedAt = time.Now()
+	r.channels[channel.ID] = stored
+	*channel = stored
+	return nil
+}
+
+func (r *MemoryChannelRepository) DeleteChannel(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.channels[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.channels, id)
+	for key := range r.members {
+		if key[0] == id {
+			delete(r.members, key)
+		}
+	}
+	for invitationID, invitation := range r.invitations {
+		if invitation.ChannelID == id {
+			delete(r.invitations, invitationID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryChannelRepository) Member(channelID, userID string) (models.ChannelMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if member, ok := r.members[[2]string{channelID, userID}]; ok {
+		return member, nil
+	}
+	return models.ChannelMember{}, ErrNotFound
+}
+
+func (r *MemoryChannelRepository) Members(channelID string) ([]models.ChannelMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var members []models.ChannelMember
+	for key, member := range r.members {
+		if key[0] == channelID {
+			members = append(members, member)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].CreatedAt.Before(members[j].CreatedAt) })
+	return members, nil
+}
+
+// changeMember applies change to a member, unless it leaves the channel
+// without an owner. The caller must hold the lock.
+func (r *MemoryChannelRepository) changeMember(channelID, userID string, stillOwner bool, change func(key [2]string, member models.ChannelMember)) error {
+	key := [2]string{channelID, userID}
+	member, ok := r.members[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if member.Role == models.ChannelRoleOwner && !stillOwner {
+		owners := 0
+		for k, m := range r.members {
+			if k[0] == channelID && m.Role == models.ChannelRoleOwner {
+				owners++
+			}
+		}
+		if owners <= 1 {
+			return ErrLastOwner
+		}
+	}
+	change(key, member)
+	return nil
+}
+
+func (r *MemoryChannelRepository) SetRole(channelID, userID string, role models.ChannelRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.changeMember(channelID, userID, role == models.ChannelRoleOwner, func(key [2]string, member models.ChannelMember) {
+		member.Role = role
+		r.members[key] = member
+	})
+}
+
+func (r *MemoryChannelRepository) RemoveMember(channelID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.changeMember(channelID, userID, false, func(key [2]string, member models.ChannelMember) {
+		delete(r.members, key)
+	})
+}
+
+func (r *MemoryChannelRepository) CreateInvitation(invitation *models.ChannelInvitation) error {
+	invitation.BeforeCreate(nil)
+	invitation.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.members[[2]string{invitation.ChannelID, invitation.InviteeID}]; ok {
+		return ErrAlreadyMember
+	}
+	for id, pending := range r.invitations {
+		if pending.ChannelID == invitation.ChannelID && pending.InviteeID == invitation.InviteeID {
+			delete(r.invitations, id)
+		}
+	}
+	r.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+// pendingInvitations returns the unexpired invitations matching keep, newest
+// first. The caller must hold the lock.
+func (r *MemoryChannelRepository) pendingInvitations(keep func(models.ChannelInvitation) bool) []models.ChannelInvitation {
+	now := time.Now()
+	var invitations []models.ChannelInvitation
+	for _, invitation := range r.invitations {
+		if invitation.ExpiresAt.After(now) && keep(invitation) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].CreatedAt.After(invitations[j].CreatedAt) })
+	return invitations
+}
+
+func (r *MemoryChannelRepository) GetInvitation(id string) (models.ChannelInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	invitations := r.pendingInvitations(func(i models.ChannelInvitation) bool { return i.ID == id })
+	if len(invitations) == 0 {
+		return models.ChannelInvitation{}, ErrNotFound
+	}
+	return invitations[0], nil
+}
+
+func (r *MemoryChannelRepository) ChannelInvitations(channelID string) ([]models.ChannelInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pendingInvitations(func(i models.ChannelInvitation) bool { return i.ChannelID == channelID }), nil
+}
+
+func (r *MemoryChannelRepository) UserInvitations(userID string) ([]models.ChannelInvitation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pendingInvitations(func(i models.ChannelInvitation) bool { return i.InviteeID == userID }), nil
+}
+
+func (r *MemoryChannelRepository) AcceptInvitation(invitation models.ChannelInvitation) (models.ChannelMember, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invitations[invitation.ID]; !ok {
+		return models.ChannelMember{}, ErrNotFound
+	}
+	key := [2]string{invitation.ChannelID, invitation.InviteeID}
+	if _, ok := r.members[key]; ok {
+		return models.ChannelMember{}, ErrAlreadyMember
+	}
+	delete(r.invitations, invitation.ID)
+
+	member := models.ChannelMember{
+		ChannelID: invitation.ChannelID,
+		UserID:    invitation.InviteeID,
+		Role:      invitation.Role,
+		CreatedAt: time.Now(),
+	}
+	r.members[key] = member
+	return member, nil
+}
+
+func (r *MemoryChannelRepository) DeleteInvitation(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.invitations[id]; !ok {
+		return ErrNotFound
+	}
+	delete(r.invitations, id)
+	return nil
+}
+
+// MemoryShareRepository is an in-memory ShareRepository for tests.
+// Deleting an item does not delete its share links.
+type MemoryShareRepository struct {
+	mu     sync.Mutex
+	shares map[string]models.ShareLink
+}
+
+// NewMemoryShareRepository returns an empty in-memory ShareRepository
+func NewMemoryShareRepository() *MemoryShareRepository {
+	return &MemoryShareRepository{shares: make(map[string]models.ShareLink)}
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryShareRepository) WithContext(ctx context.Context) ShareRepository {
+	return r
+}
+
+func (r *MemoryShareRepository) CreateShare(share *models.ShareLink) error {
+	if err := share.BeforeCreate(nil); err != nil {
+		return err
+	}
+	share.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.shares[share.ID] = *share
+	return nil
+}
+
+func (r *MemoryShareRepository) FindByToken(token string) (models.ShareLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, share := range r.shares {
+		if share.Token == token {
+			return share, nil
+		}
+	}
+	return models.ShareLink{}, ErrNotFound
+}
+
+func (r *MemoryShareRepository) ListShares(userID, itemID string) ([]models.ShareLink, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var shares []models.ShareLink
+	for _, share := range r.shares {
+		if share.UserID == userID && (itemID == "" || share.ItemID == itemID) {
+			shares = append(shares, share)
+		}
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].CreatedAt.After(shares[j].CreatedAt) })
+	return shares, nil
+}
+
+func (r *MemoryShareRepository) DeleteShare(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if share, ok := r.shares[id]; !ok || share.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.shares, id)
+	return nil
+}
+
+func (r *MemoryShareRepository) RecordView(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	share, ok := r.shares[id]
+	if !ok || share.Exhausted() {
+		return ErrViewLimitReached
+	}
+	share.Views++
+	r.shares[id] = share
+	return nil
+}
+
+// MemoryWebhookRepository is an in-memory WebhookRepository for tests
+type MemoryWebhookRepository struct {
+	mu         sync.Mutex
+	webhooks   map[string]models.Webhook
+	deliveries map[string]models.WebhookDelivery
+}
+
+// NewMemoryWebhookRepository returns an empty in-memory WebhookRepository
+func NewMemoryWebhookRepository() *MemoryWebhookRepository {
+	return &MemoryWebhookRepository{
+		webhooks:   make(map[string]models.Webhook),
+		deliveries: make(map[string]models.WebhookDelivery),
+	}
+}
+
+// WithContext returns the repository itself, the in-memory store ignores contexts
+func (r *MemoryWebhookRepository) WithContext(ctx context.Context) WebhookRepository {
+	return r
+}
+
+func (r *MemoryWebhookRepository) CreateWebhook(webhook *models.Webhook) error {
+	webhook.BeforeCreate(nil)
+	now := time.Now()
+	webhook.CreatedAt, webhook.UpdatedAt = now, now
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetWebhook(userID, id string) (models.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if webhook, ok := r.webhooks[id]; ok && webhook.UserID == userID {
+		return webhook, nil
+	}
+	return models.Webhook{}, ErrNotFound
+}
+
+// findWebhooks returns the webhooks matching keep, oldest first
+func (r *MemoryWebhookRepository) findWebhooks(keep func(models.Webhook) bool) []models.Webhook {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var webhooks []models.Webhook
+	for _, webhook := range r.webhooks {
+		if keep(webhook) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	sort.Slice(webhooks, func(i, j int) bool { return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt) })
+	return webhooks
+}
+
+func (r *MemoryWebhookRepository) ListWebhooks(userID string) ([]models.Webhook, error) {
+	return r.findWebhooks(func(w models.Webhook) bool { return w.UserID == userID }), nil
+}
+
+func (r *MemoryWebhookRepository) ActiveWebhooks(userID string) ([]models.Webhook, error) {
+	return r.findWebhooks(func(w models.Webhook) bool { return w.UserID == userID && w.Active }), nil
+}
+
+func (r *MemoryWebhookRepository) SaveWebhook(webhook *models.Webhook) error {
+	webhook.UpdatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.webhooks[webhook.ID] = *webhook
+	return nil
+}
+
+func (r *MemoryWebhookRepository) DeleteWebhook(userID, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if webhook, ok := r.webhooks[id]; !ok || webhook.UserID != userID {
+		return ErrNotFound
+	}
+	delete(r.webhooks, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.WebhookID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) CreateDeliveries(deliveries []models.WebhookDelivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	for i := range deliveries {
+		deliveries[i].BeforeCreate(nil)
+		deliveries[i].CreatedAt, deliveries[i].UpdatedAt = now, now
+		r.deliveries[deliveries[i].ID] = deliveries[i]
+	}
+	return nil
+}
+
+func (r *MemoryWebhookRepository) GetDelivery(webhookID, id string) (models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if delivery, ok := r.deliveries[id]; ok && delivery.WebhookID == webhookID {
+		return delivery, nil
+	}
+	return models.WebhookDelivery{}, ErrNotFound
+}
+
+func (r *MemoryWebhookRepository) ListDeliveries(webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if delivery.WebhookID == webhookID && (status == "" || delivery.Status == status) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt) })
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// due reports whether a delivery should be attempted at now
+func (r *MemoryWebhookRepository) due(delivery models.WebhookDelivery, now time.Time) bool {
+	return delivery.Status == models.WebhookDeliveryPending &&
+		delivery.NextAttemptAt != nil && !delivery.NextAttemptAt.After(now)
+}
+
+func (r *MemoryWebhookRepository) DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deliveries []models.WebhookDelivery
+	for _, delivery := range r.deliveries {
+		if r.due(delivery, now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].NextAttemptAt.Before(*deliveries[j].NextAttemptAt) })
+	if limit > 0 && len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (r *MemoryWebhookRepository) ClaimDelivery(id string, now, until time.Time) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok || !r.due(delivery, now) {
+		return false, nil
+	}
+	delivery.NextAttemptAt = &until
+	r.deliveries[id] = delivery
+	return true, nil
+}
+
+func (r *MemoryWebhookRepository) SaveDelivery(delivery *models.WebhookDelivery) error {
+	delivery.UpdatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
@@ -0,0 +1,272 @@
+// Package repository hides the queries of the HTTP handlers behind interfaces,
+// so that handlers get their storage injected instead of using database.DB.
+// GORM implementations are used by the server, in-memory fakes by tests.
+package repository
+
+import (
+	"clipboard-server/database"
+	"clipboard-server/models"
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrNotFound the requested record does not exist
+	ErrNotFound = errors.New("record not found")
+
+	// ErrVersionConflict the item was modified after it was read
+	ErrVersionConflict = database.ErrVersionConflict
+	// ErrIdempotencyKeyReused the key was already used for a different request
+	ErrIdempotencyKeyReused = database.ErrIdempotencyKeyReused
+	// ErrIdempotencyInProgress a request with the same key is still being processed
+	ErrIdempotencyInProgress = database.ErrIdempotencyInProgress
+
+	// ErrNoTransaction a savepoint was requested outside of a transaction
+	ErrNoTransaction = errors.New("savepoints require a transaction")
+
+	// ErrLastOwner the change would leave a channel without an owner
+	ErrLastOwner = errors.New("a channel must keep at least one owner")
+	// ErrAlreadyMember the user is already a member of the channel
+	ErrAlreadyMember = errors.New("user is already a member of the channel")
+
+	// ErrViewLimitReached the share link was opened as often as it allows
+	ErrViewLimitReached = errors.New("share link view limit reached")
+)
+
+// ItemFilter selects the clipboard items returned by ListItems
+type ItemFilter struct {
+	Since     *time.Time // items with a timestamp at or after Since
+	Type      models.ClipboardType
+	Subtype   models.ClipboardSubtype
+	Encrypted *bool
+	Search    string // case-insensitive content search, plaintext items only
+
+	Offset int
+	Limit  int
+}
+
+// ClipboardRepository stores the clipboard items of users and the records kept
+// alongside them. Reads of items skip expired items unless stated otherwise.
+type ClipboardRepository interface {
+	// WithContext returns a repository whose queries run with ctx, so that they
+	// are cancelled and traced with the request
+	WithContext(ctx context.Context) ClipboardRepository
+	// InChannel returns a repository for the shared clipboard of a channel. Its
+	// queries select the items, deletions and conflicts of every member; the
+	// userID arguments only identify the acting member.
+	InChannel(channelID string) ClipboardRepository
+
+	// CreateItem stores a new item, assigning its ID and version
+	CreateItem(item *models.ClipboardItem) error
+	// GetItem returns an item of the user, or ErrNotFound
+	GetItem(userID, id string) (models.ClipboardItem, error)
+	// FindByClientID returns the unexpired item the user uploaded under a
+	// client ID, or ErrNotFound
+	FindByClientID(userID, clientID string) (models.ClipboardItem, error)
+	// ListItems returns a page of the user's items, newest first, and the total count
+	ListItems(userID string, filter ItemFilter) ([]models.ClipboardItem, int64, error)
+	// RecentItems returns the most recently created items and the total count
+	RecentItems(userID string, limit int) ([]models.ClipboardItem, int64, error)
+	// LatestItem returns the most recently updated item, or ErrNotFound
+	LatestItem(userID string) (models.ClipboardItem, error)
+	// ItemsByID loads items of the user in the order of ids, skipping deleted ones
+	ItemsByID(userID string, ids []string) ([]models.ClipboardItem, error)
+	// SaveItem writes an existing item if it is still at the version it was read at,
+	// and increments its version. It returns ErrVersionConflict otherwise.
+	SaveItem(item *models.ClipboardItem) error
+	// DeleteItem deletes an item of the user, including an expired one, and records
+	// the deletion. It returns ErrNotFound when the user has no such item.
+	DeleteItem(userID, id string) ([]models.DeletedItem, error)
+	// DeletedSince returns the items of a user deleted after since, oldest first
+	DeletedSince(userID string, since time.Time, limit int) ([]models.DeletedItem, error)
+	// Statistics summarizes the user's items, with the daily activity since the given time
+	Statistics(userID string, activitySince time.Time) (models.StatisticsResponse, error)
+
+	// CreateConflict adds a conflict to the user's conflict log
+	CreateConflict(conflict *models.SyncConflict) error
+	// ListConflicts returns the newest conflicts of the user, optionally of one item,
+	// and the total count
+	ListConflicts(userID, itemID string, limit int) ([]models.SyncConflict, int64, error)
+
+	// SensitivePolicy returns the user's sensitive content policy, or the default policy
+	SensitivePolicy(userID string) models.SensitivePolicy
+	// SaveSensitivePolicy stores the sensitive content policy of a user
+	SaveSensitivePolicy(policy *models.SensitivePolicy) error
+	// RequiresEncryption reports whether the user only accepts end-to-end encrypted items
+	RequiresEncryption(userID string) bool
+	// CountEncryptedItems counts the user's end-to-end encrypted items under one of keyIDs
+	CountEncryptedItems(userID string, keyIDs []string) (int64, error)
+
+	// ReserveIdempotencyKey claims an idempotency key for a request. It returns the
+	// stored record when the same request already completed, or nil when the caller
+	// now owns the key and must complete or release it.
+	ReserveIdempotencyKey(userID, key, requestHash string) (*models.IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the outcome of the request owning the key
+	CompleteIdempotencyKey(record *models.IdempotencyRecord) error
+	// ReleaseIdempotencyKey frees a key whose request failed, so it can be retried
+	ReleaseIdempotencyKey(userID, key string) error
+
+	// Transaction runs fn with a repository bound to a single transaction, which is
+	// rolled back when fn returns an error
+	Transaction(fn func(tx ClipboardRepository) error) error
+	// SavePoint marks a point of the current transaction to roll back to
+	SavePoint(name string) error
+	// RollbackTo undoes the changes made after the savepoint
+	RollbackTo(name string) error
+}
+
+// UserRepository stores user accounts
+type UserRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) UserRepository
+
+	// CreateUser stores a new user, assigning its ID
+	CreateUser(user *models.User) error
+	// GetUser returns a user by ID, or ErrNotFound
+	GetUser(id string) (models.User, error)
+	// FindByUsername returns a user by username, or ErrNotFound
+	FindByUsername(username string) (models.User, error)
+	// FindByEmail returns a user by email, or ErrNotFound
+	FindByEmail(email string) (models.User, error)
+	// FindByLogin returns the user whose username or email is login, or ErrNotFound
+	FindByLogin(login string) (models.User, error)
+	// SaveUser writes all fields of an existing user
+	SaveUser(user *models.User) error
+	// UpdateToken replaces the stored token of a user
+	UpdateToken(id, token string) error
+	// UpdatePassword replaces the password hash and salt of a user
+	UpdatePassword(id, password, salt string) error
+	// SetE2EERequired sets whether a user only accepts end-to-end encrypted items
+	SetE2EERequired(id string, required bool) error
+}
+
+// KeyRepository stores the keys of users wrapped for their devices
+type KeyRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) KeyRepository
+
+	// ListKeys returns the keys of a user, newest first, optionally only those
+	// of a device or with a status
+	ListKeys(userID, deviceID string, status models.KeyStatus) ([]models.DeviceKey, error)
+	// CountKeys counts the keys of a user, optionally only those with a key ID or a status
+	CountKeys(userID, keyID string, status models.KeyStatus) (int64, error)
+	// ReplaceKey stores a key, replacing the copy of the same key wrapped for the device
+	ReplaceKey(key *models.DeviceKey) error
+	// RotateKey retires the active keys of a user and stores the new keys in a
+	// single transaction. It returns the IDs of the retired keys.
+	RotateKey(userID string, keys []models.DeviceKey) ([]string, error)
+	// DeleteKey deletes a key of the user, or returns ErrNotFound
+	DeleteKey(userID, id string) error
+}
+
+// TransferRepository reads and writes clipboard history in bulk for exports
+// and imports. Only the personal clipboard of a user is transferred.
+type TransferRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) TransferRepository
+
+	// ExportItems calls fn with the unexpired items of a user, batchSize at a
+	// time, and returns the number of items
+	ExportItems(userID string, batchSize int, fn func([]models.ClipboardItem) error) (int, error)
+	// ImportItems stores new items of a user in a single transaction. Items the
+	// user already has, by client ID or else by content, are skipped. It
+	// reports for each item whether it was stored.
+	ImportItems(userID string, items []models.ClipboardItem) ([]bool, error)
+}
+
+// ChannelRepository stores shared channels, their members and invitations
+type ChannelRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) ChannelRepository
+
+	// CreateChannel stores a new channel with its creator as owner
+	CreateChannel(channel *models.Channel) error
+	// GetChannel returns a channel by ID, or ErrNotFound
+	GetChannel(id string) (models.Channel, error)
+	// ListChannels returns the channels of a user with the user's role, by name
+	ListChannels(userID string) ([]models.ChannelMembership, error)
+	// UpdateChannel writes the name and description of a channel
+	UpdateChannel(channel *models.Channel) error
+	// DeleteChannel deletes a channel with its members, invitations and items
+	DeleteChannel(id string) error
+
+	// Member returns the membership of a user in a channel, or ErrNotFound
+	Member(channelID, userID string) (models.ChannelMember, error)
+	// Members returns the members of a channel, oldest first
+	Members(channelID string) ([]models.ChannelMember, error)
+	// SetRole changes the role of a member. It returns ErrNotFound when the user
+	// is not a member and ErrLastOwner when the channel would have no owner left.
+	SetRole(channelID, userID string, role models.ChannelRole) error
+	// RemoveMember removes a user from a channel, with the same errors as SetRole
+	RemoveMember(channelID, userID string) error
+
+	// CreateInvitation stores an invitation, replacing a pending invitation of
+	// the same user to the channel. It returns ErrAlreadyMember for members.
+	CreateInvitation(invitation *models.ChannelInvitation) error
+	// GetInvitation returns an unexpired invitation, or ErrNotFound
+	GetInvitation(id string) (models.ChannelInvitation, error)
+	// ChannelInvitations returns the unexpired invitations to a channel
+	ChannelInvitations(channelID string) ([]models.ChannelInvitation, error)
+	// UserInvitations returns the unexpired invitations of a user
+	UserInvitations(userID string) ([]models.ChannelInvitation, error)
+	// AcceptInvitation adds the invitee to the channel and deletes the invitation
+	AcceptInvitation(invitation models.ChannelInvitation) (models.ChannelMember, error)
+	// DeleteInvitation deletes an invitation, or returns ErrNotFound
+	DeleteInvitation(id string) error
+}
+
+// ShareRepository stores the public share links of clipboard items
+type ShareRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) ShareRepository
+
+	// CreateShare stores a new share link, assigning its ID and token
+	CreateShare(share *models.ShareLink) error
+	// FindByToken returns the share link with token, including an expired or
+	// exhausted one, or ErrNotFound
+	FindByToken(token string) (models.ShareLink, error)
+	// ListShares returns the share links of a user, optionally of one item, newest first
+	ListShares(userID, itemID string) ([]models.ShareLink, error)
+	// DeleteShare revokes a share link of the user, or returns ErrNotFound
+	DeleteShare(userID, id string) error
+	// RecordView counts one view of a share link, or returns ErrViewLimitReached
+	// when it has no views left
+	RecordView(id string) error
+}
+
+// WebhookRepository stores the webhooks of users and the queue of their deliveries
+type WebhookRepository interface {
+	// WithContext returns a repository whose queries run with ctx
+	WithContext(ctx context.Context) WebhookRepository
+
+	// CreateWebhook stores a new webhook, assigning its ID
+	CreateWebhook(webhook *models.Webhook) error
+	// GetWebhook returns a webhook of the user, or ErrNotFound
+	GetWebhook(userID, id string) (models.Webhook, error)
+	// ListWebhooks returns the webhooks of a user, oldest first
+	ListWebhooks(userID string) ([]models.Webhook, error)
+	// ActiveWebhooks returns the active webhooks of a user
+	ActiveWebhooks(userID string) ([]models.Webhook, error)
+	// SaveWebhook writes all fields of an existing webhook
+	SaveWebhook(webhook *models.Webhook) error
+	// DeleteWebhook deletes a webhook of the user with its deliveries, or
+	// returns ErrNotFound
+	DeleteWebhook(userID, id string) error
+
+	// CreateDeliveries queues deliveries
+	CreateDeliveries(deliveries []models.WebhookDelivery) error
+	// GetDelivery returns a delivery of a webhook, or ErrNotFound
+	GetDelivery(webhookID, id string) (models.WebhookDelivery, error)
+	// ListDeliveries returns the newest deliveries of a webhook, optionally
+	// only those with status
+	ListDeliveries(webhookID string, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error)
+	// DueDeliveries returns pending deliveries whose next attempt is due at now, oldest first
+	DueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	// ClaimDelivery postpones the next attempt of a due delivery to until, so
+	// that other servers sharing the database don't send it meanwhile. It
+	// reports false when the delivery is no longer due.
+	ClaimDelivery(id string, now, until time.Time) (bool, error)
+	// SaveDelivery writes all fields of an existing delivery
+	SaveDelivery(delivery *models.WebhookDelivery) error
+}
@@ -0,0 +1,514 @@
+package repository
+
+import (
+	"clipboard-server/database/dbtest"
+	"clipboard-server/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+// forEachClipboardRepository 对 GORM 实现和内存实现运行相同的测试
+func forEachClipboardRepository(t *testing.T, test func(t *testing.T, repo ClipboardRepository)) {
+	t.Run("gorm", func(t *testing.T) {
+		test(t, NewClipboardRepository(dbtest.Open(t)))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryClipboardRepository())
+	})
+}
+
+func TestClipboardItems(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		past := time.Now().Add(-time.Hour)
+		items := []models.ClipboardItem{
+			{UserID: "alice", ClientID: "c-1", Content: "Hello World", Type: models.ClipboardTypeText, Timestamp: past},
+			{UserID: "alice", Content: "image data", Type: models.ClipboardTypeImage, Subtype: models.ClipboardSubtypeImage, Timestamp: past.Add(time.Minute)},
+			{UserID: "alice", ClientID: "c-3", Content: "expired hello", Type: models.ClipboardTypeText, ExpiresAt: &past},
+			{UserID: "bob", Content: "hello bob", Type: models.ClipboardTypeText},
+		}
+		for i := range items {
+			if err := repo.CreateItem(&items[i]); err != nil {
+				t.Fatalf("创建项目失败: %v", err)
+			}
+		}
+		if items[0].ID == "" || items[0].Version != 1 {
+			t.Fatalf("新项目应分配 ID 和版本 1，实际得到 %q 版本 %d", items[0].ID, items[0].Version)
+		}
+
+		if _, err := repo.GetItem("bob", items[0].ID); err != ErrNotFound {
+			t.Errorf("不应读取其他用户的项目，实际错误: %v", err)
+		}
+		if _, err := repo.GetItem("alice", items[2].ID); err != ErrNotFound {
+			t.Errorf("过期项目不应可读，实际错误: %v", err)
+		}
+		if _, err := repo.FindByClientID("alice", "c-3"); err != ErrNotFound {
+			t.Errorf("按客户端ID查找不应返回过期项目，实际错误: %v", err)
+		}
+
+		list, total, err := repo.ListItems("alice", ItemFilter{Search: "HELLO"})
+		if err != nil || total != 1 || list[0].ID != items[0].ID {
+			t.Errorf("搜索应不区分大小写并跳过过期项目，实际得到 %d 条, 错误: %v", total, err)
+		}
+		list, total, _ = repo.ListItems("alice", ItemFilter{Limit: 1})
+		if total != 2 || len(list) != 1 || list[0].ID != items[1].ID {
+			t.Errorf("期望按时间倒序分页，共 2 条，实际得到 %d 条 %+v", total, list)
+		}
+		if _, total, _ = repo.ListItems("alice", ItemFilter{Type: models.ClipboardTypeImage}); total != 1 {
+			t.Errorf("按类型过滤应得到 1 条，实际得到 %d", total)
+		}
+		if _, total, _ = repo.ListItems("alice", ItemFilter{Subtype: models.ClipboardSubtypeImage}); total != 1 {
+			t.Errorf("按子类型过滤应得到 1 条，实际得到 %d", total)
+		}
+
+		stats, err := repo.Statistics("alice", past.Add(-time.Minute))
+		if err != nil || stats.TotalItems != 2 || stats.TypeDistribution["image"] != 1 ||
+			len(stats.TypeDistribution) != 2 || stats.SubtypeDistribution["image.image"] != 1 {
+			t.Errorf("统计不正确: %+v, 错误: %v", stats, err)
+		}
+	})
+}
+
+func TestSaveItemVersion(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		item := models.ClipboardItem{UserID: "alice", Content: "v1"}
+		repo.CreateItem(&item)
+
+		stale := item
+		item.Content = "v2"
+		if err := repo.SaveItem(&item); err != nil || item.Version != 2 {
+			t.Fatalf("保存应成功并递增版本，实际版本 %d, 错误: %v", item.Version, err)
+		}
+
+		stale.Content = "stale"
+		if err := repo.SaveItem(&stale); err != ErrVersionConflict || stale.Version != 1 {
+			t.Errorf("旧版本保存应返回冲突且不修改版本，实际版本 %d, 错误: %v", stale.Version, err)
+		}
+
+		if current, _ := repo.GetItem("alice", item.ID); current.Content != "v2" {
+			t.Errorf("期望内容 v2，实际得到 %q", current.Content)
+		}
+	})
+}
+
+func TestDeleteItem(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		item := models.ClipboardItem{UserID: "alice", ClientID: "c-1", Content: "hello"}
+		repo.CreateItem(&item)
+		since := time.Now().Add(-time.Minute)
+
+		if _, err := repo.DeleteItem("bob", item.ID); err != ErrNotFound {
+			t.Errorf("不应删除其他用户的项目，实际错误: %v", err)
+		}
+		deleted, err := repo.DeleteItem("alice", item.ID)
+		if err != nil || len(deleted) != 1 || deleted[0].ClientID != "c-1" {
+			t.Fatalf("删除应返回删除记录，实际得到 %+v, 错误: %v", deleted, err)
+		}
+		if _, err := repo.DeleteItem("alice", item.ID); err != ErrNotFound {
+			t.Errorf("重复删除应返回 ErrNotFound，实际错误: %v", err)
+		}
+
+		records, _ := repo.DeletedSince("alice", since, 10)
+		if len(records) != 1 || records[0].Reason != models.DeletionReasonDeleted {
+			t.Errorf("期望 1 条删除记录，实际得到 %+v", records)
+		}
+	})
+}
+
+func TestTransaction(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		errAbort := errors.New("abort")
+
+		err := repo.Transaction(func(tx ClipboardRepository) error {
+			tx.CreateItem(&models.ClipboardItem{UserID: "alice", ClientID: "kept", Content: "kept"})
+			if err := tx.SavePoint("sp"); err != nil {
+				return err
+			}
+			tx.CreateItem(&models.ClipboardItem{UserID: "alice", ClientID: "undone", Content: "undone"})
+			return tx.RollbackTo("sp")
+		})
+		if err != nil {
+			t.Fatalf("事务失败: %v", err)
+		}
+
+		err = repo.Transaction(func(tx ClipboardRepository) error {
+			tx.CreateItem(&models.ClipboardItem{UserID: "alice", ClientID: "aborted", Content: "aborted"})
+			return errAbort
+		})
+		if err != errAbort {
+			t.Errorf("期望返回事务函数的错误，实际得到 %v", err)
+		}
+
+		for clientID, want := range map[string]bool{"kept": true, "undone": false, "aborted": false} {
+			if _, err := repo.FindByClientID("alice", clientID); (err == nil) != want {
+				t.Errorf("项目 %s 是否存在应为 %v，实际错误: %v", clientID, want, err)
+			}
+		}
+	})
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		if record, err := repo.ReserveIdempotencyKey("alice", "key", "hash"); record != nil || err != nil {
+			t.Fatalf("首次使用应获得幂等键，实际得到 %+v, 错误: %v", record, err)
+		}
+		if _, err := repo.ReserveIdempotencyKey("alice", "key", "hash"); err != ErrIdempotencyInProgress {
+			t.Errorf("处理中的幂等键应返回 ErrIdempotencyInProgress，实际得到 %v", err)
+		}
+		if _, err := repo.ReserveIdempotencyKey("alice", "key", "other"); err != ErrIdempotencyKeyReused {
+			t.Errorf("不同请求复用幂等键应返回 ErrIdempotencyKeyReused，实际得到 %v", err)
+		}
+
+		repo.CompleteIdempotencyKey(&models.IdempotencyRecord{UserID: "alice", Key: "key", StatusCode: 200, ItemIDs: []string{"a"}})
+		record, err := repo.ReserveIdempotencyKey("alice", "key", "hash")
+		if err != nil || record == nil || record.StatusCode != 200 || len(record.ItemIDs) != 1 {
+			t.Errorf("完成后应返回保存的结果，实际得到 %+v, 错误: %v", record, err)
+		}
+
+		repo.ReleaseIdempotencyKey("alice", "key")
+		if record, err := repo.ReserveIdempotencyKey("alice", "key", "other"); record != nil || err != nil {
+			t.Errorf("释放后幂等键应可重新使用，实际得到 %+v, 错误: %v", record, err)
+		}
+	})
+}
+
+func TestChannelScope(t *testing.T) {
+	forEachClipboardRepository(t, func(t *testing.T, repo ClipboardRepository) {
+		team := repo.InChannel("team")
+		personal := models.ClipboardItem{UserID: "alice", ClientID: "c-1", Content: "personal"}
+		shared := models.ClipboardItem{UserID: "alice", ClientID: "c-1", Content: "shared"}
+		repo.CreateItem(&personal)
+		team.CreateItem(&shared)
+		if shared.ChannelID != "team" {
+			t.Fatalf("频道项目应记录频道ID，实际得到 %q", shared.ChannelID)
+		}
+
+		if _, total, _ := repo.ListItems("alice", ItemFilter{}); total != 1 {
+			t.Errorf("个人剪贴板不应包含频道项目，实际得到 %d 条", total)
+		}
+		if item, err := team.GetItem("bob", shared.ID); err != nil || item.UserID != "alice" {
+			t.Errorf("频道成员应读取其他成员的项目，实际错误: %v", err)
+		}
+		if _, err := team.GetItem("alice", personal.ID); err != ErrNotFound {
+			t.Errorf("频道中不应读取个人项目，实际错误: %v", err)
+		}
+		if _, err := repo.InChannel("other").GetItem("alice", shared.ID); err != ErrNotFound {
+			t.Errorf("不应读取其他频道的项目，实际错误: %v", err)
+		}
+		if item, err := team.FindByClientID("bob", "c-1"); err != nil || item.ID != shared.ID {
+			t.Errorf("客户端ID应在频道内查找，实际得到 %q, 错误: %v", item.ID, err)
+		}
+
+		since := time.Now().Add(-time.Minute)
+		if _, err := team.DeleteItem("bob", shared.ID); err != nil {
+			t.Fatalf("频道成员应能删除项目: %v", err)
+		}
+		if records, _ := team.DeletedSince("carol", since, 10); len(records) != 1 || records[0].ChannelID != "team" {
+			t.Errorf("频道删除记录应对所有成员可见，实际得到 %+v", records)
+		}
+		if records, _ := repo.DeletedSince("alice", since, 10); len(records) != 0 {
+			t.Errorf("个人删除记录不应包含频道项目，实际得到 %+v", records)
+		}
+	})
+}
+
+// forEachChannelRepository 对 GORM 实现和内存实现运行相同的频道测试
+func forEachChannelRepository(t *testing.T, test func(t *testing.T, repo ChannelRepository)) {
+	t.Run("gorm", func(t *testing.T) {
+		test(t, NewChannelRepository(dbtest.Open(t)))
+	})
+	t.Run("memory", func(t *testing.T) {
+		test(t, NewMemoryChannelRepository())
+	})
+}
+
+func TestChannelMembers(t *testing.T) {
+	forEachChannelRepository(t, func(t *testing.T, repo ChannelRepository) {
+		channel := models.Channel{Name: "team", CreatedBy: "alice"}
+		if err := repo.CreateChannel(&channel); err != nil || channel.ID == "" {
+			t.Fatalf("创建频道失败: %v", err)
+		}
+		if member, err := repo.Member(channel.ID, "alice"); err != nil || member.Role != models.ChannelRoleOwner {
+			t.Fatalf("创建者应为所有者，实际得到 %+v, 错误: %v", member, err)
+		}
+
+		invitation := models.ChannelInvitation{ChannelID: channel.ID, InviteeID: "bob", InviterID: "alice",
+			Role: models.ChannelRoleReader, ExpiresAt: time.Now().Add(time.Hour)}
+		repo.CreateInvitation(&invitation)
+		again := invitation
+		again.ID, again.Role = "", models.ChannelRoleWriter
+		if err := repo.CreateInvitation(&again); err != nil {
+			t.Fatalf("重新邀请失败: %v", err)
+		}
+		if pending, _ := repo.UserInvitations("bob"); len(pending) != 1 || pending[0].Role != models.ChannelRoleWriter {
+			t.Errorf("重新邀请应替换未处理的邀请，实际得到 %+v", pending)
+		}
+		if _, err := repo.GetInvitation(invitation.ID); err != ErrNotFound {
+			t.Errorf("被替换的邀请应不存在，实际错误: %v", err)
+		}
+
+		expired := models.ChannelInvitation{ChannelID: channel.ID, InviteeID: "carol", Role: models.ChannelRoleReader,
+			ExpiresAt: time.Now().Add(-time.Minute)}
+		repo.CreateInvitation(&expired)
+		if _, err := repo.GetInvitation(expired.ID); err != ErrNotFound {
+			t.Errorf("过期的邀请应不可用，实际错误: %v", err)
+		}
+
+		if member, err := repo.AcceptInvitation(again); err != nil || member.Role != models.ChannelRoleWriter {
+			t.Fatalf("接受邀请失败: %+v, 错误: %v", member, err)
+		}
+		if err := repo.CreateInvitation(&models.ChannelInvitation{ChannelID: channel.ID, InviteeID: "bob",
+			Role: models.ChannelRoleReader, ExpiresAt: time.Now().Add(time.Hour)}); err != ErrAlreadyMember {
+			t.Errorf("不应邀请已有成员，实际错误: %v", err)
+		}
+		if channels, _ := repo.ListChannels("bob"); len(channels) != 1 || channels[0].Name != "team" || channels[0].Role != models.ChannelRoleWriter {
+			t.Errorf("期望列出频道及角色，实际得到 %+v", channels)
+		}
+
+		if err := repo.RemoveMember(channel.ID, "alice"); err != ErrLastOwner {
+			t.Errorf("不应移除最后一个所有者，实际错误: %v", err)
+		}
+		if err := repo.SetRole(channel.ID, "alice", models.ChannelRoleReader); err != ErrLastOwner {
+			t.Errorf("不应降级最后一个所有者，实际错误: %v", err)
+		}
+		repo.SetRole(channel.ID, "bob", models.ChannelRoleOwner)
+		if err := repo.RemoveMember(channel.ID, "alice"); err != nil {
+			t.Errorf("还有其他所有者时应能移除，实际错误: %v", err)
+		}
+		if members, _ := repo.Members(channel.ID); len(members) != 1 || members[0].UserID != "bob" {
+			t.Errorf("期望只剩 bob，实际得到 %+v", members)
+		}
+
+		if err := repo.DeleteChannel(channel.ID); err != nil {
+			t.Fatalf("删除频道失败: %v", err)
+		}
+		if _, err := repo.Member(channel.ID, "bob"); err != ErrNotFound {
+			t.Errorf("删除频道后成员应被删除，实际错误: %v", err)
+		}
+	})
+}
+
+func TestShareLinks(t *testing.T) {
+	repos := map[string]ShareRepository{
+		"gorm":   NewShareRepository(dbtest.Open(t)),
+		"memory": NewMemoryShareRepository(),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			share := models.ShareLink{ItemID: "item", UserID: "alice", MaxViews: 2}
+			if err := repo.CreateShare(&share); err != nil || share.ID == "" || len(share.Token) < 40 {
+				t.Fatalf("创建分享链接失败: %+v, 错误: %v", share, err)
+			}
+			other := models.ShareLink{ItemID: "other", UserID: "alice"}
+			repo.CreateShare(&other)
+			if other.Token == share.Token {
+				t.Error("每个链接的 token 应不同")
+			}
+
+			if found, err := repo.FindByToken(share.Token); err != nil || found.ID != share.ID {
+				t.Errorf("按 token 查找失败: %+v, 错误: %v", found, err)
+			}
+			if shares, _ := repo.ListShares("alice", "item"); len(shares) != 1 || shares[0].ID != share.ID {
+				t.Errorf("期望按项目列出 1 个链接，实际得到 %+v", shares)
+			}
+
+			for i := 0; i < 2; i++ {
+				if err := repo.RecordView(share.ID); err != nil {
+					t.Fatalf("第 %d 次访问失败: %v", i+1, err)
+				}
+			}
+			if err := repo.RecordView(share.ID); err != ErrViewLimitReached {
+				t.Errorf("超过访问次数应返回 ErrViewLimitReached，实际错误: %v", err)
+			}
+			if found, _ := repo.FindByToken(share.Token); found.Views != 2 || !found.Exhausted() {
+				t.Errorf("期望访问次数为 2 且已用尽，实际得到 %+v", found)
+			}
+
+			if err := repo.DeleteShare("bob", share.ID); err != ErrNotFound {
+				t.Errorf("不应撤销其他用户的链接，实际错误: %v", err)
+			}
+			if err := repo.DeleteShare("alice", share.ID); err != nil {
+				t.Fatalf("撤销链接失败: %v", err)
+			}
+			if _, err := repo.FindByToken(share.Token); err != ErrNotFound {
+				t.Errorf("撤销后链接应不存在，实际错误: %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookDeliveries(t *testing.T) {
+	repos := map[string]WebhookRepository{
+		"gorm":   NewWebhookRepository(dbtest.Open(t)),
+		"memory": NewMemoryWebhookRepository(),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			active := models.Webhook{UserID: "alice", URL: "http://localhost/hook", Active: true}
+			paused := models.Webhook{UserID: "alice", URL: "http://localhost/paused"}
+			repo.CreateWebhook(&active)
+			repo.CreateWebhook(&paused)
+			if webhooks, _ := repo.ActiveWebhooks("alice"); len(webhooks) != 1 || webhooks[0].ID != active.ID {
+				t.Errorf("期望只返回启用的 webhook，实际得到 %+v", webhooks)
+			}
+			if _, err := repo.GetWebhook("bob", active.ID); err != ErrNotFound {
+				t.Errorf("不应读取其他用户的 webhook，实际错误: %v", err)
+			}
+
+			now := time.Now()
+			later := now.Add(time.Hour)
+			deliveries := []models.WebhookDelivery{
+				{WebhookID: active.ID, UserID: "alice", Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: &now},
+				{WebhookID: active.ID, UserID: "alice", Payload: "{}", Status: models.WebhookDeliveryPending, NextAttemptAt: &later},
+			}
+			if err := repo.CreateDeliveries(deliveries); err != nil || deliveries[0].ID == "" {
+				t.Fatalf("创建投递失败: %v", err)
+			}
+
+			due, _ := repo.DueDeliveries(now, 10)
+			if len(due) != 1 || due[0].ID != deliveries[0].ID {
+				t.Fatalf("期望 1 个到期的投递，实际得到 %+v", due)
+			}
+			if claimed, err := repo.ClaimDelivery(due[0].ID, now, later); !claimed || err != nil {
+				t.Fatalf("领取投递失败: %v", err)
+			}
+			if claimed, _ := repo.ClaimDelivery(due[0].ID, now, later); claimed {
+				t.Error("已领取的投递不应被再次领取")
+			}
+
+			delivery, _ := repo.GetDelivery(active.ID, due[0].ID)
+			delivery.Status, delivery.Attempts, delivery.NextAttemptAt = models.WebhookDeliverySucceeded, 1, nil
+			repo.SaveDelivery(&delivery)
+			if list, _ := repo.ListDeliveries(active.ID, models.WebhookDeliverySucceeded, 10); len(list) != 1 || list[0].Attempts != 1 {
+				t.Errorf("期望 1 个成功的投递，实际得到 %+v", list)
+			}
+
+			if err := repo.DeleteWebhook("alice", active.ID); err != nil {
+				t.Fatalf("删除 webhook 失败: %v", err)
+			}
+			if list, _ := repo.ListDeliveries(active.ID, "", 10); len(list) != 0 {
+				t.Errorf("删除 webhook 后投递记录应被删除，实际得到 %d 条", len(list))
+			}
+		})
+	}
+}
+
+func TestUserRepository(t *testing.T) {
+	repos := map[string]UserRepository{
+		"gorm":   NewUserRepository(dbtest.Open(t)),
+		"memory": NewMemoryUserRepository(),
+	}
+	for name, users := range repos {
+		t.Run(name, func(t *testing.T) {
+			user := models.User{Username: "alice", Email: "alice@example.com", IsActive: true}
+			if err := users.CreateUser(&user); err != nil || user.ID == "" {
+				t.Fatalf("创建用户失败: %v", err)
+			}
+
+			for _, login := range []string{"alice", "alice@example.com"} {
+				if found, err := users.FindByLogin(login); err != nil || found.ID != user.ID {
+					t.Errorf("应通过 %s 找到用户，实际错误: %v", login, err)
+				}
+			}
+			if _, err := users.FindByUsername("bob"); err != ErrNotFound {
+				t.Errorf("不存在的用户应返回 ErrNotFound，实际得到 %v", err)
+			}
+
+			users.UpdatePassword(user.ID, "hash", "salt")
+			users.UpdateToken(user.ID, "token")
+			users.SetE2EERequired(user.ID, true)
+			found, _ := users.GetUser(user.ID)
+			if found.Password != "hash" || found.Salt != "salt" || found.Token != "token" || !found.E2EERequired {
+				t.Errorf("密码、token 和加密要求应被更新，实际得到 %+v", found)
+			}
+		})
+	}
+}
+
+func TestDeviceKeys(t *testing.T) {
+	repos := map[string]KeyRepository{
+		"gorm":   NewKeyRepository(dbtest.Open(t)),
+		"memory": NewMemoryKeyRepository(),
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			for _, device := range []string{"laptop", "phone", "laptop"} {
+				key := models.DeviceKey{UserID: "alice", DeviceID: device, KeyID: "k1", WrappedKey: "wrapped-" + device}
+				if err := repo.ReplaceKey(&key); err != nil {
+					t.Fatalf("保存密钥失败: %v", err)
+				}
+			}
+			if keys, _ := repo.ListKeys("alice", "laptop", ""); len(keys) != 1 {
+				t.Errorf("同一设备的密钥应被替换，实际得到 %d 个", len(keys))
+			}
+
+			retired, err := repo.RotateKey("alice", []models.DeviceKey{{UserID: "alice", DeviceID: "laptop", KeyID: "k2"}})
+			if err != nil || len(retired) != 1 || retired[0] != "k1" {
+				t.Fatalf("期望退役 k1，实际得到 %v, 错误: %v", retired, err)
+			}
+			if n, _ := repo.CountKeys("alice", "k1", models.KeyStatusRetired); n != 2 {
+				t.Errorf("期望 2 个已退役的 k1，实际得到 %d", n)
+			}
+			active, _ := repo.ListKeys("alice", "", models.KeyStatusActive)
+			if len(active) != 1 || active[0].KeyID != "k2" {
+				t.Fatalf("期望唯一的活动密钥为 k2，实际得到 %+v", active)
+			}
+
+			if err := repo.DeleteKey("bob", active[0].ID); err != ErrNotFound {
+				t.Errorf("不应删除其他用户的密钥，实际错误: %v", err)
+			}
+			if err := repo.DeleteKey("alice", active[0].ID); err != nil {
+				t.Errorf("删除密钥失败: %v", err)
+			}
+			if n, _ := repo.CountKeys("alice", "", ""); n != 2 {
+				t.Errorf("期望剩余 2 个密钥，实际得到 %d", n)
+			}
+		})
+	}
+}
+
+func TestTransferItems(t *testing.T) {
+	db := dbtest.Open(t)
+	memory := NewMemoryClipboardRepository()
+	repos := map[string]struct {
+		items     ClipboardRepository
+		transfers TransferRepository
+	}{
+		"gorm":   {NewClipboardRepository(db), NewTransferRepository(db)},
+		"memory": {memory, NewMemoryTransferRepository(memory)},
+	}
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			past := time.Now().Add(-time.Hour)
+			repo.items.CreateItem(&models.ClipboardItem{UserID: "alice", ClientID: "c-1", Content: "one"})
+			repo.items.CreateItem(&models.ClipboardItem{UserID: "alice", Content: "two"})
+			repo.items.CreateItem(&models.ClipboardItem{UserID: "alice", Content: "expired", ExpiresAt: &past})
+			repo.items.InChannel("team").CreateItem(&models.ClipboardItem{UserID: "alice", Content: "shared"})
+
+			var exported []models.ClipboardItem
+			count, err := repo.transfers.ExportItems("alice", 1, func(batch []models.ClipboardItem) error {
+				if len(batch) != 1 {
+					t.Errorf("每批应有 1 个项目，实际得到 %d", len(batch))
+				}
+				exported = append(exported, batch...)
+				return nil
+			})
+			if err != nil || count != 2 || len(exported) != 2 {
+				t.Fatalf("期望导出 2 个个人项目，实际得到 %d, 错误: %v", count, err)
+			}
+
+			stored, err := repo.transfers.ImportItems("carol", []models.ClipboardItem{
+				{ClientID: "c-1", Content: "one"},
+				{Content: "two"},
+				{Content: "two"},
+				{ClientID: "c-1", Content: "changed"},
+			})
+			if err != nil || len(stored) != 4 || !stored[0] || !stored[1] || stored[2] || stored[3] {
+				t.Errorf("期望按 client_id 和内容去重，实际得到 %v, 错误: %v", stored, err)
+			}
+			if stats, _ := repo.items.Statistics("carol", past); stats.TotalItems != 2 {
+				t.Errorf("期望导入 2 个项目，实际得到 %d", stats.TotalItems)
+			}
+		})
+	}
+}
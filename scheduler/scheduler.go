@@ -0,0 +1,173 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job a task run periodically by the scheduler
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+
+	// Wake optionally runs the job before its next interval when signalled
+	Wake <-chan struct{}
+}
+
+// JobStatus last known state of a job
+type JobStatus struct {
+	Name      string        `json:"name"`
+	Interval  time.Duration `json:"interval"`
+	LastRun   time.Time     `json:"last_run,omitempty"`
+	LastError string        `json:"last_error,omitempty"`
+	Runs      int64         `json:"runs"`
+	Running   bool          `json:"running"`
+}
+
+// Scheduler runs background jobs at fixed intervals
+type Scheduler struct {
+	jobs []Job
+
+	mu     sync.RWMutex
+	status map[string]*JobStatus
+
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+	startedAt time.Time
+	stopped   bool
+}
+
+// New creates an empty scheduler
+func New() *Scheduler {
+	return &Scheduler{
+		status: make(map[string]*JobStatus),
+	}
+}
+
+// Add registers a job, it must be called before Start
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+
+	s.mu.Lock()
+	s.status[job.Name] = &JobStatus{Name: job.Name, Interval: job.Interval}
+	s.mu.Unlock()
+}
+
+// Start runs every job in its own goroutine until Stop is called
+func (s *Scheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.mu.Lock()
+	s.startedAt = time.Now()
+	s.mu.Unlock()
+
+	for _, job := range s.jobs {
+		if job.Interval <= 0 {
+			slog.Warn("任务的间隔无效，已跳过", "job", job.Name)
+			continue
+		}
+
+		s.wg.Add(1)
+		go s.loop(ctx, job)
+	}
+}
+
+// Stop cancels all jobs and waits for running ones to finish
+func (s *Scheduler) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+}
+
+// Alive reports an error when the scheduler isn't running or a job has missed
+// two runs in a row without running
+func (s *Scheduler) Alive() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.startedAt.IsZero() {
+		return fmt.Errorf("scheduler has not been started")
+	}
+	if s.stopped {
+		return fmt.Errorf("scheduler has been stopped")
+	}
+
+	now := time.Now()
+	for _, job := range s.jobs {
+		status := s.status[job.Name]
+		if job.Interval <= 0 || status.Running {
+			continue
+		}
+		last := status.LastRun
+		if last.IsZero() {
+			last = s.startedAt
+		}
+		if now.Sub(last) > 2*job.Interval {
+			return fmt.Errorf("job %s has not run since %s", job.Name, last.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Status returns the state of all jobs
+func (s *Scheduler) Status() []JobStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]JobStatus, 0, len(s.jobs))
+	for _, job := range s.jobs {
+		result = append(result, *s.status[job.Name])
+	}
+	return result
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx, job)
+		case <-job.Wake:
+			s.runOnce(ctx, job)
+		}
+	}
+}
+
+func (s *Scheduler) runOnce(ctx context.Context, job Job) {
+	s.mu.Lock()
+	s.status[job.Name].Running = true
+	s.mu.Unlock()
+
+	err := job.Run(ctx)
+	if err != nil {
+		slog.Error("任务执行失败", "job", job.Name, "error", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.status[job.Name]
+	status.LastRun = time.Now()
+	status.Running = false
+	status.Runs++
+	status.LastError = ""
+	if err != nil {
+		status.LastError = err.Error()
+	}
+}
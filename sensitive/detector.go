@@ -0,0 +1,66 @@
+package sensitive
+
+import (
+	"sort"
+	"sync"
+)
+
+// Match a sensitive value found in content, as a byte range
+type Match struct {
+	Start int
+	End   int
+}
+
+// Detector finds one kind of sensitive value in clipboard content
+type Detector interface {
+	// Name is the stable identifier used in policies and reports
+	Name() string
+	// Detect returns the ranges of all sensitive values in content
+	Detect(content string) []Match
+	// Mask returns the replacement for a matched value
+	Mask(value string) string
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Detector{}
+)
+
+// Register adds a detector to the registry, replacing any detector with the same name
+func Register(d Detector) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[d.Name()] = d
+}
+
+// Lookup returns the registered detector with the given name
+func Lookup(name string) (Detector, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	d, ok := registry[name]
+	return d, ok
+}
+
+// DetectorNames returns the names of all registered detectors in sorted order
+func DetectorNames() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register(creditCardDetector{})
+	Register(newPatternDetector("aws_access_key", `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`))
+	Register(newPatternDetector("aws_secret_key", `(?i)aws_?secret_?access_?key["']?\s*[:=]\s*["']?([A-Za-z0-9/+=]{40})`))
+	Register(newPatternDetector("github_token", `\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`))
+	Register(newPatternDetector("slack_token", `\bxox[abposr]-[A-Za-z0-9-]{10,}\b`))
+	Register(newPatternDetector("jwt", `\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`))
+	Register(privateKeyDetector{})
+	Register(highEntropyDetector{})
+}
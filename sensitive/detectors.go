@@ -0,0 +1,193 @@
+package sensitive
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// patternDetector matches a regular expression. When the expression has a
+// capturing group only the group is treated as the sensitive value.
+type patternDetector struct {
+	name    string
+	pattern *regexp.Regexp
+}
+
+func newPatternDetector(name, pattern string) patternDetector {
+	return patternDetector{name: name, pattern: regexp.MustCompile(pattern)}
+}
+
+func (d patternDetector) Name() string { return d.name }
+
+func (d patternDetector) Detect(content string) []Match {
+	var matches []Match
+	for _, loc := range d.pattern.FindAllStringSubmatchIndex(content, -1) {
+		if len(loc) >= 4 && loc[2] >= 0 {
+			matches = append(matches, Match{Start: loc[2], End: loc[3]})
+		} else {
+			matches = append(matches, Match{Start: loc[0], End: loc[1]})
+		}
+	}
+	return matches
+}
+
+func (d patternDetector) Mask(value string) string {
+	return maskKeepLast(value, 4)
+}
+
+// creditCardDetector finds card numbers that pass the Luhn check
+type creditCardDetector struct{}
+
+var creditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+
+func (creditCardDetector) Name() string { return "credit_card" }
+
+func (creditCardDetector) Detect(content string) []Match {
+	var matches []Match
+	for _, loc := range creditCardPattern.FindAllStringIndex(content, -1) {
+		digits := onlyDigits(content[loc[0]:loc[1]])
+		if len(digits) >= 13 && len(digits) <= 19 && luhnValid(digits) {
+			matches = append(matches, Match{Start: loc[0], End: loc[1]})
+		}
+	}
+	return matches
+}
+
+func (creditCardDetector) Mask(value string) string {
+	// Keep the separators and the last four digits
+	digits := len(onlyDigits(value))
+	var b strings.Builder
+	seen := 0
+	for _, r := range value {
+		if unicode.IsDigit(r) {
+			seen++
+			if seen <= digits-4 {
+				b.WriteRune('*')
+				continue
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// luhnValid validates a digit string with the Luhn checksum
+func luhnValid(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+func onlyDigits(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// privateKeyDetector finds PEM encoded private keys
+type privateKeyDetector struct{}
+
+var privateKeyPattern = regexp.MustCompile(`-----BEGIN (?:[A-Z0-9]+ )*PRIVATE KEY-----[\s\S]*?(?:-----END (?:[A-Z0-9]+ )*PRIVATE KEY-----|$)`)
+
+func (privateKeyDetector) Name() string { return "private_key" }
+
+func (privateKeyDetector) Detect(content string) []Match {
+	var matches []Match
+	for _, loc := range privateKeyPattern.FindAllStringIndex(content, -1) {
+		matches = append(matches, Match{Start: loc[0], End: loc[1]})
+	}
+	return matches
+}
+
+func (privateKeyDetector) Mask(value string) string {
+	return "[REDACTED PRIVATE KEY]"
+}
+
+// highEntropyDetector finds long random looking tokens such as API keys
+type highEntropyDetector struct{}
+
+const (
+	highEntropyMinLength = 24
+	highEntropyThreshold = 4.2
+)
+
+var tokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_\-]{24,}`)
+
+func (highEntropyDetector) Name() string { return "high_entropy" }
+
+func (highEntropyDetector) Detect(content string) []Match {
+	var matches []Match
+	for _, loc := range tokenPattern.FindAllStringIndex(content, -1) {
+		token := content[loc[0]:loc[1]]
+		if isRandomToken(token) {
+			matches = append(matches, Match{Start: loc[0], End: loc[1]})
+		}
+	}
+	return matches
+}
+
+func (highEntropyDetector) Mask(value string) string {
+	return maskKeepLast(value, 4)
+}
+
+// isRandomToken reports whether a token mixes letters and digits and has high Shannon entropy
+func isRandomToken(token string) bool {
+	if len(token) < highEntropyMinLength {
+		return false
+	}
+
+	var hasLetter, hasDigit bool
+	for _, r := range token {
+		if unicode.IsLetter(r) {
+			hasLetter = true
+		} else if unicode.IsDigit(r) {
+			hasDigit = true
+		}
+	}
+	if !hasLetter || !hasDigit {
+		return false
+	}
+
+	return shannonEntropy(token) >= highEntropyThreshold
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	length := float64(len(s))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / length
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// maskKeepLast replaces all but the last n characters with '*'
+func maskKeepLast(value string, n int) string {
+	runes := []rune(value)
+	if len(runes) <= n*2 {
+		return strings.Repeat("*", len(runes))
+	}
+	return strings.Repeat("*", len(runes)-n) + string(runes[len(runes)-n:])
+}
@@ -0,0 +1,168 @@
+package sensitive
+
+import (
+	"clipboard-server/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// severity orders actions, the most severe action of all findings applies to the item
+var severity = map[models.SensitiveAction]int{
+	models.SensitiveActionKeep:   0,
+	models.SensitiveActionTag:    1,
+	models.SensitiveActionMask:   2,
+	models.SensitiveActionExpire: 3,
+	models.SensitiveActionReject: 4,
+}
+
+// ValidAction reports whether a is a known policy action
+func ValidAction(a models.SensitiveAction) bool {
+	_, ok := severity[a]
+	return ok
+}
+
+// DefaultPolicy is used for users without a stored policy. It only tags
+// matches so that nothing is changed or lost without the user opting in.
+func DefaultPolicy() models.SensitivePolicy {
+	return models.SensitivePolicy{
+		DefaultAction: models.SensitiveActionTag,
+		ExpireMinutes: 10,
+	}
+}
+
+// Result outcome of applying a policy to content
+type Result struct {
+	Content   string // content to store, with masked values replaced
+	Sensitive bool
+	Tags      []string
+	ExpiresAt *time.Time
+	Rejected  bool
+	Report    *models.SensitivityReport
+}
+
+type detection struct {
+	detector Detector
+	match    Match
+}
+
+// Evaluate scans content with every registered detector and applies the policy
+func Evaluate(content string, policy models.SensitivePolicy, now time.Time) Result {
+	detections := scan(content)
+
+	counts := make(map[string]int)
+	for _, d := range detections {
+		counts[d.detector.Name()]++
+	}
+
+	report := &models.SensitivityReport{
+		Action:   models.SensitiveActionKeep,
+		Findings: []models.SensitiveFinding{},
+	}
+	result := Result{Content: content, Report: report}
+
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		action := policy.ActionFor(name)
+		if !ValidAction(action) {
+			action = models.SensitiveActionTag
+		}
+
+		report.Findings = append(report.Findings, models.SensitiveFinding{
+			Detector: name,
+			Action:   action,
+			Count:    counts[name],
+		})
+		if severity[action] > severity[report.Action] {
+			report.Action = action
+		}
+		if action != models.SensitiveActionKeep {
+			result.Sensitive = true
+			result.Tags = append(result.Tags, name)
+		}
+	}
+
+	switch report.Action {
+	case models.SensitiveActionReject:
+		result.Rejected = true
+	case models.SensitiveActionExpire:
+		minutes := policy.ExpireMinutes
+		if minutes <= 0 {
+			minutes = DefaultPolicy().ExpireMinutes
+		}
+		expiresAt := now.Add(time.Duration(minutes) * time.Minute)
+		result.ExpiresAt = &expiresAt
+		report.ExpiresAt = &expiresAt
+	}
+
+	// Masking applies per detector, whatever the overall action is
+	var masked []detection
+	for _, d := range detections {
+		if policy.ActionFor(d.detector.Name()) == models.SensitiveActionMask {
+			masked = append(masked, d)
+		}
+	}
+	result.Content = applyMasks(content, masked)
+
+	return result
+}
+
+// scan runs every registered detector. High entropy matches that overlap a
+// more specific detector are dropped so each value is reported once.
+func scan(content string) []detection {
+	var specific, generic []detection
+	for _, name := range DetectorNames() {
+		d, _ := Lookup(name)
+		for _, m := range d.Detect(content) {
+			if name == "high_entropy" {
+				generic = append(generic, detection{d, m})
+			} else {
+				specific = append(specific, detection{d, m})
+			}
+		}
+	}
+
+	detections := specific
+	for _, g := range generic {
+		overlaps := false
+		for _, s := range specific {
+			if g.match.Start < s.match.End && s.match.Start < g.match.End {
+				overlaps = true
+				break
+			}
+		}
+		if !overlaps {
+			detections = append(detections, g)
+		}
+	}
+	return detections
+}
+
+// applyMasks replaces the matched ranges, skipping ranges that overlap an earlier one
+func applyMasks(content string, detections []detection) string {
+	if len(detections) == 0 {
+		return content
+	}
+
+	sort.Slice(detections, func(i, j int) bool {
+		return detections[i].match.Start < detections[j].match.Start
+	})
+
+	var b strings.Builder
+	last := 0
+	for _, d := range detections {
+		if d.match.Start < last {
+			continue
+		}
+		b.WriteString(content[last:d.match.Start])
+		b.WriteString(d.detector.Mask(content[d.match.Start:d.match.End]))
+		last = d.match.End
+	}
+	b.WriteString(content[last:])
+	return b.String()
+}
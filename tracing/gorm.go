@@ -0,0 +1,94 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	spanInstanceKey   = "tracing:span"
+	parentInstanceKey = "tracing:parent"
+)
+
+// rowsAffectedKey span attribute holding the rows affected by a statement
+const rowsAffectedKey = attribute.Key("db.rows_affected")
+
+// gormPlugin traces GORM queries through callbacks around every operation
+type gormPlugin struct{}
+
+// GormPlugin returns the GORM plugin adding a span for each query. Only queries
+// run with the context of a traced request, via db.WithContext, get a span;
+// background jobs and CLI commands are not traced.
+func GormPlugin() gorm.Plugin {
+	return gormPlugin{}
+}
+
+func (gormPlugin) Name() string {
+	return "tracing"
+}
+
+func (p gormPlugin) Initialize(db *gorm.DB) error {
+	callback := db.Callback()
+	registrations := []error{
+		callback.Create().Before("*").Register("tracing:before_create", p.before("create")),
+		callback.Create().After("*").Register("tracing:after_create", p.after),
+		callback.Query().Before("*").Register("tracing:before_query", p.before("query")),
+		callback.Query().After("*").Register("tracing:after_query", p.after),
+		callback.Update().Before("*").Register("tracing:before_update", p.before("update")),
+		callback.Update().After("*").Register("tracing:after_update", p.after),
+		callback.Delete().Before("*").Register("tracing:before_delete", p.before("delete")),
+		callback.Delete().After("*").Register("tracing:after_delete", p.after),
+		callback.Row().Before("*").Register("tracing:before_row", p.before("row")),
+		callback.Row().After("*").Register("tracing:after_row", p.after),
+		callback.Raw().Before("*").Register("tracing:before_raw", p.before("raw")),
+		callback.Raw().After("*").Register("tracing:after_raw", p.after),
+	}
+	return errors.Join(registrations...)
+}
+
+func (p gormPlugin) before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !trace.SpanContextFromContext(parent).IsValid() {
+			return
+		}
+
+		ctx, span := tracer().Start(parent, "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemKey.String(db.Dialector.Name())),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(spanInstanceKey, span)
+		db.InstanceSet(parentInstanceKey, parent)
+	}
+}
+
+func (p gormPlugin) after(db *gorm.DB) {
+	value, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span := value.(trace.Span)
+	defer span.End()
+
+	// Later statements of the same instance are siblings, not children, of this one
+	if parent, ok := db.InstanceGet(parentInstanceKey); ok {
+		db.Statement.Context = parent.(context.Context)
+	}
+
+	span.SetAttributes(
+		semconv.DBStatementKey.String(db.Statement.SQL.String()),
+		semconv.DBSQLTableKey.String(db.Statement.Table),
+		rowsAffectedKey.Int64(db.Statement.RowsAffected),
+	)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
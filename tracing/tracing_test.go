@@ -0,0 +1,104 @@
+package tracing_test
+
+import (
+	"clipboard-server/database/dbtest"
+	"clipboard-server/middleware"
+	"clipboard-server/models"
+	"clipboard-server/tracing"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestRequestAndQuerySpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+
+	db := dbtest.Open(t)
+	if err := db.Use(tracing.GormPlugin()); err != nil {
+		t.Fatalf("注册 GORM 插件失败: %v", err)
+	}
+
+	// Queries outside of a request are not traced
+	db.Create(&models.ClipboardItem{UserID: "u", Content: "untraced"})
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(tracing.Middleware(), middleware.RequestID())
+	router.GET("/items/:id", func(c *gin.Context) {
+		var item models.ClipboardItem
+		db.WithContext(c.Request.Context()).Where("user_id = ?", "u").First(&item)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/items/1", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("应记录请求和查询 2 个 span，实际为 %d", len(spans))
+	}
+	query, request := spans[0], spans[1]
+
+	if request.Name() != "GET /items/:id" || request.SpanKind() != trace.SpanKindServer {
+		t.Errorf("请求 span 应以路由模板命名，实际为 %q", request.Name())
+	}
+	if query.Name() != "gorm.query" || query.Parent().SpanID() != request.SpanContext().SpanID() {
+		t.Errorf("查询 span 应是请求 span 的子 span，实际为 %q", query.Name())
+	}
+
+	// Without an X-Request-ID the trace ID is used as the request ID
+	traceID := request.SpanContext().TraceID().String()
+	if got := w.Header().Get("X-Request-ID"); got != traceID {
+		t.Errorf("请求 ID 应为追踪 ID %s，实际为 %s", traceID, got)
+	}
+	found := false
+	for _, attr := range request.Attributes() {
+		if attr.Key == tracing.RequestIDKey && attr.Value.AsString() == traceID {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("请求 span 应记录请求 ID")
+	}
+}
+
+func TestMiddlewareContinuesClientTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	router := gin.New()
+	router.Use(tracing.Middleware(), middleware.RequestID())
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	req := httptest.NewRequest("GET", "/ping", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("X-Request-ID", "client-request")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].SpanContext().TraceID().String() != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatal("请求 span 应延续客户端的追踪")
+	}
+	if got := w.Header().Get("X-Request-ID"); got != "client-request" {
+		t.Errorf("客户端提供的请求 ID 应保留，实际为 %s", got)
+	}
+}
@@ -0,0 +1,111 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bytes"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// exportBatchSize items loaded and written at a time
+const exportBatchSize = 500
+
+// eachBatch calls fn with the records of a user's items, one batch at a time
+func eachBatch(items repository.TransferRepository, userID string, fn func([]Record) error) (int, error) {
+	return items.ExportItems(userID, exportBatchSize, func(batch []models.ClipboardItem) error {
+		records := make([]Record, len(batch))
+		for i, item := range batch {
+			records[i] = FromItem(item)
+		}
+		return fn(records)
+	})
+}
+
+// WriteNDJSON writes all items of a user as newline-delimited JSON.
+// flush, if set, is called after every batch.
+func WriteNDJSON(w io.Writer, items repository.TransferRepository, userID string, flush func()) (int, error) {
+	encoder := json.NewEncoder(w)
+	return eachBatch(items, userID, func(records []Record) error {
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+		if flush != nil {
+			flush()
+		}
+		return nil
+	})
+}
+
+// WriteArchive writes all items of a user as a tar.gz archive holding one
+// NDJSON file per batch and a manifest.json. Only one batch is held in memory.
+func WriteArchive(w io.Writer, items repository.TransferRepository, userID string, flush func()) (int, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	var files []string
+	count, err := eachBatch(items, userID, func(records []Record) error {
+		var buf bytes.Buffer
+		encoder := json.NewEncoder(&buf)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return err
+			}
+		}
+
+		name := fmt.Sprintf("items/%05d.ndjson", len(files)+1)
+		if err := writeFile(tw, name, buf.Bytes()); err != nil {
+			return err
+		}
+		files = append(files, name)
+
+		if err := gz.Flush(); err != nil {
+			return err
+		}
+		if flush != nil {
+			flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	manifest, err := json.MarshalIndent(Manifest{
+		Format:     FormatName,
+		Version:    FormatVersion,
+		ExportedAt: time.Now(),
+		Items:      count,
+		Files:      files,
+	}, "", "  ")
+	if err != nil {
+		return count, err
+	}
+	if err := writeFile(tw, "manifest.json", manifest); err != nil {
+		return count, err
+	}
+
+	if err := tw.Close(); err != nil {
+		return count, err
+	}
+	return count, gz.Close()
+}
+
+func writeFile(tw *tar.Writer, name string, data []byte) error {
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
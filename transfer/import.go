@@ -0,0 +1,255 @@
+package transfer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrSkip returned by Importer.Prepare to skip a record without reporting an error
+var ErrSkip = errors.New("skip record")
+
+const (
+	// defaultImportBatchSize records stored per transaction
+	defaultImportBatchSize = 100
+	// maxReportedErrors record errors included in the progress report
+	maxReportedErrors = 100
+)
+
+// Progress of an import
+type Progress struct {
+	Processed int           `json:"processed"`
+	Imported  int           `json:"imported"`
+	Skipped   int           `json:"skipped"` // duplicates and records skipped by Prepare
+	Failed    int           `json:"failed"`
+	Errors    []RecordError `json:"errors,omitempty"`
+	Error     string        `json:"error,omitempty"` // set when the import was aborted
+	Done      bool          `json:"done"`
+}
+
+// RecordError a record that could not be imported
+type RecordError struct {
+	Record   int    `json:"record"` // 1-based position in the upload
+	ClientID string `json:"client_id,omitempty"`
+	Error    string `json:"error"`
+}
+
+// Importer streams exported records into a user's clipboard history.
+// Records are deduplicated by client ID, or by content hash when they have none.
+type Importer struct {
+	Items  repository.TransferRepository
+	UserID string
+
+	// Prepare validates a record and sets the content of the new item.
+	// Returning ErrSkip skips the record, any other error fails it.
+	Prepare func(item *models.ClipboardItem, record Record) error
+
+	// Progress is called after every stored batch
+	Progress func(Progress)
+
+	BatchSize int
+
+	progress Progress
+	batch    []pendingRecord
+}
+
+type pendingRecord struct {
+	position int
+	record   Record
+}
+
+// Import reads NDJSON or a tar.gz export archive, detected from the content
+func (im *Importer) Import(r io.Reader) (Progress, error) {
+	br := bufio.NewReader(r)
+	magic, _ := br.Peek(2)
+	if bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		return im.ImportArchive(br)
+	}
+	return im.ImportNDJSON(br)
+}
+
+// ImportNDJSON reads newline-delimited JSON records
+func (im *Importer) ImportNDJSON(r io.Reader) (Progress, error) {
+	if err := im.readNDJSON(r); err != nil {
+		return im.progress, err
+	}
+	return im.Finish()
+}
+
+// ImportArchive reads the NDJSON files of a tar.gz export archive
+func (im *Importer) ImportArchive(r io.Reader) (Progress, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return im.progress, fmt.Errorf("invalid gzip archive: %v", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return im.progress, fmt.Errorf("invalid tar archive: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg || !strings.HasSuffix(header.Name, ".ndjson") {
+			continue
+		}
+		if err := im.readNDJSON(tr); err != nil {
+			return im.progress, err
+		}
+	}
+	return im.Finish()
+}
+
+// Add queues a record for import, storing the batch once it is full.
+// It lets other formats feed records into the importer.
+func (im *Importer) Add(record Record) error {
+	im.progress.Processed++
+	im.batch = append(im.batch, pendingRecord{im.progress.Processed, record})
+
+	if len(im.batch) >= im.batchSize() {
+		return im.flush()
+	}
+	return nil
+}
+
+// Fail reports an entry of the upload that could not be read as a record
+func (im *Importer) Fail(clientID, message string) {
+	im.progress.Processed++
+	im.fail(im.progress.Processed, clientID, message)
+}
+
+// Finish stores the remaining records and returns the final progress
+func (im *Importer) Finish() (Progress, error) {
+	err := im.flush()
+	return im.progress, err
+}
+
+func (im *Importer) readNDJSON(r io.Reader) error {
+	br := bufio.NewReader(r)
+	for {
+		line, readErr := br.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				im.Fail("", fmt.Sprintf("invalid record: %v", err))
+			} else if err := im.Add(record); err != nil {
+				return err
+			}
+		}
+
+		if readErr == io.EOF {
+			return nil
+		}
+	}
+}
+
+// flush stores the pending batch in a single transaction
+func (im *Importer) flush() error {
+	if len(im.batch) == 0 {
+		return nil
+	}
+
+	items := make([]models.ClipboardItem, 0, len(im.batch))
+	for _, pending := range im.batch {
+		if item, ok := im.prepare(pending); ok {
+			items = append(items, item)
+		}
+	}
+
+	stored, err := im.Items.ImportItems(im.UserID, items)
+	if err != nil {
+		return fmt.Errorf("failed to store imported items: %v", err)
+	}
+	for _, ok := range stored {
+		if ok {
+			im.progress.Imported++
+		} else {
+			im.progress.Skipped++
+		}
+	}
+
+	im.batch = im.batch[:0]
+	if im.Progress != nil {
+		im.Progress(im.progress)
+	}
+	return nil
+}
+
+// prepare builds the item of a record, reporting false for skipped and failed records
+func (im *Importer) prepare(pending pendingRecord) (models.ClipboardItem, bool) {
+	record := pending.record
+
+	item := models.ClipboardItem{
+		UserID:    im.UserID,
+		ClientID:  record.ClientID,
+		Type:      record.Type,
+		Timestamp: record.Timestamp.Time,
+		CreatedAt: record.CreatedAt.Time,
+	}
+	if item.Timestamp.IsZero() {
+		item.Timestamp = time.Now()
+	}
+
+	prepare := im.Prepare
+	if prepare == nil {
+		prepare = setContent
+	}
+	if err := prepare(&item, record); err == ErrSkip {
+		im.progress.Skipped++
+		return item, false
+	} else if err != nil {
+		im.fail(pending.position, record.ClientID, err.Error())
+		return item, false
+	}
+	return item, true
+}
+
+func (im *Importer) fail(position int, clientID, message string) {
+	im.progress.Failed++
+	if len(im.progress.Errors) < maxReportedErrors {
+		im.progress.Errors = append(im.progress.Errors, RecordError{
+			Record:   position,
+			ClientID: clientID,
+			Error:    message,
+		})
+	}
+}
+
+func (im *Importer) batchSize() int {
+	if im.BatchSize > 0 {
+		return im.BatchSize
+	}
+	return defaultImportBatchSize
+}
+
+// setContent is the default Prepare, copying the record content as-is
+func setContent(item *models.ClipboardItem, record Record) error {
+	if item.Type == "" {
+		item.Type = models.ClipboardTypeText
+	}
+	item.SetEnvelope(record.Encryption)
+	item.Content = record.Content
+	item.Sensitive = record.Sensitive
+	item.SensitiveTags = strings.Join(record.SensitiveTags, ",")
+	if record.ExpiresAt != nil {
+		expiresAt := record.ExpiresAt.Time
+		item.ExpiresAt = &expiresAt
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package transfer
+
+import (
+	"clipboard-server/models"
+	"time"
+)
+
+// FormatName identifies clipboard history exports
+const FormatName = "clipboard-server-export"
+
+// FormatVersion version of the export format
+const FormatVersion = 1
+
+// Record one clipboard item in an export, written as a single NDJSON line
+type Record struct {
+	ID            string                     `json:"id,omitempty"`
+	ClientID      string                     `json:"client_id,omitempty"`
+	Content       string                     `json:"content"`
+	Type          models.ClipboardType       `json:"type"`
+	Timestamp     models.CustomTime          `json:"timestamp"`
+	CreatedAt     models.CustomTime          `json:"created_at"`
+	ExpiresAt     *models.CustomTime         `json:"expires_at,omitempty"`
+	Encryption    *models.EncryptionEnvelope `json:"encryption,omitempty"`
+	Sensitive     bool                       `json:"sensitive,omitempty"`
+	SensitiveTags []string                   `json:"sensitive_tags,omitempty"`
+}
+
+// FromItem converts a clipboard item to an export record
+func FromItem(item models.ClipboardItem) Record {
+	record := Record{
+		ID:            item.ID,
+		ClientID:      item.ClientID,
+		Content:       item.Content,
+		Type:          item.Type,
+		Timestamp:     models.CustomTime{Time: item.Timestamp},
+		CreatedAt:     models.CustomTime{Time: item.CreatedAt},
+		Encryption:    item.Envelope(),
+		Sensitive:     item.Sensitive,
+		SensitiveTags: item.Tags(),
+	}
+	if item.ExpiresAt != nil {
+		record.ExpiresAt = &models.CustomTime{Time: *item.ExpiresAt}
+	}
+	return record
+}
+
+// Manifest describes a tar.gz export archive
+type Manifest struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	ExportedAt time.Time `json:"exported_at"`
+	Items      int       `json:"items"`
+	Files      []string  `json:"files"`
+}
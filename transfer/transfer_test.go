@@ -0,0 +1,95 @@
+package transfer
+
+import (
+	"bytes"
+	"clipboard-server/database/dbtest"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	return dbtest.Open(t)
+}
+
+func countItems(db *gorm.DB, userID string) int64 {
+	var count int64
+	db.Model(&models.ClipboardItem{}).Where("user_id = ?", userID).Count(&count)
+	return count
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	db := setupTestDB(t)
+
+	past := time.Now().Add(-time.Hour)
+	db.Create(&models.ClipboardItem{UserID: "alice", ClientID: "c-1", Content: "one", Timestamp: past})
+	db.Create(&models.ClipboardItem{UserID: "alice", Content: "two"})
+	db.Create(&models.ClipboardItem{UserID: "alice", Content: "expired", ExpiresAt: &past})
+	db.Create(&models.ClipboardItem{UserID: "bob", Content: "not alice"})
+
+	var archive bytes.Buffer
+	count, err := WriteArchive(&archive, repository.NewTransferRepository(db), "alice", nil)
+	if err != nil || count != 2 {
+		t.Fatalf("期望导出 2 个项目，实际得到 %d, 错误: %v", count, err)
+	}
+
+	importer := Importer{Items: repository.NewTransferRepository(db), UserID: "carol", BatchSize: 1}
+	progress, err := importer.Import(bytes.NewReader(archive.Bytes()))
+	if err != nil || progress.Imported != 2 {
+		t.Fatalf("期望导入 2 个项目，实际得到 %+v, 错误: %v", progress, err)
+	}
+
+	var imported models.ClipboardItem
+	db.Where("user_id = ? AND client_id = ?", "carol", "c-1").First(&imported)
+	if imported.Content != "one" || !imported.Timestamp.Equal(past) {
+		t.Errorf("导入的项目应保留内容和原始时间戳，实际得到 %q %v", imported.Content, imported.Timestamp)
+	}
+
+	// Importing again is deduplicated by client ID and content hash
+	importer = Importer{Items: repository.NewTransferRepository(db), UserID: "carol"}
+	progress, _ = importer.Import(bytes.NewReader(archive.Bytes()))
+	if progress.Imported != 0 || progress.Skipped != 2 {
+		t.Errorf("重复导入应全部跳过，实际得到 %+v", progress)
+	}
+	if n := countItems(db, "carol"); n != 2 {
+		t.Errorf("期望 2 个项目，实际得到 %d", n)
+	}
+}
+
+func TestImportNDJSONReportsFailures(t *testing.T) {
+	db := setupTestDB(t)
+
+	input := strings.Join([]string{
+		`{"client_id":"a","content":"first","timestamp":"2024-01-01T12:00:00.000000"}`,
+		`not json`,
+		``,
+		`{"client_id":"b","content":"second","type":"text"}`,
+		`{"client_id":"b","content":"second again"}`,
+	}, "\n")
+
+	var reports []Progress
+	importer := Importer{
+		Items:     repository.NewTransferRepository(db),
+		UserID:    "alice",
+		BatchSize: 2,
+		Progress:  func(p Progress) { reports = append(reports, p) },
+	}
+
+	progress, err := importer.ImportNDJSON(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("导入失败: %v", err)
+	}
+	if progress.Processed != 4 || progress.Imported != 2 || progress.Skipped != 1 || progress.Failed != 1 {
+		t.Errorf("导入统计不正确: %+v", progress)
+	}
+	if len(progress.Errors) != 1 || progress.Errors[0].Record != 2 {
+		t.Errorf("应报告第 2 条记录无效，实际得到 %+v", progress.Errors)
+	}
+	if len(reports) < 2 {
+		t.Errorf("应在每个批次后报告进度，实际报告 %d 次", len(reports))
+	}
+}
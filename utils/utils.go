@@ -159,29 +159,6 @@ func TruncateString(s string, maxLength int) string {
 	return s
 }
 
-func SanitizeContent(content string) string {
-	patterns := []string{
-		//	"password",
-		//	"passwd",
-		//	"pwd",
-		//	"secret",s
-		//	"token",S
-		//	"key",S
-		//	"auth",
-	}
-
-	lowerContent := strings.ToLower(content)
-	for _, pattern := range patterns {
-		if strings.Contains(lowerContent, pattern) {
-			if len(content) < 100 && (strings.Contains(lowerContent, "=") || strings.Contains(lowerContent, ":")) {
-				return "[SENSITIVE_CONTENT_HIDDEN]"
-			}
-		}
-	}
-
-	return content
-}
-
 func GetContentSize(content string) int64 {
 	return int64(len([]byte(content)))
 }
@@ -212,6 +189,21 @@ func IsValidContentType(contentType string) bool {
 	return false
 }
 
+// IsValidContentSubtype reports whether subtype is one of the subtypes the
+// server detects, for filtering by subtype
+func IsValidContentSubtype(subtype string) bool {
+	validSubtypes := []string{
+		"plain", "url", "email", "phone", "color", "json", "code",
+		"image", "audio", "video", "pdf", "archive", "binary",
+	}
+	for _, validSubtype := range validSubtypes {
+		if subtype == validSubtype {
+			return true
+		}
+	}
+	return false
+}
+
 func GenerateContentHash(content string) string {
 	hash := sha256.Sum256([]byte(content))
 	return hex.EncodeToString(hash[:])
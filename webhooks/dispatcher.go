@@ -0,0 +1,349 @@
+// Package webhooks delivers clipboard events to the URLs users subscribed.
+// Published events are buffered in memory and queued as deliveries in the
+// database by a worker, then sent by a background job, which retries failed
+// attempts with exponential backoff.
+package webhooks
+
+import (
+	"bytes"
+	"clipboard-server/config"
+	"clipboard-server/events"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Ping type of the test deliveries queued on request, regardless of filters
+const Ping events.Type = "ping"
+
+const (
+	// deliveryBatchSize deliveries loaded per query by Deliver
+	deliveryBatchSize = 50
+	// maxRetryDelay caps the exponential backoff between attempts
+	maxRetryDelay = 6 * time.Hour
+	// maxErrorLength fits the error column of a delivery
+	maxErrorLength = 1024
+	// queueSize events buffered before they are persisted, later ones are dropped
+	queueSize = 1024
+
+	userAgent = "clipboard-sync-server-webhooks/1.0"
+)
+
+// Payload JSON body of a delivery request
+type Payload struct {
+	ID        string       `json:"id"` // delivery ID, the same on every attempt
+	WebhookID string       `json:"webhook_id"`
+	Type      events.Type  `json:"type"`
+	CreatedAt time.Time    `json:"created_at"`
+	Data      events.Event `json:"data"`
+}
+
+// Dispatcher queues and sends webhook deliveries
+type Dispatcher struct {
+	repo         repository.WebhookRepository
+	client       *http.Client
+	allowPrivate bool
+	wake         chan struct{}
+
+	queue chan events.Event
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// NewDispatcher creates a dispatcher storing its queue in repo. Webhooks may
+// target private, loopback and link-local addresses only with allowPrivate.
+func NewDispatcher(repo repository.WebhookRepository, allowPrivate bool) *Dispatcher {
+	return &Dispatcher{
+		repo:         repo,
+		client:       newClient(allowPrivate),
+		allowPrivate: allowPrivate,
+		wake:         make(chan struct{}, 1),
+		queue:        make(chan events.Event, queueSize),
+		stop:         make(chan struct{}),
+	}
+}
+
+// Wake is signalled when deliveries are queued, to run Deliver right away
+func (d *Dispatcher) Wake() <-chan struct{} {
+	return d.wake
+}
+
+// Notify signals Wake without blocking
+func (d *Dispatcher) Notify() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue buffers an event for the matching active webhooks of its user. It
+// is registered with events.Hub.OnPublish and never blocks: the deliveries are
+// persisted by the worker started with Start, and events are dropped while the
+// buffer is full. Webhooks belong to personal clipboards, so events of
+// channels are not delivered.
+func (d *Dispatcher) Enqueue(e events.Event) {
+	if e.ChannelID != "" || e.UserID == "" {
+		return
+	}
+
+	select {
+	case d.queue <- e:
+	default:
+		slog.Warn("webhook 事件队列已满，丢弃事件", "user_id", e.UserID, "event", e.Type, "item_id", e.ItemID)
+	}
+}
+
+// Start runs the worker persisting the deliveries of enqueued events until Stop is called
+func (d *Dispatcher) Start() {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		for {
+			select {
+			case e := <-d.queue:
+				d.persist(e)
+			case <-d.stop:
+				d.drain()
+				return
+			}
+		}
+	}()
+}
+
+// Stop persists the events still buffered and stops the worker
+func (d *Dispatcher) Stop() {
+	close(d.stop)
+	d.wg.Wait()
+}
+
+// drain persists the buffered events without waiting for new ones
+func (d *Dispatcher) drain() {
+	for {
+		select {
+		case e := <-d.queue:
+			d.persist(e)
+		default:
+			return
+		}
+	}
+}
+
+// persist queues the deliveries of an event to the webhooks it matches
+func (d *Dispatcher) persist(e events.Event) {
+	webhooks, err := d.repo.ActiveWebhooks(e.UserID)
+	if err != nil {
+		slog.Error("查询 webhook 失败", "user_id", e.UserID, "error", err)
+		return
+	}
+
+	now := time.Now()
+	var deliveries []models.WebhookDelivery
+	for _, webhook := range webhooks {
+		if !Matches(webhook.Filters(), e) {
+			continue
+		}
+		delivery, err := NewDelivery(webhook, e, now)
+		if err != nil {
+			slog.Error("生成 webhook 负载失败", "webhook_id", webhook.ID, "error", err)
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+
+	if err := d.repo.CreateDeliveries(deliveries); err != nil {
+		slog.Error("保存 webhook 投递失败", "user_id", e.UserID, "event", e.Type, "error", err)
+		return
+	}
+	d.Notify()
+}
+
+// Matches reports whether an event passes the filters of a webhook
+func Matches(f models.WebhookFilters, e events.Event) bool {
+	if len(f.EventTypes) > 0 && !slices.Contains(f.EventTypes, string(e.Type)) {
+		return false
+	}
+	if len(f.ContentTypes) > 0 && (e.Item == nil || !slices.Contains(f.ContentTypes, string(e.Item.Type))) {
+		return false
+	}
+	if len(f.Tags) > 0 {
+		if e.Item == nil || !slices.ContainsFunc(e.Item.SensitiveTags, func(tag string) bool {
+			return slices.Contains(f.Tags, tag)
+		}) {
+			return false
+		}
+	}
+	if len(f.Devices) > 0 && !slices.Contains(f.Devices, e.DeviceID) {
+		return false
+	}
+	return true
+}
+
+// NewDelivery returns a pending delivery of an event to a webhook, due at now
+func NewDelivery(webhook models.Webhook, e events.Event, now time.Time) (models.WebhookDelivery, error) {
+	if e.Timestamp.IsZero() {
+		e.Timestamp = now
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID:     webhook.ID,
+		UserID:        webhook.UserID,
+		EventType:     string(e.Type),
+		Status:        models.WebhookDeliveryPending,
+		NextAttemptAt: &now,
+	}
+	delivery.BeforeCreate(nil)
+
+	payload, err := json.Marshal(Payload{
+		ID:        delivery.ID,
+		WebhookID: webhook.ID,
+		Type:      e.Type,
+		CreatedAt: now,
+		Data:      e,
+	})
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	delivery.Payload = string(payload)
+	return delivery, nil
+}
+
+// RetryDelay returns the wait after the given number of failed attempts,
+// base doubled after each one up to maxRetryDelay
+func RetryDelay(base time.Duration, attempts int) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxRetryDelay)
+}
+
+// Deliver sends the due deliveries, it runs as a scheduler job. Each delivery
+// is claimed first, so servers sharing the database don't send it twice.
+func (d *Dispatcher) Deliver(ctx context.Context) error {
+	cfg := config.GetConfig()
+	repo := d.repo.WithContext(ctx)
+
+	for {
+		now := time.Now()
+		due, err := repo.DueDeliveries(now, deliveryBatchSize)
+		if err != nil {
+			return fmt.Errorf("failed to load due webhook deliveries: %v", err)
+		}
+
+		for _, delivery := range due {
+			if ctx.Err() != nil {
+				return nil
+			}
+			claimed, err := repo.ClaimDelivery(delivery.ID, now, now.Add(2*cfg.WebhookTimeout))
+			if err != nil {
+				return fmt.Errorf("failed to claim webhook delivery: %v", err)
+			}
+			if claimed {
+				d.attempt(ctx, cfg, repo, delivery)
+			}
+		}
+
+		if len(due) < deliveryBatchSize {
+			return nil
+		}
+	}
+}
+
+// attempt sends a claimed delivery and records the outcome, scheduling the
+// next attempt after a failure
+func (d *Dispatcher) attempt(ctx context.Context, cfg *config.Config, repo repository.WebhookRepository, delivery models.WebhookDelivery) {
+	webhook, err := repo.GetWebhook(delivery.UserID, delivery.WebhookID)
+	switch {
+	case err == repository.ErrNotFound:
+		err = fmt.Errorf("webhook was deleted")
+	case err != nil:
+		// The claim expires and the delivery is attempted again
+		slog.Error("加载 webhook 失败", "webhook_id", delivery.WebhookID, "error", err)
+		return
+	case !webhook.Active:
+		err = fmt.Errorf("webhook is inactive")
+	default:
+		delivery.ResponseStatus, err = d.send(ctx, cfg.WebhookTimeout, webhook, delivery)
+		if ctx.Err() != nil {
+			// Shutting down, the attempt is repeated once the claim expires
+			return
+		}
+	}
+
+	now := time.Now()
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	switch {
+	case err == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.Error = ""
+	case delivery.Attempts >= cfg.WebhookMaxAttempts || !webhook.Active:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+		delivery.Error = truncate(err.Error(), maxErrorLength)
+		slog.Warn("webhook 投递失败，不再重试", "webhook_id", delivery.WebhookID, "delivery_id", delivery.ID,
+			"attempts", delivery.Attempts, "error", err)
+	default:
+		next := now.Add(RetryDelay(cfg.WebhookRetryBase, delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.Error = truncate(err.Error(), maxErrorLength)
+	}
+
+	if err := repo.SaveDelivery(&delivery); err != nil {
+		slog.Error("保存 webhook 投递结果失败", "delivery_id", delivery.ID, "error", err)
+	}
+}
+
+// send posts the payload of a delivery with signature headers, returning the
+// response status. Statuses other than 2xx are errors.
+func (d *Dispatcher) send(ctx context.Context, timeout time.Duration, webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// truncate shortens s to at most n bytes
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
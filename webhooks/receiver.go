@@ -0,0 +1,72 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxReceivedBody limits the payloads accepted by Receiver
+const maxReceivedBody = 1 << 20
+
+// Receiver is an HTTP handler printing the deliveries it receives, to test
+// webhooks against a local endpoint
+type Receiver struct {
+	// Secret verifies the signature of deliveries when set, answering 401 to invalid ones
+	Secret string
+	// Status answered to valid deliveries, 204 by default. An error status
+	// exercises the retries of the server.
+	Status int
+	// Out receives a line per delivery followed by its indented payload
+	Out io.Writer
+
+	mu sync.Mutex
+}
+
+// ServeHTTP handles one delivery
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxReceivedBody))
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	verdict := "unsigned"
+	status := r.Status
+	if status == 0 {
+		status = http.StatusNoContent
+	}
+	if r.Secret != "" {
+		verdict = "valid"
+		err := Verify(r.Secret, req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, DefaultTolerance, time.Now())
+		if err != nil {
+			verdict = err.Error()
+			status = http.StatusUnauthorized
+		}
+	}
+
+	if r.Out != nil {
+		var payload bytes.Buffer
+		if json.Indent(&payload, body, "", "  ") != nil {
+			payload.Reset()
+			payload.Write(body)
+		}
+
+		r.mu.Lock()
+		fmt.Fprintf(r.Out, "%s %s delivery=%s signature=%s -> %d\n%s\n",
+			time.Now().Format(time.RFC3339), req.Header.Get(HeaderEvent), req.Header.Get(HeaderDelivery),
+			verdict, status, payload.String())
+		r.mu.Unlock()
+	}
+
+	w.WriteHeader(status)
+}
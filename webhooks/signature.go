@@ -0,0 +1,74 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of a delivery request
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery" // delivery ID, the same on every attempt
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm of the signature header
+const signaturePrefix = "sha256="
+
+// DefaultTolerance how far the timestamp of a delivery may be from the
+// receiver's clock, limiting replays of captured requests
+const DefaultTolerance = 5 * time.Minute
+
+var (
+	// ErrInvalidSignature the signature doesn't match the payload and secret
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrStaleTimestamp the delivery was signed too long ago
+	ErrStaleTimestamp = errors.New("webhook timestamp outside of the tolerance")
+)
+
+// Sign returns the signature header of a payload sent at timestamp, the
+// HMAC-SHA256 of "<timestamp>.<payload>" keyed with the webhook secret
+func Sign(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of a received delivery.
+// A tolerance of 0 accepts any timestamp.
+func Verify(secret, timestamp, signature string, payload []byte, tolerance time.Duration, now time.Time) error {
+	sent, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !strings.HasPrefix(signature, signaturePrefix) ||
+		!hmac.Equal([]byte(signature), []byte(Sign(secret, sent, payload))) {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(sent, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrStaleTimestamp
+		}
+	}
+	return nil
+}
+
+// GenerateSecret returns a random secret for a new webhook
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %v", err)
+	}
+	return "whsec_" + hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,106 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateTarget a webhook URL resolving to an address of the server's own
+// network, refused unless private targets are allowed
+var ErrPrivateTarget = errors.New("url must not target a private, loopback or link-local address")
+
+// blockedPrefixes non public ranges not covered by the netip.Addr predicates:
+// "this network" and the carrier-grade NAT range, used by some cloud metadata
+// services
+var blockedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// publicAddr reports whether deliveries may be sent to addr
+func publicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckTarget validates the URL of a webhook, which must be an HTTP(S) URL.
+// Unless private targets are allowed, every address its host resolves to must
+// be public. The addresses are checked again when deliveries are sent, as the
+// host may resolve differently by then.
+func (d *Dispatcher) CheckTarget(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http or https URL")
+	}
+	if d.allowPrivate {
+		return nil
+	}
+
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !publicAddr(addr) {
+			return ErrPrivateTarget
+		}
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil || len(addrs) == 0 {
+		return fmt.Errorf("url host %q could not be resolved", host)
+	}
+	for _, addr := range addrs {
+		if !publicAddr(addr) {
+			return ErrPrivateTarget
+		}
+	}
+	return nil
+}
+
+// newClient returns the HTTP client sending deliveries. Unless private targets
+// are allowed, connections to non public addresses are refused once the host
+// is resolved, so a host re-pointed after registration is refused too.
+func newClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !publicAddr(addrPort.Addr()) {
+				return ErrPrivateTarget
+			}
+			return nil
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Connections go straight to the receiver, a proxy would dial the target
+	// instead of the checked dialer
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		// A redirect is answered like any other non-2xx status
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,313 @@
+package webhooks
+
+import (
+	"bytes"
+	"clipboard-server/config"
+	"clipboard-server/events"
+	"clipboard-server/models"
+	"clipboard-server/repository"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSignature(t *testing.T) {
+	payload := []byte(`{"type":"item.created"}`)
+	now := time.Now()
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	signature := Sign("secret", now.Unix(), payload)
+
+	if err := Verify("secret", timestamp, signature, payload, DefaultTolerance, now); err != nil {
+		t.Errorf("有效签名验证失败: %v", err)
+	}
+	if err := Verify("other", timestamp, signature, payload, DefaultTolerance, now); err != ErrInvalidSignature {
+		t.Errorf("密钥错误应验证失败，实际得到 %v", err)
+	}
+	if err := Verify("secret", timestamp, signature, []byte(`{}`), DefaultTolerance, now); err != ErrInvalidSignature {
+		t.Errorf("负载被篡改应验证失败，实际得到 %v", err)
+	}
+	if err := Verify("secret", timestamp, signature, payload, DefaultTolerance, now.Add(time.Hour)); err != ErrStaleTimestamp {
+		t.Errorf("过期的时间戳应被拒绝，实际得到 %v", err)
+	}
+}
+
+func TestMatches(t *testing.T) {
+	item := &models.ClipboardItemResponse{Type: models.ClipboardTypeText, SensitiveTags: []string{"email"}}
+	created := events.Event{Type: events.ItemCreated, Item: item, DeviceID: "laptop"}
+	deleted := events.Event{Type: events.ItemDeleted, ItemID: "1", DeviceID: "laptop"}
+
+	tests := []struct {
+		name    string
+		filters models.WebhookFilters
+		event   events.Event
+		want    bool
+	}{
+		{"无过滤条件", models.WebhookFilters{}, deleted, true},
+		{"事件类型匹配", models.WebhookFilters{EventTypes: []string{"item.created"}}, created, true},
+		{"事件类型不匹配", models.WebhookFilters{EventTypes: []string{"item.created"}}, deleted, false},
+		{"内容类型不匹配", models.WebhookFilters{ContentTypes: []string{"image"}}, created, false},
+		{"删除事件不带内容类型", models.WebhookFilters{ContentTypes: []string{"text"}}, deleted, false},
+		{"标签匹配", models.WebhookFilters{Tags: []string{"password", "email"}}, created, true},
+		{"标签不匹配", models.WebhookFilters{Tags: []string{"password"}}, created, false},
+		{"设备匹配", models.WebhookFilters{Devices: []string{"laptop"}}, deleted, true},
+		{"设备不匹配", models.WebhookFilters{Devices: []string{"phone"}}, created, false},
+	}
+	for _, tt := range tests {
+		if got := Matches(tt.filters, tt.event); got != tt.want {
+			t.Errorf("%s: 期望 %v，实际得到 %v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	base := 30 * time.Second
+	if got := RetryDelay(base, 1); got != base {
+		t.Errorf("首次重试应等待 %s，实际得到 %s", base, got)
+	}
+	if got := RetryDelay(base, 4); got != 8*base {
+		t.Errorf("第 4 次重试应等待 %s，实际得到 %s", 8*base, got)
+	}
+	if got := RetryDelay(base, 100); got != maxRetryDelay {
+		t.Errorf("重试间隔应不超过 %s，实际得到 %s", maxRetryDelay, got)
+	}
+}
+
+// recorder 记录本地接收端收到的请求
+type recorder struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	w.WriteHeader(r.status)
+}
+
+func TestDeliver(t *testing.T) {
+	receiver := &recorder{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	dispatcher := NewDispatcher(repo, true)
+
+	webhook := models.Webhook{UserID: "u1", URL: server.URL, Secret: "secret", Active: true}
+	webhook.SetFilters(models.WebhookFilters{EventTypes: []string{"item.created"}})
+	repo.CreateWebhook(&webhook)
+
+	hub := events.NewHub()
+	defer hub.Close()
+	hub.OnPublish(dispatcher.Enqueue)
+	dispatcher.Start()
+
+	item := &models.ClipboardItemResponse{ID: "item-1", Content: "hello"}
+	hub.Publish(events.Event{Type: events.ItemCreated, UserID: "u1", Item: item, DeviceID: "laptop"})
+	hub.Publish(events.Event{Type: events.ItemDeleted, UserID: "u1", ItemID: "item-1"})
+	hub.Publish(events.Event{Type: events.ItemCreated, UserID: "u2", Item: item})
+	hub.Publish(events.Event{Type: events.ItemCreated, UserID: "u1", ChannelID: "c1", Item: item})
+
+	// Stop 写入缓冲中的事件后返回
+	dispatcher.Stop()
+
+	select {
+	case <-dispatcher.Wake():
+	default:
+		t.Error("入队后应唤醒投递任务")
+	}
+
+	if err := dispatcher.Deliver(context.Background()); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+	if len(receiver.requests) != 1 {
+		t.Fatalf("期望收到 1 个投递，实际得到 %d", len(receiver.requests))
+	}
+
+	req, body := receiver.requests[0], receiver.bodies[0]
+	if err := Verify("secret", req.Header.Get(HeaderTimestamp), req.Header.Get(HeaderSignature), body, DefaultTolerance, time.Now()); err != nil {
+		t.Errorf("签名验证失败: %v", err)
+	}
+	var payload Payload
+	json.Unmarshal(body, &payload)
+	if payload.Type != events.ItemCreated || payload.WebhookID != webhook.ID || payload.Data.DeviceID != "laptop" ||
+		payload.ID != req.Header.Get(HeaderDelivery) || payload.Data.Item == nil || payload.Data.Item.Content != "hello" {
+		t.Errorf("负载内容不正确: %s", body)
+	}
+
+	deliveries, _ := repo.ListDeliveries(webhook.ID, "", 0)
+	if len(deliveries) != 1 || deliveries[0].Status != models.WebhookDeliverySucceeded ||
+		deliveries[0].Attempts != 1 || deliveries[0].ResponseStatus != http.StatusNoContent {
+		t.Errorf("投递记录不正确: %+v", deliveries)
+	}
+}
+
+func TestEnqueueDoesNotBlock(t *testing.T) {
+	repo := repository.NewMemoryWebhookRepository()
+	dispatcher := NewDispatcher(repo, true)
+
+	webhook := models.Webhook{UserID: "u1", URL: "https://example.com/hook", Secret: "secret", Active: true}
+	repo.CreateWebhook(&webhook)
+
+	// 未启动写入协程时，缓冲已满的事件被丢弃而不是阻塞发布者
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < queueSize+10; i++ {
+			dispatcher.Enqueue(events.Event{Type: events.ItemDeleted, UserID: "u1", ItemID: strconv.Itoa(i)})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("缓冲已满时 Enqueue 不应阻塞")
+	}
+
+	if deliveries, _ := repo.ListDeliveries(webhook.ID, "", 0); len(deliveries) != 0 {
+		t.Errorf("投递应由写入协程保存，而不是在发布时保存，实际已有 %d 个", len(deliveries))
+	}
+
+	dispatcher.Start()
+	dispatcher.Stop()
+	if deliveries, _ := repo.ListDeliveries(webhook.ID, "", 0); len(deliveries) != queueSize {
+		t.Errorf("期望保存 %d 个缓冲中的投递，实际得到 %d", queueSize, len(deliveries))
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	receiver := &recorder{status: http.StatusInternalServerError}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	dispatcher := NewDispatcher(repo, true)
+	cfg := config.GetConfig()
+
+	webhook := models.Webhook{UserID: "u1", URL: server.URL, Secret: "secret", Active: true}
+	repo.CreateWebhook(&webhook)
+
+	first, _ := NewDelivery(webhook, events.Event{Type: Ping}, time.Now())
+	last, _ := NewDelivery(webhook, events.Event{Type: Ping}, time.Now())
+	last.Attempts = cfg.WebhookMaxAttempts - 1
+	repo.CreateDeliveries([]models.WebhookDelivery{first, last})
+
+	start := time.Now()
+	if err := dispatcher.Deliver(context.Background()); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+	if len(receiver.requests) != 2 {
+		t.Fatalf("期望收到 2 个投递，实际得到 %d", len(receiver.requests))
+	}
+
+	first, _ = repo.GetDelivery(webhook.ID, first.ID)
+	if first.Status != models.WebhookDeliveryPending || first.Attempts != 1 || first.ResponseStatus != http.StatusInternalServerError ||
+		first.NextAttemptAt == nil || first.NextAttemptAt.Before(start.Add(cfg.WebhookRetryBase)) || !strings.Contains(first.Error, "500") {
+		t.Errorf("失败的投递应稍后重试: %+v", first)
+	}
+
+	last, _ = repo.GetDelivery(webhook.ID, last.ID)
+	if last.Status != models.WebhookDeliveryFailed || last.Attempts != cfg.WebhookMaxAttempts || last.NextAttemptAt != nil {
+		t.Errorf("达到最大次数的投递应标记为失败: %+v", last)
+	}
+
+	// 未到重试时间的投递不会再次发送
+	if err := dispatcher.Deliver(context.Background()); err != nil || len(receiver.requests) != 2 {
+		t.Errorf("不应提前重试，收到 %d 个投递", len(receiver.requests))
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	dispatcher := NewDispatcher(repository.NewMemoryWebhookRepository(), false)
+	ctx := context.Background()
+
+	private := []string{
+		"http://127.0.0.1:9000/",
+		"http://localhost/",
+		"http://[::1]/",
+		"http://[::ffff:127.0.0.1]/",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.1.2.3/",
+		"http://192.168.1.1/",
+		"http://100.100.100.200/",
+		"http://0.0.0.0/",
+	}
+	for _, target := range private {
+		if err := dispatcher.CheckTarget(ctx, target); err != ErrPrivateTarget {
+			t.Errorf("%s 应被拒绝，实际得到 %v", target, err)
+		}
+	}
+	if err := dispatcher.CheckTarget(ctx, "ftp://example.com/hook"); err == nil {
+		t.Error("非 HTTP(S) URL 应被拒绝")
+	}
+	if err := dispatcher.CheckTarget(ctx, "https://93.184.216.34/hook"); err != nil {
+		t.Errorf("公网地址应被接受: %v", err)
+	}
+
+	allowed := NewDispatcher(repository.NewMemoryWebhookRepository(), true)
+	if err := allowed.CheckTarget(ctx, "http://localhost:9000/"); err != nil {
+		t.Errorf("允许内网目标时应接受 localhost: %v", err)
+	}
+}
+
+func TestDeliverRefusesPrivateTarget(t *testing.T) {
+	receiver := &recorder{status: http.StatusNoContent}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+
+	repo := repository.NewMemoryWebhookRepository()
+	dispatcher := NewDispatcher(repo, false)
+
+	// 注册后才指向内网的 webhook，连接时仍会被拒绝
+	webhook := models.Webhook{UserID: "u1", URL: server.URL, Secret: "secret", Active: true}
+	repo.CreateWebhook(&webhook)
+	delivery, _ := NewDelivery(webhook, events.Event{Type: Ping}, time.Now())
+	repo.CreateDeliveries([]models.WebhookDelivery{delivery})
+
+	if err := dispatcher.Deliver(context.Background()); err != nil {
+		t.Fatalf("投递失败: %v", err)
+	}
+	if len(receiver.requests) != 0 {
+		t.Errorf("不应连接内网地址，实际收到 %d 个投递", len(receiver.requests))
+	}
+	delivery, _ = repo.GetDelivery(webhook.ID, delivery.ID)
+	if delivery.Status != models.WebhookDeliveryPending || !strings.Contains(delivery.Error, ErrPrivateTarget.Error()) {
+		t.Errorf("投递应因内网地址失败并稍后重试: %+v", delivery)
+	}
+}
+
+func TestReceiver(t *testing.T) {
+	var out bytes.Buffer
+	receiver := &Receiver{Secret: "secret", Out: &out}
+
+	body := []byte(`{"type":"ping"}`)
+	now := time.Now().Unix()
+	post := func(signature string) int {
+		req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body))
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(now, 10))
+		req.Header.Set(HeaderSignature, signature)
+		w := httptest.NewRecorder()
+		receiver.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := post(Sign("secret", now, body)); code != http.StatusNoContent {
+		t.Errorf("有效签名应返回 204，实际得到 %d", code)
+	}
+	if code := post(Sign("other", now, body)); code != http.StatusUnauthorized {
+		t.Errorf("无效签名应返回 401，实际得到 %d", code)
+	}
+	if !strings.Contains(out.String(), "signature=valid") || !strings.Contains(out.String(), `"type": "ping"`) {
+		t.Errorf("输出内容不正确: %s", out.String())
+	}
+}